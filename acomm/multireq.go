@@ -33,7 +33,11 @@ func (m *MultiRequest) AddRequest(name string, req *Request) error {
 	req.ErrorHandler = m.responseHandler
 
 	m.respWG.Add(1)
-	return m.tracker.TrackRequest(req, m.timeout)
+	if err := m.tracker.TrackRequest(req, m.timeout); err != nil {
+		m.respWG.Done()
+		return err
+	}
+	return nil
 }
 
 // RemoveRequest removes a request from the MultiRequest. Useful if the send fails.
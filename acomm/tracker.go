@@ -17,6 +17,7 @@ import (
 	"github.com/Sirupsen/logrus"
 	"github.com/cerana/cerana/pkg/errors"
 	"github.com/cerana/cerana/pkg/logrusx"
+	"golang.org/x/net/context"
 )
 
 const (
@@ -103,6 +104,26 @@ func (t *Tracker) NumRequests() int {
 	return len(t.requests)
 }
 
+// NumStreams returns the number of open data streams.
+func (t *Tracker) NumStreams() int {
+	t.dsLock.Lock()
+	defer t.dsLock.Unlock()
+
+	return len(t.dataStreams)
+}
+
+// SetDefaultTimeout changes the timeout used for requests subsequently tracked
+// without an explicit timeout.
+func (t *Tracker) SetDefaultTimeout(defaultTimeout time.Duration) {
+	t.requestsLock.Lock()
+	defer t.requestsLock.Unlock()
+
+	if defaultTimeout <= 0 {
+		defaultTimeout = time.Minute
+	}
+	t.defaultTimeout = defaultTimeout
+}
+
 // Addr returns the string representation of the Tracker's response listener socket.
 func (t *Tracker) Addr() string {
 	return t.responseListener.Addr()
@@ -474,6 +495,66 @@ func (t *Tracker) SyncRequest(dest *url.URL, opts RequestOptions, timeout time.D
 	return resp, errors.ResetStack(resp.Error)
 }
 
+// Call sends a request to dest and waits for the response, unmarshalling the
+// result into result if it is not nil. The request times out at the context's
+// deadline, falling back to the default timeout, and stops waiting if the
+// context is canceled. The response's stream url, if any, is returned.
+func (t *Tracker) Call(ctx context.Context, dest *url.URL, opts RequestOptions, result interface{}) (*url.URL, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, errors.Wrap(err)
+	}
+
+	var timeout time.Duration
+	if deadline, ok := ctx.Deadline(); ok {
+		timeout = deadline.Sub(time.Now())
+	}
+
+	// Buffered so a late response doesn't block after the context is done
+	ch := make(chan *Response, 1)
+	rh := func(_ *Request, resp *Response) {
+		ch <- resp
+	}
+
+	opts.ResponseHook = t.URL()
+	opts.SuccessHandler = rh
+	opts.ErrorHandler = rh
+
+	req, err := NewRequest(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	errData := map[string]interface{}{"requestID": req.ID, "task": req.Task}
+
+	if err := t.TrackRequest(req, timeout); err != nil {
+		return nil, errors.Wrapv(err, errData)
+	}
+
+	if err := Send(dest, req); err != nil {
+		_ = t.RemoveRequest(req)
+		return nil, errors.Wrapv(err, errData)
+	}
+
+	var resp *Response
+	select {
+	case resp = <-ch:
+	case <-ctx.Done():
+		_ = t.RemoveRequest(req)
+		return nil, errors.Wrapv(ctx.Err(), errData)
+	}
+
+	if resp.Error != nil {
+		return nil, errors.ResetStack(resp.Error)
+	}
+
+	if result != nil {
+		if err := resp.UnmarshalResult(result); err != nil {
+			return nil, errors.Wrapv(err, errData)
+		}
+	}
+	return resp.StreamURL, nil
+}
+
 // ReplaceLocalhost replaces localhost, 127.0.0.1, or ::1 with the specified host.
 func ReplaceLocalhost(u *url.URL, replacement string) error {
 	if u == nil || u.Scheme == "unix" {
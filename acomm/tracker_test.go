@@ -13,7 +13,9 @@ import (
 
 	"github.com/Sirupsen/logrus"
 	"github.com/cerana/cerana/acomm"
+	"github.com/cerana/cerana/pkg/errors"
 	"github.com/stretchr/testify/suite"
+	"golang.org/x/net/context"
 )
 
 type TrackerTestSuite struct {
@@ -221,6 +223,81 @@ func (s *TrackerTestSuite) TestProxyExternal() {
 
 }
 
+func (s *TrackerTestSuite) TestCall() {
+	if !s.NoError(s.Tracker.Start(), "listener should start") {
+		return
+	}
+
+	type result struct {
+		Foo string `json:"foo"`
+	}
+
+	// Mock task handler that acknowledges requests and responds based on the task
+	taskServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		req := &acomm.Request{}
+		body, err := ioutil.ReadAll(r.Body)
+		s.NoError(err, "should not fail reading body")
+		s.NoError(json.Unmarshal(body, req), "should not fail unmarshalling request")
+
+		ack, _ := acomm.NewResponse(req, nil, nil, nil)
+		ackJSON, _ := json.Marshal(ack)
+		_, _ = w.Write(ackJSON)
+
+		var resp *acomm.Response
+		switch req.Task {
+		case "noreply":
+			return
+		case "fail":
+			resp, _ = acomm.NewResponse(req, nil, nil, errors.New("task failed"))
+		default:
+			resp, _ = acomm.NewResponse(req, &result{Foo: "bar"}, req.StreamURL, nil)
+		}
+		go func() { _ = acomm.Send(req.ResponseHook, resp) }()
+	}))
+	defer taskServer.Close()
+	dest, _ := url.ParseRequestURI(taskServer.URL)
+
+	streamURL, _ := url.ParseRequestURI("http://localhost/stream")
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+	timeout, cancelTimeout := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancelTimeout()
+
+	tests := []struct {
+		description string
+		ctx         context.Context
+		task        string
+		streamURL   *url.URL
+		result      *result
+		expectErr   bool
+		err         string
+	}{
+		{"valid", context.Background(), "foobar", nil, &result{Foo: "bar"}, false, ""},
+		{"valid with stream", context.Background(), "foobar", streamURL, &result{Foo: "bar"}, false, ""},
+		{"task error", context.Background(), "fail", nil, nil, true, "task failed"},
+		{"canceled", canceled, "foobar", nil, nil, true, context.Canceled.Error()},
+		// Either the context or the tracker's request timeout may fire first
+		{"timeout", timeout, "noreply", nil, nil, true, ""},
+	}
+
+	for _, test := range tests {
+		res := &result{}
+		respStreamURL, err := s.Tracker.Call(test.ctx, dest, acomm.RequestOptions{Task: test.task, StreamURL: test.streamURL}, res)
+		if test.expectErr {
+			if test.err != "" {
+				s.EqualError(err, test.err, test.description)
+			} else {
+				s.Error(err, test.description)
+			}
+			continue
+		}
+		s.NoError(err, test.description)
+		s.Equal(test.result, res, test.description)
+		s.Equal(test.streamURL, respStreamURL, test.description)
+	}
+	s.Equal(0, s.Tracker.NumRequests(), "should have removed all requests")
+}
+
 func (s *TrackerTestSuite) TestReplaceLocalhost() {
 	tests := []struct {
 		orig        string
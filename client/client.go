@@ -0,0 +1,54 @@
+// Package client provides typed clients for the tasks of all of the providers.
+// Each provider package has a generated Client, created by cmd/clientgen, with a
+// method per task that handles building and tracking the request, waiting for
+// the response, and decoding the result. Client groups them for callers that
+// use several providers.
+package client
+
+import (
+	"net/url"
+
+	"github.com/cerana/cerana/acomm"
+	"github.com/cerana/cerana/providers/clusterconf"
+	"github.com/cerana/cerana/providers/datatrade"
+	"github.com/cerana/cerana/providers/dhcp"
+	"github.com/cerana/cerana/providers/health"
+	"github.com/cerana/cerana/providers/kv"
+	"github.com/cerana/cerana/providers/metrics"
+	"github.com/cerana/cerana/providers/namespace"
+	"github.com/cerana/cerana/providers/service"
+	"github.com/cerana/cerana/providers/systemd"
+	"github.com/cerana/cerana/providers/zfs"
+)
+
+// Client is a collection of provider clients sharing a tracker and
+// coordinator.
+type Client struct {
+	ClusterConf *clusterconf.Client
+	DataTrade   *datatrade.Client
+	DHCP        *dhcp.Client
+	Health      *health.Client
+	KV          *kv.Client
+	Metrics     *metrics.Client
+	Namespace   *namespace.Client
+	Service     *service.Client
+	Systemd     *systemd.Client
+	ZFS         *zfs.Client
+}
+
+// New creates a new Client. Requests are sent to the coordinator and tracked
+// by the tracker, which must already be started.
+func New(tracker *acomm.Tracker, coordinator *url.URL) *Client {
+	return &Client{
+		ClusterConf: clusterconf.NewClient(tracker, coordinator),
+		DataTrade:   datatrade.NewClient(tracker, coordinator),
+		DHCP:        dhcp.NewClient(tracker, coordinator),
+		Health:      health.NewClient(tracker, coordinator),
+		KV:          kv.NewClient(tracker, coordinator),
+		Metrics:     metrics.NewClient(tracker, coordinator),
+		Namespace:   namespace.NewClient(tracker, coordinator),
+		Service:     service.NewClient(tracker, coordinator),
+		Systemd:     systemd.NewClient(tracker, coordinator),
+		ZFS:         zfs.NewClient(tracker, coordinator),
+	}
+}
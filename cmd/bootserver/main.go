@@ -9,7 +9,6 @@ import (
 	"io"
 	"io/ioutil"
 	"net/http"
-	"net/url"
 	"os"
 	"strings"
 	"sync"
@@ -18,7 +17,6 @@ import (
 	"time"
 
 	"github.com/Sirupsen/logrus"
-	"github.com/cerana/cerana/acomm"
 	"github.com/cerana/cerana/pkg/errors"
 	"github.com/cerana/cerana/pkg/logrusx"
 	"github.com/cerana/cerana/provider"
@@ -27,6 +25,7 @@ import (
 	"github.com/pin/tftp"
 	"github.com/spf13/pflag"
 	"github.com/spf13/viper"
+	"golang.org/x/net/context"
 )
 
 type addrser interface {
@@ -34,9 +33,8 @@ type addrser interface {
 }
 
 type dhcpHandler struct {
-	iface       addrser
-	tracker     *acomm.Tracker
-	coordinator *url.URL
+	iface addrser
+	dhcp  *dhcp.Client
 }
 
 var args = []string{
@@ -54,32 +52,6 @@ initrd http://{{.IP}}/initrd
 boot
 `))
 
-func comm(tracker *acomm.Tracker, coordinator *url.URL, task string, args interface{}, resp interface{}) error {
-	ch := make(chan *acomm.Response, 1)
-	handler := func(_ *acomm.Request, resp *acomm.Response) {
-		ch <- resp
-	}
-
-	req, err := acomm.NewRequest(acomm.RequestOptions{
-		Task:           task,
-		Args:           args,
-		ResponseHook:   tracker.URL(),
-		SuccessHandler: handler,
-		ErrorHandler:   handler,
-	})
-	logrusx.DieOnError(err, "create request object")
-	logrusx.DieOnError(tracker.TrackRequest(req, 0), "track request")
-	logrusx.DieOnError(acomm.Send(coordinator, req), "send request")
-
-	aResp := <-ch
-	if aResp.Error != nil {
-		return aResp.Error
-	}
-
-	logrusx.DieOnError(aResp.UnmarshalResult(resp), "deserialize result")
-	return nil
-}
-
 func tftpReadHandler(undi []byte) func(string, io.ReaderFrom) error {
 	fn := func(name string, rf io.ReaderFrom) error {
 		if name != "undionly.kpxe" {
@@ -175,10 +147,12 @@ func (h *dhcpHandler) ServeDHCP(p dhcp4.Packet, msgType dhcp4.MessageType, optio
 		IP:  stringIP(reqIP),
 	}
 	var offer dhcp.Lease
+	var err error
 	var responseType dhcp4.MessageType
 	switch msgType {
 	case dhcp4.Discover:
-		logrusx.DieOnError(comm(h.tracker, h.coordinator, "dhcp-offer-lease", args, &offer), "get lease offering")
+		offer, err = h.dhcp.OfferLease(context.Background(), args)
+		logrusx.DieOnError(err, "get lease offering")
 
 		responseType = dhcp4.Offer
 	case dhcp4.Request:
@@ -195,7 +169,7 @@ func (h *dhcpHandler) ServeDHCP(p dhcp4.Packet, msgType dhcp4.MessageType, optio
 		} else if reqIP.Equal(net.IPv4zero) {
 			logrus.Error("requested ip is 0.0.0.0")
 			return nack(p, ip)
-		} else if err := comm(h.tracker, h.coordinator, "dhcp-ack-lease", args, &offer); err != nil {
+		} else if offer, err = h.dhcp.AckLease(context.Background(), args); err != nil {
 			logrus.WithField("error", err).Error("")
 			return nack(p, ip)
 		}
@@ -240,9 +214,8 @@ func main() {
 	logrusx.DieOnError(errors.Wrapv(err, map[string]interface{}{"iface": conf.iface()}), "get interface")
 
 	handler := &dhcpHandler{
-		iface:       iface,
-		coordinator: conf.CoordinatorURL(),
-		tracker:     tracker,
+		iface: iface,
+		dhcp:  dhcp.NewClient(tracker, conf.CoordinatorURL()),
 	}
 
 	dConn, err := net.ListenPacket("udp4", ":67")
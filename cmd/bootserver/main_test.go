@@ -104,8 +104,7 @@ func (s *DHCPS) TestDHCP() {
 			ip:   "10.0.0.1/8",
 			name: "eth0",
 		},
-		tracker:     s.tracker,
-		coordinator: s.coord,
+		dhcp: dhcp.NewClient(s.tracker, s.coord),
 	}
 
 	ips := make(map[string]string)
@@ -6,7 +6,9 @@ import (
 	"net/url"
 	"strconv"
 	"strings"
+	"time"
 
+	"github.com/Sirupsen/logrus"
 	"github.com/cerana/cerana/acomm"
 	"github.com/cerana/cerana/pkg/errors"
 	"github.com/cerana/cerana/providers/clusterconf"
@@ -38,16 +40,17 @@ func bundleHeartbeats(config tick.Configer, tracker *acomm.Tracker) error {
 func getBundles(config tick.Configer, tracker *acomm.Tracker) ([]*clusterconf.Bundle, error) {
 	requests := map[string]struct {
 		task     string
+		taskArgs interface{}
 		url      *url.URL
 		respData interface{}
 	}{
 		"local": {task: "service-list", url: config.NodeDataURL(), respData: &service.ListResult{}},
-		"known": {task: "list-bundles", url: config.ClusterDataURL(), respData: &clusterconf.BundleListResult{}},
+		"known": {task: "list-bundles", taskArgs: clusterconf.ListBundleArgs{}, url: config.ClusterDataURL(), respData: &clusterconf.BundleListResult{}},
 	}
 
 	multiRequest := acomm.NewMultiRequest(tracker, config.RequestTimeout())
 	for name, args := range requests {
-		req, err := acomm.NewRequest(acomm.RequestOptions{Task: args.task})
+		req, err := acomm.NewRequest(acomm.RequestOptions{Task: args.task, Args: args.taskArgs})
 		if err != nil {
 			return nil, err
 		}
@@ -75,6 +78,7 @@ func getBundles(config tick.Configer, tracker *acomm.Tracker) ([]*clusterconf.Bu
 	knownBundles := requests["known"].respData.(*clusterconf.BundleListResult).Bundles
 
 	bundles := make([]*clusterconf.Bundle, 0, len(localBundles))
+	untracked := make([]*clusterconf.Bundle, 0, len(localBundles))
 	for _, local := range localBundles {
 		// Attempt to add the known bundle with service and healthcheck info.
 		found := false
@@ -89,11 +93,67 @@ func getBundles(config tick.Configer, tracker *acomm.Tracker) ([]*clusterconf.Bu
 		// If not found, add an entry anyway so it is tracked by heartbeat.
 		// Something will later clean the untracked bundle up.
 		if !found {
-			bundles = append(bundles, &clusterconf.Bundle{ID: local})
+			untracked = append(untracked, &clusterconf.Bundle{ID: local})
 		}
 	}
 
-	return bundles, nil
+	return append(combineBundles(config, tracker, bundles), untracked...), nil
+}
+
+// combineBundles replaces the known bundles with their combined overlays,
+// which include the services' health checks and the cluster defaults. A bundle
+// whose overlay fails, e.g. referencing a deleted dataset, is logged and kept
+// as is so it doesn't stop the other heartbeats.
+func combineBundles(config tick.Configer, tracker *acomm.Tracker, bundles []*clusterconf.Bundle) []*clusterconf.Bundle {
+	multiRequest := acomm.NewMultiRequest(tracker, config.RequestTimeout())
+	for _, bundle := range bundles {
+		name := strconv.FormatUint(bundle.ID, 10)
+		req, err := acomm.NewRequest(acomm.RequestOptions{
+			Task: "get-bundle",
+			Args: clusterconf.GetBundleArgs{ID: bundle.ID, CombinedOverlay: true},
+		})
+		if err != nil {
+			logBundleError(bundle.ID, err)
+			continue
+		}
+		if err := multiRequest.AddRequest(name, req); err != nil {
+			logBundleError(bundle.ID, err)
+			continue
+		}
+		if err := acomm.Send(config.ClusterDataURL(), req); err != nil {
+			multiRequest.RemoveRequest(req)
+			logBundleError(bundle.ID, err)
+		}
+	}
+
+	responses := multiRequest.Responses()
+	combined := make([]*clusterconf.Bundle, 0, len(bundles))
+	for _, bundle := range bundles {
+		resp, ok := responses[strconv.FormatUint(bundle.ID, 10)]
+		if !ok {
+			combined = append(combined, bundle)
+			continue
+		}
+		var result clusterconf.BundlePayload
+		err := errors.ResetStack(resp.Error)
+		if err == nil {
+			err = resp.UnmarshalResult(&result)
+		}
+		if err != nil || result.Bundle == nil {
+			logBundleError(bundle.ID, err)
+			combined = append(combined, bundle)
+			continue
+		}
+		combined = append(combined, result.Bundle)
+	}
+	return combined
+}
+
+func logBundleError(bundleID uint64, err error) {
+	logrus.WithFields(logrus.Fields{
+		"bundleID": bundleID,
+		"error":    err,
+	}).Error("failed to get bundle combined overlay")
 }
 
 func getSerial(config tick.Configer, tracker *acomm.Tracker) (string, error) {
@@ -174,15 +234,34 @@ func sendBundleHeartbeats(config tick.Configer, tracker *acomm.Tracker, bundles
 	return nil
 }
 
+// healthCheckState is the state of a health check across heartbeats.
+type healthCheckState struct {
+	lastRun  time.Time
+	failures int
+	err      error
+}
+
+// healthCheckStates are kept across heartbeats to apply health check intervals
+// and failure thresholds, keyed by bundle, service, and health check.
+var healthCheckStates = make(map[string]*healthCheckState)
+
 func runHealthChecks(config tick.Configer, tracker *acomm.Tracker, bundles []*clusterconf.Bundle) (map[uint64]map[string]error, map[string]error) {
-	multiRequest := acomm.NewMultiRequest(tracker, 0)
+	now := time.Now()
+	// Checks are grouped by timeout, since it applies to a whole multirequest
+	multiRequests := make(map[time.Duration]*acomm.MultiRequest)
 
+	checks := make(map[string]clusterconf.HealthCheck)
 	requests := make(map[string]*acomm.Request)
 	errs := make(map[string]error)
 	for _, bundle := range bundles {
 		for serviceID, service := range bundle.Services {
 			for healthID, healthCheck := range service.HealthChecks {
 				name := fmt.Sprintf("%d:%s:%s", bundle.ID, serviceID, healthID)
+				checks[name] = healthCheck
+				state, ok := healthCheckStates[name]
+				if ok && now.Sub(state.lastRun) < healthCheck.Interval {
+					continue
+				}
 				req, err := acomm.NewRequest(acomm.RequestOptions{
 					Task: healthCheck.Type,
 					Args: healthCheck.Args,
@@ -197,29 +276,63 @@ func runHealthChecks(config tick.Configer, tracker *acomm.Tracker, bundles []*cl
 	}
 
 	for name, req := range requests {
+		timeout := checks[name].Timeout
+		multiRequest, ok := multiRequests[timeout]
+		if !ok {
+			multiRequest = acomm.NewMultiRequest(tracker, timeout)
+			multiRequests[timeout] = multiRequest
+		}
 		if err := multiRequest.AddRequest(name, req); err != nil {
+			delete(requests, name)
 			errs[name] = err
 			continue
 		}
 		if err := acomm.Send(config.NodeDataURL(), req); err != nil {
 			multiRequest.RemoveRequest(req)
+			delete(requests, name)
 			errs[name] = err
 		}
 	}
 
-	responses := multiRequest.Responses()
+	responses := make(map[string]*acomm.Response)
+	for _, multiRequest := range multiRequests {
+		for name, resp := range multiRequest.Responses() {
+			responses[name] = resp
+		}
+	}
+	for name := range requests {
+		state, ok := healthCheckStates[name]
+		if !ok {
+			state = &healthCheckState{}
+			healthCheckStates[name] = state
+		}
+		state.lastRun = now
+		if resp, ok := responses[name]; ok && resp.Error != nil {
+			state.failures++
+			state.err = errors.ResetStack(resp.Error)
+		} else {
+			state.failures = 0
+			state.err = nil
+		}
+	}
+
 	healthResults := make(map[uint64]map[string]error)
 	for _, bundle := range bundles {
 		healthResults[bundle.ID] = make(map[string]error)
 	}
 
-	for name, resp := range responses {
+	for name, state := range healthCheckStates {
+		healthCheck, ok := checks[name]
+		if !ok {
+			delete(healthCheckStates, name)
+			continue
+		}
+		if state.err == nil || state.failures < healthCheck.FailureThreshold {
+			continue
+		}
 		nameParts := strings.Split(name, ":")
 		bundleID, _ := strconv.ParseUint(nameParts[0], 10, 64)
-		healthCheck := nameParts[1] + ":" + nameParts[2]
-		if resp.Error != nil {
-			healthResults[bundleID][healthCheck] = errors.ResetStack(resp.Error)
-		}
+		healthResults[bundleID][nameParts[1]+":"+nameParts[2]] = state.err
 	}
 
 	return healthResults, errs
@@ -3,6 +3,7 @@ package main
 import (
 	"net"
 	"sort"
+	"time"
 
 	"github.com/cerana/cerana/providers/clusterconf"
 	"github.com/cerana/cerana/providers/health"
@@ -72,7 +73,30 @@ func (s *BundleHeartbeat) TestGetBundles() {
 	}
 }
 
+func (s *BundleHeartbeat) TestGetBundlesOverlayError() {
+	s.service.ClearData()
+	s.clusterConf.Data.Bundles = make(map[uint64]*clusterconf.Bundle)
+	for _, id := range []uint64{123, 456} {
+		s.service.Add(service.Service{ID: uuid.New(), BundleID: id})
+		s.clusterConf.Data.Bundles[id] = &clusterconf.Bundle{ID: id}
+	}
+	// The bundle's dataset was deleted, so its overlay fails
+	s.clusterConf.Data.Bundles[456].Datasets = map[string]clusterconf.BundleDataset{
+		"missing": {ID: "missing"},
+	}
+
+	bundles, err := getBundles(s.config, s.tracker)
+	s.Require().NoError(err)
+	bundleIDs := make(uint64s, 0, len(bundles))
+	for _, bundle := range bundles {
+		bundleIDs = append(bundleIDs, bundle.ID)
+	}
+	sort.Sort(bundleIDs)
+	s.Equal(uint64s{123, 456}, bundleIDs)
+}
+
 func (s *BundleHeartbeat) TestRunHealthChecks() {
+	healthCheckStates = make(map[string]*healthCheckState)
 	s.health.Data.Uptime = false
 	bundle := &clusterconf.Bundle{
 		ID: 123,
@@ -109,6 +133,59 @@ func (s *BundleHeartbeat) TestRunHealthChecks() {
 	s.True(ok)
 }
 
+func (s *BundleHeartbeat) TestRunHealthChecksStates() {
+	healthCheckStates = make(map[string]*healthCheckState)
+	s.health.Data.Uptime = false
+	bundle := &clusterconf.Bundle{
+		ID: 123,
+		Services: map[string]clusterconf.BundleService{
+			"foobar": {
+				ServiceConf: clusterconf.ServiceConf{
+					HealthChecks: map[string]clusterconf.HealthCheck{
+						"uptime": {
+							ID:               "uptime",
+							Type:             "health-uptime",
+							Args:             health.UptimeArgs{},
+							FailureThreshold: 2,
+						},
+						"interval": {
+							ID:       "interval",
+							Type:     "health-uptime",
+							Args:     health.UptimeArgs{},
+							Interval: time.Hour,
+							Timeout:  time.Second,
+						},
+					},
+				},
+			},
+		},
+	}
+	bundles := []*clusterconf.Bundle{bundle}
+
+	// The threshold check is only reported on its second failure
+	healthErrors, errs := runHealthChecks(s.config, s.tracker, bundles)
+	s.Len(errs, 0)
+	s.NotContains(healthErrors[bundle.ID], "foobar:uptime")
+	s.Contains(healthErrors[bundle.ID], "foobar:interval")
+
+	// The interval check isn't rerun, but keeps its last result
+	s.health.Data.Uptime = true
+	healthErrors, errs = runHealthChecks(s.config, s.tracker, bundles)
+	s.Len(errs, 0)
+	s.NotContains(healthErrors[bundle.ID], "foobar:uptime")
+	s.Contains(healthErrors[bundle.ID], "foobar:interval")
+
+	s.health.Data.Uptime = false
+	_, _ = runHealthChecks(s.config, s.tracker, bundles)
+	healthErrors, errs = runHealthChecks(s.config, s.tracker, bundles)
+	s.Len(errs, 0)
+	s.Contains(healthErrors[bundle.ID], "foobar:uptime")
+
+	// State is dropped for checks that are no longer configured
+	_, _ = runHealthChecks(s.config, s.tracker, nil)
+	s.Empty(healthCheckStates)
+}
+
 func (s *BundleHeartbeat) TestSendBundleHeartbeats() {
 	serial := "foobar"
 	ip := net.ParseIP("123.123.123.123")
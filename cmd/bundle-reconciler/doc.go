@@ -0,0 +1,32 @@
+/*
+bundle-reconciler converges the services running on a node to those of the
+bundles the node is assigned to. Missing services are created, services whose
+command, environment, or dataset have drifted from the bundle configuration
+are updated, and services of bundles no longer assigned to the node are
+removed along with their dataset clones. Services that aren't part of a bundle
+are left alone, as are all services while the node has no assigned bundles,
+since the assignments may be missing. Drift and every action taken are logged.
+
+Env vars referencing secrets, e.g. "secret:db-password", are resolved from the
+cluster config when a service is created or updated, and are passed to the
+service provider separately from the rest of the env so they are never stored
+in the unit. Coordinators only resolve secrets for requests on their internal
+socket, so the clusterDataURL must be the node's coordinator socket for
+bundles that use them.
+
+Usage:
+
+	$ bundle-reconciler -h
+	Usage of bundle-reconciler:
+	-u, --clusterDataURL string        url of coordinator for the cluster information
+	-c, --configFile string            path to config file
+	-d, --datasetCloneDir string       service dataset clone directory
+	-a, --datasetPrefix string         dataset directory
+	-l, --logLevel string              log level: debug/info/warn/error/fatal/panic (default "warning")
+	-n, --nodeDataURL string           url of coordinator for node information retrieval
+	-r, --requestTimeout duration      default timeout for external requests made
+	-t, --tickInterval duration        tick run frequency
+	-i, --tickRetryInterval duration   tick retry on error frequency
+	Note: Long flag names can be specified in either fooBar or foo[_-.]bar form.
+*/
+package main
@@ -0,0 +1,318 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/cerana/cerana/acomm"
+	"github.com/cerana/cerana/pkg/errors"
+	"github.com/cerana/cerana/providers/clusterconf"
+	"github.com/cerana/cerana/providers/service"
+	"github.com/cerana/cerana/providers/zfs"
+	"github.com/cerana/cerana/tick"
+	"golang.org/x/net/context"
+)
+
+// internalEnvPrefix marks environment variables set by the service provider,
+// which are not part of a service's configuration.
+const internalEnvPrefix = "_CERANA_"
+
+// cloneSourceEnv is the env var the service provider records a service's
+// dataset in.
+const cloneSourceEnv = internalEnvPrefix + "CLONE_SOURCE"
+
+type actionType string
+
+const (
+	actionCreate actionType = "create"
+	actionUpdate actionType = "update"
+	actionRemove actionType = "remove"
+)
+
+// action is a change needed to converge a local service to its desired state.
+type action struct {
+	Type      actionType
+	BundleID  uint64
+	ServiceID string
+	// Drift lists the fields of an existing service that differ from the
+	// desired configuration.
+	Drift []string
+	// Service is the desired configuration for create and update actions.
+	Service *service.CreateArgs
+}
+
+func (a *action) name() string {
+	return fmt.Sprintf("%d:%s", a.BundleID, a.ServiceID)
+}
+
+func (a *action) fields() logrus.Fields {
+	fields := logrus.Fields{
+		"action":    a.Type,
+		"bundleID":  a.BundleID,
+		"serviceID": a.ServiceID,
+	}
+	if len(a.Drift) > 0 {
+		fields["drift"] = a.Drift
+	}
+	return fields
+}
+
+type actionsByName []*action
+
+func (a actionsByName) Len() int           { return len(a) }
+func (a actionsByName) Swap(i, j int)      { a[i], a[j] = a[j], a[i] }
+func (a actionsByName) Less(i, j int) bool { return a[i].name() < a[j].name() }
+
+// reconcileBundles is the tick function that converges the services running
+// on this node to those of the bundles assigned to it.
+func reconcileBundles(config tick.Configer, tracker *acomm.Tracker) error {
+	conf, ok := config.(*Config)
+	if !ok {
+		return errors.New("not the right type of config")
+	}
+
+	// Nodes are identified by their IP, matching the node heartbeat
+	ip, err := tick.GetIP(conf, tracker)
+	if err != nil {
+		return err
+	}
+
+	bundles, err := getAssignedBundles(conf, tracker, ip.String())
+	if err != nil {
+		return err
+	}
+	services, err := getLocalServices(conf, tracker)
+	if err != nil {
+		return err
+	}
+
+	if len(bundles) == 0 {
+		logrus.WithField("nodeID", ip.String()).Warn("no bundles assigned to node, leaving local services in place")
+	}
+	actions := plan(conf, bundles, services)
+	errs := make(map[string]error)
+	for _, a := range actions {
+		if a.Type == actionUpdate {
+			logrus.WithFields(a.fields()).Warn("service drifted from bundle configuration")
+		}
+		if err := applyAction(conf, tracker, a); err != nil {
+			errs[a.name()] = err
+			continue
+		}
+		logrus.WithFields(a.fields()).Info("service reconciled")
+	}
+
+	if len(errs) > 0 {
+		return errors.Newv("one or more services failed to reconcile", map[string]interface{}{"errors": errs})
+	}
+	return nil
+}
+
+// getAssignedBundles retrieves the bundles, with their services fully
+// configured, that are assigned to the node. Assignments for bundles that no
+// longer exist are ignored.
+func getAssignedBundles(config *Config, tracker *acomm.Tracker, nodeID string) ([]*clusterconf.Bundle, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), config.RequestTimeout())
+	defer cancel()
+
+	client := clusterconf.NewClient(tracker, config.ClusterDataURL())
+	assignments, err := client.ListBundleAssignments(ctx, clusterconf.ListBundleAssignmentsArgs{NodeID: nodeID})
+	if err != nil {
+		return nil, err
+	}
+
+	bundles := make([]*clusterconf.Bundle, 0, len(assignments.Assignments))
+	for _, assignment := range assignments.Assignments {
+		result, err := client.GetBundle(ctx, clusterconf.GetBundleArgs{
+			ID:              assignment.BundleID,
+			CombinedOverlay: true,
+		})
+		if err != nil {
+			if strings.Contains(err.Error(), "bundle config not found") {
+				continue
+			}
+			return nil, err
+		}
+		bundles = append(bundles, result.Bundle)
+	}
+	return bundles, nil
+}
+
+// getLocalServices retrieves the services present on the node.
+func getLocalServices(config *Config, tracker *acomm.Tracker) ([]service.Service, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), config.RequestTimeout())
+	defer cancel()
+
+	result, err := service.NewClient(tracker, config.NodeDataURL()).List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return result.Services, nil
+}
+
+// plan determines the actions needed for the local services to match the
+// services of the assigned bundles. Missing services are created, services
+// whose command, environment, or dataset differ are updated, and bundle
+// services not belonging to an assigned bundle are removed. Without any
+// assigned bundles, the assignments may just be missing, so nothing is
+// removed. Env vars referencing secrets are set aside as secret names, which
+// aren't compared since their values aren't part of the local services.
+func plan(config *Config, bundles []*clusterconf.Bundle, services []service.Service) []*action {
+	desired := make(map[string]*service.CreateArgs)
+	for _, bundle := range bundles {
+		for _, bundleService := range bundle.Services {
+			args := &service.CreateArgs{
+				ID:          bundleService.ID,
+				BundleID:    bundle.ID,
+				Dataset:     filepath.Join(config.DatasetPrefix(), bundleService.Dataset),
+				Description: fmt.Sprintf("bundle %d service %s", bundle.ID, bundleService.ID),
+				Cmd:         bundleService.Cmd,
+				Env:         make(map[string]string, len(bundleService.Env)),
+				Secrets:     make(map[string]string),
+				Overwrite:   true,
+			}
+			// Secrets are kept by name until the service is created
+			for key, val := range bundleService.Env {
+				if name, ok := clusterconf.SecretName(val); ok {
+					args.Secrets[key] = name
+					continue
+				}
+				args.Env[key] = val
+			}
+			desired[fmt.Sprintf("%d:%s", args.BundleID, args.ID)] = args
+		}
+	}
+
+	actions := make(actionsByName, 0)
+	for _, local := range services {
+		a := &action{BundleID: local.BundleID, ServiceID: local.ID}
+		args, ok := desired[a.name()]
+		if !ok {
+			// Services outside of bundles aren't managed here
+			if local.BundleID != 0 && len(bundles) > 0 {
+				a.Type = actionRemove
+				actions = append(actions, a)
+			}
+			continue
+		}
+		delete(desired, a.name())
+
+		a.Drift = drift(args, local)
+		if len(a.Drift) > 0 {
+			a.Type = actionUpdate
+			a.Service = args
+			actions = append(actions, a)
+		}
+	}
+
+	for _, args := range desired {
+		actions = append(actions, &action{
+			Type:      actionCreate,
+			BundleID:  args.BundleID,
+			ServiceID: args.ID,
+			Service:   args,
+		})
+	}
+
+	sort.Sort(actions)
+	return actions
+}
+
+// drift returns the fields of a local service that differ from its desired
+// configuration.
+func drift(desired *service.CreateArgs, local service.Service) []string {
+	var fields []string
+	if !reflect.DeepEqual(desired.Cmd, local.Cmd) {
+		fields = append(fields, "cmd")
+	}
+	if desired.Dataset != local.Env[cloneSourceEnv] {
+		fields = append(fields, "dataset")
+	}
+
+	localEnv := make(map[string]string, len(local.Env))
+	for key, val := range local.Env {
+		if !strings.HasPrefix(key, internalEnvPrefix) {
+			localEnv[key] = val
+		}
+	}
+	desiredEnv := make(map[string]string, len(desired.Env))
+	for key, val := range desired.Env {
+		if !strings.HasPrefix(key, internalEnvPrefix) {
+			desiredEnv[key] = val
+		}
+	}
+	if !reflect.DeepEqual(desiredEnv, localEnv) {
+		fields = append(fields, "env")
+	}
+
+	return fields
+}
+
+// applyAction performs an action against the node. Removing a service also
+// destroys the dataset clone created for it when it was started.
+func applyAction(config *Config, tracker *acomm.Tracker, a *action) error {
+	ctx, cancel := context.WithTimeout(context.Background(), config.RequestTimeout())
+	defer cancel()
+
+	services := service.NewClient(tracker, config.NodeDataURL())
+	switch a.Type {
+	case actionCreate, actionUpdate:
+		args := *a.Service
+		secrets, err := resolveSecrets(ctx, config, tracker, a.BundleID, a.Service.Secrets)
+		if err != nil {
+			return err
+		}
+		args.Secrets = secrets
+		_, err = services.Create(ctx, args)
+		return err
+	case actionRemove:
+		if err := services.Remove(ctx, service.RemoveArgs{ID: a.ServiceID, BundleID: a.BundleID}); err != nil {
+			return err
+		}
+		return destroyClone(ctx, config, tracker, a)
+	default:
+		return errors.Newv("unknown action type", map[string]interface{}{"action": a})
+	}
+}
+
+// resolveSecrets retrieves the values of the secrets referenced by env vars,
+// returning the env vars with their secret values.
+func resolveSecrets(ctx context.Context, config *Config, tracker *acomm.Tracker, bundleID uint64, names map[string]string) (map[string]string, error) {
+	if len(names) == 0 {
+		return nil, nil
+	}
+
+	args := clusterconf.ResolveSecretsArgs{BundleID: bundleID}
+	for _, name := range names {
+		args.Names = append(args.Names, name)
+	}
+	result, err := clusterconf.NewClient(tracker, config.ClusterDataURL()).ResolveSecrets(ctx, args)
+	if err != nil {
+		return nil, err
+	}
+
+	secrets := make(map[string]string, len(names))
+	for key, name := range names {
+		secrets[key] = result.Values[name]
+	}
+	return secrets, nil
+}
+
+func destroyClone(ctx context.Context, config *Config, tracker *acomm.Tracker, a *action) error {
+	client := zfs.NewClient(tracker, config.NodeDataURL())
+	// Matches the clone destination used by the service provider
+	name := filepath.Join(config.DatasetCloneDir(), fmt.Sprintf("%d:%s.service", a.BundleID, a.ServiceID))
+
+	result, err := client.Exists(ctx, zfs.CommonArgs{Name: name})
+	if err != nil {
+		return err
+	}
+	if !result.Exists {
+		return nil
+	}
+	return client.Destroy(ctx, zfs.DestroyArgs{Name: name, Recursive: true})
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"github.com/cerana/cerana/providers/clusterconf"
+	"github.com/cerana/cerana/providers/service"
+	zfsp "github.com/cerana/cerana/providers/zfs"
+	"github.com/cerana/cerana/zfs"
+)
+
+func (s *BundleReconciler) TestPlan() {
+	bundle := &clusterconf.Bundle{
+		ID: 1,
+		Services: map[string]clusterconf.BundleService{
+			"web": {ServiceConf: clusterconf.ServiceConf{
+				ID:      "web",
+				Dataset: "image",
+				Cmd:     []string{"web", "-p", "80"},
+				Env:     map[string]string{"FOO": "bar", "PASSWORD": "secret:web-password"},
+			}},
+		},
+	}
+	matching := service.Service{
+		ID:       "web",
+		BundleID: 1,
+		Cmd:      []string{"web", "-p", "80"},
+		Env:      map[string]string{"FOO": "bar", "_CERANA_CLONE_SOURCE": "data/datasets/image"},
+	}
+	drifted := matching
+	drifted.Cmd = []string{"web"}
+	drifted.Env = map[string]string{"_CERANA_CLONE_SOURCE": "data/datasets/image"}
+	otherDataset := matching
+	otherDataset.Env = map[string]string{"FOO": "bar", "_CERANA_CLONE_SOURCE": "data/datasets/other"}
+	orphan := service.Service{ID: "old", BundleID: 2}
+	unbundled := service.Service{ID: "sshd"}
+
+	tests := []struct {
+		desc     string
+		bundles  []*clusterconf.Bundle
+		services []service.Service
+		expected []*action
+	}{
+		{"nothing", nil, nil, []*action{}},
+		{"in sync", []*clusterconf.Bundle{bundle}, []service.Service{matching}, []*action{}},
+		{"missing", []*clusterconf.Bundle{bundle}, nil, []*action{
+			{Type: actionCreate, BundleID: 1, ServiceID: "web"},
+		}},
+		{"drifted", []*clusterconf.Bundle{bundle}, []service.Service{drifted}, []*action{
+			{Type: actionUpdate, BundleID: 1, ServiceID: "web", Drift: []string{"cmd", "env"}},
+		}},
+		{"dataset drifted", []*clusterconf.Bundle{bundle}, []service.Service{otherDataset}, []*action{
+			{Type: actionUpdate, BundleID: 1, ServiceID: "web", Drift: []string{"dataset"}},
+		}},
+		{"unassigned", []*clusterconf.Bundle{bundle}, []service.Service{matching, orphan}, []*action{
+			{Type: actionRemove, BundleID: 2, ServiceID: "old"},
+		}},
+		{"not a bundle service", []*clusterconf.Bundle{bundle}, []service.Service{matching, unbundled}, []*action{}},
+		{"no assignments", nil, []service.Service{orphan, unbundled}, []*action{}},
+	}
+
+	for _, test := range tests {
+		actions := plan(s.config, test.bundles, test.services)
+		if !s.Len(actions, len(test.expected), test.desc) {
+			continue
+		}
+		for i, expected := range test.expected {
+			a := actions[i]
+			s.Equal(expected.Type, a.Type, test.desc)
+			s.Equal(expected.BundleID, a.BundleID, test.desc)
+			s.Equal(expected.ServiceID, a.ServiceID, test.desc)
+			s.Equal(expected.Drift, a.Drift, test.desc)
+			if a.Type == actionRemove {
+				s.Nil(a.Service, test.desc)
+				continue
+			}
+			if s.NotNil(a.Service, test.desc) {
+				s.Equal("data/datasets/image", a.Service.Dataset, test.desc)
+				s.Equal(bundle.Services["web"].Cmd, a.Service.Cmd, test.desc)
+				s.True(a.Service.Overwrite, test.desc)
+				s.Equal(map[string]string{"FOO": "bar"}, a.Service.Env, test.desc)
+				s.Equal(map[string]string{"PASSWORD": "web-password"}, a.Service.Secrets, test.desc)
+			}
+		}
+	}
+}
+
+func (s *BundleReconciler) TestReconcileBundles() {
+	nodeID := "123.123.123.123"
+	s.clusterConf.Data.Bundles = map[uint64]*clusterconf.Bundle{
+		1: {
+			ID: 1,
+			Services: map[string]clusterconf.BundleService{
+				"web": {ServiceConf: clusterconf.ServiceConf{
+					ID:      "web",
+					Dataset: "image",
+					Cmd:     []string{"web"},
+					Env:     map[string]string{"PASSWORD": "secret:web-password"},
+				}},
+			},
+		},
+		2: {
+			ID: 2,
+			Services: map[string]clusterconf.BundleService{
+				"db": {ServiceConf: clusterconf.ServiceConf{ID: "db", Dataset: "image", Cmd: []string{"db"}}},
+			},
+		},
+	}
+	s.clusterConf.Data.Assignments = map[uint64]*clusterconf.BundleAssignment{
+		1: {BundleID: 1, Nodes: []string{nodeID}},
+		2: {BundleID: 2, Nodes: []string{"10.0.0.1"}},
+		// Assignment of a deleted bundle
+		3: {BundleID: 3, Nodes: []string{nodeID}},
+	}
+	s.clusterConf.Data.Secrets = map[string]*clusterconf.Secret{
+		"web-password": {Name: "web-password", Value: "hunter2", Bundles: []uint64{2}},
+	}
+	s.service.ClearData()
+	s.service.Add(service.Service{ID: "web", BundleID: 1, Cmd: []string{"outdated"}})
+	s.service.Add(service.Service{ID: "db", BundleID: 2, Cmd: []string{"db"}})
+	clone := "data/running-clones/2:db.service"
+	s.zfs.Data.Datasets = map[string]*zfsp.Dataset{
+		clone: {Name: clone, Properties: &zfs.DatasetProperties{Type: "filesystem"}},
+	}
+
+	// The bundle isn't allowed to use the secret
+	err := reconcileBundles(s.config, s.tracker)
+	if s.Error(err) {
+		s.Contains(err.Error(), "one or more services failed to reconcile")
+	}
+	s.Equal([]string{"outdated"}, s.service.Data.Services[1]["web"].Cmd)
+
+	s.clusterConf.Data.Secrets["web-password"].Bundles = []uint64{1}
+	s.Require().NoError(reconcileBundles(s.config, s.tracker))
+
+	s.Len(s.service.Data.Services[1], 1)
+	s.NotContains(s.service.Data.Services[1]["web"].Env, "PASSWORD")
+	s.Equal([]string{"web"}, s.service.Data.Services[1]["web"].Cmd)
+	s.Len(s.service.Data.Services[2], 0)
+	s.NotContains(s.zfs.Data.Datasets, clone)
+
+	// Converged, so nothing else to do
+	actions := plan(s.config, []*clusterconf.Bundle{s.clusterConf.Data.Bundles[1]}, []service.Service{s.service.Data.Services[1]["web"]})
+	s.Len(actions, 0)
+
+	// Missing assignments don't remove the services
+	s.clusterConf.Data.Assignments = map[uint64]*clusterconf.BundleAssignment{}
+	s.Require().NoError(reconcileBundles(s.config, s.tracker))
+	s.Len(s.service.Data.Services[1], 1)
+}
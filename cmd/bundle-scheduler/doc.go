@@ -0,0 +1,23 @@
+/*
+bundle-scheduler periodically places bundles on nodes. Each bundle is assigned
+as many live nodes as its redundancy calls for, limited to nodes with enough
+free memory, cpu cores, and disk for the bundle's services and datasets.
+Nodes must also satisfy the bundle's placement constraints: the labels of its
+node selector, taints it tolerates, and spreading copies across values of its
+anti-affinity label. Cordoned nodes are not assigned additional bundles.
+Existing assignments to live nodes are kept while they satisfy the constraints. Assignments are saved in
+clusterconf, where nodes can find the bundles they should run.
+
+Usage:
+
+	Usage of ./bundle-scheduler:
+	-u, --clusterDataURL string        url of coordinator for the cluster information
+	-c, --configFile string            path to config file
+	-l, --logLevel string              log level: debug/info/warn/error/fatal/panic (default "warning")
+	-n, --nodeDataURL string           url of coordinator for node information retrieval
+	-r, --requestTimeout duration      default timeout for external requests made
+	-t, --tickInterval duration        tick run frequency
+	-i, --tickRetryInterval duration   tick retry on error frequency
+	Note: Long flag names can be specified in either fooBar or foo[_-.]bar form.
+*/
+package main
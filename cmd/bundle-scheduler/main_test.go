@@ -0,0 +1,106 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/cerana/cerana/acomm"
+	"github.com/cerana/cerana/pkg/test"
+	"github.com/cerana/cerana/providers/clusterconf"
+	"github.com/cerana/cerana/tick"
+	"github.com/pborman/uuid"
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/suite"
+)
+
+type BundleScheduler struct {
+	suite.Suite
+	config      *tick.Config
+	configData  *tick.ConfigData
+	configFile  *os.File
+	tracker     *acomm.Tracker
+	coordinator *test.Coordinator
+	clusterConf *clusterconf.MockClusterConf
+}
+
+func TestBundleScheduler(t *testing.T) {
+	suite.Run(t, new(BundleScheduler))
+}
+
+func (s *BundleScheduler) SetupSuite() {
+	noError := s.Require().NoError
+
+	logrus.SetLevel(logrus.FatalLevel)
+
+	// Setup mock coordinator
+	var err error
+	s.coordinator, err = test.NewCoordinator("")
+	noError(err)
+
+	coordinatorURL := s.coordinator.NewProviderViper().GetString("coordinator_url")
+	s.configData = &tick.ConfigData{
+		NodeDataURL:       coordinatorURL,
+		ClusterDataURL:    coordinatorURL,
+		LogLevel:          "fatal",
+		RequestTimeout:    "5s",
+		TickInterval:      "4s",
+		TickRetryInterval: "4s",
+	}
+
+	s.config, s.configFile, err = newTestConfig(s.configData)
+	noError(err, "failed to create config")
+	noError(s.config.LoadConfig(), "failed to load config")
+
+	s.tracker, err = acomm.NewTracker("", nil, nil, s.config.RequestTimeout())
+	noError(err)
+	noError(s.tracker.Start())
+
+	// Setup mock providers
+	s.clusterConf = clusterconf.NewMockClusterConf()
+	s.coordinator.RegisterProvider(s.clusterConf)
+
+	noError(s.coordinator.Start())
+}
+
+func (s *BundleScheduler) TearDownSuite() {
+	s.coordinator.Stop()
+	s.Require().NoError(s.coordinator.Cleanup())
+	_ = os.Remove(s.configFile.Name())
+	s.tracker.Stop()
+}
+
+func newTestConfig(configData *tick.ConfigData) (*tick.Config, *os.File, error) {
+	fs := pflag.NewFlagSet(uuid.New(), pflag.ExitOnError)
+	v := viper.New()
+	v.SetConfigType("json")
+	config := tick.NewConfig(fs, v)
+	if config == nil {
+		return nil, nil, errors.New("failed to return a config")
+	}
+
+	configFile, err := ioutil.TempFile("", "bundleScheduler-")
+	if err != nil {
+		return nil, nil, err
+	}
+	defer func() { _ = configFile.Close() }()
+
+	configJSON, _ := json.Marshal(configData)
+	if _, err := configFile.Write(configJSON); err != nil {
+		return nil, configFile, err
+	}
+
+	if err := fs.Set("configFile", configFile.Name()); err != nil {
+		return nil, configFile, err
+	}
+
+	if err := fs.Parse([]string{}); err != nil {
+		return nil, configFile, err
+	}
+
+	return config, configFile, nil
+}
@@ -0,0 +1,302 @@
+package main
+
+import (
+	"reflect"
+	"sort"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/cerana/cerana/acomm"
+	"github.com/cerana/cerana/pkg/errors"
+	"github.com/cerana/cerana/providers/clusterconf"
+	"github.com/cerana/cerana/tick"
+	"golang.org/x/net/context"
+)
+
+// requirements are the resources needed to run one instance of a bundle.
+type requirements struct {
+	memory uint64
+	cpu    int
+	disk   uint64
+}
+
+// bundleRequirements sums the resource limits of a bundle's services and the
+// quotas of its datasets.
+func bundleRequirements(bundle *clusterconf.Bundle) requirements {
+	var r requirements
+	for _, service := range bundle.Services {
+		if service.Limits.Memory > 0 {
+			r.memory += uint64(service.Limits.Memory)
+		}
+		if service.Limits.CPU > 0 {
+			r.cpu += service.Limits.CPU
+		}
+	}
+	for _, dataset := range bundle.Datasets {
+		r.disk += dataset.Quota
+	}
+	return r
+}
+
+// capacity is the resources a node has available for additional bundles.
+// Memory and disk start from what the node last reported as free, which
+// already accounts for bundles running on it. Nodes only report their core
+// count, so cpu is reduced by the cpu limits of every bundle assigned to it.
+type capacity struct {
+	nodeID string
+	memory uint64
+	cpu    int
+	disk   uint64
+}
+
+func (c *capacity) fits(r requirements) bool {
+	return c.memory >= r.memory && c.cpu >= r.cpu && c.disk >= r.disk
+}
+
+func (c *capacity) reserve(r requirements) {
+	c.memory -= r.memory
+	c.cpu -= r.cpu
+	c.disk -= r.disk
+}
+
+type byFreeMemory []*capacity
+
+func (b byFreeMemory) Len() int      { return len(b) }
+func (b byFreeMemory) Swap(i, j int) { b[i], b[j] = b[j], b[i] }
+func (b byFreeMemory) Less(i, j int) bool {
+	if b[i].memory == b[j].memory {
+		return b[i].nodeID < b[j].nodeID
+	}
+	return b[i].memory > b[j].memory
+}
+
+type bundlesByID []*clusterconf.Bundle
+
+func (b bundlesByID) Len() int           { return len(b) }
+func (b bundlesByID) Swap(i, j int)      { b[i], b[j] = b[j], b[i] }
+func (b bundlesByID) Less(i, j int) bool { return b[i].ID < b[j].ID }
+
+// schedule computes the nodes each bundle should run on. Existing assignments
+// to live nodes are kept, up to the bundle's redundancy, as long as the nodes
+// still satisfy the bundle's placement constraints. Additional nodes are chosen
+// from the live, uncordoned nodes with enough capacity that satisfy the
+// constraints, preferring those with the most free memory. Bundles that can't
+// be fully placed are assigned as many nodes as possible and reported in the
+// returned errors.
+func schedule(bundles []*clusterconf.Bundle, nodes []clusterconf.Node, configs map[string]*clusterconf.NodeConfig, assignments []*clusterconf.BundleAssignment) (map[uint64][]string, map[uint64]error) {
+	capacities := make(map[string]*capacity, len(nodes))
+	for _, node := range nodes {
+		capacities[node.ID] = &capacity{
+			nodeID: node.ID,
+			memory: node.MemoryFree,
+			cpu:    node.CPUCores,
+			disk:   node.DiskFree,
+		}
+	}
+
+	current := make(map[uint64][]string, len(assignments))
+	for _, assignment := range assignments {
+		current[assignment.BundleID] = assignment.Nodes
+	}
+
+	sorted := make(bundlesByID, len(bundles))
+	copy(sorted, bundles)
+	sort.Sort(sorted)
+
+	// Keep existing assignments to live nodes first, so they are accounted
+	// for before any new placements are made.
+	placements := make(map[uint64][]string, len(bundles))
+	for _, bundle := range sorted {
+		redundancy := redundancy(bundle)
+		r := bundleRequirements(bundle)
+
+		kept := make([]string, 0, redundancy)
+		domains := make(map[string]bool)
+		currentNodes := append([]string{}, current[bundle.ID]...)
+		sort.Strings(currentNodes)
+		for _, nodeID := range currentNodes {
+			if uint64(len(kept)) == redundancy {
+				break
+			}
+			c, ok := capacities[nodeID]
+			if !ok {
+				continue
+			}
+			if !eligible(bundle, configs[nodeID], domains) {
+				continue
+			}
+			c.cpu -= r.cpu
+			kept = append(kept, nodeID)
+		}
+		placements[bundle.ID] = kept
+	}
+
+	errs := make(map[uint64]error)
+	for _, bundle := range sorted {
+		redundancy := redundancy(bundle)
+		r := bundleRequirements(bundle)
+		placed := placements[bundle.ID]
+		domains := make(map[string]bool)
+		for _, nodeID := range placed {
+			domains[configs[nodeID].Label(bundle.Placement.AntiAffinity)] = true
+		}
+
+		candidates := make(byFreeMemory, 0, len(capacities))
+		for _, c := range capacities {
+			if contains(placed, c.nodeID) {
+				continue
+			}
+			if config := configs[c.nodeID]; config != nil && config.Cordoned {
+				continue
+			}
+			candidates = append(candidates, c)
+		}
+		sort.Sort(candidates)
+
+		for _, c := range candidates {
+			if uint64(len(placed)) == redundancy {
+				break
+			}
+			if !c.fits(r) || !eligible(bundle, configs[c.nodeID], domains) {
+				continue
+			}
+			c.reserve(r)
+			placed = append(placed, c.nodeID)
+		}
+
+		if uint64(len(placed)) < redundancy {
+			errs[bundle.ID] = errors.Newv("insufficient node capacity for bundle redundancy", map[string]interface{}{
+				"bundleID":   bundle.ID,
+				"redundancy": redundancy,
+				"placed":     len(placed),
+			})
+		}
+
+		sort.Strings(placed)
+		placements[bundle.ID] = placed
+	}
+
+	return placements, errs
+}
+
+// eligible returns whether a copy of the bundle may run on the node, given the
+// anti-affinity domains already used by other copies. The node's domain is
+// marked as used when it is eligible.
+func eligible(bundle *clusterconf.Bundle, config *clusterconf.NodeConfig, domains map[string]bool) bool {
+	if !bundle.Placement.Permits(config) {
+		return false
+	}
+	if bundle.Placement.AntiAffinity == "" {
+		return true
+	}
+	domain := config.Label(bundle.Placement.AntiAffinity)
+	if domains[domain] {
+		return false
+	}
+	domains[domain] = true
+	return true
+}
+
+// redundancy returns the number of nodes a bundle should run on. Every bundle
+// runs on at least one node.
+func redundancy(bundle *clusterconf.Bundle) uint64 {
+	if bundle.Redundancy == 0 {
+		return 1
+	}
+	return bundle.Redundancy
+}
+
+func contains(list []string, value string) bool {
+	for _, v := range list {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// scheduleBundles is the tick function that places bundles on nodes and saves
+// any changed assignments.
+func scheduleBundles(config tick.Configer, tracker *acomm.Tracker) error {
+	client := clusterconf.NewClient(tracker, config.ClusterDataURL())
+	timeout := config.RequestTimeout()
+
+	bundles, nodes, configs, assignments, err := getSchedulingData(client, timeout)
+	if err != nil {
+		return err
+	}
+
+	placements, errs := schedule(bundles, nodes, configs, assignments)
+	for bundleID, err := range errs {
+		logrus.WithFields(logrus.Fields{
+			"bundleID": bundleID,
+			"error":    err,
+		}).Warn("bundle not fully scheduled")
+	}
+
+	current := make(map[uint64]*clusterconf.BundleAssignment, len(assignments))
+	for _, assignment := range assignments {
+		current[assignment.BundleID] = assignment
+	}
+
+	updateErrs := make(map[uint64]error)
+	for bundleID, nodeIDs := range placements {
+		assignment, ok := current[bundleID]
+		if !ok {
+			assignment = &clusterconf.BundleAssignment{BundleID: bundleID}
+		}
+		existing := append([]string{}, assignment.Nodes...)
+		sort.Strings(existing)
+		if reflect.DeepEqual(existing, nodeIDs) {
+			continue
+		}
+
+		assignment.Nodes = nodeIDs
+		if err := updateAssignment(client, timeout, assignment); err != nil {
+			updateErrs[bundleID] = err
+		}
+	}
+
+	if len(updateErrs) > 0 {
+		return errors.Newv("one or more bundle assignment updates unsuccessful", map[string]interface{}{"errors": updateErrs})
+	}
+	return nil
+}
+
+// getSchedulingData retrieves the bundles, live nodes, node configs keyed by
+// node id, and current bundle assignments.
+func getSchedulingData(client *clusterconf.Client, timeout time.Duration) ([]*clusterconf.Bundle, []clusterconf.Node, map[string]*clusterconf.NodeConfig, []*clusterconf.BundleAssignment, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	bundles, err := client.ListBundles(ctx, clusterconf.ListBundleArgs{CombinedOverlay: true})
+	if err != nil {
+		return nil, nil, nil, nil, err
+	}
+	nodes, err := client.ListNodes(ctx)
+	if err != nil {
+		return nil, nil, nil, nil, err
+	}
+	nodeConfigs, err := client.ListNodeConfigs(ctx)
+	if err != nil {
+		return nil, nil, nil, nil, err
+	}
+	assignments, err := client.ListBundleAssignments(ctx, clusterconf.ListBundleAssignmentsArgs{})
+	if err != nil {
+		return nil, nil, nil, nil, err
+	}
+
+	configs := make(map[string]*clusterconf.NodeConfig, len(nodeConfigs.NodeConfigs))
+	for _, config := range nodeConfigs.NodeConfigs {
+		configs[config.ID] = config
+	}
+	return bundles.Bundles, nodes.Nodes, configs, assignments.Assignments, nil
+}
+
+func updateAssignment(client *clusterconf.Client, timeout time.Duration, assignment *clusterconf.BundleAssignment) error {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	_, err := client.UpdateBundleAssignment(ctx, clusterconf.BundleAssignmentPayload{Assignment: assignment})
+	return err
+}
@@ -0,0 +1,226 @@
+package main
+
+import (
+	"github.com/cerana/cerana/providers/clusterconf"
+)
+
+func testBundle(id, redundancy uint64, memory int64, cpu int) *clusterconf.Bundle {
+	return &clusterconf.Bundle{
+		ID:         id,
+		Redundancy: redundancy,
+		Services: map[string]clusterconf.BundleService{
+			"foo": {
+				ServiceConf: clusterconf.ServiceConf{
+					ID:     "foo",
+					Limits: clusterconf.ResourceLimits{CPU: cpu, Memory: memory},
+				},
+			},
+		},
+	}
+}
+
+func testNode(id string, memory uint64, cpu int) clusterconf.Node {
+	return clusterconf.Node{
+		ID:         id,
+		MemoryFree: memory,
+		CPUCores:   cpu,
+		DiskFree:   1 << 30,
+	}
+}
+
+func (s *BundleScheduler) TestSchedule() {
+	nodes := []clusterconf.Node{
+		testNode("a", 4096, 4),
+		testNode("b", 2048, 4),
+		testNode("c", 1024, 1),
+	}
+
+	tests := []struct {
+		desc        string
+		bundles     []*clusterconf.Bundle
+		nodes       []clusterconf.Node
+		assignments []*clusterconf.BundleAssignment
+		expected    map[uint64][]string
+		errs        []uint64
+	}{
+		{"no nodes",
+			[]*clusterconf.Bundle{testBundle(1, 1, 0, 0)},
+			nil, nil,
+			map[uint64][]string{1: {}},
+			[]uint64{1}},
+		{"default redundancy",
+			[]*clusterconf.Bundle{testBundle(1, 0, 0, 0)},
+			nodes, nil,
+			map[uint64][]string{1: {"a"}},
+			nil},
+		{"most free memory first",
+			[]*clusterconf.Bundle{testBundle(1, 2, 0, 0)},
+			nodes, nil,
+			map[uint64][]string{1: {"a", "b"}},
+			nil},
+		{"reserves placed resources",
+			[]*clusterconf.Bundle{testBundle(1, 1, 3072, 1), testBundle(2, 1, 1536, 1)},
+			nodes, nil,
+			map[uint64][]string{1: {"a"}, 2: {"b"}},
+			nil},
+		{"resource limits exclude nodes",
+			[]*clusterconf.Bundle{testBundle(1, 3, 1536, 2)},
+			nodes, nil,
+			map[uint64][]string{1: {"a", "b"}},
+			[]uint64{1}},
+		{"keeps existing assignments",
+			[]*clusterconf.Bundle{testBundle(1, 1, 0, 0)},
+			nodes,
+			[]*clusterconf.BundleAssignment{{BundleID: 1, Nodes: []string{"c"}}},
+			map[uint64][]string{1: {"c"}},
+			nil},
+		{"replaces dead nodes",
+			[]*clusterconf.Bundle{testBundle(1, 2, 0, 0)},
+			nodes,
+			[]*clusterconf.BundleAssignment{{BundleID: 1, Nodes: []string{"c", "dead"}}},
+			map[uint64][]string{1: {"a", "c"}},
+			nil},
+		{"trims excess nodes",
+			[]*clusterconf.Bundle{testBundle(1, 1, 0, 0)},
+			nodes,
+			[]*clusterconf.BundleAssignment{{BundleID: 1, Nodes: []string{"c", "b"}}},
+			map[uint64][]string{1: {"b"}},
+			nil},
+		{"existing assignments use cpu",
+			[]*clusterconf.Bundle{testBundle(1, 1, 0, 4), testBundle(2, 1, 0, 1)},
+			nodes,
+			[]*clusterconf.BundleAssignment{{BundleID: 1, Nodes: []string{"a"}}},
+			map[uint64][]string{1: {"a"}, 2: {"b"}},
+			nil},
+	}
+
+	for _, test := range tests {
+		placements, errs := schedule(test.bundles, test.nodes, nil, test.assignments)
+		s.Equal(test.expected, placements, test.desc)
+		s.Len(errs, len(test.errs), test.desc)
+		for _, id := range test.errs {
+			s.Contains(errs, id, test.desc)
+		}
+	}
+}
+
+func (s *BundleScheduler) TestSchedulePlacement() {
+	nodes := []clusterconf.Node{
+		testNode("a", 4096, 4),
+		testNode("b", 2048, 4),
+		testNode("c", 1024, 4),
+	}
+	configs := map[string]*clusterconf.NodeConfig{
+		"a": {ID: "a", Labels: map[string]string{"rack": "1", "disk": "ssd"}},
+		"b": {ID: "b", Labels: map[string]string{"rack": "1"}},
+		"c": {ID: "c", Labels: map[string]string{"rack": "2", "disk": "ssd"}},
+	}
+	cordoned := map[string]*clusterconf.NodeConfig{
+		"a": {ID: "a", Cordoned: true},
+	}
+	tainted := map[string]*clusterconf.NodeConfig{
+		"a": {ID: "a", Taints: []clusterconf.Taint{{Key: "gpu", Value: "true"}}},
+	}
+
+	withPlacement := func(bundle *clusterconf.Bundle, placement clusterconf.BundlePlacement) *clusterconf.Bundle {
+		bundle.Placement = placement
+		return bundle
+	}
+
+	tests := []struct {
+		desc        string
+		bundles     []*clusterconf.Bundle
+		configs     map[string]*clusterconf.NodeConfig
+		assignments []*clusterconf.BundleAssignment
+		expected    map[uint64][]string
+		errs        []uint64
+	}{
+		{"node selector",
+			[]*clusterconf.Bundle{withPlacement(testBundle(1, 2, 0, 0), clusterconf.BundlePlacement{
+				NodeSelector: map[string]string{"disk": "ssd"},
+			})},
+			configs, nil,
+			map[uint64][]string{1: {"a", "c"}},
+			nil},
+		{"node selector without configs",
+			[]*clusterconf.Bundle{withPlacement(testBundle(1, 1, 0, 0), clusterconf.BundlePlacement{
+				NodeSelector: map[string]string{"disk": "ssd"},
+			})},
+			nil, nil,
+			map[uint64][]string{1: {}},
+			[]uint64{1}},
+		{"anti-affinity",
+			[]*clusterconf.Bundle{withPlacement(testBundle(1, 3, 0, 0), clusterconf.BundlePlacement{
+				AntiAffinity: "rack",
+			})},
+			configs, nil,
+			map[uint64][]string{1: {"a", "c"}},
+			[]uint64{1}},
+		{"anti-affinity drops existing assignments",
+			[]*clusterconf.Bundle{withPlacement(testBundle(1, 2, 0, 0), clusterconf.BundlePlacement{
+				AntiAffinity: "rack",
+			})},
+			configs,
+			[]*clusterconf.BundleAssignment{{BundleID: 1, Nodes: []string{"a", "b"}}},
+			map[uint64][]string{1: {"a", "c"}},
+			nil},
+		{"cordoned nodes get no new bundles",
+			[]*clusterconf.Bundle{testBundle(1, 1, 0, 0)},
+			cordoned, nil,
+			map[uint64][]string{1: {"b"}},
+			nil},
+		{"cordoned nodes keep bundles",
+			[]*clusterconf.Bundle{testBundle(1, 1, 0, 0)},
+			cordoned,
+			[]*clusterconf.BundleAssignment{{BundleID: 1, Nodes: []string{"a"}}},
+			map[uint64][]string{1: {"a"}},
+			nil},
+		{"taints",
+			[]*clusterconf.Bundle{testBundle(1, 1, 0, 0)},
+			tainted, nil,
+			map[uint64][]string{1: {"b"}},
+			nil},
+		{"tolerations",
+			[]*clusterconf.Bundle{withPlacement(testBundle(1, 1, 0, 0), clusterconf.BundlePlacement{
+				Tolerations: []clusterconf.Taint{{Key: "gpu", Value: "true"}},
+			})},
+			tainted, nil,
+			map[uint64][]string{1: {"a"}},
+			nil},
+	}
+
+	for _, test := range tests {
+		placements, errs := schedule(test.bundles, nodes, test.configs, test.assignments)
+		s.Equal(test.expected, placements, test.desc)
+		s.Len(errs, len(test.errs), test.desc)
+		for _, id := range test.errs {
+			s.Contains(errs, id, test.desc)
+		}
+	}
+}
+
+func (s *BundleScheduler) TestScheduleBundles() {
+	s.clusterConf.Data.Bundles = map[uint64]*clusterconf.Bundle{
+		1: testBundle(1, 2, 1024, 1),
+		2: testBundle(2, 1, 1024, 1),
+	}
+	s.clusterConf.Data.Nodes = map[string]*clusterconf.Node{}
+	for _, node := range []clusterconf.Node{testNode("a", 4096, 4), testNode("b", 2048, 4)} {
+		node := node
+		s.clusterConf.Data.Nodes[node.ID] = &node
+	}
+	s.clusterConf.Data.Assignments = map[uint64]*clusterconf.BundleAssignment{
+		2: {BundleID: 2, Nodes: []string{"b"}, ModIndex: 1},
+	}
+	s.clusterConf.Data.NodeConfigs = map[string]*clusterconf.NodeConfig{}
+
+	s.NoError(scheduleBundles(s.config, s.tracker))
+
+	if s.Contains(s.clusterConf.Data.Assignments, uint64(1)) {
+		s.Equal([]string{"a", "b"}, s.clusterConf.Data.Assignments[1].Nodes)
+	}
+	if s.Contains(s.clusterConf.Data.Assignments, uint64(2)) {
+		s.Equal([]string{"b"}, s.clusterConf.Data.Assignments[2].Nodes)
+		s.Equal(uint64(1), s.clusterConf.Data.Assignments[2].ModIndex, "unchanged assignment should not be updated")
+	}
+}
@@ -0,0 +1,25 @@
+/*
+clientgen generates a typed Client for a provider package, with a method for
+each task registered in the provider's RegisterTasks method. It is run with go
+generate from the file containing RegisterTasks:
+
+	//go:generate clientgen
+
+Task handlers are inspected to find the type of their request args and result,
+and whether they return or expect a stream url. Method names are derived from
+the task name, dropping a prefix matching the package name, e.g. zfs-snapshot
+becomes Snapshot. Details that can't be inferred from the handler are provided
+by a directive comment on the RegisterTask line:
+
+	server.RegisterTask("kv-get", k.get) // clientgen:result Value
+	server.RegisterTask("foo-bar", p.bar) // clientgen:args BarArgs; stream
+	server.RegisterTask("foo-baz", p.baz) // clientgen:skip
+
+Usage
+
+	$ clientgen -h
+	Usage of clientgen:
+	-o, --output string   output file name (default "client.go")
+	-s, --source string   file containing the RegisterTasks method
+*/
+package main
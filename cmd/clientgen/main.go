@@ -0,0 +1,700 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/cerana/cerana/pkg/errors"
+	"github.com/cerana/cerana/pkg/logrusx"
+	flag "github.com/spf13/pflag"
+)
+
+// directivePrefix marks comments on task registrations that supply details
+// which can't be inferred from the task handler.
+const directivePrefix = "clientgen:"
+
+// task describes a registered task and the client method generated for it.
+type task struct {
+	Name        string
+	Method      string
+	Args        string
+	Result      string
+	Stream      bool
+	InputStream bool
+}
+
+// generator gathers the tasks of a provider package.
+type generator struct {
+	fset    *token.FileSet
+	pkgName string
+	files   map[string]*ast.File
+	funcs   map[string]*ast.FuncDecl
+	imports map[string]string
+}
+
+func main() {
+	logrus.SetFormatter(&logrusx.JSONFormatter{})
+
+	output := flag.StringP("output", "o", "client.go", "output file name")
+	source := flag.StringP("source", "s", os.Getenv("GOFILE"), "file containing the RegisterTasks method")
+	flag.Parse()
+
+	if *source == "" {
+		logrusx.DieOnError(errors.New("missing source file"), "generate client")
+	}
+
+	g, err := newGenerator(".", *output)
+	logrusx.DieOnError(err, "parse package")
+
+	tasks, err := g.tasks(*source)
+	logrusx.DieOnError(err, "find tasks")
+
+	code, err := g.generate(tasks)
+	logrusx.DieOnError(err, "generate client")
+
+	logrusx.DieOnError(errors.Wrapv(ioutil.WriteFile(*output, code, 0644), map[string]interface{}{"output": *output}), "write client")
+}
+
+// newGenerator parses the non-test go files of the package in dir, skipping
+// a previously generated output file.
+func newGenerator(dir, output string) (*generator, error) {
+	fset := token.NewFileSet()
+	filter := func(fi os.FileInfo) bool {
+		return !strings.HasSuffix(fi.Name(), "_test.go") && fi.Name() != output
+	}
+	pkgs, err := parser.ParseDir(fset, dir, filter, parser.ParseComments)
+	if err != nil {
+		return nil, errors.Wrapv(err, map[string]interface{}{"dir": dir})
+	}
+	if len(pkgs) != 1 {
+		return nil, errors.Newv("expected exactly one package", map[string]interface{}{"dir": dir, "packages": len(pkgs)})
+	}
+
+	g := &generator{
+		fset:    fset,
+		files:   make(map[string]*ast.File),
+		funcs:   make(map[string]*ast.FuncDecl),
+		imports: make(map[string]string),
+	}
+	for _, pkg := range pkgs {
+		g.pkgName = pkg.Name
+		for name, file := range pkg.Files {
+			g.files[filepath.Base(name)] = file
+			for _, decl := range file.Decls {
+				if fn, ok := decl.(*ast.FuncDecl); ok {
+					g.funcs[funcKey(fn)] = fn
+				}
+			}
+		}
+	}
+	return g, nil
+}
+
+// funcKey returns the key of a function or method, of the form Recv.Name for
+// methods.
+func funcKey(fn *ast.FuncDecl) string {
+	if fn.Recv == nil || len(fn.Recv.List) == 0 {
+		return fn.Name.Name
+	}
+	recv := fn.Recv.List[0].Type
+	if star, ok := recv.(*ast.StarExpr); ok {
+		recv = star.X
+	}
+	if ident, ok := recv.(*ast.Ident); ok {
+		return ident.Name + "." + fn.Name.Name
+	}
+	return fn.Name.Name
+}
+
+// tasks returns the tasks registered by the RegisterTasks method in the
+// source file.
+func (g *generator) tasks(source string) ([]*task, error) {
+	file, ok := g.files[filepath.Base(source)]
+	if !ok {
+		return nil, errors.Newv("source file not found", map[string]interface{}{"source": source})
+	}
+
+	var registerTasks *ast.FuncDecl
+	for _, decl := range file.Decls {
+		if fn, ok := decl.(*ast.FuncDecl); ok && fn.Recv != nil && fn.Name.Name == "RegisterTasks" {
+			registerTasks = fn
+			break
+		}
+	}
+	if registerTasks == nil {
+		return nil, errors.Newv("RegisterTasks not found", map[string]interface{}{"source": source})
+	}
+	recvType := strings.Split(funcKey(registerTasks), ".")[0]
+
+	directives := g.directives(file)
+
+	var tasks []*task
+	var taskErr error
+	ast.Inspect(registerTasks.Body, func(n ast.Node) bool {
+		if taskErr != nil {
+			return false
+		}
+		call, ok := n.(*ast.CallExpr)
+		if !ok || !isMethodCall(call, "RegisterTask") || len(call.Args) != 2 {
+			return true
+		}
+
+		lit, ok := call.Args[0].(*ast.BasicLit)
+		if !ok || lit.Kind != token.STRING {
+			taskErr = errors.Newv("task name must be a string literal", map[string]interface{}{"pos": g.fset.Position(call.Pos()).String()})
+			return false
+		}
+		name, _ := strconv.Unquote(lit.Value)
+
+		handler, ok := call.Args[1].(*ast.SelectorExpr)
+		if !ok {
+			taskErr = errors.Newv("task handler must be a method", map[string]interface{}{"task": name})
+			return false
+		}
+
+		t, err := g.task(name, recvType, handler.Sel.Name, directives[g.fset.Position(call.Pos()).Line])
+		if err != nil {
+			taskErr = err
+			return false
+		}
+		if t != nil {
+			tasks = append(tasks, t)
+		}
+		return true
+	})
+	if taskErr != nil {
+		return nil, taskErr
+	}
+
+	sort.Sort(byMethod(tasks))
+	return tasks, nil
+}
+
+// directives returns the clientgen directives in a file's comments, keyed by
+// line number.
+func (g *generator) directives(file *ast.File) map[int]map[string]string {
+	directives := make(map[int]map[string]string)
+	for _, group := range file.Comments {
+		for _, comment := range group.List {
+			text := strings.TrimSpace(strings.TrimPrefix(comment.Text, "//"))
+			if !strings.HasPrefix(text, directivePrefix) {
+				continue
+			}
+			line := g.fset.Position(comment.Pos()).Line
+			if directives[line] == nil {
+				directives[line] = make(map[string]string)
+			}
+			for _, d := range strings.Split(strings.TrimPrefix(text, directivePrefix), ";") {
+				parts := strings.SplitN(strings.TrimSpace(d), " ", 2)
+				value := ""
+				if len(parts) == 2 {
+					value = strings.TrimSpace(parts[1])
+				}
+				directives[line][parts[0]] = value
+			}
+		}
+	}
+	return directives
+}
+
+// task describes a single task based on its handler and directives. It
+// returns nil if the task should be skipped.
+func (g *generator) task(name, recvType, handlerName string, directives map[string]string) (*task, error) {
+	if _, ok := directives["skip"]; ok {
+		return nil, nil
+	}
+
+	handler, ok := g.funcs[recvType+"."+handlerName]
+	if !ok {
+		return nil, errors.Newv("task handler not found", map[string]interface{}{"task": name, "handler": handlerName})
+	}
+
+	t := &task{
+		Name:   name,
+		Method: methodName(g.pkgName, name),
+	}
+	errData := map[string]interface{}{"task": name, "handler": handlerName}
+
+	if args, ok := directives["args"]; ok {
+		t.Args = args
+		g.useImports(args, nil)
+	} else if argsExpr, argsFile := g.argsType(handler, map[string]bool{}); argsExpr != nil {
+		t.Args = g.exprString(argsExpr)
+		g.useImports(t.Args, argsFile)
+	}
+
+	t.InputStream = g.usesStreamURL(handler, map[string]bool{})
+
+	results, stream, unknown := g.results(handler, map[string]bool{})
+	t.Stream = stream
+	if _, ok := directives["stream"]; ok {
+		t.Stream = true
+	}
+	if result, ok := directives["result"]; ok {
+		t.Result = result
+		g.useImports(result, nil)
+	} else if unknown {
+		return nil, errors.Newv("unable to infer result type, add a clientgen:result directive", errData)
+	} else if len(results) > 1 {
+		errData["results"] = results
+		return nil, errors.Newv("multiple result types, add a clientgen:result directive", errData)
+	} else {
+		for result, resultFile := range results {
+			t.Result = result
+			g.useImports(result, resultFile)
+		}
+	}
+
+	return t, nil
+}
+
+// argsType finds the type of the variable the request args are unmarshalled
+// into, following calls to other functions and methods that are passed the
+// request.
+func (g *generator) argsType(fn *ast.FuncDecl, visited map[string]bool) (ast.Expr, *ast.File) {
+	key := funcKey(fn)
+	if visited[key] {
+		return nil, nil
+	}
+	visited[key] = true
+
+	var argsExpr ast.Expr
+	var callees []*ast.FuncDecl
+	ast.Inspect(fn.Body, func(n ast.Node) bool {
+		if argsExpr != nil {
+			return false
+		}
+		call, ok := n.(*ast.CallExpr)
+		if !ok {
+			return true
+		}
+		if isMethodCall(call, "UnmarshalArgs") && len(call.Args) == 1 {
+			switch arg := call.Args[0].(type) {
+			case *ast.UnaryExpr:
+				if ident, ok := arg.X.(*ast.Ident); ok && arg.Op == token.AND {
+					argsExpr = varType(fn.Body, ident.Name)
+				}
+			case *ast.Ident:
+				argsExpr = varType(fn.Body, arg.Name)
+			}
+			return false
+		}
+		if callee := g.callee(fn, call); callee != nil {
+			callees = append(callees, callee)
+		}
+		return true
+	})
+	if argsExpr != nil {
+		return deref(argsExpr), g.fileOf(fn)
+	}
+
+	for _, callee := range callees {
+		if argsExpr, file := g.argsType(callee, visited); argsExpr != nil {
+			return argsExpr, file
+		}
+	}
+	return nil, nil
+}
+
+// usesStreamURL determines whether a task handler, or a function it passes
+// the request to, reads the request's stream url.
+func (g *generator) usesStreamURL(fn *ast.FuncDecl, visited map[string]bool) bool {
+	key := funcKey(fn)
+	if visited[key] {
+		return false
+	}
+	visited[key] = true
+
+	var uses bool
+	var callees []*ast.FuncDecl
+	ast.Inspect(fn.Body, func(n ast.Node) bool {
+		if uses {
+			return false
+		}
+		switch node := n.(type) {
+		case *ast.SelectorExpr:
+			if ident, ok := node.X.(*ast.Ident); ok && ident.Name == reqName(fn) && node.Sel.Name == "StreamURL" {
+				uses = true
+				return false
+			}
+		case *ast.CallExpr:
+			if callee := g.callee(fn, node); callee != nil {
+				callees = append(callees, callee)
+			}
+		}
+		return true
+	})
+
+	for _, callee := range callees {
+		uses = uses || g.usesStreamURL(callee, visited)
+	}
+	return uses
+}
+
+// results finds the types of the results returned by a task handler, keyed
+// on type with the file the type appears in. It also reports whether a
+// stream url is returned and whether any result type could not be inferred.
+func (g *generator) results(fn *ast.FuncDecl, visited map[string]bool) (map[string]*ast.File, bool, bool) {
+	results := make(map[string]*ast.File)
+	key := funcKey(fn)
+	if visited[key] {
+		return results, false, false
+	}
+	visited[key] = true
+
+	var stream, unknown bool
+	ast.Inspect(fn.Body, func(n ast.Node) bool {
+		switch node := n.(type) {
+		case *ast.FuncLit:
+			// Returns within function literals are not the handler's
+			return false
+		case *ast.ReturnStmt:
+			if len(node.Results) == 1 {
+				// Delegating to another handler
+				call, ok := node.Results[0].(*ast.CallExpr)
+				if !ok {
+					unknown = true
+					return false
+				}
+				callee := g.callee(fn, call)
+				if callee == nil {
+					unknown = true
+					return false
+				}
+				calleeResults, calleeStream, calleeUnknown := g.results(callee, visited)
+				for result, file := range calleeResults {
+					results[result] = file
+				}
+				stream = stream || calleeStream
+				unknown = unknown || calleeUnknown
+				return false
+			}
+			if len(node.Results) != 3 {
+				return false
+			}
+
+			if !isNil(node.Results[1]) {
+				stream = true
+			}
+
+			resultExpr := node.Results[0]
+			if isNil(resultExpr) {
+				return false
+			}
+			if ident, ok := resultExpr.(*ast.Ident); ok {
+				resultExpr = varType(fn.Body, ident.Name)
+			} else {
+				resultExpr = literalType(resultExpr)
+			}
+			if resultExpr == nil {
+				unknown = true
+				return false
+			}
+			results[g.exprString(resultExpr)] = g.fileOf(fn)
+			return false
+		}
+		return true
+	})
+	return results, stream, unknown
+}
+
+// callee returns the declaration of a function or method with the same
+// receiver called by fn, if it is passed the request.
+func (g *generator) callee(fn *ast.FuncDecl, call *ast.CallExpr) *ast.FuncDecl {
+	passesReq := false
+	for _, arg := range call.Args {
+		if ident, ok := arg.(*ast.Ident); ok && ident.Name == reqName(fn) {
+			passesReq = true
+		}
+	}
+	if !passesReq {
+		return nil
+	}
+
+	switch f := call.Fun.(type) {
+	case *ast.Ident:
+		return g.funcs[f.Name]
+	case *ast.SelectorExpr:
+		recvType := strings.Split(funcKey(fn), ".")[0]
+		return g.funcs[recvType+"."+f.Sel.Name]
+	}
+	return nil
+}
+
+// fileOf returns the file containing a function declaration.
+func (g *generator) fileOf(fn *ast.FuncDecl) *ast.File {
+	for _, file := range g.files {
+		if file.Pos() <= fn.Pos() && fn.End() <= file.End() {
+			return file
+		}
+	}
+	return nil
+}
+
+// useImports records the imports needed for qualified identifiers in a type
+// expression, based on the imports of the file the expression comes from, or
+// of any file in the package if file is nil.
+func (g *generator) useImports(typeExpr string, file *ast.File) {
+	expr, err := parser.ParseExpr(typeExpr)
+	if err != nil {
+		return
+	}
+
+	var imports []*ast.ImportSpec
+	if file != nil {
+		imports = file.Imports
+	} else {
+		for _, f := range g.files {
+			imports = append(imports, f.Imports...)
+		}
+	}
+	ast.Inspect(expr, func(n ast.Node) bool {
+		sel, ok := n.(*ast.SelectorExpr)
+		if !ok {
+			return true
+		}
+		pkg, ok := sel.X.(*ast.Ident)
+		if !ok {
+			return true
+		}
+		for _, imp := range imports {
+			path, _ := strconv.Unquote(imp.Path.Value)
+			name := filepath.Base(path)
+			if imp.Name != nil {
+				name = imp.Name.Name
+			}
+			if name == pkg.Name {
+				g.imports[pkg.Name] = path
+			}
+		}
+		return false
+	})
+}
+
+// exprString formats an expression as source.
+func (g *generator) exprString(expr ast.Expr) string {
+	var buf bytes.Buffer
+	_ = format.Node(&buf, g.fset, expr)
+	return buf.String()
+}
+
+// generate renders the client source for the tasks.
+func (g *generator) generate(tasks []*task) ([]byte, error) {
+	stdImports := []string{`"net/url"`}
+	imports := []string{
+		`"github.com/cerana/cerana/acomm"`,
+		`"golang.org/x/net/context"`,
+	}
+	for name, path := range g.imports {
+		imp := strconv.Quote(path)
+		if filepath.Base(path) != name {
+			imp = name + " " + imp
+		}
+		if strings.Contains(strings.Split(path, "/")[0], ".") {
+			imports = append(imports, imp)
+		} else {
+			stdImports = append(stdImports, imp)
+		}
+	}
+	sort.Strings(stdImports)
+	sort.Strings(imports)
+
+	var buf bytes.Buffer
+	err := clientTemplate.Execute(&buf, map[string]interface{}{
+		"Package":    g.pkgName,
+		"StdImports": stdImports,
+		"Imports":    imports,
+		"Tasks":      tasks,
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to execute template")
+	}
+
+	code, err := format.Source(buf.Bytes())
+	if err != nil {
+		return nil, errors.Wrapv(err, map[string]interface{}{"source": buf.String()}, "failed to format source")
+	}
+	return code, nil
+}
+
+// methodName converts a task name into a client method name, dropping a
+// prefix matching the package name.
+func methodName(pkgName, taskName string) string {
+	taskName = strings.TrimPrefix(taskName, pkgName+"-")
+
+	var name string
+	for _, part := range strings.Split(taskName, "-") {
+		if initialism, ok := initialisms[strings.ToUpper(part)]; ok {
+			name += initialism
+			continue
+		}
+		name += strings.ToUpper(part[:1]) + part[1:]
+	}
+	return name
+}
+
+// initialisms are words that are written in all caps in method names.
+var initialisms = map[string]string{
+	"CPU":  "CPU",
+	"DHCP": "DHCP",
+	"HTTP": "HTTP",
+	"ID":   "ID",
+	"TCP":  "TCP",
+}
+
+// varType finds the declared type of a variable within a function body.
+func varType(body *ast.BlockStmt, name string) ast.Expr {
+	var typeExpr ast.Expr
+	ast.Inspect(body, func(n ast.Node) bool {
+		if typeExpr != nil {
+			return false
+		}
+		switch node := n.(type) {
+		case *ast.ValueSpec:
+			for i, ident := range node.Names {
+				if ident.Name != name {
+					continue
+				}
+				if node.Type != nil {
+					typeExpr = node.Type
+				} else if i < len(node.Values) {
+					typeExpr = literalType(node.Values[i])
+				}
+			}
+		case *ast.AssignStmt:
+			if node.Tok != token.DEFINE || len(node.Lhs) != len(node.Rhs) {
+				return true
+			}
+			for i, lhs := range node.Lhs {
+				if ident, ok := lhs.(*ast.Ident); ok && ident.Name == name {
+					typeExpr = literalType(node.Rhs[i])
+				}
+			}
+		}
+		return true
+	})
+	return typeExpr
+}
+
+// literalType returns the type of a composite literal, its address, or the
+// result of make or new.
+func literalType(expr ast.Expr) ast.Expr {
+	switch e := expr.(type) {
+	case *ast.CompositeLit:
+		return e.Type
+	case *ast.UnaryExpr:
+		if e.Op == token.AND {
+			if lit, ok := e.X.(*ast.CompositeLit); ok {
+				return &ast.StarExpr{X: lit.Type}
+			}
+		}
+	case *ast.CallExpr:
+		if ident, ok := e.Fun.(*ast.Ident); ok && len(e.Args) > 0 {
+			switch ident.Name {
+			case "make":
+				return e.Args[0]
+			case "new":
+				return &ast.StarExpr{X: e.Args[0]}
+			}
+		}
+	}
+	return nil
+}
+
+// deref removes a pointer from a type.
+func deref(expr ast.Expr) ast.Expr {
+	if star, ok := expr.(*ast.StarExpr); ok {
+		return star.X
+	}
+	return expr
+}
+
+// reqName returns the name of the request parameter of a task handler.
+func reqName(fn *ast.FuncDecl) string {
+	for _, field := range fn.Type.Params.List {
+		if star, ok := field.Type.(*ast.StarExpr); ok {
+			if sel, ok := star.X.(*ast.SelectorExpr); ok && sel.Sel.Name == "Request" && len(field.Names) > 0 {
+				return field.Names[0].Name
+			}
+		}
+	}
+	return ""
+}
+
+func isMethodCall(call *ast.CallExpr, name string) bool {
+	sel, ok := call.Fun.(*ast.SelectorExpr)
+	return ok && sel.Sel.Name == name
+}
+
+func isNil(expr ast.Expr) bool {
+	ident, ok := expr.(*ast.Ident)
+	return ok && ident.Name == "nil"
+}
+
+type byMethod []*task
+
+func (b byMethod) Len() int           { return len(b) }
+func (b byMethod) Swap(i, j int)      { b[i], b[j] = b[j], b[i] }
+func (b byMethod) Less(i, j int) bool { return b[i].Method < b[j].Method }
+
+var clientTemplate = template.Must(template.New("client").Parse(fmt.Sprintf(`// Code generated by clientgen. DO NOT EDIT.
+
+package {{.Package}}
+
+import (
+{{range .StdImports}}	{{.}}
+{{end}}
+{{range .Imports}}	{{.}}
+{{end}})
+
+// Client makes requests for the {{.Package}} provider's tasks through a
+// coordinator, handling request tracking and result decoding.
+type Client struct {
+	tracker     *acomm.Tracker
+	coordinator *url.URL
+}
+
+// NewClient creates a new Client. Requests are sent to the coordinator and
+// tracked by the tracker, which must already be started.
+func NewClient(tracker *acomm.Tracker, coordinator *url.URL) *Client {
+	return &Client{
+		tracker:     tracker,
+		coordinator: coordinator,
+	}
+}
+{{range .Tasks}}
+// {{.Method}} makes a %[1]s{{.Name}}%[1]s request.
+func (c *Client) {{.Method}}(ctx context.Context{{if .Args}}, args {{.Args}}{{end}}{{if .InputStream}}, streamURL *url.URL{{end}}) ({{if .Result}}{{.Result}}, {{end}}{{if .Stream}}*url.URL, {{end}}error) {
+	opts := acomm.RequestOptions{
+		Task: "{{.Name}}",
+		{{- if .Args}}
+		Args: args,
+		{{- end}}
+		{{- if .InputStream}}
+		StreamURL: streamURL,
+		{{- end}}
+	}
+	{{- if .Result}}
+	var result {{.Result}}
+	{{if .Stream}}respStreamURL{{else}}_{{end}}, err := c.tracker.Call(ctx, c.coordinator, opts, &result)
+	return result, {{if .Stream}}respStreamURL, {{end}}err
+	{{- else if .Stream}}
+	return c.tracker.Call(ctx, c.coordinator, opts, nil)
+	{{- else}}
+	_, err := c.tracker.Call(ctx, c.coordinator, opts, nil)
+	return err
+	{{- end}}
+}
+{{end}}`, "`")))
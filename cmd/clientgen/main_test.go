@@ -0,0 +1,60 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type ClientGen struct {
+	suite.Suite
+}
+
+func TestClientGen(t *testing.T) {
+	suite.Run(t, new(ClientGen))
+}
+
+func (s *ClientGen) TestMethodName() {
+	tests := []struct {
+		pkgName  string
+		taskName string
+		expected string
+	}{
+		{"zfs", "zfs-snapshot", "Snapshot"},
+		{"kv", "kv-ephemeral-set", "EphemeralSet"},
+		{"kv", "kv-getAll", "GetAll"},
+		{"clusterconf", "get-dhcp-config", "GetDHCPConfig"},
+		{"metrics", "metrics-cpu", "CPU"},
+		{"health", "health-tcp-response", "TCPResponse"},
+		{"datatrade", "import-dataset", "ImportDataset"},
+	}
+
+	for _, test := range tests {
+		s.Equal(test.expected, methodName(test.pkgName, test.taskName), test.taskName)
+	}
+}
+
+func (s *ClientGen) TestTasks() {
+	g, err := newGenerator("testdata", "client.go")
+	s.Require().NoError(err)
+
+	tasks, err := g.tasks("example.go")
+	s.Require().NoError(err)
+
+	expected := []*task{
+		{Name: "example-get", Method: "Get", Args: "GetArgs", Result: "*Thing"},
+		{Name: "get-cpu-id", Method: "GetCPUID", Result: "[]Thing"},
+		{Name: "example-host", Method: "Host", Result: "*host.InfoStat"},
+		{Name: "example-list", Method: "List", Result: "[]Thing"},
+		{Name: "example-receive", Method: "Receive", InputStream: true},
+		{Name: "example-send", Method: "Send", Args: "GetArgs", Stream: true},
+	}
+	s.Equal(expected, tasks)
+	s.Equal(map[string]string{"host": "github.com/shirou/gopsutil/host"}, g.imports)
+
+	code, err := g.generate(tasks)
+	s.NoError(err)
+	s.Contains(string(code), "func (c *Client) Get(ctx context.Context, args GetArgs) (*Thing, error) {")
+	s.Contains(string(code), "func (c *Client) Receive(ctx context.Context, streamURL *url.URL) error {")
+	s.Contains(string(code), "func (c *Client) Send(ctx context.Context, args GetArgs) (*url.URL, error) {")
+}
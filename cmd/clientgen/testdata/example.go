@@ -0,0 +1,66 @@
+package example
+
+import (
+	"net/url"
+
+	"github.com/cerana/cerana/acomm"
+	"github.com/cerana/cerana/provider"
+	"github.com/shirou/gopsutil/host"
+)
+
+type Example struct{}
+
+type GetArgs struct {
+	ID string `json:"id"`
+}
+
+type Thing struct {
+	ID string `json:"id"`
+}
+
+func (e *Example) RegisterTasks(server *provider.Server) {
+	server.RegisterTask("example-get", e.get)
+	server.RegisterTask("example-list", e.list)
+	server.RegisterTask("example-send", e.send)
+	server.RegisterTask("example-receive", e.receive)
+	server.RegisterTask("example-host", e.host)    // clientgen:result *host.InfoStat
+	server.RegisterTask("example-internal", e.get) // clientgen:skip
+	server.RegisterTask("get-cpu-id", e.list)
+}
+
+func (e *Example) get(req *acomm.Request) (interface{}, *url.URL, error) {
+	var args GetArgs
+	if err := req.UnmarshalArgs(&args); err != nil {
+		return nil, nil, err
+	}
+	return &Thing{ID: args.ID}, nil, nil
+}
+
+func (e *Example) list(req *acomm.Request) (interface{}, *url.URL, error) {
+	things := make([]Thing, 0)
+	return things, nil, nil
+}
+
+func (e *Example) send(req *acomm.Request) (interface{}, *url.URL, error) {
+	return e.doSend(req)
+}
+
+func (e *Example) doSend(req *acomm.Request) (interface{}, *url.URL, error) {
+	args := &GetArgs{}
+	if err := req.UnmarshalArgs(args); err != nil {
+		return nil, nil, err
+	}
+	return nil, req.ResponseHook, nil
+}
+
+func (e *Example) receive(req *acomm.Request) (interface{}, *url.URL, error) {
+	if req.StreamURL == nil {
+		return nil, nil, nil
+	}
+	return nil, nil, nil
+}
+
+func (e *Example) host(req *acomm.Request) (interface{}, *url.URL, error) {
+	info, err := host.Info()
+	return info, nil, err
+}
@@ -14,9 +14,12 @@ func main() {
 	logrus.SetFormatter(&logrusx.JSONFormatter{})
 
 	config := clusterconf.NewConfig(nil, nil)
-	flag.DurationP("dataset_ttl", "d", time.Minute, "ttl for dataset usage heartbeats")
-	flag.DurationP("bundle_ttl", "b", time.Minute, "ttl for bundle usage heartbeats")
-	flag.DurationP("node_ttl", "o", time.Minute, "ttl for node heartbeats")
+	flag.DurationP("dataset_ttl", "d", time.Minute, "ttl for dataset usage heartbeats, unless set in the cluster defaults")
+	flag.DurationP("bundle_ttl", "b", time.Minute, "ttl for bundle usage heartbeats, unless set in the cluster defaults")
+	flag.DurationP("node_ttl", "o", time.Minute, "ttl for node heartbeats, unless set in the cluster defaults")
+	flag.DurationP("node_history_max_age", "m", 0, "how long to keep node heartbeat history, forever if 0")
+	flag.StringP("secret_key_file", "k", "", "file containing the hex encoded cluster key for encrypting secrets")
+	flag.DurationP("audit_retention", "a", 30*24*time.Hour, "how long to keep audit events of configuration changes")
 	flag.Parse()
 
 	logrusx.DieOnError(config.LoadConfig(), "load config")
@@ -29,6 +32,7 @@ func main() {
 
 	if len(server.RegisteredTasks()) != 0 {
 		logrusx.DieOnError(server.Start(), "start server")
+		go server.ReloadOnSignal()
 		server.StopOnSignal()
 	} else {
 		logrus.Warn("no registered tasks, exiting")
@@ -5,6 +5,7 @@ Usage
 	Usage of coordinator:
 	-c, --config_file="": path to config file
 	-p, --external_port=8080: port for the http external request server to listen
+	    --local_only_tasks="resolve-secrets": comma separated tasks returning sensitive data that are only accepted on the internal socket
 	-l, --log_level="warning": log level: debug/info/warn/error/fatal/panic
 	-t, --request_timeout=0: default timeout for requests in seconds
 	-n, --service_name="": name of the coordinator
@@ -20,5 +20,6 @@ func main() {
 	logrusx.DieOnError(err, "new server")
 
 	logrusx.DieOnError(server.Start(), "start server")
+	go server.ReloadOnSignal()
 	server.StopOnSignal()
 }
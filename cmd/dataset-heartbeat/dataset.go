@@ -4,6 +4,9 @@ import (
 	"net"
 	"net/url"
 	"path/filepath"
+	"sort"
+	"strings"
+	"time"
 
 	"github.com/cerana/cerana/acomm"
 	"github.com/cerana/cerana/pkg/errors"
@@ -89,12 +92,23 @@ func getDatasets(config *Config, tracker *acomm.Tracker, ip net.IP) ([]clusterco
 		}
 	}
 
+	// collect the snapshots of each dataset
+	snapshots := make(map[string][]*zfs.Dataset)
+	for _, dataset := range listResult {
+		if name, isSnapshot := snapshotOf(dataset); isSnapshot {
+			snapshots[name] = append(snapshots[name], dataset)
+		}
+	}
+
 	// extract just the dataset ids and ignore the base directory
 	datasets := make([]clusterconf.DatasetHeartbeatArgs, 0, len(listResult))
 	for _, dataset := range listResult {
 		if config.DatasetPrefix() == dataset.Name {
 			continue
 		}
+		if _, isSnapshot := snapshotOf(dataset); isSnapshot {
+			continue
+		}
 
 		datasetID := filepath.Base(dataset.Name)
 
@@ -102,12 +116,55 @@ func getDatasets(config *Config, tracker *acomm.Tracker, ip net.IP) ([]clusterco
 			ID:    datasetID,
 			InUse: datasetsInUse[datasetID],
 		}
+		if len(snapshots[dataset.Name]) > 0 {
+			args.Snapshots = datasetSnapshots(snapshots[dataset.Name])
+		}
 		datasets = append(datasets, args)
 	}
 
 	return datasets, nil
 }
 
+// snapshotOf returns the name of the dataset a snapshot was taken of.
+func snapshotOf(dataset *zfs.Dataset) (string, bool) {
+	parts := strings.SplitN(dataset.Name, "@", 2)
+	if len(parts) != 2 {
+		return "", false
+	}
+	return parts[0], true
+}
+
+// datasetSnapshots converts zfs snapshots for a dataset heartbeat, oldest
+// first.
+func datasetSnapshots(snapshots []*zfs.Dataset) []clusterconf.DatasetSnapshot {
+	sort.Sort(snapshotsByTxg(snapshots))
+	result := make([]clusterconf.DatasetSnapshot, 0, len(snapshots))
+	for _, snapshot := range snapshots {
+		datasetSnapshot := clusterconf.DatasetSnapshot{
+			Name: strings.SplitN(snapshot.Name, "@", 2)[1],
+		}
+		if props := snapshot.Properties; props != nil {
+			datasetSnapshot.GUID = props.GUID
+			datasetSnapshot.Created = time.Unix(int64(props.Creation), 0).UTC()
+			datasetSnapshot.Used = props.Used
+			datasetSnapshot.Referenced = props.Referenced
+		}
+		result = append(result, datasetSnapshot)
+	}
+	return result
+}
+
+type snapshotsByTxg []*zfs.Dataset
+
+func (s snapshotsByTxg) Len() int      { return len(s) }
+func (s snapshotsByTxg) Swap(i, j int) { s[i], s[j] = s[j], s[i] }
+func (s snapshotsByTxg) Less(i, j int) bool {
+	if s[i].Properties == nil || s[j].Properties == nil {
+		return s[i].Name < s[j].Name
+	}
+	return s[i].Properties.CreateTxg < s[j].Properties.CreateTxg
+}
+
 func sendDatasetHeartbeats(config *Config, tracker *acomm.Tracker, datasetArgs []clusterconf.DatasetHeartbeatArgs, ip net.IP) error {
 	var errored bool
 	multiRequest := acomm.NewMultiRequest(tracker, config.RequestTimeout())
@@ -2,6 +2,7 @@ package main
 
 import (
 	"net"
+	"time"
 
 	"github.com/cerana/cerana/providers/clusterconf"
 	zfsp "github.com/cerana/cerana/providers/zfs"
@@ -20,6 +21,10 @@ func (s *DatasetHeartbeat) TestGetDatasets() {
 		{"base stripping", []string{"base/asdf"}, []clusterconf.DatasetHeartbeatArgs{{ID: "asdf"}}},
 		{"base stripping", []string{"foobar", "foobar/asdf"}, []clusterconf.DatasetHeartbeatArgs{{ID: "asdf"}}},
 		{"in use", []string{"useddataset"}, []clusterconf.DatasetHeartbeatArgs{{ID: "useddataset", InUse: true}}},
+		{"snapshots", []string{"asdf", "asdf@snap"}, []clusterconf.DatasetHeartbeatArgs{{
+			ID:        "asdf",
+			Snapshots: []clusterconf.DatasetSnapshot{{Name: "snap", Created: time.Unix(0, 0).UTC()}},
+		}}},
 	}
 
 	for _, test := range tests {
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"time"
+
+	"github.com/cerana/cerana/pkg/errors"
+	"github.com/cerana/cerana/tick"
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+)
+
+// Config contains configuration required for the dataset replicator tick.
+type Config struct {
+	*tick.Config
+	flagSet *pflag.FlagSet
+	viper   *viper.Viper
+}
+
+// ConfigData defines the structure of the config data (e.g. in the config file).
+type ConfigData struct {
+	tick.ConfigData
+	DatasetPrefix       string `json:"datasetPrefix"`
+	NodeCoordinatorPort uint   `json:"nodeCoordinatorPort"`
+	TransferTimeout     string `json:"transferTimeout"`
+}
+
+// NewConfig creates a new instance of Config.
+func NewConfig(flagSet *pflag.FlagSet, v *viper.Viper) *Config {
+	if flagSet == nil {
+		flagSet = pflag.CommandLine
+	}
+
+	if v == nil {
+		v = viper.New()
+	}
+
+	config := &Config{
+		Config:  tick.NewConfig(flagSet, v),
+		flagSet: flagSet,
+		viper:   v,
+	}
+	config.flagSet.StringP("datasetPrefix", "a", "", "dataset directory")
+	config.flagSet.UintP("nodeCoordinatorPort", "p", 0, "port that node coordinators are running on")
+	config.flagSet.DurationP("transferTimeout", "x", time.Hour, "timeout for a dataset transfer")
+
+	return config
+}
+
+// LoadConfig loads and validates the config.
+func (c *Config) LoadConfig() error {
+	if err := c.Config.LoadConfig(); err != nil {
+		return err
+	}
+
+	return c.Validate()
+}
+
+// DatasetPrefix returns the prefix under which cluster datasets are stored.
+func (c *Config) DatasetPrefix() string {
+	return c.viper.GetString("datasetPrefix")
+}
+
+// NodeCoordinatorPort returns the port that node coordinators are running on.
+func (c *Config) NodeCoordinatorPort() uint {
+	return uint(c.viper.GetInt("nodeCoordinatorPort"))
+}
+
+// TransferTimeout returns how long a dataset transfer may take. Transfers
+// still in flight for longer are considered abandoned.
+func (c *Config) TransferTimeout() time.Duration {
+	return c.viper.GetDuration("transferTimeout")
+}
+
+// Validate ensures the configuration is valid.
+func (c *Config) Validate() error {
+	if err := c.Config.Validate(); err != nil {
+		return err
+	}
+	if c.DatasetPrefix() == "" {
+		return errors.New("missing datasetPrefix")
+	}
+	if c.NodeCoordinatorPort() == 0 {
+		return errors.New("missing nodeCoordinatorPort")
+	}
+	if c.TransferTimeout() <= 0 {
+		return errors.New("transferTimeout must be greater than 0")
+	}
+
+	return nil
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"strconv"
+
+	"github.com/cerana/cerana/tick"
+	"github.com/pborman/uuid"
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+)
+
+func (s *DatasetReplicator) TestValidate() {
+	u := "unix:///tmp/foobar"
+	tests := []struct {
+		desc            string
+		datasetPrefix   string
+		port            uint
+		transferTimeout string
+		expectedErr     string
+	}{
+		{"valid", "foobar", 1234, "1h", ""},
+		{"missing prefix", "", 1234, "1h", "missing datasetPrefix"},
+		{"missing port", "foobar", 0, "1h", "missing nodeCoordinatorPort"},
+		{"missing transfer timeout", "foobar", 1234, "0s", "transferTimeout must be greater than 0"},
+	}
+
+	for _, test := range tests {
+		configData := &ConfigData{
+			ConfigData: tick.ConfigData{
+				NodeDataURL:       u,
+				ClusterDataURL:    u,
+				RequestTimeout:    "5s",
+				TickInterval:      "4s",
+				TickRetryInterval: "3s",
+			},
+			DatasetPrefix:       test.datasetPrefix,
+			NodeCoordinatorPort: test.port,
+			TransferTimeout:     test.transferTimeout,
+		}
+
+		config, fs, v, _, err := newTestConfig(true, false, configData)
+		if !s.NoError(err, test.desc) {
+			continue
+		}
+		// Bind here to avoid the need for Load
+		s.Require().NoError(v.BindPFlags(fs), test.desc)
+
+		err = config.Validate()
+		if test.expectedErr != "" {
+			s.Contains(err.Error(), test.expectedErr, test.desc)
+		} else {
+			s.NoError(err, test.desc)
+		}
+	}
+}
+
+func (s *DatasetReplicator) TestDatasetPrefix() {
+	s.EqualValues(s.configData.DatasetPrefix, s.config.DatasetPrefix())
+}
+
+func (s *DatasetReplicator) TestNodeCoordinatorPort() {
+	s.EqualValues(s.configData.NodeCoordinatorPort, s.config.NodeCoordinatorPort())
+}
+
+func (s *DatasetReplicator) TestTransferTimeout() {
+	s.Equal(s.configData.TransferTimeout, s.config.TransferTimeout().String())
+}
+
+func newTestConfig(setFlags, writeConfig bool, configData *ConfigData) (*Config, *pflag.FlagSet, *viper.Viper, *os.File, error) {
+	fs := pflag.NewFlagSet(uuid.New(), pflag.ExitOnError)
+	v := viper.New()
+	v.SetConfigType("json")
+	config := NewConfig(fs, v)
+	if config == nil {
+		return nil, nil, nil, nil, errors.New("failed to return a config")
+	}
+
+	var configFile *os.File
+	if writeConfig {
+		var err error
+		configFile, err = ioutil.TempFile("", "datasetReplicator-")
+		if err != nil {
+			return nil, nil, nil, nil, err
+		}
+		defer func() { _ = configFile.Close() }()
+
+		configJSON, _ := json.Marshal(configData)
+		if _, err := configFile.Write(configJSON); err != nil {
+			return nil, nil, nil, configFile, err
+		}
+
+		if err := fs.Set("configFile", configFile.Name()); err != nil {
+			return nil, nil, nil, configFile, err
+		}
+	}
+
+	if err := fs.Parse([]string{}); err != nil {
+		return nil, nil, nil, nil, err
+	}
+
+	if setFlags {
+		if err := fs.Set("nodeDataURL", configData.NodeDataURL); err != nil {
+			return nil, nil, nil, configFile, err
+		}
+		if err := fs.Set("clusterDataURL", configData.ClusterDataURL); err != nil {
+			return nil, nil, nil, configFile, err
+		}
+		if err := fs.Set("logLevel", configData.LogLevel); err != nil {
+			return nil, nil, nil, configFile, err
+		}
+		if err := fs.Set("requestTimeout", configData.RequestTimeout); err != nil {
+			return nil, nil, nil, configFile, err
+		}
+		if err := fs.Set("tickInterval", configData.TickInterval); err != nil {
+			return nil, nil, nil, configFile, err
+		}
+		if err := fs.Set("tickRetryInterval", configData.TickRetryInterval); err != nil {
+			return nil, nil, nil, configFile, err
+		}
+		if err := fs.Set("datasetPrefix", configData.DatasetPrefix); err != nil {
+			return nil, nil, nil, configFile, err
+		}
+		if err := fs.Set("nodeCoordinatorPort", strconv.FormatUint(uint64(configData.NodeCoordinatorPort), 10)); err != nil {
+			return nil, nil, nil, configFile, err
+		}
+		if err := fs.Set("transferTimeout", configData.TransferTimeout); err != nil {
+			return nil, nil, nil, configFile, err
+		}
+	}
+
+	return config, fs, v, configFile, nil
+}
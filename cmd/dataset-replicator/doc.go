@@ -0,0 +1,32 @@
+/*
+dataset-replicator periodically copies datasets to additional nodes until
+heartbeats show as many copies as each dataset's redundancy. New copies are
+sent in full to live, uncordoned nodes with enough free disk for the dataset's
+quota, preferring those with the most free disk. Copies missing the latest
+snapshot are caught up with an incremental stream from the latest snapshot they
+hold. The source of each stream is the node chosen by clusterconf's
+find-snapshot-node, which prefers nodes not using the dataset, and the stream is
+sent with zfs-send and zfs-receive through the node coordinators.
+
+The replication status of every dataset is saved in clusterconf, where it can be
+retrieved with get-dataset-replication and list-dataset-replications. Transfers
+are recorded in the status while in flight, and a target with a transfer in
+flight is skipped until it finishes or exceeds the transfer timeout.
+
+Usage:
+
+	$ dataset-replicator -h
+	Usage of dataset-replicator:
+	-u, --clusterDataURL string        url of coordinator for the cluster information
+	-c, --configFile string            path to config file
+	-a, --datasetPrefix string         dataset directory
+	-l, --logLevel string              log level: debug/info/warn/error/fatal/panic (default "warning")
+	-p, --nodeCoordinatorPort uint     port that node coordinators are running on
+	-n, --nodeDataURL string           url of coordinator for node information retrieval
+	-r, --requestTimeout duration      default timeout for external requests made
+	-t, --tickInterval duration        tick run frequency
+	-i, --tickRetryInterval duration   tick retry on error frequency
+	-x, --transferTimeout duration     timeout for a dataset transfer (default 1h0m0s)
+	Note: Long flag names can be specified in either fooBar or foo[_-.]bar form.
+*/
+package main
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"os"
+	"testing"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/cerana/cerana/acomm"
+	"github.com/cerana/cerana/pkg/test"
+	"github.com/cerana/cerana/provider"
+	"github.com/cerana/cerana/providers/clusterconf"
+	"github.com/cerana/cerana/providers/zfs"
+	"github.com/cerana/cerana/tick"
+	"github.com/spf13/pflag"
+	"github.com/stretchr/testify/suite"
+)
+
+type DatasetReplicator struct {
+	suite.Suite
+	config      *Config
+	configData  *ConfigData
+	configFile  *os.File
+	tracker     *acomm.Tracker
+	coordinator *test.Coordinator
+	// nodeCoordinator serves as the coordinator of every node.
+	nodeCoordinator *test.Coordinator
+	zfs             *zfs.MockZFS
+	clusterConf     *clusterconf.MockClusterConf
+}
+
+func TestDatasetReplicator(t *testing.T) {
+	suite.Run(t, new(DatasetReplicator))
+}
+
+func (s *DatasetReplicator) SetupSuite() {
+	noError := s.Require().NoError
+
+	logrus.SetLevel(logrus.FatalLevel)
+
+	// Setup mock coordinators
+	var err error
+	s.coordinator, err = test.NewCoordinator("")
+	noError(err)
+	s.nodeCoordinator, err = test.NewCoordinator("")
+	noError(err)
+
+	coordinatorURL := s.coordinator.NewProviderViper().GetString("coordinator_url")
+	s.configData = &ConfigData{
+		ConfigData: tick.ConfigData{
+			NodeDataURL:       coordinatorURL,
+			ClusterDataURL:    coordinatorURL,
+			LogLevel:          "fatal",
+			RequestTimeout:    "5s",
+			TickInterval:      "4s",
+			TickRetryInterval: "4s",
+		},
+		DatasetPrefix:       "data/datasets",
+		NodeCoordinatorPort: uint(s.nodeCoordinator.HTTPPort),
+		TransferTimeout:     "10s",
+	}
+
+	s.config, _, _, s.configFile, err = newTestConfig(false, true, s.configData)
+	noError(err, "failed to create config")
+	noError(s.config.LoadConfig(), "failed to load config")
+
+	tracker, err := acomm.NewTracker("", nil, nil, s.config.RequestTimeout())
+	noError(err)
+	s.tracker = tracker
+	noError(s.tracker.Start())
+
+	// Setup mock providers
+	s.setupZFS()
+	s.setupClusterConf()
+
+	noError(s.nodeCoordinator.Start())
+	noError(s.coordinator.Start())
+}
+
+func (s *DatasetReplicator) setupClusterConf() {
+	s.clusterConf = clusterconf.NewMockClusterConf()
+	s.coordinator.RegisterProvider(s.clusterConf)
+}
+
+func (s *DatasetReplicator) setupZFS() {
+	v := s.nodeCoordinator.NewProviderViper()
+	flagset := pflag.NewFlagSet("zfs", pflag.PanicOnError)
+	config := provider.NewConfig(flagset, v)
+	s.Require().NoError(flagset.Parse([]string{}))
+	s.Require().NoError(config.LoadConfig())
+	s.zfs = zfs.NewMockZFS(config, s.nodeCoordinator.ProviderTracker())
+	s.nodeCoordinator.RegisterProvider(s.zfs)
+}
+
+func (s *DatasetReplicator) TearDownSuite() {
+	s.coordinator.Stop()
+	s.Require().NoError(s.coordinator.Cleanup())
+	s.nodeCoordinator.Stop()
+	s.Require().NoError(s.nodeCoordinator.Cleanup())
+	_ = os.Remove(s.configFile.Name())
+	s.tracker.Stop()
+}
@@ -0,0 +1,427 @@
+package main
+
+import (
+	"net"
+	"net/url"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/cerana/cerana/acomm"
+	"github.com/cerana/cerana/pkg/errors"
+	"github.com/cerana/cerana/providers/clusterconf"
+	"github.com/cerana/cerana/providers/zfs"
+	"github.com/cerana/cerana/tick"
+	"golang.org/x/net/context"
+)
+
+// transfer is a zfs stream to send to a node to add or catch up a copy of a
+// dataset.
+type transfer struct {
+	DatasetID string
+	Target    string
+	Snapshot  string
+	// FromSnap is the latest snapshot held by the target for an
+	// incremental transfer. Full transfers have none.
+	FromSnap string
+	// Exclude are the nodes holding Snapshot that can't be the source
+	// because they don't hold FromSnap.
+	Exclude []net.IP
+}
+
+func (t *transfer) name() string {
+	return t.DatasetID + ":" + t.Target
+}
+
+func (t *transfer) fields() logrus.Fields {
+	return logrus.Fields{
+		"datasetID": t.DatasetID,
+		"target":    t.Target,
+		"snapshot":  t.Snapshot,
+		"fromSnap":  t.FromSnap,
+	}
+}
+
+// replicationData is the cluster state replications are planned from.
+type replicationData struct {
+	datasets     []*clusterconf.Dataset
+	heartbeats   map[string]map[string]clusterconf.DatasetHeartbeat
+	catalogs     map[string][]*clusterconf.CatalogSnapshot
+	nodes        []clusterconf.Node
+	configs      map[string]*clusterconf.NodeConfig
+	replications map[string]*clusterconf.DatasetReplication
+}
+
+type byFreeDisk []clusterconf.Node
+
+func (b byFreeDisk) Len() int      { return len(b) }
+func (b byFreeDisk) Swap(i, j int) { b[i], b[j] = b[j], b[i] }
+func (b byFreeDisk) Less(i, j int) bool {
+	if b[i].DiskFree == b[j].DiskFree {
+		return b[i].ID < b[j].ID
+	}
+	return b[i].DiskFree > b[j].DiskFree
+}
+
+type datasetsByID []*clusterconf.Dataset
+
+func (d datasetsByID) Len() int           { return len(d) }
+func (d datasetsByID) Swap(i, j int)      { d[i], d[j] = d[j], d[i] }
+func (d datasetsByID) Less(i, j int) bool { return d[i].ID < d[j].ID }
+
+// plan computes the replication status of a dataset and the transfers needed
+// for it to reach its redundancy. Copies missing the latest snapshot are
+// caught up incrementally from the latest snapshot they hold. Additional
+// copies are sent in full to live, uncordoned nodes with enough free disk for
+// the dataset's quota, preferring those with the most free disk.
+func plan(dataset *clusterconf.Dataset, heartbeats map[string]clusterconf.DatasetHeartbeat, catalog []*clusterconf.CatalogSnapshot, nodes []clusterconf.Node, configs map[string]*clusterconf.NodeConfig) (*clusterconf.DatasetReplication, []*transfer) {
+	status := &clusterconf.DatasetReplication{
+		DatasetID:  dataset.ID,
+		Redundancy: redundancy(dataset),
+		Nodes:      make([]string, 0, len(heartbeats)),
+		Stale:      []string{},
+	}
+	for ip := range heartbeats {
+		status.Nodes = append(status.Nodes, ip)
+	}
+	sort.Strings(status.Nodes)
+
+	if len(status.Nodes) == 0 {
+		status.State = clusterconf.ReplicationDegraded
+		status.Error = "no node holds the dataset"
+		return status, nil
+	}
+	if len(catalog) == 0 {
+		status.State = clusterconf.ReplicationNoSnapshot
+		return status, nil
+	}
+	latest := catalog[len(catalog)-1]
+	status.Snapshot = latest.Name
+
+	transfers := make([]*transfer, 0)
+	problems := make([]string, 0)
+	for _, nodeID := range status.Nodes {
+		if holds(latest, nodeID) {
+			continue
+		}
+		status.Stale = append(status.Stale, nodeID)
+
+		from := latestHeld(catalog, nodeID)
+		if from == nil {
+			problems = append(problems, "node "+nodeID+" has no snapshot in common")
+			continue
+		}
+		exclude := make([]net.IP, 0)
+		for _, ip := range latest.Nodes {
+			if !holds(from, ip.String()) {
+				exclude = append(exclude, ip)
+			}
+		}
+		transfers = append(transfers, &transfer{
+			DatasetID: dataset.ID,
+			Target:    nodeID,
+			Snapshot:  latest.Name,
+			FromSnap:  from.Name,
+			Exclude:   exclude,
+		})
+	}
+
+	if needed := int(status.Redundancy) - len(status.Nodes); needed > 0 {
+		candidates := make(byFreeDisk, 0, len(nodes))
+		for _, node := range nodes {
+			if _, ok := heartbeats[node.ID]; ok {
+				continue
+			}
+			if config := configs[node.ID]; config != nil && config.Cordoned {
+				continue
+			}
+			if node.DiskFree < dataset.Quota {
+				continue
+			}
+			candidates = append(candidates, node)
+		}
+		sort.Sort(candidates)
+
+		for _, node := range candidates {
+			if needed == 0 {
+				break
+			}
+			transfers = append(transfers, &transfer{
+				DatasetID: dataset.ID,
+				Target:    node.ID,
+				Snapshot:  latest.Name,
+			})
+			needed--
+		}
+		if needed > 0 {
+			problems = append(problems, "insufficient nodes for dataset redundancy")
+		}
+	}
+
+	switch {
+	case len(problems) > 0:
+		status.State = clusterconf.ReplicationDegraded
+		status.Error = strings.Join(problems, "; ")
+	case len(transfers) > 0:
+		status.State = clusterconf.ReplicationReplicating
+	default:
+		status.State = clusterconf.ReplicationSynced
+	}
+	return status, transfers
+}
+
+// redundancy returns the number of copies a dataset should have. Every
+// dataset has at least one copy.
+func redundancy(dataset *clusterconf.Dataset) uint64 {
+	if dataset.Redundancy == 0 {
+		return 1
+	}
+	return dataset.Redundancy
+}
+
+func holds(snapshot *clusterconf.CatalogSnapshot, nodeID string) bool {
+	for _, ip := range snapshot.Nodes {
+		if ip.String() == nodeID {
+			return true
+		}
+	}
+	return false
+}
+
+// latestHeld returns the latest snapshot in the catalog held by a node.
+func latestHeld(catalog []*clusterconf.CatalogSnapshot, nodeID string) *clusterconf.CatalogSnapshot {
+	for i := len(catalog) - 1; i >= 0; i-- {
+		if holds(catalog[i], nodeID) {
+			return catalog[i]
+		}
+	}
+	return nil
+}
+
+// replicateDatasets is the tick function that copies datasets to additional
+// nodes until they reach their redundancy, catches up stale copies, and saves
+// the replication status of each dataset.
+func replicateDatasets(config tick.Configer, tracker *acomm.Tracker) error {
+	conf, ok := config.(*Config)
+	if !ok {
+		return errors.New("not the right type of config")
+	}
+
+	client := clusterconf.NewClient(tracker, conf.ClusterDataURL())
+	data, err := getReplicationData(client, conf.RequestTimeout())
+	if err != nil {
+		return err
+	}
+
+	errs := make(map[string]error)
+	for _, dataset := range data.datasets {
+		current := data.replications[dataset.ID]
+		heartbeats := data.heartbeats[dataset.ID]
+		status, transfers := plan(dataset, heartbeats, data.catalogs[dataset.ID], data.nodes, data.configs)
+		if status.State == clusterconf.ReplicationDegraded {
+			logrus.WithFields(logrus.Fields{
+				"datasetID": dataset.ID,
+				"error":     status.Error,
+			}).Warn("dataset not fully replicated")
+		}
+
+		transfers = claimTransfers(current, status, transfers, time.Now(), conf.TransferTimeout())
+		if len(transfers) > 0 {
+			// Saving the claimed transfers fails if another replicator
+			// updated the status in the meantime
+			saved, err := saveStatus(client, conf.RequestTimeout(), current, status)
+			if err != nil {
+				errs[dataset.ID] = err
+				continue
+			}
+			current = saved
+		}
+
+		failed := make([]string, 0)
+		for _, t := range transfers {
+			err := runTransfer(conf, tracker, t)
+			delete(status.Transfers, t.Target)
+			if err != nil {
+				errs[t.name()] = err
+				failed = append(failed, t.Target)
+				continue
+			}
+			logrus.WithFields(t.fields()).Info("dataset transferred")
+		}
+		if len(failed) > 0 {
+			problem := "transfer to nodes " + strings.Join(failed, ", ") + " failed"
+			if status.Error != "" {
+				problem = status.Error + "; " + problem
+			}
+			status.State = clusterconf.ReplicationDegraded
+			status.Error = problem
+		}
+
+		if _, err := saveStatus(client, conf.RequestTimeout(), current, status); err != nil {
+			errs[dataset.ID] = err
+		}
+	}
+
+	if len(errs) > 0 {
+		return errors.Newv("one or more dataset replications unsuccessful", map[string]interface{}{"errors": errs})
+	}
+	return nil
+}
+
+// claimTransfers records the transfers in the status as in flight and returns
+// the ones to start. Targets with a transfer already in flight, possibly from
+// another replicator, are skipped until it finishes or exceeds the transfer
+// timeout.
+func claimTransfers(current, status *clusterconf.DatasetReplication, transfers []*transfer, now time.Time, timeout time.Duration) []*transfer {
+	status.Transfers = make(map[string]time.Time)
+	start := make([]*transfer, 0, len(transfers))
+	for _, t := range transfers {
+		if current != nil {
+			if started, ok := current.Transfers[t.Target]; ok && now.Sub(started) < timeout {
+				logrus.WithFields(t.fields()).Debug("dataset transfer already in flight")
+				status.Transfers[t.Target] = started
+				continue
+			}
+		}
+		status.Transfers[t.Target] = now
+		start = append(start, t)
+	}
+	return start
+}
+
+// getReplicationData retrieves the datasets, their heartbeats and snapshot
+// catalogs, live nodes, node configs keyed by node id, and the current
+// replication statuses keyed by dataset id.
+func getReplicationData(client *clusterconf.Client, timeout time.Duration) (*replicationData, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	datasets, err := client.ListDatasets(ctx, clusterconf.ListDatasetsArgs{})
+	if err != nil {
+		return nil, err
+	}
+	heartbeats, err := client.ListDatasetHeartbeats(ctx)
+	if err != nil {
+		return nil, err
+	}
+	nodes, err := client.ListNodes(ctx)
+	if err != nil {
+		return nil, err
+	}
+	nodeConfigs, err := client.ListNodeConfigs(ctx)
+	if err != nil {
+		return nil, err
+	}
+	replications, err := client.ListDatasetReplications(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	data := &replicationData{
+		datasets:     datasets.Datasets,
+		heartbeats:   heartbeats.Heartbeats,
+		catalogs:     make(map[string][]*clusterconf.CatalogSnapshot),
+		nodes:        nodes.Nodes,
+		configs:      make(map[string]*clusterconf.NodeConfig, len(nodeConfigs.NodeConfigs)),
+		replications: make(map[string]*clusterconf.DatasetReplication, len(replications.Replications)),
+	}
+	sort.Sort(datasetsByID(data.datasets))
+	for _, config := range nodeConfigs.NodeConfigs {
+		data.configs[config.ID] = config
+	}
+	for _, replication := range replications.Replications {
+		data.replications[replication.DatasetID] = replication
+	}
+	for _, dataset := range data.datasets {
+		if len(data.heartbeats[dataset.ID]) == 0 {
+			continue
+		}
+		catalog, err := client.ListDatasetSnapshots(ctx, clusterconf.IDArgs{ID: dataset.ID})
+		if err != nil {
+			return nil, err
+		}
+		data.catalogs[dataset.ID] = catalog.Snapshots
+	}
+	return data, nil
+}
+
+// runTransfer sends a snapshot of a dataset from the best node holding it to
+// the target node. Receiving the stream may take up to the transfer timeout.
+func runTransfer(config *Config, tracker *acomm.Tracker, t *transfer) error {
+	ctx, cancel := context.WithTimeout(context.Background(), config.RequestTimeout())
+	defer cancel()
+
+	client := clusterconf.NewClient(tracker, config.ClusterDataURL())
+	source, err := client.FindSnapshotNode(ctx, clusterconf.FindSnapshotNodeArgs{
+		ID:       t.DatasetID,
+		Snapshot: t.Snapshot,
+		Exclude:  t.Exclude,
+	})
+	if err != nil {
+		return err
+	}
+
+	name := filepath.Join(config.DatasetPrefix(), t.DatasetID)
+	sendArgs := zfs.SendArgs{Name: name + "@" + t.Snapshot}
+	if t.FromSnap != "" {
+		sendArgs.FromSnap = name + "@" + t.FromSnap
+	}
+	resp, err := nodeRequest(config, tracker, source.IP.String(), acomm.RequestOptions{
+		Task: "zfs-send",
+		Args: sendArgs,
+	}, config.RequestTimeout())
+	if err != nil {
+		return err
+	}
+	if resp.StreamURL == nil {
+		return errors.Newv("missing zfs-send stream url", map[string]interface{}{"transfer": t})
+	}
+
+	_, err = nodeRequest(config, tracker, t.Target, acomm.RequestOptions{
+		Task:      "zfs-receive",
+		StreamURL: resp.StreamURL,
+		Args:      zfs.CommonArgs{Name: name},
+	}, config.TransferTimeout())
+	return err
+}
+
+// nodeRequest makes a request to the coordinator of a node.
+func nodeRequest(config *Config, tracker *acomm.Tracker, nodeID string, opts acomm.RequestOptions, timeout time.Duration) (*acomm.Response, error) {
+	host := net.JoinHostPort(nodeID, strconv.FormatUint(uint64(config.NodeCoordinatorPort()), 10))
+	taskURL, err := url.ParseRequestURI("http://" + host)
+	if err != nil {
+		return nil, errors.Wrapv(err, map[string]interface{}{"nodeID": nodeID}, "failed to generate taskURL")
+	}
+	opts.TaskURL = taskURL
+	return tracker.SyncRequest(config.NodeDataURL(), opts, timeout)
+}
+
+// saveStatus saves the replication status of a dataset if it changed and
+// returns the saved status.
+func saveStatus(client *clusterconf.Client, timeout time.Duration, current, status *clusterconf.DatasetReplication) (*clusterconf.DatasetReplication, error) {
+	if current != nil {
+		if current.State == status.State &&
+			current.Redundancy == status.Redundancy &&
+			current.Snapshot == status.Snapshot &&
+			current.Error == status.Error &&
+			reflect.DeepEqual(current.Nodes, status.Nodes) &&
+			reflect.DeepEqual(current.Stale, status.Stale) &&
+			(len(current.Transfers) == 0 && len(status.Transfers) == 0 || reflect.DeepEqual(current.Transfers, status.Transfers)) {
+			return current, nil
+		}
+		status.ModIndex = current.ModIndex
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	result, err := client.UpdateDatasetReplication(ctx, clusterconf.DatasetReplicationPayload{Replication: status})
+	if err != nil {
+		return nil, err
+	}
+	return result.Replication, nil
+}
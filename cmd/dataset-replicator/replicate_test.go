@@ -0,0 +1,173 @@
+package main
+
+import (
+	"net"
+	"time"
+
+	"github.com/cerana/cerana/providers/clusterconf"
+)
+
+func (s *DatasetReplicator) TestPlan() {
+	nodeIDs := []string{"127.0.0.1", "127.0.0.2", "127.0.0.3", "127.0.0.4"}
+	nodes := []clusterconf.Node{
+		{ID: nodeIDs[0], DiskFree: 40},
+		{ID: nodeIDs[1], DiskFree: 10},
+		{ID: nodeIDs[2], DiskFree: 20},
+		{ID: nodeIDs[3], DiskFree: 30},
+	}
+	configs := map[string]*clusterconf.NodeConfig{
+		nodeIDs[3]: {ID: nodeIDs[3], Cordoned: true},
+	}
+
+	tests := []struct {
+		desc       string
+		redundancy uint64
+		quota      uint64
+		holders    []string
+		catalog    []*clusterconf.CatalogSnapshot
+		state      clusterconf.ReplicationState
+		stale      []string
+		err        string
+		transfers  []transfer
+	}{
+		{"no copies", 2, 0, nil, nil, clusterconf.ReplicationDegraded, []string{}, "no node holds the dataset", nil},
+		{"no snapshots", 2, 0, nodeIDs[:1], nil, clusterconf.ReplicationNoSnapshot, []string{}, "", nil},
+		{"synced", 2, 0, nodeIDs[:2],
+			[]*clusterconf.CatalogSnapshot{snapshot("s1", 0, nodeIDs[:2]...)},
+			clusterconf.ReplicationSynced, []string{}, "", nil},
+		{"over-replicated", 1, 0, nodeIDs[:2],
+			[]*clusterconf.CatalogSnapshot{snapshot("s1", 0, nodeIDs[:2]...)},
+			clusterconf.ReplicationSynced, []string{}, "", nil},
+		{"additional copies", 3, 0, nodeIDs[:1],
+			[]*clusterconf.CatalogSnapshot{snapshot("s1", 0, nodeIDs[0])},
+			clusterconf.ReplicationReplicating, []string{}, "", []transfer{
+				{Target: nodeIDs[2], Snapshot: "s1"},
+				{Target: nodeIDs[1], Snapshot: "s1"},
+			}},
+		{"insufficient disk", 3, 15, nodeIDs[:1],
+			[]*clusterconf.CatalogSnapshot{snapshot("s1", 0, nodeIDs[0])},
+			clusterconf.ReplicationDegraded, []string{}, "insufficient nodes for dataset redundancy", []transfer{
+				{Target: nodeIDs[2], Snapshot: "s1"},
+			}},
+		{"stale copy", 2, 0, nodeIDs[:2],
+			[]*clusterconf.CatalogSnapshot{snapshot("s1", 0, nodeIDs[:2]...), snapshot("s2", 1, nodeIDs[0])},
+			clusterconf.ReplicationReplicating, nodeIDs[1:2], "", []transfer{
+				{Target: nodeIDs[1], Snapshot: "s2", FromSnap: "s1", Exclude: []net.IP{}},
+			}},
+		{"stale copy without source", 3, 0, nodeIDs[:3],
+			[]*clusterconf.CatalogSnapshot{snapshot("s1", 0, nodeIDs[:2]...), snapshot("s2", 1, nodeIDs[0], nodeIDs[2])},
+			clusterconf.ReplicationReplicating, nodeIDs[1:2], "", []transfer{
+				{Target: nodeIDs[1], Snapshot: "s2", FromSnap: "s1", Exclude: []net.IP{net.ParseIP(nodeIDs[2])}},
+			}},
+		{"no common snapshot", 2, 0, nodeIDs[:2],
+			[]*clusterconf.CatalogSnapshot{snapshot("s1", 0, nodeIDs[0])},
+			clusterconf.ReplicationDegraded, nodeIDs[1:2], "has no snapshot in common", nil},
+	}
+
+	for _, test := range tests {
+		dataset := &clusterconf.Dataset{ID: "foo", Redundancy: test.redundancy, Quota: test.quota}
+		heartbeats := make(map[string]clusterconf.DatasetHeartbeat)
+		for _, nodeID := range test.holders {
+			heartbeats[nodeID] = clusterconf.DatasetHeartbeat{IP: net.ParseIP(nodeID)}
+		}
+
+		status, transfers := plan(dataset, heartbeats, test.catalog, nodes, configs)
+		s.Equal(test.state, status.State, test.desc)
+		s.Equal(test.stale, status.Stale, test.desc)
+		if test.err != "" {
+			s.Contains(status.Error, test.err, test.desc)
+		} else {
+			s.Empty(status.Error, test.desc)
+		}
+		if !s.Len(transfers, len(test.transfers), test.desc) {
+			continue
+		}
+		for i, expected := range test.transfers {
+			expected.DatasetID = dataset.ID
+			s.Equal(&expected, transfers[i], test.desc)
+		}
+	}
+}
+
+func (s *DatasetReplicator) TestReplicateDatasets() {
+	id := "replicated"
+	source := net.ParseIP("127.0.0.1")
+	target := "127.0.0.2"
+	s.clusterConf.Data.Datasets[id] = &clusterconf.Dataset{ID: id, Redundancy: 2}
+	s.clusterConf.Data.DatasetsHB[id] = map[string]clusterconf.DatasetHeartbeat{
+		source.String(): {
+			IP:        source,
+			Snapshots: []clusterconf.DatasetSnapshot{{Name: "snap", GUID: 1, Created: time.Now()}},
+		},
+	}
+	s.clusterConf.Data.Nodes[source.String()] = &clusterconf.Node{ID: source.String()}
+	s.clusterConf.Data.Nodes[target] = &clusterconf.Node{ID: target}
+	s.zfs.Data.Data["data/datasets/replicated@snap"] = []byte("dataset data")
+
+	s.Require().NoError(replicateDatasets(s.config, s.tracker))
+	s.Equal("dataset data", string(s.zfs.Data.Data["data/datasets/replicated"]))
+
+	replication := s.clusterConf.Data.Replications[id]
+	if s.NotNil(replication) {
+		s.Equal(clusterconf.ReplicationReplicating, replication.State)
+		s.Equal([]string{source.String()}, replication.Nodes)
+		s.Equal("snap", replication.Snapshot)
+	}
+
+	// Once the target's heartbeat reports the snapshot, the dataset is synced
+	s.clusterConf.Data.DatasetsHB[id][target] = clusterconf.DatasetHeartbeat{
+		IP:        net.ParseIP(target),
+		Snapshots: s.clusterConf.Data.DatasetsHB[id][source.String()].Snapshots,
+	}
+	s.Require().NoError(replicateDatasets(s.config, s.tracker))
+	s.Equal(clusterconf.ReplicationSynced, s.clusterConf.Data.Replications[id].State)
+	s.Equal([]string{source.String(), target}, s.clusterConf.Data.Replications[id].Nodes)
+}
+
+func (s *DatasetReplicator) TestReplicateDatasetsInFlight() {
+	id := "inflight"
+	source := net.ParseIP("127.0.0.1")
+	target := "127.0.0.2"
+	s.clusterConf.Data.Datasets[id] = &clusterconf.Dataset{ID: id, Redundancy: 2}
+	s.clusterConf.Data.DatasetsHB[id] = map[string]clusterconf.DatasetHeartbeat{
+		source.String(): {
+			IP:        source,
+			Snapshots: []clusterconf.DatasetSnapshot{{Name: "snap", GUID: 1, Created: time.Now()}},
+		},
+	}
+	s.clusterConf.Data.Nodes[source.String()] = &clusterconf.Node{ID: source.String()}
+	s.clusterConf.Data.Nodes[target] = &clusterconf.Node{ID: target}
+	s.zfs.Data.Data["data/datasets/inflight@snap"] = []byte("dataset data")
+
+	// A transfer in flight to the target isn't started again
+	started := time.Now().Add(-time.Second).UTC()
+	s.clusterConf.Data.Replications[id] = &clusterconf.DatasetReplication{
+		DatasetID: id,
+		State:     clusterconf.ReplicationReplicating,
+		Transfers: map[string]time.Time{target: started},
+	}
+	s.Require().NoError(replicateDatasets(s.config, s.tracker))
+	_, ok := s.zfs.Data.Data["data/datasets/inflight"]
+	s.False(ok)
+	s.True(started.Equal(s.clusterConf.Data.Replications[id].Transfers[target]))
+
+	// Once it exceeds the transfer timeout, it is considered abandoned
+	s.clusterConf.Data.Replications[id].Transfers[target] = time.Now().Add(-s.config.TransferTimeout())
+	s.Require().NoError(replicateDatasets(s.config, s.tracker))
+	s.Equal("dataset data", string(s.zfs.Data.Data["data/datasets/inflight"]))
+	s.Empty(s.clusterConf.Data.Replications[id].Transfers)
+}
+
+func snapshot(name string, age time.Duration, nodeIDs ...string) *clusterconf.CatalogSnapshot {
+	snapshot := &clusterconf.CatalogSnapshot{
+		DatasetSnapshot: clusterconf.DatasetSnapshot{
+			Name:    name,
+			Created: time.Unix(0, 0).Add(age * time.Hour),
+		},
+		Nodes: make([]net.IP, 0, len(nodeIDs)),
+	}
+	for _, nodeID := range nodeIDs {
+		snapshot.Nodes = append(snapshot.Nodes, net.ParseIP(nodeID))
+	}
+	return snapshot
+}
@@ -2,19 +2,18 @@ package main
 
 import (
 	"net"
-	"net/url"
 	"os"
 	"strings"
 	"time"
 
 	"github.com/Sirupsen/logrus"
-	"github.com/cerana/cerana/acomm"
 	"github.com/cerana/cerana/pkg/logrusx"
 	"github.com/cerana/cerana/provider"
 	"github.com/cerana/cerana/providers/clusterconf"
 	"github.com/cerana/cerana/providers/dhcp"
 	"github.com/spf13/pflag"
 	"github.com/spf13/viper"
+	"golang.org/x/net/context"
 )
 
 func defaultNetwork() net.IPNet {
@@ -22,40 +21,6 @@ func defaultNetwork() net.IPNet {
 	return *net
 }
 
-func comm(tracker *acomm.Tracker, coordinator *url.URL, task string, args interface{}, resp interface{}) error {
-	ch := make(chan *acomm.Response, 1)
-	handler := func(_ *acomm.Request, resp *acomm.Response) {
-		ch <- resp
-	}
-
-	req, err := acomm.NewRequest(acomm.RequestOptions{
-		Task:           task,
-		Args:           args,
-		ResponseHook:   tracker.URL(),
-		SuccessHandler: handler,
-		ErrorHandler:   handler,
-	})
-	logrusx.DieOnError(err, "create request object")
-	logrusx.DieOnError(tracker.TrackRequest(req, 0), "track request")
-
-	if err = acomm.Send(coordinator, req); err != nil {
-		return err
-	}
-
-	aResp := <-ch
-	if aResp.Error != nil {
-		return aResp.Error
-	}
-
-	logrusx.DieOnError(aResp.UnmarshalResult(resp), "deserialize result")
-	return nil
-}
-
-func getDHCPConfig(tracker *acomm.Tracker, coordinator *url.URL) (*clusterconf.DHCPConfig, error) {
-	dconf := &clusterconf.DHCPConfig{}
-	return dconf, comm(tracker, coordinator, "get-dhcp-config", nil, dconf)
-}
-
 func joinDNS(dns []net.IP) string {
 	strs := make([]string, len(dns))
 	for i := range dns {
@@ -79,9 +44,10 @@ func main() {
 	logrusx.DieOnError(err, "create provider")
 	logrusx.DieOnError(server.Tracker().Start(), "start tracker")
 
-	var storedConfig *clusterconf.DHCPConfig
+	clusterConf := clusterconf.NewClient(server.Tracker(), config.CoordinatorURL())
+	var storedConfig clusterconf.DHCPConfig
 	for {
-		storedConfig, err = getDHCPConfig(server.Tracker(), config.CoordinatorURL())
+		storedConfig, err = clusterConf.GetDHCPConfig(context.Background())
 		if err == nil {
 			break
 		}
@@ -105,5 +71,6 @@ func main() {
 		os.Exit(1)
 	}
 	logrusx.DieOnError(server.Start(), "successfully run dhcp provider")
+	go server.ReloadOnSignal()
 	server.StopOnSignal()
 }
@@ -32,5 +32,6 @@ func main() {
 		os.Exit(1)
 	}
 	logrusx.DieOnError(server.Start(), "start server")
+	go server.ReloadOnSignal()
 	server.StopOnSignal()
 }
@@ -14,6 +14,7 @@ func main() {
 	config := service.NewConfig(nil, nil)
 	flag.StringP("rollback_clone_cmd", "r", "/run/current-system/sw/bin/rollback_clone", "full path to dataset clone/rollback tool")
 	flag.StringP("dataset_clone_dir", "d", "data/running-clones", "destination for dataset clones used by running services")
+	flag.StringP("secret_env_dir", "e", "/var/lib/cerana/service-secrets", "directory for environment files holding the secret env vars of services")
 	flag.Parse()
 
 	logrusx.DieOnError(config.LoadConfig(), "load config")
@@ -26,6 +27,7 @@ func main() {
 
 	if len(server.RegisteredTasks()) != 0 {
 		logrusx.DieOnError(server.Start(), "start server")
+		go server.ReloadOnSignal()
 		server.StopOnSignal()
 	} else {
 		logrus.Warn("no registered tasks, exiting")
@@ -24,6 +24,7 @@ func main() {
 
 	if len(server.RegisteredTasks()) != 0 {
 		logrusx.DieOnError(server.Start(), "start server")
+		go server.ReloadOnSignal()
 		server.StopOnSignal()
 	} else {
 		logrus.Warn("no registered tasks, exiting")
@@ -1,8 +1,12 @@
 package coordinator
 
 import (
+	"bytes"
+	"io/ioutil"
+	"strings"
 	"time"
 
+	"github.com/cerana/cerana/pkg/configutil"
 	"github.com/cerana/cerana/pkg/errors"
 	"github.com/cerana/cerana/pkg/logrusx"
 	flag "github.com/spf13/pflag"
@@ -11,8 +15,9 @@ import (
 
 // Config holds all configuration for the provider.
 type Config struct {
-	viper   *viper.Viper
-	flagSet *flag.FlagSet
+	viper    *viper.Viper
+	flagSet  *flag.FlagSet
+	fileData []byte
 }
 
 // ConfigData defines the structure of the config data (e.g. in the config file)
@@ -22,6 +27,8 @@ type ConfigData struct {
 	ExternalPort   uint   `json:"external_port"`
 	RequestTimeout uint   `json:"request_timeout"`
 	LogLevel       string `json:"log_level"`
+	DrainTimeout   uint   `json:"drain_timeout"`
+	LocalOnlyTasks string `json:"local_only_tasks"`
 }
 
 // NewConfig creates a new instance of Config. If a viper instance is not
@@ -41,6 +48,8 @@ func NewConfig(flagSet *flag.FlagSet, v *viper.Viper) *Config {
 	flagSet.UintP("external_port", "p", 8080, "port for the http external request server to listen")
 	flagSet.StringP("log_level", "l", "warning", "log level: debug/info/warn/error/fatal/panic")
 	flagSet.UintP("request_timeout", "t", 0, "default timeout for requests in seconds")
+	flagSet.Uint("drain_timeout", 0, "time in seconds to wait for in-flight requests and streams to finish when stopping on a signal")
+	flagSet.String("local_only_tasks", "resolve-secrets", "comma separated tasks returning sensitive data that are only accepted on the internal socket")
 
 	return &Config{
 		viper:   v,
@@ -59,14 +68,65 @@ func (c *Config) LoadConfig() error {
 		return c.Validate()
 	}
 
-	c.viper.SetConfigFile(filePath)
-	if err := c.viper.ReadInConfig(); err != nil {
-		return errors.Wrapv(err, map[string]interface{}{"configFile": filePath}, "failed to read config file")
+	fileData, err := c.readConfigFile(filePath)
+	if err != nil {
+		return err
 	}
+	c.fileData = fileData
 
 	return c.Validate()
 }
 
+// restartSettings are the settings that can't be changed by a config reload.
+var restartSettings = []string{"socket_dir", "service_name", "external_port"}
+
+// ReloadConfig rereads the config file and reports the settings that changed.
+// If the new config is invalid, the previous one is kept. Changes to settings
+// that require a restart are reported, but the running values are kept.
+func (c *Config) ReloadConfig() (*configutil.Changes, error) {
+	filePath := c.viper.GetString("config_file")
+	if filePath == "" {
+		return nil, errors.New("no config file to reload")
+	}
+
+	oldSettings := c.viper.AllSettings()
+	fileData, err := c.readConfigFile(filePath)
+	if err == nil {
+		err = c.Validate()
+	}
+	if err != nil {
+		// Restore the previously loaded config
+		_ = c.viper.ReadConfig(bytes.NewReader(c.fileData))
+		return nil, err
+	}
+	c.fileData = fileData
+
+	changes := configutil.Diff(oldSettings, c.viper.AllSettings(), restartSettings...)
+	// Keep the running values of settings that need a restart
+	for _, key := range restartSettings {
+		if value, ok := oldSettings[key]; ok {
+			c.viper.Set(key, value)
+		}
+	}
+
+	return changes, nil
+}
+
+// readConfigFile reads the config file into viper and returns the raw file
+// data.
+func (c *Config) readConfigFile(filePath string) ([]byte, error) {
+	fileData, err := ioutil.ReadFile(filePath)
+	if err != nil {
+		return nil, errors.Wrapv(err, map[string]interface{}{"configFile": filePath}, "failed to read config file")
+	}
+
+	c.viper.SetConfigFile(filePath)
+	if err := c.viper.ReadInConfig(); err != nil {
+		return nil, errors.Wrapv(err, map[string]interface{}{"configFile": filePath}, "failed to read config file")
+	}
+	return fileData, nil
+}
+
 // SocketDir returns the base directory for task sockets.
 func (c *Config) SocketDir() string {
 	return c.viper.GetString("socket_dir")
@@ -87,6 +147,25 @@ func (c *Config) RequestTimeout() time.Duration {
 	return time.Second * time.Duration(c.viper.GetInt("request_timeout"))
 }
 
+// DrainTimeout returns the maximum duration to wait for in-flight work to
+// finish when draining before a signaled stop.
+func (c *Config) DrainTimeout() time.Duration {
+	return time.Second * time.Duration(c.viper.GetInt("drain_timeout"))
+}
+
+// LocalOnlyTasks returns the tasks that return sensitive data, e.g. secret
+// values, and are only accepted on the internal socket.
+func (c *Config) LocalOnlyTasks() []string {
+	// Comma separated, since viper has trouble with StringSlice
+	tasks := make([]string, 0)
+	for _, task := range strings.Split(c.viper.GetString("local_only_tasks"), ",") {
+		if task = strings.TrimSpace(task); task != "" {
+			tasks = append(tasks, task)
+		}
+	}
+	return tasks
+}
+
 // Validate returns whether the config is valid, containing necessary values.
 func (c *Config) Validate() error {
 	if c.SocketDir() == "" {
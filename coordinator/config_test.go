@@ -104,6 +104,30 @@ func (s *ConfigSuite) TestLoadConfig() {
 	}
 }
 
+func (s *ConfigSuite) TestReloadConfig() {
+	configData := *s.configData
+	configData.LogLevel = "error"
+	configData.RequestTimeout = 10
+	configData.ExternalPort = 45679
+	configJSON, _ := json.Marshal(configData)
+	s.Require().NoError(ioutil.WriteFile(s.configFile.Name(), configJSON, 0644))
+
+	changes, err := s.config.ReloadConfig()
+	if !s.NoError(err, "failed to reload config") {
+		return
+	}
+	s.Equal([]string{"log_level", "request_timeout"}, changes.Applied)
+	s.Equal([]string{"external_port"}, changes.RestartRequired)
+	s.Equal(10*time.Second, s.config.RequestTimeout(), "should apply new timeout")
+	s.EqualValues(s.configData.ExternalPort, s.config.ExternalPort(), "should keep running port")
+
+	s.Require().NoError(ioutil.WriteFile(s.configFile.Name(), []byte(`{"request_timeout":`), 0644))
+
+	_, err = s.config.ReloadConfig()
+	s.Error(err, "should not reload malformed config")
+	s.Equal(10*time.Second, s.config.RequestTimeout(), "should keep previous config")
+}
+
 func (s *ConfigSuite) TestSocketDir() {
 	s.Equal(s.configData.SocketDir, s.config.SocketDir())
 }
@@ -120,6 +144,23 @@ func (s *ConfigSuite) TestRequestTimeout() {
 	s.EqualValues(s.configData.RequestTimeout, s.config.RequestTimeout()/time.Second)
 }
 
+func (s *ConfigSuite) TestLocalOnlyTasks() {
+	config, _, _, _, err := newConfig(true, false, s.configData)
+	s.Require().NoError(err)
+	s.Require().NoError(config.LoadConfig())
+	s.Equal([]string{"resolve-secrets"}, config.LocalOnlyTasks(), "should default to secret resolution")
+
+	configData := *s.configData
+	configData.LocalOnlyTasks = "foo, bar"
+	config, _, _, configFile, err := newConfig(false, true, &configData)
+	if configFile != nil {
+		defer func() { _ = os.Remove(configFile.Name()) }()
+	}
+	s.Require().NoError(err)
+	s.Require().NoError(config.LoadConfig())
+	s.Equal([]string{"foo", "bar"}, config.LocalOnlyTasks())
+}
+
 func (s *ConfigSuite) TestValidate() {
 	tests := []struct {
 		description   string
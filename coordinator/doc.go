@@ -16,12 +16,46 @@ Providers responding directly to the outside world. Similarly, if the response
 to a proxied request contains a StreamURL, the Coordinator proxies the stream,
 modifying the StreamURL being sent externally appropriately.
 
+External requests without a response hook are handled synchronously. The
+Coordinator tracks the request itself and the http response contains the final
+response rather than the initial one. A `timeout` query parameter, as a
+duration string such as "30s", limits the wait; otherwise the default request
+timeout applies. If the final response has a stream, its StreamURL points to
+the Coordinator's stream proxy and the http response is a 303 redirect to it.
+
+Clients that can't receive responses on an http response hook of their own,
+such as browsers or clients behind NAT, can instead connect to the websocket
+endpoint. Requests are sent as JSON messages without a response hook, and the
+Coordinator acts as the response hook on the client's behalf. Each request
+receives an ack message with the initial response and a response message with
+the final response, both identified by request ID. If the final response has a
+stream, its data follows in stream messages, terminated by a streamEnd
+message.
+
+Tasks returning sensitive data, configured by `local_only_tasks` and defaulting
+to `resolve-secrets`, are only accepted on the internal socket. The http and
+websocket endpoints reject them, including requests forwarded by other
+Coordinators.
+
+A `reload-config` request is handled by the Coordinator itself. It reloads its
+own config file and then sends a `reload-config` request to every provider
+registered for the task, responding with the combined ReloadResult. Sending
+SIGHUP reloads only the Coordinator's config (see ReloadOnSignal). Changes to
+`socket_dir`, `service_name`, and `external_port` require a restart.
+
+A Coordinator can be drained before it is stopped. Drain removes the internal
+request socket and rejects new external requests, while still handling
+responses and streams for requests already accepted, until they finish or a
+deadline passes. When `drain_timeout` is set, StopOnSignal drains the server
+before stopping it.
+
 Endpoints
 
 	External Request: http, /
 	Internal Request: unix, /[socket_dir]/coordinator/[coordinator name].sock
 	Internal Response: unix, /[socket_dir]/response/[coordinator name].sock
 	Proxied Stream: http, /stream?addr=[original StreamURL]
+	Websocket Requests: ws, /ws
 
 Config
 
@@ -31,6 +65,7 @@ Config
 		"service_name": "NameOfThisCoordinator",
 		"external_port": 8080,
 		"request_timeout": 0,
+		"drain_timeout": 0,
 		"log_level": "warning"
 	}
 */
@@ -0,0 +1,83 @@
+package coordinator
+
+import (
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/cerana/cerana/acomm"
+	"github.com/cerana/cerana/pkg/errors"
+)
+
+// DrainStatus describes the work still in progress while a Server drains.
+type DrainStatus struct {
+	Draining        bool `json:"draining"`
+	TrackedRequests int  `json:"trackedRequests"`
+	ActiveStreams   int  `json:"activeStreams"`
+}
+
+// done returns whether there is no work left in progress.
+func (d *DrainStatus) done() bool {
+	return d.TrackedRequests == 0 && d.ActiveStreams == 0
+}
+
+// Drain stops accepting new requests, removing the internal request socket
+// and rejecting new external requests, then waits up to the timeout for
+// proxied requests and streams to finish. Responses and streams for requests
+// already accepted continue to be handled. Progress is logged periodically and
+// can be checked with DrainStatus. An error is returned if work remains when
+// the timeout is reached. Stop should still be called afterwards.
+func (s *Server) Drain(timeout time.Duration) error {
+	atomic.StoreInt32(&s.draining, 1)
+	s.stopInternal()
+
+	deadline := time.Now().Add(timeout)
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for {
+		status := s.DrainStatus()
+		if status.done() {
+			logrus.Info("drain complete")
+			return nil
+		}
+		if !time.Now().Before(deadline) {
+			return errors.Newv("drain timeout exceeded with work in progress", map[string]interface{}{
+				"timeout": timeout.String(),
+				"status":  status,
+			})
+		}
+
+		logrus.WithFields(logrus.Fields{
+			"trackedRequests": status.TrackedRequests,
+			"activeStreams":   status.ActiveStreams,
+		}).Info("draining")
+		<-ticker.C
+	}
+}
+
+// DrainStatus returns the current drain state and the amount of work in
+// progress.
+func (s *Server) DrainStatus() *DrainStatus {
+	return &DrainStatus{
+		Draining:        atomic.LoadInt32(&s.draining) == 1,
+		TrackedRequests: s.proxy.NumRequests(),
+		ActiveStreams:   int(atomic.LoadInt32(&s.activeStreams)),
+	}
+}
+
+// stopInternal stops accepting new internal requests. It is safe to call more
+// than once.
+func (s *Server) stopInternal() {
+	s.internalStop.Do(func() {
+		s.internal.Stop(0)
+	})
+}
+
+// proxyStreamHandler proxies streams while keeping count of those active.
+func (s *Server) proxyStreamHandler(w http.ResponseWriter, r *http.Request) {
+	atomic.AddInt32(&s.activeStreams, 1)
+	defer atomic.AddInt32(&s.activeStreams, -1)
+
+	acomm.ProxyStreamHandler(w, r)
+}
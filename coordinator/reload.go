@@ -0,0 +1,155 @@
+package coordinator
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/cerana/cerana/acomm"
+	"github.com/cerana/cerana/pkg/configutil"
+	"github.com/cerana/cerana/pkg/errors"
+)
+
+// reloadConfigTask is the task for reloading the config of the coordinator
+// and every provider registered with it.
+const reloadConfigTask = "reload-config"
+
+// ReloadResult is the result of a reload-config request. Changes and errors
+// for providers are keyed on provider name.
+type ReloadResult struct {
+	Coordinator *configutil.Changes            `json:"coordinator"`
+	Providers   map[string]*configutil.Changes `json:"providers"`
+	Errors      map[string]string              `json:"errors"`
+}
+
+// ReloadConfig reloads the config file and applies the changes that are safe
+// to make while running: log level and request timeout.
+func (s *Server) ReloadConfig() (*configutil.Changes, error) {
+	changes, err := s.config.ReloadConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.config.SetupLogging(); err != nil {
+		return changes, err
+	}
+
+	s.proxy.SetDefaultTimeout(s.config.RequestTimeout())
+
+	logrus.WithFields(logrus.Fields{
+		"applied":         changes.Applied,
+		"restartRequired": changes.RestartRequired,
+	}).Info("config reloaded")
+	return changes, nil
+}
+
+// ReloadOnSignal will reload the config each time one of the specified
+// signals is received. If no signals are specified, it will use SIGHUP.
+func (s *Server) ReloadOnSignal(signals ...os.Signal) {
+	if len(signals) == 0 {
+		signals = []os.Signal{syscall.SIGHUP}
+	}
+
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, signals...)
+	for sig := range sigChan {
+		logrus.WithFields(logrus.Fields{
+			"signal": sig,
+		}).Info("signal received, reloading config")
+
+		if _, err := s.ReloadConfig(); err != nil {
+			logrus.WithField("error", err).Error("failed to reload config")
+		}
+	}
+}
+
+// reloadConfigRequest handles a reload-config request by reloading the
+// coordinator's config and then that of each registered provider. The
+// aggregated result is sent to the request's response hook once all of the
+// providers have responded.
+func (s *Server) reloadConfigRequest(req *acomm.Request) {
+	result := s.reloadAll()
+
+	resp, err := acomm.NewResponse(req, result, nil, nil)
+	if err != nil {
+		err = errors.Wrapv(err, map[string]interface{}{"request": req, "result": result})
+		logrus.WithField("error", err).Error("failed to create response")
+		return
+	}
+
+	if err := req.Respond(resp); err != nil {
+		err = errors.Wrapv(err, map[string]interface{}{"request": req, "result": result})
+		logrus.WithField("error", err).Error("failed to send response")
+	}
+}
+
+// reloadAll reloads the coordinator's config and asks every provider
+// registered for the reload-config task to reload its own.
+func (s *Server) reloadAll() *ReloadResult {
+	result := &ReloadResult{
+		Providers: make(map[string]*configutil.Changes),
+		Errors:    make(map[string]string),
+	}
+
+	changes, err := s.ReloadConfig()
+	if err != nil {
+		result.Errors[s.config.ServiceName()] = err.Error()
+	} else {
+		result.Coordinator = changes
+	}
+
+	providerSockets, err := s.getProviders(reloadConfigTask)
+	if err != nil {
+		result.Errors[reloadConfigTask] = err.Error()
+		return result
+	}
+
+	multiRequest := acomm.NewMultiRequest(s.proxy, 0)
+	for _, providerSocket := range providerSockets {
+		name := providerName(providerSocket)
+		req, err := acomm.NewRequest(acomm.RequestOptions{Task: reloadConfigTask})
+		if err != nil {
+			result.Errors[name] = err.Error()
+			continue
+		}
+		if err := multiRequest.AddRequest(name, req); err != nil {
+			result.Errors[name] = err.Error()
+			continue
+		}
+		addr, _ := url.ParseRequestURI(fmt.Sprintf("unix://%s", providerSocket))
+		if err := acomm.Send(addr, req); err != nil {
+			multiRequest.RemoveRequest(req)
+			result.Errors[name] = err.Error()
+		}
+	}
+
+	for name, resp := range multiRequest.Responses() {
+		if resp.Error != nil {
+			result.Errors[name] = resp.Error.Error()
+			continue
+		}
+		changes := &configutil.Changes{}
+		if err := resp.UnmarshalResult(changes); err != nil {
+			result.Errors[name] = err.Error()
+			continue
+		}
+		result.Providers[name] = changes
+	}
+
+	return result
+}
+
+// providerName extracts the provider name from a task socket path of the
+// form <priority>-<name>.sock.
+func providerName(socketPath string) string {
+	name := strings.TrimSuffix(filepath.Base(socketPath), ".sock")
+	if i := strings.Index(name, "-"); i >= 0 {
+		name = name[i+1:]
+	}
+	return name
+}
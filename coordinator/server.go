@@ -11,7 +11,10 @@ import (
 	"os/signal"
 	"path/filepath"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"syscall"
+	"time"
 
 	"github.com/Sirupsen/logrus"
 	"github.com/cerana/cerana/acomm"
@@ -22,10 +25,13 @@ import (
 // Server is the coordinator server. It handles accepting internal and external
 // requests and proxying them to appropriate providers.
 type Server struct {
-	config   *Config
-	proxy    *acomm.Tracker
-	internal *acomm.UnixListener
-	external *graceful.Server
+	config        *Config
+	proxy         *acomm.Tracker
+	internal      *acomm.UnixListener
+	internalStop  sync.Once
+	external      *graceful.Server
+	draining      int32
+	activeStreams int32
 }
 
 // NewServer creates and initializes a new instance of Server.
@@ -76,8 +82,9 @@ func NewServer(config *Config) (*Server, error) {
 
 	// External server for requests to and from outside
 	mux := http.NewServeMux()
-	mux.HandleFunc("/stream", acomm.ProxyStreamHandler)
+	mux.HandleFunc("/stream", s.proxyStreamHandler)
 	mux.HandleFunc("/proxy", s.proxy.ProxyExternalHandler)
+	mux.HandleFunc("/ws", s.websocketHandler)
 	mux.HandleFunc("/", s.externalHandler)
 	s.external = &graceful.Server{
 		Server: &http.Server{
@@ -97,14 +104,27 @@ func NewServer(config *Config) (*Server, error) {
 	return s, nil
 }
 
-// externalHandler is the http handler for external requests.
+// externalHandler is the http handler for external requests. Requests with a
+// response hook receive an immediate response acknowledging receipt. Requests
+// without one are handled synchronously, receiving the final response instead.
 func (s *Server) externalHandler(w http.ResponseWriter, r *http.Request) {
 	var respErr error
+	var finalResp *acomm.Response
 	req := &acomm.Request{}
 
-	// Send the immediate response
+	// Send the immediate or final response
 	defer func() {
-		resp, err := acomm.NewResponse(req, nil, nil, respErr)
+		resp := finalResp
+		if resp == nil {
+			resp, _ = acomm.NewResponse(req, nil, nil, respErr)
+		}
+		if resp != nil && resp.StreamURL != nil {
+			// Redirect to the stream through the host used for the request
+			resp.StreamURL.Host = r.Host
+			w.Header().Set("Location", resp.StreamURL.String())
+			w.WriteHeader(http.StatusSeeOther)
+		}
+
 		errData := map[string]interface{}{
 			"request":  req,
 			"response": resp,
@@ -112,12 +132,12 @@ func (s *Server) externalHandler(w http.ResponseWriter, r *http.Request) {
 		respJSON, err := json.Marshal(resp)
 		if err != nil {
 			err = errors.Wrapv(err, errData)
-			logrus.WithField("error", err).Error("failed to marshal initial response")
+			logrus.WithField("error", err).Error("failed to marshal response")
 		}
 
 		if _, err := w.Write(respJSON); err != nil {
 			err = errors.Wrapv(err, errData)
-			logrus.WithField("error", err).Error("failed to send initial response")
+			logrus.WithField("error", err).Error("failed to send response")
 		}
 	}()
 
@@ -142,9 +162,73 @@ func (s *Server) externalHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if atomic.LoadInt32(&s.draining) == 1 {
+		respErr = errors.Newv("coordinator is draining", map[string]interface{}{"request": req})
+		return
+	}
+
+	if err := s.checkExternalTask(req); err != nil {
+		respErr = err
+		return
+	}
+
+	if req.ResponseHook == nil {
+		finalResp, respErr = s.syncRequest(req, r.URL.Query().Get("timeout"))
+		return
+	}
+
 	respErr = s.handleRequest(req)
 }
 
+// checkExternalTask rejects external requests for local only tasks. They are
+// only accepted on the internal socket, from the node itself, and rejected on
+// the external http and websocket handlers, which includes requests forwarded
+// by other coordinators.
+func (s *Server) checkExternalTask(req *acomm.Request) error {
+	for _, task := range s.config.LocalOnlyTasks() {
+		if req.Task == task {
+			return errors.Newv("task is not available externally", map[string]interface{}{"task": req.Task})
+		}
+	}
+	return nil
+}
+
+// syncRequest handles a request synchronously, blocking until the final
+// response is received or the timeout, a duration string, is reached. If the
+// response has a stream, its StreamURL is replaced with a proxy stream url.
+func (s *Server) syncRequest(req *acomm.Request, timeoutStr string) (*acomm.Response, error) {
+	if err := req.Validate(); err != nil {
+		return nil, errors.Wrapv(err, map[string]interface{}{"request": req})
+	}
+
+	var timeout time.Duration
+	if timeoutStr != "" {
+		var err error
+		timeout, err = time.ParseDuration(timeoutStr)
+		if err != nil {
+			return nil, errors.Wrapv(err, map[string]interface{}{"timeout": timeoutStr}, "invalid timeout")
+		}
+	}
+
+	respChan := make(chan *acomm.Response, 1)
+	handler := func(_ *acomm.Request, resp *acomm.Response) {
+		respChan <- resp
+	}
+	if err := s.handleHooklessRequest(req, timeout, handler); err != nil {
+		return nil, err
+	}
+
+	resp := <-respChan
+	if resp.StreamURL != nil {
+		streamURL, err := s.proxy.ProxyStreamHTTPURL(resp.StreamURL)
+		if err != nil {
+			return nil, errors.Wrapv(err, map[string]interface{}{"requestID": req.ID})
+		}
+		resp.StreamURL = streamURL
+	}
+	return resp, nil
+}
+
 func (s *Server) internalHandler() {
 	for {
 		conn := s.internal.NextConn()
@@ -192,12 +276,37 @@ func (s *Server) acceptInternalRequest(conn net.Conn) {
 	respErr = s.handleRequest(req)
 }
 
+// handleHooklessRequest handles a request from a client that can't receive
+// responses on a response hook of its own. The coordinator acts as the
+// response hook on the client's behalf, tracking the request and passing the
+// final response to the handler.
+func (s *Server) handleHooklessRequest(req *acomm.Request, timeout time.Duration, handler acomm.ResponseHandler) error {
+	if req.TaskURL != nil {
+		return errors.Newv("task url requires a response hook", map[string]interface{}{"request": req})
+	}
+	if atomic.LoadInt32(&s.draining) == 1 {
+		return errors.Newv("coordinator is draining", map[string]interface{}{"request": req})
+	}
+
+	req.ResponseHook = s.proxy.URL()
+	req.SuccessHandler = handler
+	req.ErrorHandler = handler
+	if err := s.proxy.TrackRequest(req, timeout); err != nil {
+		return err
+	}
+
+	return s.handleRequest(req)
+}
+
 func (s *Server) handleRequest(req *acomm.Request) error {
 	var err error
-	if req.TaskURL == nil {
-		err = s.localTask(req)
-	} else {
+	switch {
+	case req.TaskURL != nil:
 		err = s.externalTask(req)
+	case req.Task == reloadConfigTask:
+		go s.reloadConfigRequest(req)
+	default:
+		err = s.localTask(req)
 	}
 	if err != nil {
 		_ = s.proxy.RemoveRequest(req)
@@ -321,7 +430,7 @@ func (s *Server) Stop() {
 	<-stopChan
 
 	// Stop accepting new internal requests
-	s.internal.Stop(0)
+	s.stopInternal()
 
 	// Stop the proxy tracker
 	s.proxy.Stop()
@@ -329,7 +438,8 @@ func (s *Server) Stop() {
 
 // StopOnSignal will wait until one of the specified signals is received and
 // then stop the server. If no signals are specified, it will use a default
-// set.
+// set. If a drain timeout is configured, the server is drained before being
+// stopped.
 func (s *Server) StopOnSignal(signals ...os.Signal) {
 	if len(signals) == 0 {
 		signals = []os.Signal{os.Interrupt, os.Kill, syscall.SIGTERM}
@@ -342,5 +452,11 @@ func (s *Server) StopOnSignal(signals ...os.Signal) {
 		"signal": sig,
 	}).Info("signal received, stopping")
 
+	if drainTimeout := s.config.DrainTimeout(); drainTimeout > 0 {
+		if err := s.Drain(drainTimeout); err != nil {
+			logrus.WithField("error", err).Error("failed to drain")
+		}
+	}
+
 	s.Stop()
 }
@@ -1,6 +1,7 @@
 package coordinator_test
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
@@ -17,6 +18,7 @@ import (
 	"github.com/Sirupsen/logrus"
 	"github.com/cerana/cerana/acomm"
 	"github.com/cerana/cerana/coordinator"
+	"github.com/gorilla/websocket"
 	"github.com/pborman/uuid"
 	"github.com/stretchr/testify/suite"
 )
@@ -162,6 +164,195 @@ func (s *ServerSuite) TestReqRespHandle() {
 	}
 }
 
+func (s *ServerSuite) TestDrain() {
+	if !s.NoError(s.server.Start(), "failed to start server") {
+		return
+	}
+	time.Sleep(time.Second)
+	defer s.server.Stop()
+
+	s.False(s.server.DrainStatus().Draining)
+	s.NoError(s.server.Drain(5*time.Second), "should drain with no work in progress")
+	s.True(s.server.DrainStatus().Draining)
+
+	internalSocket := filepath.Join(s.config.SocketDir(), "coordinator", s.config.ServiceName()+".sock")
+	_, err := os.Stat(internalSocket)
+	s.True(os.IsNotExist(err), "internal socket should be removed")
+
+	externalURL, _ := url.ParseRequestURI(fmt.Sprintf("http://localhost:%v", s.configData.ExternalPort))
+	req, err := acomm.NewRequest(acomm.RequestOptions{
+		Task:               "foobar",
+		ResponseHookString: "http://localhost:8080/",
+	})
+	s.Require().NoError(err)
+	s.Error(acomm.Send(externalURL, req), "should reject new external requests")
+}
+
+func (s *ServerSuite) TestSyncRequest() {
+	if !s.NoError(s.server.Start(), "failed to start server") {
+		return
+	}
+	time.Sleep(time.Second)
+	defer s.server.Stop()
+
+	result := make(chan *params, 10)
+	taskListener := s.createTaskListener("foobar", result)
+	if taskListener == nil {
+		return
+	}
+	defer taskListener.Stop(0)
+
+	localOnlyListener := s.createTaskListener("resolve-secrets", result)
+	if localOnlyListener == nil {
+		return
+	}
+	defer localOnlyListener.Stop(0)
+
+	// Task that acknowledges requests but never responds
+	noReplyListener := acomm.NewUnixListener(filepath.Join(s.configData.SocketDir, "noreply", "test.sock"), 0)
+	s.Require().NoError(noReplyListener.Start(), "failed to start task listener")
+	defer noReplyListener.Stop(0)
+	go func() {
+		for {
+			conn := noReplyListener.NextConn()
+			if conn == nil {
+				return
+			}
+			req := &acomm.Request{}
+			_ = acomm.UnmarshalConnData(conn, req)
+			resp, _ := acomm.NewResponse(req, nil, nil, nil)
+			_ = acomm.SendConnData(conn, resp)
+			noReplyListener.DoneConn(conn)
+		}
+	}()
+
+	tests := []struct {
+		description  string
+		taskName     string
+		timeout      string
+		params       *params
+		expectFailed bool
+	}{
+		{"valid", "foobar", "", &params{uuid.New()}, false},
+		{"valid with timeout", "foobar", "5s", &params{uuid.New()}, false},
+		{"bad task", "asdf", "", &params{uuid.New()}, true},
+		{"bad timeout", "foobar", "asdf", &params{uuid.New()}, true},
+		{"timed out", "noreply", "100ms", &params{uuid.New()}, true},
+		{"local only task", "resolve-secrets", "", &params{uuid.New()}, true},
+	}
+
+	for _, test := range tests {
+		msg := testMsgFunc(test.description)
+
+		req, err := acomm.NewRequest(acomm.RequestOptions{
+			Task: test.taskName,
+			Args: test.params,
+		})
+		s.Require().NoError(err, msg("should have created req"))
+		reqJSON, err := json.Marshal(req)
+		s.Require().NoError(err, msg("should have marshalled req"))
+
+		reqURL := fmt.Sprintf("http://localhost:%d/?timeout=%s", s.configData.ExternalPort, test.timeout)
+		httpResp, err := http.Post(reqURL, "application/json", bytes.NewReader(reqJSON))
+		if !s.NoError(err, msg("should have sent req")) {
+			continue
+		}
+		resp := &acomm.Response{}
+		err = json.NewDecoder(httpResp.Body).Decode(resp)
+		_ = httpResp.Body.Close()
+		if !s.NoError(err, msg("should have decoded response")) {
+			continue
+		}
+
+		if test.expectFailed {
+			s.Error(resp.Error, msg("should have failed"))
+		} else {
+			s.NoError(resp.Error, msg("should have succeeded"))
+			p := &params{}
+			s.NoError(resp.UnmarshalResult(p), msg("should have unmarshalled result"))
+			s.Equal(test.params, p, msg("should have gotten the correct response data"))
+		}
+		drainChan(result)
+	}
+}
+
+func (s *ServerSuite) TestWebsocket() {
+	if !s.NoError(s.server.Start(), "failed to start server") {
+		return
+	}
+	time.Sleep(time.Second)
+	defer s.server.Stop()
+
+	result := make(chan *params, 10)
+	taskListener := s.createTaskListener("foobar", result)
+	if taskListener == nil {
+		return
+	}
+	defer taskListener.Stop(0)
+
+	localOnlyListener := s.createTaskListener("resolve-secrets", result)
+	if localOnlyListener == nil {
+		return
+	}
+	defer localOnlyListener.Stop(0)
+
+	conn, _, err := websocket.DefaultDialer.Dial(fmt.Sprintf("ws://localhost:%d/ws", s.configData.ExternalPort), nil)
+	if !s.NoError(err, "failed to dial websocket") {
+		return
+	}
+	defer func() { _ = conn.Close() }()
+
+	tests := []struct {
+		description  string
+		taskName     string
+		params       *params
+		expectFailed bool
+	}{
+		{"valid", "foobar", &params{uuid.New()}, false},
+		{"bad task", "asdf", &params{uuid.New()}, true},
+		{"local only task", "resolve-secrets", &params{uuid.New()}, true},
+	}
+
+	for _, test := range tests {
+		msg := testMsgFunc(test.description)
+
+		req, err := acomm.NewRequest(acomm.RequestOptions{
+			Task: test.taskName,
+			Args: test.params,
+		})
+		s.Require().NoError(err, msg("should have created req"))
+		s.Require().NoError(conn.WriteJSON(req), msg("should have sent req"))
+
+		expected := 2
+		if test.expectFailed {
+			expected = 1
+		}
+		for i := 0; i < expected; i++ {
+			wsMsg := &coordinator.WSMessage{}
+			if !s.NoError(conn.ReadJSON(wsMsg), msg("should have read message")) {
+				return
+			}
+			s.Equal(req.ID, wsMsg.ID, msg("should be for the request"))
+
+			switch wsMsg.Type {
+			case coordinator.WSMessageAck:
+				if test.expectFailed {
+					s.Error(wsMsg.Response.Error, msg("should have failed"))
+				} else {
+					s.NoError(wsMsg.Response.Error, msg("should have succeeded"))
+				}
+			case coordinator.WSMessageResponse:
+				p := &params{}
+				s.NoError(wsMsg.Response.UnmarshalResult(p), msg("should have unmarshalled result"))
+				s.Equal(test.params, p, msg("should have gotten the correct response data"))
+			default:
+				s.Fail(msg("unexpected message type %s", wsMsg.Type))
+			}
+		}
+		drainChan(result)
+	}
+}
+
 func (s *ServerSuite) TestStopOnSignal() {
 	selfProcess, err := os.FindProcess(os.Getpid())
 	if !s.NoError(err, "couldn't find this process") {
@@ -0,0 +1,151 @@
+package coordinator
+
+import (
+	"net/http"
+	"sync"
+	"sync/atomic"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/cerana/cerana/acomm"
+	"github.com/cerana/cerana/pkg/errors"
+	"github.com/gorilla/websocket"
+)
+
+// Types of messages sent to websocket clients.
+const (
+	WSMessageAck       = "ack"
+	WSMessageResponse  = "response"
+	WSMessageStream    = "stream"
+	WSMessageStreamEnd = "streamEnd"
+)
+
+// WSMessage is a message sent to a websocket client. Ack and Response
+// messages carry a Response. Stream messages carry a chunk of the response's
+// stream data, and the StreamEnd message marks the end of the stream, with
+// Error set if the stream was not completed.
+type WSMessage struct {
+	Type     string          `json:"type"`
+	ID       string          `json:"id"`
+	Response *acomm.Response `json:"response,omitempty"`
+	Data     []byte          `json:"data,omitempty"`
+	Error    string          `json:"error,omitempty"`
+}
+
+var wsUpgrader = websocket.Upgrader{
+	// The external http endpoints are open to any client, so the websocket
+	// endpoint does not restrict origins either.
+	CheckOrigin: func(r *http.Request) bool { return true },
+}
+
+// wsConn is a websocket connection to a client. Writes are serialized, since
+// the connection supports only one concurrent writer.
+type wsConn struct {
+	conn      *websocket.Conn
+	writeLock sync.Mutex
+}
+
+// send writes a message to the client.
+func (c *wsConn) send(msg *WSMessage) error {
+	c.writeLock.Lock()
+	defer c.writeLock.Unlock()
+
+	return errors.Wrapv(c.conn.WriteJSON(msg), map[string]interface{}{"requestID": msg.ID, "type": msg.Type})
+}
+
+// wsStreamWriter sends data written to it as stream messages.
+type wsStreamWriter struct {
+	conn *wsConn
+	id   string
+}
+
+func (w *wsStreamWriter) Write(p []byte) (int, error) {
+	data := make([]byte, len(p))
+	copy(data, p)
+	if err := w.conn.send(&WSMessage{Type: WSMessageStream, ID: w.id, Data: data}); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// websocketHandler is the http handler for websocket clients. Clients send
+// requests as JSON messages over the connection without a response hook; the
+// coordinator acts as the response hook on their behalf and sends the
+// responses, along with any stream data, back over the same connection.
+func (s *Server) websocketHandler(w http.ResponseWriter, r *http.Request) {
+	conn, err := wsUpgrader.Upgrade(w, r, nil)
+	if err != nil {
+		// The upgrader has already responded with an http error
+		logrus.WithField("error", errors.Wrap(err)).Error("failed to upgrade websocket connection")
+		return
+	}
+	defer func() {
+		if err := conn.Close(); err != nil {
+			logrus.WithField("error", errors.Wrap(err)).Error("failed to close websocket connection")
+		}
+	}()
+
+	wc := &wsConn{conn: conn}
+	for {
+		req := &acomm.Request{}
+		if err := conn.ReadJSON(req); err != nil {
+			if !websocket.IsCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway) {
+				logrus.WithField("error", errors.Wrap(err)).Error("failed to read websocket request")
+			}
+			return
+		}
+
+		respErr := s.handleWSRequest(wc, req)
+		resp, err := acomm.NewResponse(req, nil, nil, respErr)
+		if err != nil {
+			err = errors.Wrapv(err, map[string]interface{}{"request": req, "respErr": respErr})
+			logrus.WithField("error", err).Error("failed to create initial response")
+			continue
+		}
+		if err := wc.send(&WSMessage{Type: WSMessageAck, ID: req.ID, Response: resp}); err != nil {
+			logrus.WithField("error", err).Error("failed to send initial response")
+			return
+		}
+	}
+}
+
+// handleWSRequest handles a request from a websocket client, sending the
+// response to the client.
+func (s *Server) handleWSRequest(wc *wsConn, req *acomm.Request) error {
+	if err := req.Validate(); err != nil {
+		return errors.Wrapv(err, map[string]interface{}{"request": req})
+	}
+	if err := s.checkExternalTask(req); err != nil {
+		return err
+	}
+
+	return s.handleHooklessRequest(req, 0, func(req *acomm.Request, resp *acomm.Response) {
+		s.sendWSResponse(wc, resp)
+	})
+}
+
+// sendWSResponse sends a response to a websocket client, followed by the
+// response's stream data if there is any.
+func (s *Server) sendWSResponse(wc *wsConn, resp *acomm.Response) {
+	streamURL := resp.StreamURL
+	if err := wc.send(&WSMessage{Type: WSMessageResponse, ID: resp.ID, Response: resp}); err != nil {
+		logrus.WithField("error", err).Error("failed to send response")
+		return
+	}
+
+	if streamURL == nil {
+		return
+	}
+
+	atomic.AddInt32(&s.activeStreams, 1)
+	defer atomic.AddInt32(&s.activeStreams, -1)
+
+	end := &WSMessage{Type: WSMessageStreamEnd, ID: resp.ID}
+	if err := acomm.Stream(&wsStreamWriter{conn: wc, id: resp.ID}, streamURL); err != nil {
+		err = errors.Wrapv(err, map[string]interface{}{"requestID": resp.ID, "streamURL": streamURL})
+		logrus.WithField("error", err).Error("failed to stream data")
+		end.Error = err.Error()
+	}
+	if err := wc.send(end); err != nil {
+		logrus.WithField("error", err).Error("failed to send stream end")
+	}
+}
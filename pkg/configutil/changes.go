@@ -0,0 +1,124 @@
+package configutil
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Changes describes the settings that differ between two loads of a config.
+// Applied settings take effect immediately, while those in RestartRequired
+// keep their previous values until the service is restarted.
+type Changes struct {
+	Applied         []string `json:"applied"`
+	RestartRequired []string `json:"restartRequired"`
+}
+
+// Diff compares two sets of settings, such as those returned by viper's
+// AllSettings, and reports which keys differ. Nested maps are compared by
+// their dotted key paths. Keys matching or nested under one of restartKeys
+// are reported as requiring a restart. A "*" in a restart key matches any
+// single path segment, e.g. "tasks.*.timeout".
+func Diff(oldSettings, newSettings map[string]interface{}, restartKeys ...string) *Changes {
+	oldFlat := make(map[string]string)
+	flatten("", oldSettings, oldFlat)
+	newFlat := make(map[string]string)
+	flatten("", newSettings, newFlat)
+
+	changed := make(map[string]bool)
+	for key, value := range oldFlat {
+		if newValue, ok := newFlat[key]; !ok || newValue != value {
+			changed[key] = true
+		}
+	}
+	for key := range newFlat {
+		if _, ok := oldFlat[key]; !ok {
+			changed[key] = true
+		}
+	}
+
+	changes := &Changes{
+		Applied:         []string{},
+		RestartRequired: []string{},
+	}
+	for key := range changed {
+		if matchesKey(key, restartKeys) {
+			changes.RestartRequired = append(changes.RestartRequired, key)
+		} else {
+			changes.Applied = append(changes.Applied, key)
+		}
+	}
+	sort.Strings(changes.Applied)
+	sort.Strings(changes.RestartRequired)
+
+	return changes
+}
+
+// flatten converts nested settings into a map of dotted keys to string
+// representations of their values. String representations avoid false
+// positives from the same number being decoded as different types by
+// different config sources.
+func flatten(prefix string, settings map[string]interface{}, out map[string]string) {
+	for key, value := range settings {
+		key = strings.ToLower(key)
+		if prefix != "" {
+			key = prefix + "." + key
+		}
+
+		switch v := value.(type) {
+		case map[string]interface{}:
+			flatten(key, v, out)
+		case map[interface{}]interface{}:
+			nested := make(map[string]interface{}, len(v))
+			for k, val := range v {
+				nested[fmt.Sprint(k)] = val
+			}
+			flatten(key, nested, out)
+		default:
+			out[key] = fmt.Sprint(v)
+		}
+	}
+}
+
+// Lookup returns the value of a dotted key path in nested settings, such as
+// those returned by viper's AllSettings.
+func Lookup(settings map[string]interface{}, key string) (interface{}, bool) {
+	var value interface{} = settings
+	for _, segment := range strings.Split(key, ".") {
+		var ok bool
+		switch v := value.(type) {
+		case map[string]interface{}:
+			value, ok = v[segment]
+		case map[interface{}]interface{}:
+			value, ok = v[segment]
+		}
+		if !ok {
+			return nil, false
+		}
+	}
+	return value, true
+}
+
+func matchesKey(key string, keys []string) bool {
+	segments := strings.Split(key, ".")
+	for _, k := range keys {
+		if matchesSegments(segments, strings.Split(k, ".")) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchesSegments returns whether the key segments match or are nested under
+// the pattern segments.
+func matchesSegments(segments, pattern []string) bool {
+	if len(segments) < len(pattern) {
+		return false
+	}
+	for i, p := range pattern {
+		if p != "*" && p != segments[i] {
+			return false
+		}
+	}
+	return true
+}
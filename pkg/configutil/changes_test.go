@@ -0,0 +1,87 @@
+package configutil_test
+
+import (
+	"github.com/cerana/cerana/pkg/configutil"
+)
+
+func (s *ConfigUtil) TestDiff() {
+	base := map[string]interface{}{
+		"log_level":   "warning",
+		"socket_dir":  "/tmp/cerana",
+		"timeout":     10,
+		"unchanged":   true,
+		"nested":      map[string]interface{}{"foo": map[string]interface{}{"priority": 50}},
+		"nestedOther": map[interface{}]interface{}{"bar": 1},
+	}
+
+	tests := []struct {
+		desc            string
+		newSettings     map[string]interface{}
+		applied         []string
+		restartRequired []string
+	}{
+		{"no changes", base, []string{}, []string{}},
+		{"number types", map[string]interface{}{
+			"log_level":   "warning",
+			"socket_dir":  "/tmp/cerana",
+			"timeout":     float64(10),
+			"unchanged":   true,
+			"nested":      map[string]interface{}{"foo": map[string]interface{}{"priority": float64(50)}},
+			"nestedOther": map[string]interface{}{"bar": 1},
+		}, []string{}, []string{}},
+		{"changes", map[string]interface{}{
+			"log_level":   "debug",
+			"socket_dir":  "/tmp/foobar",
+			"timeout":     10,
+			"unchanged":   true,
+			"nested":      map[string]interface{}{"foo": map[string]interface{}{"priority": 60}},
+			"nestedOther": map[string]interface{}{"bar": 1},
+			"added":       "baz",
+		}, []string{"added", "log_level", "nested.foo.priority"}, []string{"socket_dir"}},
+		{"removed", map[string]interface{}{
+			"log_level": "warning",
+			"timeout":   10,
+			"unchanged": true,
+		}, []string{"nested.foo.priority", "nestedother.bar"}, []string{"socket_dir"}},
+	}
+
+	for _, test := range tests {
+		changes := configutil.Diff(base, test.newSettings, "socket_dir")
+		s.Equal(test.applied, changes.Applied, test.desc)
+		s.Equal(test.restartRequired, changes.RestartRequired, test.desc)
+	}
+
+	changes := configutil.Diff(base, map[string]interface{}{
+		"nested": map[string]interface{}{"foo": map[string]interface{}{"priority": 60}},
+	}, "nested.*.priority")
+	s.Equal([]string{"log_level", "nestedother.bar", "socket_dir", "timeout", "unchanged"}, changes.Applied)
+	s.Equal([]string{"nested.foo.priority"}, changes.RestartRequired)
+}
+
+func (s *ConfigUtil) TestLookup() {
+	settings := map[string]interface{}{
+		"log_level":   "warning",
+		"nested":      map[string]interface{}{"foo": map[string]interface{}{"priority": 50}},
+		"nestedOther": map[interface{}]interface{}{"bar": 1},
+	}
+
+	tests := []struct {
+		desc  string
+		key   string
+		value interface{}
+		found bool
+	}{
+		{"top level", "log_level", "warning", true},
+		{"nested", "nested.foo.priority", 50, true},
+		{"nested map", "nested.foo", map[string]interface{}{"priority": 50}, true},
+		{"interface keys", "nestedOther.bar", 1, true},
+		{"missing", "nested.bar.priority", nil, false},
+		{"past a leaf", "log_level.foo", nil, false},
+	}
+
+	for _, test := range tests {
+		value, found := configutil.Lookup(settings, test.key)
+		s.Equal(test.found, found, test.desc)
+		s.Equal(test.value, value, test.desc)
+	}
+}
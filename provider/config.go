@@ -1,11 +1,14 @@
 package provider
 
 import (
+	"bytes"
 	"fmt"
+	"io/ioutil"
 	"net/url"
 	"path/filepath"
 	"time"
 
+	"github.com/cerana/cerana/pkg/configutil"
 	"github.com/cerana/cerana/pkg/errors"
 	"github.com/cerana/cerana/pkg/logrusx"
 	"github.com/mitchellh/mapstructure"
@@ -15,8 +18,9 @@ import (
 
 // Config holds all configuration for the provider.
 type Config struct {
-	viper   *viper.Viper
-	flagSet *flag.FlagSet
+	viper    *viper.Viper
+	flagSet  *flag.FlagSet
+	fileData []byte
 }
 
 // ConfigData defines the structure of the config data (e.g. in the config file)
@@ -28,6 +32,7 @@ type ConfigData struct {
 	LogLevel        string                     `json:"log_level"`
 	DefaultTimeout  uint64                     `json:"default_timeout"`
 	RequestTimeout  uint64                     `json:"request_timeout"`
+	DrainTimeout    uint64                     `json:"drain_timeout"`
 	Tasks           map[string]*TaskConfigData `json:"tasks"`
 }
 
@@ -55,6 +60,7 @@ func NewConfig(flagSet *flag.FlagSet, v *viper.Viper) *Config {
 	flagSet.StringP("coordinator_url", "u", "", "url of coordinator for making requests")
 	flagSet.StringP("log_level", "l", "warning", "log level: debug/info/warn/error/fatal/panic")
 	flagSet.Uint64P("request_timeout", "t", 0, "default timeout for requests made by this provider in seconds")
+	flagSet.Uint64("drain_timeout", 0, "time in seconds to wait for in-flight requests and streams to finish when stopping on a signal")
 
 	return &Config{
 		viper:   v,
@@ -73,14 +79,66 @@ func (c *Config) LoadConfig() error {
 		return c.Validate()
 	}
 
-	c.viper.SetConfigFile(filePath)
-	if err := c.viper.ReadInConfig(); err != nil {
-		return errors.Wrapv(err, map[string]interface{}{"configFile": filePath}, "failed to read config file")
+	fileData, err := c.readConfigFile(filePath)
+	if err != nil {
+		return err
 	}
+	c.fileData = fileData
 
 	return c.Validate()
 }
 
+// restartSettings are the settings that can't be changed by a config reload.
+// Task timeouts are only read when tasks are registered.
+var restartSettings = []string{"socket_dir", "service_name", "default_timeout", "tasks.*.timeout"}
+
+// ReloadConfig rereads the config file and reports the settings that changed.
+// If the new config is invalid, the previous one is kept. Changes to settings
+// that require a restart are reported, but the running values are kept.
+func (c *Config) ReloadConfig() (*configutil.Changes, error) {
+	filePath := c.viper.GetString("config_file")
+	if filePath == "" {
+		return nil, errors.New("no config file to reload")
+	}
+
+	oldSettings := c.viper.AllSettings()
+	fileData, err := c.readConfigFile(filePath)
+	if err == nil {
+		err = c.Validate()
+	}
+	if err != nil {
+		// Restore the previously loaded config
+		_ = c.viper.ReadConfig(bytes.NewReader(c.fileData))
+		return nil, err
+	}
+	c.fileData = fileData
+
+	changes := configutil.Diff(oldSettings, c.viper.AllSettings(), restartSettings...)
+	// Keep the running values of settings that need a restart
+	for _, key := range changes.RestartRequired {
+		if value, ok := configutil.Lookup(oldSettings, key); ok {
+			c.viper.Set(key, value)
+		}
+	}
+
+	return changes, nil
+}
+
+// readConfigFile reads the config file into viper and returns the raw file
+// data.
+func (c *Config) readConfigFile(filePath string) ([]byte, error) {
+	fileData, err := ioutil.ReadFile(filePath)
+	if err != nil {
+		return nil, errors.Wrapv(err, map[string]interface{}{"configFile": filePath}, "failed to read config file")
+	}
+
+	c.viper.SetConfigFile(filePath)
+	if err := c.viper.ReadInConfig(); err != nil {
+		return nil, errors.Wrapv(err, map[string]interface{}{"configFile": filePath}, "failed to read config file")
+	}
+	return fileData, nil
+}
+
 // TaskPriority determines the registration priority of a task. If a
 // priority was not explicitly configured for the task, it will return the
 // default.
@@ -138,6 +196,12 @@ func (c *Config) RequestTimeout() time.Duration {
 	return time.Second * time.Duration(c.viper.GetInt("request_timeout"))
 }
 
+// DrainTimeout returns the maximum duration to wait for in-flight work to
+// finish when draining before a signaled stop.
+func (c *Config) DrainTimeout() time.Duration {
+	return time.Second * time.Duration(c.viper.GetInt("drain_timeout"))
+}
+
 // Validate returns whether the config is valid, containing necessary values.
 func (c *Config) Validate() error {
 	if c.SocketDir() == "" {
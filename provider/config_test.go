@@ -109,6 +109,49 @@ func (s *ConfigSuite) TestLoadConfig() {
 	}
 }
 
+func (s *ConfigSuite) TestReloadConfig() {
+	config, _, _, _, err := newConfig(true, false, s.configData)
+	s.Require().NoError(err, "failed to create config")
+	s.Require().NoError(config.LoadConfig(), "failed to load config")
+	_, err = config.ReloadConfig()
+	s.Error(err, "should not reload without a config file")
+
+	configData := *s.configData
+	configData.LogLevel = "error"
+	configData.ServiceName = uuid.New()
+	configData.DefaultTimeout = 200
+	configData.Tasks = map[string]*provider.TaskConfigData{
+		"foobar": {
+			Priority: 60,
+			Timeout:  70,
+		},
+	}
+	configJSON, _ := json.Marshal(configData)
+	s.Require().NoError(ioutil.WriteFile(s.configFile.Name(), configJSON, 0644))
+
+	changes, err := s.config.ReloadConfig()
+	if !s.NoError(err, "failed to reload config") {
+		return
+	}
+	s.Equal([]string{"log_level", "tasks.foobar.priority"}, changes.Applied)
+	s.Equal([]string{"default_timeout", "service_name", "tasks.foobar.timeout"}, changes.RestartRequired)
+	s.EqualValues(60, s.config.TaskPriority("foobar"), "should apply new priority")
+	s.EqualValues(64, s.config.TaskTimeout("foobar")/time.Second, "should keep running task timeout")
+	s.EqualValues(100, s.config.TaskTimeout(uuid.New())/time.Second, "should keep running default timeout")
+	s.Equal(s.configData.ServiceName, s.config.ServiceName(), "should keep running service name")
+
+	configData.CoordinatorURL = ""
+	configData.DefaultPriority = 10
+	configJSON, _ = json.Marshal(configData)
+	s.Require().NoError(ioutil.WriteFile(s.configFile.Name(), configJSON, 0644))
+
+	_, err = s.config.ReloadConfig()
+	s.Error(err, "should not reload invalid config")
+	s.Equal(s.configData.CoordinatorURL, s.config.CoordinatorURL().String(), "should keep previous config")
+	s.EqualValues(s.configData.DefaultPriority, s.config.TaskPriority(uuid.New()), "should keep previous config")
+	s.EqualValues(60, s.config.TaskPriority("foobar"), "should keep previous config")
+}
+
 func (s *ConfigSuite) TestTaskPriority() {
 	s.EqualValues(s.configData.DefaultPriority, s.config.TaskPriority(uuid.New()))
 	s.EqualValues(s.configData.Tasks["foobar"].Priority, s.config.TaskPriority("foobar"))
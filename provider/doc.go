@@ -66,6 +66,7 @@ There are a number of values required in the config for a provider to operate su
 		"default_priority": 50,
 		"log_level": "warning",
 		"request_timeout": 0,
+		"drain_timeout": 0,
 		"tasks":{
 			"ATaskNameFoo":{
 				"priority": 60,
@@ -73,6 +74,25 @@ There are a number of values required in the config for a provider to operate su
 		}
 	}
 
+Reloading Config
+
+A running provider can reload its config file, either by receiving SIGHUP (see
+ReloadOnSignal) or by handling the `reload-config` task, which every Server
+registers when started. Log level, request timeout, and task priorities take
+effect immediately; a task whose priority changes is moved to its new socket
+path without becoming unavailable. Changes to `socket_dir`, `service_name`, and
+task timeouts (`default_timeout` and `tasks.<name>.timeout`) are reported as
+requiring a restart and are otherwise ignored.
+
+Draining
+
+Before a provider is replaced, e.g. during a rolling upgrade, it can be
+drained. Drain removes all of the task sockets, so Coordinators stop routing
+new requests to the provider, and then waits up to a deadline for in-flight
+requests and data streams to finish, logging progress as it goes. DrainStatus
+reports the work still in progress. When `drain_timeout` is set, StopOnSignal
+drains the server before stopping it.
+
 Suggestions
 
 Task handlers should be kept focused and self-contained as possible, doing one
@@ -1,22 +1,45 @@
 package provider
 
 import (
+	"net/url"
 	"os"
 	"os/signal"
 	"path/filepath"
 	"strconv"
 	"sync"
+	"sync/atomic"
 	"syscall"
+	"time"
 
 	"github.com/Sirupsen/logrus"
 	"github.com/cerana/cerana/acomm"
+	"github.com/cerana/cerana/pkg/configutil"
+	"github.com/cerana/cerana/pkg/errors"
 )
 
+// ReloadConfigTask is the name of the task that every Server registers for
+// reloading its config.
+const ReloadConfigTask = "reload-config"
+
 // Server is the main server struct.
 type Server struct {
-	config  *Config
-	tasks   map[string]*task
-	tracker *acomm.Tracker
+	config   *Config
+	tasks    map[string]*task
+	tracker  *acomm.Tracker
+	draining int32
+}
+
+// DrainStatus describes the work still in progress while a Server drains.
+type DrainStatus struct {
+	Draining        bool `json:"draining"`
+	ActiveRequests  int  `json:"activeRequests"`
+	TrackedRequests int  `json:"trackedRequests"`
+	ActiveStreams   int  `json:"activeStreams"`
+}
+
+// done returns whether there is no work left in progress.
+func (d *DrainStatus) done() bool {
+	return d.ActiveRequests == 0 && d.TrackedRequests == 0 && d.ActiveStreams == 0
 }
 
 // Provider is an interface to allow a provider to register its tasks with a
@@ -80,6 +103,10 @@ func (s *Server) Start() error {
 		return err
 	}
 
+	if _, ok := s.tasks[ReloadConfigTask]; !ok {
+		s.RegisterTask(ReloadConfigTask, s.reloadConfig)
+	}
+
 	for _, t := range s.tasks {
 		if err := t.start(); err != nil {
 			return err
@@ -105,9 +132,68 @@ func (s *Server) Stop() {
 	return
 }
 
+// Drain removes all of the task sockets so that no new requests are routed
+// to the server, then waits up to the timeout for in-flight requests,
+// outstanding requests made by the server, and data streams to finish.
+// Progress is logged periodically and can be checked with DrainStatus. An
+// error is returned if work remains when the timeout is reached. Stop should
+// still be called afterwards.
+func (s *Server) Drain(timeout time.Duration) error {
+	atomic.StoreInt32(&s.draining, 1)
+
+	var taskWG sync.WaitGroup
+	for _, t := range s.tasks {
+		taskWG.Add(1)
+		go func(t *task) {
+			defer taskWG.Done()
+			t.stopListener()
+		}(t)
+	}
+	taskWG.Wait()
+
+	deadline := time.Now().Add(timeout)
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for {
+		status := s.DrainStatus()
+		if status.done() {
+			logrus.Info("drain complete")
+			return nil
+		}
+		if !time.Now().Before(deadline) {
+			return errors.Newv("drain timeout exceeded with work in progress", map[string]interface{}{
+				"timeout": timeout.String(),
+				"status":  status,
+			})
+		}
+
+		logrus.WithFields(logrus.Fields{
+			"activeRequests":  status.ActiveRequests,
+			"trackedRequests": status.TrackedRequests,
+			"activeStreams":   status.ActiveStreams,
+		}).Info("draining")
+		<-ticker.C
+	}
+}
+
+// DrainStatus returns the current drain state and the amount of work in
+// progress.
+func (s *Server) DrainStatus() *DrainStatus {
+	status := &DrainStatus{
+		Draining:        atomic.LoadInt32(&s.draining) == 1,
+		TrackedRequests: s.tracker.NumRequests(),
+		ActiveStreams:   s.tracker.NumStreams(),
+	}
+	for _, t := range s.tasks {
+		status.ActiveRequests += t.activeRequests()
+	}
+	return status
+}
+
 // StopOnSignal will wait until one of the specified signals is received and
 // then stop the server. If no signals are specified, it will use a default
-// set.
+// set. If a drain timeout is configured, the server is drained before being
+// stopped.
 func (s *Server) StopOnSignal(signals ...os.Signal) {
 	if len(signals) == 0 {
 		signals = []os.Signal{os.Interrupt, os.Kill, syscall.SIGTERM}
@@ -120,5 +206,68 @@ func (s *Server) StopOnSignal(signals ...os.Signal) {
 		"signal": sig,
 	}).Info("signal received, stopping")
 
+	if drainTimeout := s.config.DrainTimeout(); drainTimeout > 0 {
+		if err := s.Drain(drainTimeout); err != nil {
+			logrus.WithField("error", err).Error("failed to drain")
+		}
+	}
+
 	s.Stop()
 }
+
+// ReloadConfig reloads the config file and applies the changes that are safe
+// to make while running: log level, request timeout, and task priorities.
+// Tasks with a changed priority are moved to their new socket paths.
+func (s *Server) ReloadConfig() (*configutil.Changes, error) {
+	changes, err := s.config.ReloadConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.config.SetupLogging(); err != nil {
+		return changes, err
+	}
+
+	s.tracker.SetDefaultTimeout(s.config.RequestTimeout())
+
+	for taskName, t := range s.tasks {
+		if err := t.setSocketPath(s.TaskSocketPath(taskName)); err != nil {
+			return changes, err
+		}
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"applied":         changes.Applied,
+		"restartRequired": changes.RestartRequired,
+	}).Info("config reloaded")
+	return changes, nil
+}
+
+// ReloadOnSignal will reload the config each time one of the specified
+// signals is received. If no signals are specified, it will use SIGHUP.
+func (s *Server) ReloadOnSignal(signals ...os.Signal) {
+	if len(signals) == 0 {
+		signals = []os.Signal{syscall.SIGHUP}
+	}
+
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, signals...)
+	for sig := range sigChan {
+		logrus.WithFields(logrus.Fields{
+			"signal": sig,
+		}).Info("signal received, reloading config")
+
+		if _, err := s.ReloadConfig(); err != nil {
+			logrus.WithField("error", err).Error("failed to reload config")
+		}
+	}
+}
+
+// reloadConfig is the task handler for reloading the config.
+func (s *Server) reloadConfig(req *acomm.Request) (interface{}, *url.URL, error) {
+	changes, err := s.ReloadConfig()
+	if err != nil {
+		return nil, nil, err
+	}
+	return changes, nil, nil
+}
@@ -1,6 +1,7 @@
 package provider_test
 
 import (
+	"encoding/json"
 	"io/ioutil"
 	"net/url"
 	"os"
@@ -128,6 +129,86 @@ func (s *ServerSuite) TestStartHandleStop() {
 	<-handled
 }
 
+func (s *ServerSuite) TestReloadConfig() {
+	configData := *s.configData
+	configData.ServiceName = uuid.New()
+	config, _, _, configFile, err := newConfig(false, true, &configData)
+	if configFile != nil {
+		defer func() { _ = os.Remove(configFile.Name()) }()
+	}
+	s.Require().NoError(err, "failed to create config")
+	s.Require().NoError(config.LoadConfig(), "failed to load config")
+
+	server, err := provider.NewServer(config)
+	s.Require().NoError(err, "failed to create server")
+	server.RegisterTask("foobar", func(a *acomm.Request) (interface{}, *url.URL, error) {
+		return nil, nil, nil
+	})
+	s.Contains(server.RegisteredTasks(), "foobar")
+	s.Require().NoError(server.Start(), "failed to start server")
+	defer server.Stop()
+	s.Contains(server.RegisteredTasks(), provider.ReloadConfigTask, "should register reload task")
+
+	oldSocket := server.TaskSocketPath("foobar")
+	configData.Tasks = map[string]*provider.TaskConfigData{
+		"foobar": {
+			Priority: 70,
+			Timeout:  64,
+		},
+	}
+	configJSON, _ := json.Marshal(configData)
+	s.Require().NoError(ioutil.WriteFile(configFile.Name(), configJSON, 0644))
+
+	changes, err := server.ReloadConfig()
+	if !s.NoError(err, "failed to reload config") {
+		return
+	}
+	s.Equal([]string{"tasks.foobar.priority"}, changes.Applied)
+
+	newSocket := server.TaskSocketPath("foobar")
+	s.NotEqual(oldSocket, newSocket, "socket path should change")
+	_, err = os.Stat(oldSocket)
+	s.True(os.IsNotExist(err), "old socket should be removed")
+	_, err = os.Stat(newSocket)
+	s.NoError(err, "new socket should exist")
+}
+
+func (s *ServerSuite) TestDrain() {
+	release := make(chan struct{})
+	started := make(chan struct{}, 2)
+	s.server.RegisterTask("foobar", func(a *acomm.Request) (interface{}, *url.URL, error) {
+		started <- struct{}{}
+		<-release
+		return nil, nil, nil
+	})
+	s.Require().NoError(s.server.Start(), "failed to start server")
+	defer s.server.Stop()
+
+	socketPath := s.server.TaskSocketPath("foobar")
+	providerSocket, _ := url.ParseRequestURI("unix://" + socketPath)
+	for i := 0; i < 2; i++ {
+		req, err := acomm.NewRequest(acomm.RequestOptions{Task: "foobar"})
+		s.Require().NoError(err)
+		s.Require().NoError(acomm.Send(providerSocket, req))
+		<-started
+	}
+	s.False(s.server.DrainStatus().Draining)
+	s.Equal(2, s.server.DrainStatus().ActiveRequests)
+
+	s.Error(s.server.Drain(time.Second), "should time out with active requests")
+	_, err := os.Stat(socketPath)
+	s.True(os.IsNotExist(err), "task socket should be removed")
+	s.True(s.server.DrainStatus().Draining)
+
+	drained := make(chan error)
+	go func() {
+		drained <- s.server.Drain(5 * time.Second)
+	}()
+	close(release)
+	s.NoError(<-drained, "should finish draining")
+	s.Equal(0, s.server.DrainStatus().ActiveRequests)
+}
+
 func (s *ServerSuite) TestStopOnSignal() {
 	selfProcess, err := os.FindProcess(os.Getpid())
 	if !s.NoError(err, "couldn't find this process") {
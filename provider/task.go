@@ -4,6 +4,7 @@ import (
 	"net"
 	"net/url"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/Sirupsen/logrus"
@@ -22,7 +23,10 @@ type task struct {
 	handler      TaskHandler
 	reqTimeout   time.Duration
 	reqListener  *acomm.UnixListener
+	running      bool
+	lock         sync.Mutex // Protects reqListener and running
 	waitgroup    sync.WaitGroup
+	active       int32
 }
 
 // newTask creates and initializes a new task.
@@ -38,35 +42,88 @@ func newTask(name, providerName, socketPath string, reqTimeout time.Duration, ha
 
 // start starts the task handler.
 func (t *task) start() error {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
 	if err := t.reqListener.Start(); err != nil {
 		return err
 	}
+	t.running = true
 
-	go t.handleConns()
+	go t.handleConns(t.reqListener)
 	return nil
 }
 
 // stop shuts down the task handler.
 func (t *task) stop() {
-	// Stop request listener and handle all open connections
-	t.reqListener.Stop(0)
+	t.stopListener()
 
 	// Wait for all actively handled requests
 	t.waitgroup.Wait()
 }
 
-func (t *task) handleConns() {
+// stopListener stops accepting new requests, removing the task socket, and
+// handles all open connections. Requests already accepted continue to be
+// handled.
+func (t *task) stopListener() {
+	t.lock.Lock()
+	reqListener := t.reqListener
+	running := t.running
+	t.running = false
+	t.lock.Unlock()
+
+	if running {
+		reqListener.Stop(0)
+	}
+}
+
+// activeRequests returns the number of requests currently being handled.
+func (t *task) activeRequests() int {
+	return int(atomic.LoadInt32(&t.active))
+}
+
+// setSocketPath moves the task's request listener to a new socket path. When
+// the task is running, the new listener is started before the old one is
+// stopped so that the task remains available throughout.
+func (t *task) setSocketPath(socketPath string) error {
+	t.lock.Lock()
+	oldListener := t.reqListener
+	if oldListener.Addr() == socketPath {
+		t.lock.Unlock()
+		return nil
+	}
+
+	reqListener := acomm.NewUnixListener(socketPath, 0)
+	if !t.running {
+		t.reqListener = reqListener
+		t.lock.Unlock()
+		return nil
+	}
+
+	if err := reqListener.Start(); err != nil {
+		t.lock.Unlock()
+		return err
+	}
+	t.reqListener = reqListener
+	t.lock.Unlock()
+
+	go t.handleConns(reqListener)
+	oldListener.Stop(0)
+	return nil
+}
+
+func (t *task) handleConns(reqListener *acomm.UnixListener) {
 	for {
-		conn := t.reqListener.NextConn()
+		conn := reqListener.NextConn()
 		if conn == nil {
 			return
 		}
-		go t.acceptRequest(conn)
+		go t.acceptRequest(reqListener, conn)
 	}
 }
 
-func (t *task) acceptRequest(conn net.Conn) {
-	defer t.reqListener.DoneConn(conn)
+func (t *task) acceptRequest(reqListener *acomm.UnixListener, conn net.Conn) {
+	defer reqListener.DoneConn(conn)
 	var respErr error
 
 	req := &acomm.Request{}
@@ -96,6 +153,7 @@ func (t *task) acceptRequest(conn net.Conn) {
 	}
 	// Actually perform the task
 	t.waitgroup.Add(1)
+	atomic.AddInt32(&t.active, 1)
 	go t.handleRequest(req)
 }
 
@@ -103,6 +161,7 @@ func (t *task) acceptRequest(conn net.Conn) {
 // request's response hook.
 func (t *task) handleRequest(req *acomm.Request) {
 	defer t.waitgroup.Done()
+	defer atomic.AddInt32(&t.active, -1)
 
 	// Run the task-specific request handler
 	result, streamAddr, taskErr := t.handler(req)
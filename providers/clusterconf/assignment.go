@@ -0,0 +1,200 @@
+package clusterconf
+
+import (
+	"encoding/json"
+	"net/url"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/cerana/cerana/acomm"
+	"github.com/cerana/cerana/pkg/errors"
+)
+
+const assignmentKey string = "nodes"
+
+// BundleAssignment is the set of nodes a bundle has been scheduled to run on.
+type BundleAssignment struct {
+	c        *ClusterConf
+	BundleID uint64   `json:"bundleID"`
+	Nodes    []string `json:"nodes"`
+	// ModIndex should be treated as opaque, but passed back on updates.
+	ModIndex uint64 `json:"modIndex"`
+}
+
+// BundleAssignmentArgs are args for retrieving a bundle assignment.
+type BundleAssignmentArgs struct {
+	BundleID uint64 `json:"bundleID"`
+}
+
+// ListBundleAssignmentsArgs are args for retrieving a list of bundle
+// assignments, optionally limited to those including a node.
+type ListBundleAssignmentsArgs struct {
+	NodeID string `json:"nodeID"`
+}
+
+// BundleAssignmentPayload can be used for task args or result when a bundle
+// assignment object needs to be sent.
+type BundleAssignmentPayload struct {
+	Assignment *BundleAssignment `json:"assignment"`
+}
+
+// BundleAssignmentListResult is the result from listing bundle assignments.
+type BundleAssignmentListResult struct {
+	Assignments []*BundleAssignment `json:"assignments"`
+}
+
+// HasNode returns whether the node is part of the assignment.
+func (a *BundleAssignment) HasNode(nodeID string) bool {
+	for _, id := range a.Nodes {
+		if id == nodeID {
+			return true
+		}
+	}
+	return false
+}
+
+// GetBundleAssignment retrieves the node assignment for a bundle.
+func (c *ClusterConf) GetBundleAssignment(req *acomm.Request) (interface{}, *url.URL, error) {
+	var args BundleAssignmentArgs
+	if err := req.UnmarshalArgs(&args); err != nil {
+		return nil, nil, err
+	}
+	if args.BundleID == 0 {
+		return nil, nil, errors.Newv("missing arg: bundleID", map[string]interface{}{"args": args})
+	}
+
+	assignment, err := c.getBundleAssignment(args.BundleID)
+	if err != nil {
+		return nil, nil, err
+	}
+	return &BundleAssignmentPayload{assignment}, nil, nil
+}
+
+// ListBundleAssignments retrieves the node assignments for all bundles.
+func (c *ClusterConf) ListBundleAssignments(req *acomm.Request) (interface{}, *url.URL, error) {
+	var args ListBundleAssignmentsArgs
+	if err := req.UnmarshalArgs(&args); err != nil {
+		return nil, nil, err
+	}
+
+	ids, err := c.objectIDs(bundlesPrefix)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var wg sync.WaitGroup
+	aChan := make(chan *BundleAssignment, len(ids))
+	errChan := make(chan error, len(ids))
+	for id := range ids {
+		wg.Add(1)
+		go func(id string) {
+			defer wg.Done()
+			bundleID, err := strconv.ParseUint(id, 10, 64)
+			if err != nil {
+				errChan <- errors.Wrapv(err, map[string]interface{}{"bundleID": id})
+				return
+			}
+			assignment, err := c.getBundleAssignment(bundleID)
+			if err != nil {
+				errChan <- err
+				return
+			}
+			aChan <- assignment
+		}(id)
+	}
+	wg.Wait()
+
+	close(aChan)
+	close(errChan)
+
+	if len(errChan) > 0 {
+		err := <-errChan
+		return nil, nil, err
+	}
+
+	assignments := make([]*BundleAssignment, 0, len(aChan))
+	for assignment := range aChan {
+		// Bundles that haven't been scheduled have no stored assignment
+		if assignment.ModIndex == 0 {
+			continue
+		}
+		if args.NodeID != "" && !assignment.HasNode(args.NodeID) {
+			continue
+		}
+		assignments = append(assignments, assignment)
+	}
+
+	return &BundleAssignmentListResult{assignments}, nil, nil
+}
+
+// UpdateBundleAssignment creates or updates the node assignment for a bundle.
+// When updating, a Get should first be performed and the modified
+// BundleAssignment passed back.
+func (c *ClusterConf) UpdateBundleAssignment(req *acomm.Request) (interface{}, *url.URL, error) {
+	var args BundleAssignmentPayload
+	if err := req.UnmarshalArgs(&args); err != nil {
+		return nil, nil, err
+	}
+	if args.Assignment == nil {
+		return nil, nil, errors.Newv("missing arg: assignment", map[string]interface{}{"args": args})
+	}
+	if args.Assignment.BundleID == 0 {
+		return nil, nil, errors.Newv("missing arg: assignment.bundleID", map[string]interface{}{"args": args})
+	}
+	args.Assignment.c = c
+
+	// Don't leave assignments behind for bundles that don't exist
+	if _, err := c.getBundle(args.Assignment.BundleID); err != nil {
+		return nil, nil, err
+	}
+
+	if err := args.Assignment.update(); err != nil {
+		return nil, nil, err
+	}
+	return &BundleAssignmentPayload{args.Assignment}, nil, nil
+}
+
+func (c *ClusterConf) getBundleAssignment(bundleID uint64) (*BundleAssignment, error) {
+	assignment := &BundleAssignment{
+		c:        c,
+		BundleID: bundleID,
+		Nodes:    []string{},
+	}
+
+	key := path.Join(bundlesPrefix, strconv.FormatUint(bundleID, 10), assignmentKey)
+	value, err := c.kvGet(key)
+	if err != nil {
+		if strings.Contains(err.Error(), "key not found") {
+			// A bundle that hasn't been scheduled yet has no nodes
+			return assignment, nil
+		}
+		return nil, errors.Wrapv(err, map[string]interface{}{"bundleID": bundleID})
+	}
+
+	if err := json.Unmarshal(value.Data, assignment); err != nil {
+		return nil, errors.Wrapv(err, map[string]interface{}{"json": string(value.Data)})
+	}
+	assignment.ModIndex = value.Index
+	return assignment, nil
+}
+
+// update saves the bundle assignment.
+func (a *BundleAssignment) update() error {
+	key := path.Join(bundlesPrefix, strconv.FormatUint(a.BundleID, 10), assignmentKey)
+
+	if a.Nodes == nil {
+		a.Nodes = []string{}
+	}
+	sort.Strings(a.Nodes)
+
+	index, err := a.c.kvUpdate(key, a, a.ModIndex)
+	if err != nil {
+		return errors.Wrapv(err, map[string]interface{}{"bundleID": a.BundleID})
+	}
+	a.ModIndex = index
+
+	return nil
+}
@@ -0,0 +1,164 @@
+package clusterconf_test
+
+import (
+	"math/rand"
+	"path"
+	"strconv"
+
+	"github.com/cerana/cerana/acomm"
+	"github.com/cerana/cerana/providers/clusterconf"
+)
+
+func (s *clusterConf) TestGetBundleAssignment() {
+	assignment, err := s.addBundleAssignment("node1", "node2")
+	s.Require().NoError(err)
+
+	tests := []struct {
+		desc     string
+		bundleID uint64
+		nodes    []string
+		err      string
+	}{
+		{"zero id", 0, nil, "missing arg: bundleID"},
+		{"unscheduled bundle", uint64(rand.Int63()), []string{}, ""},
+		{"scheduled bundle", assignment.BundleID, []string{"node1", "node2"}, ""},
+	}
+
+	for _, test := range tests {
+		req, err := acomm.NewRequest(acomm.RequestOptions{
+			Task: "get-bundle-assignment",
+			Args: &clusterconf.BundleAssignmentArgs{BundleID: test.bundleID},
+		})
+		s.Require().NoError(err, test.desc)
+		result, streamURL, err := s.clusterConf.GetBundleAssignment(req)
+		s.Nil(streamURL, test.desc)
+		if test.err != "" {
+			s.Contains(err.Error(), test.err, test.desc)
+			s.Nil(result, test.desc)
+			continue
+		}
+		if !s.NoError(err, test.desc) {
+			continue
+		}
+		payload, ok := result.(*clusterconf.BundleAssignmentPayload)
+		if !s.True(ok, test.desc) {
+			continue
+		}
+		s.Equal(test.bundleID, payload.Assignment.BundleID, test.desc)
+		s.Equal(test.nodes, payload.Assignment.Nodes, test.desc)
+	}
+}
+
+func (s *clusterConf) TestListBundleAssignments() {
+	assignment, err := s.addBundleAssignment("node1", "node2")
+	s.Require().NoError(err)
+	assignment2, err := s.addBundleAssignment("node2")
+	s.Require().NoError(err)
+
+	tests := []struct {
+		desc      string
+		nodeID    string
+		bundleIDs []uint64
+	}{
+		{"all", "", []uint64{assignment.BundleID, assignment2.BundleID}},
+		{"single node", "node1", []uint64{assignment.BundleID}},
+		{"shared node", "node2", []uint64{assignment.BundleID, assignment2.BundleID}},
+		{"unused node", "node3", []uint64{}},
+	}
+
+	for _, test := range tests {
+		req, err := acomm.NewRequest(acomm.RequestOptions{
+			Task: "list-bundle-assignments",
+			Args: &clusterconf.ListBundleAssignmentsArgs{NodeID: test.nodeID},
+		})
+		s.Require().NoError(err, test.desc)
+		result, streamURL, err := s.clusterConf.ListBundleAssignments(req)
+		s.Nil(streamURL, test.desc)
+		if !s.NoError(err, test.desc) {
+			continue
+		}
+		list, ok := result.(*clusterconf.BundleAssignmentListResult)
+		if !s.True(ok, test.desc) {
+			continue
+		}
+		bundleIDs := make([]uint64, 0, len(list.Assignments))
+		for _, a := range list.Assignments {
+			bundleIDs = append(bundleIDs, a.BundleID)
+		}
+		s.Len(bundleIDs, len(test.bundleIDs), test.desc)
+		for _, id := range test.bundleIDs {
+			s.Contains(bundleIDs, id, test.desc)
+		}
+	}
+}
+
+func (s *clusterConf) TestUpdateBundleAssignment() {
+	bundle, err := s.addBundle()
+	s.Require().NoError(err)
+	assignment, err := s.addBundleAssignment("node1")
+	s.Require().NoError(err)
+
+	tests := []struct {
+		desc     string
+		bundleID uint64
+		modIndex uint64
+		err      string
+	}{
+		{"zero id", 0, 0, "missing arg: assignment.bundleID"},
+		{"nonexistent bundle", uint64(rand.Int63()), 0, "bundle config not found"},
+		{"new assignment", bundle.ID, 0, ""},
+		{"create existing assignment", assignment.BundleID, 0, "CAS failed"},
+		{"update existing assignment", assignment.BundleID, assignment.ModIndex, ""},
+	}
+
+	for _, test := range tests {
+		req, err := acomm.NewRequest(acomm.RequestOptions{
+			Task: "update-bundle-assignment",
+			Args: &clusterconf.BundleAssignmentPayload{
+				Assignment: &clusterconf.BundleAssignment{
+					BundleID: test.bundleID,
+					Nodes:    []string{"node3", "node2"},
+					ModIndex: test.modIndex,
+				},
+			},
+		})
+		s.Require().NoError(err, test.desc)
+		result, streamURL, err := s.clusterConf.UpdateBundleAssignment(req)
+		s.Nil(streamURL, test.desc)
+		if test.err != "" {
+			s.Contains(err.Error(), test.err, test.desc)
+			s.Nil(result, test.desc)
+			continue
+		}
+		if !s.NoError(err, test.desc) {
+			continue
+		}
+		payload, ok := result.(*clusterconf.BundleAssignmentPayload)
+		if !s.True(ok, test.desc) {
+			continue
+		}
+		s.Equal([]string{"node2", "node3"}, payload.Assignment.Nodes, test.desc)
+		s.NotEqual(test.modIndex, payload.Assignment.ModIndex, test.desc)
+	}
+}
+
+func (s *clusterConf) addBundleAssignment(nodes ...string) (*clusterconf.BundleAssignment, error) {
+	bundle, err := s.addBundle()
+	if err != nil {
+		return nil, err
+	}
+
+	assignment := &clusterconf.BundleAssignment{
+		BundleID: bundle.ID,
+		Nodes:    nodes,
+	}
+	key := path.Join("bundles", strconv.FormatUint(bundle.ID, 10), "nodes")
+
+	indexes, err := s.loadData(map[string]interface{}{key: assignment})
+	if err != nil {
+		return nil, err
+	}
+	assignment.ModIndex = indexes[key]
+
+	return assignment, nil
+}
@@ -0,0 +1,357 @@
+package clusterconf
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"path"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/cerana/cerana/acomm"
+	"github.com/cerana/cerana/pkg/errors"
+	"github.com/pborman/uuid"
+)
+
+const auditPrefix string = "audit"
+
+// auditPruneWrites is how many audit events are saved between prunes of
+// expired events.
+const auditPruneWrites = 100
+
+// Audit event actions.
+const (
+	AuditCreate = "create"
+	AuditUpdate = "update"
+	AuditDelete = "delete"
+)
+
+// Object types of audit events, in addition to those of config problems and
+// import changes.
+const (
+	secretObject         = "secret"
+	bundleTemplateObject = "bundleTemplate"
+)
+
+// redactedFields are stored fields whose values are replaced with a digest in
+// audit events.
+var redactedFields = map[string]bool{"ciphertext": true}
+
+// AuditEvent is a record of a change made to the cluster configuration.
+type AuditEvent struct {
+	ID        string    `json:"id"`
+	Timestamp time.Time `json:"timestamp"`
+	// Task is the task that made the change.
+	Task      string `json:"task"`
+	RequestID string `json:"requestID"`
+	// Author identifies who made the change, if given to the task.
+	Author     string `json:"author"`
+	ObjectType string `json:"objectType"`
+	ObjectID   string `json:"objectID"`
+	// Action is one of create, update, or delete.
+	Action  string         `json:"action"`
+	Changes []*AuditChange `json:"changes"`
+}
+
+// AuditChange is a changed value of a config object. Path is the dotted path of
+// the changed field, e.g. "services.foo.cmd", and is empty when the whole
+// object was created or deleted.
+type AuditChange struct {
+	Path   string      `json:"path"`
+	Before interface{} `json:"before"`
+	After  interface{} `json:"after"`
+}
+
+// ListAuditEventsArgs are arguments for ListAuditEvents. Events are only
+// returned if they match all of the set filters.
+type ListAuditEventsArgs struct {
+	// Since and Until bound the time of the events.
+	Since      time.Time `json:"since"`
+	Until      time.Time `json:"until"`
+	Task       string    `json:"task"`
+	Author     string    `json:"author"`
+	ObjectType string    `json:"objectType"`
+	ObjectID   string    `json:"objectID"`
+	// Limit is the maximum number of events returned, the most recent ones.
+	Limit int `json:"limit"`
+}
+
+// AuditEventListResult is the result from listing audit events, newest first.
+type AuditEventListResult struct {
+	Events []*AuditEvent `json:"events"`
+}
+
+// ListAuditEvents retrieves audit events of changes to the cluster
+// configuration.
+func (c *ClusterConf) ListAuditEvents(req *acomm.Request) (interface{}, *url.URL, error) {
+	var args ListAuditEventsArgs
+	if err := req.UnmarshalArgs(&args); err != nil {
+		return nil, nil, err
+	}
+	if args.Limit < 0 {
+		return nil, nil, errors.Newv("invalid arg: limit", map[string]interface{}{"args": args})
+	}
+
+	// Event ids sort by time, so events can be selected by their keys and
+	// only those in range retrieved
+	keys, err := c.kvKeys(auditPrefix)
+	if err != nil {
+		return nil, nil, err
+	}
+	ids := make([]string, 0, len(keys))
+	for _, key := range keys {
+		if id := path.Base(key); id != auditPrefix && args.matchID(id) {
+			ids = append(ids, id)
+		}
+	}
+	sort.Sort(sort.Reverse(sort.StringSlice(ids)))
+
+	events := make([]*AuditEvent, 0)
+	for _, id := range ids {
+		if args.Limit > 0 && len(events) >= args.Limit {
+			break
+		}
+
+		key := path.Join(auditPrefix, id)
+		value, err := c.kvGet(key)
+		if err != nil {
+			if strings.Contains(err.Error(), "key not found") {
+				// pruned since the keys were listed
+				continue
+			}
+			return nil, nil, err
+		}
+		event := &AuditEvent{}
+		if err := json.Unmarshal(value.Data, event); err != nil {
+			return nil, nil, errors.Wrapv(err, map[string]interface{}{"key": key})
+		}
+		if args.match(event) {
+			events = append(events, event)
+		}
+	}
+	return &AuditEventListResult{events}, nil, nil
+}
+
+// matchID returns whether the time an audit event id is prefixed by passes the
+// time filters.
+func (a ListAuditEventsArgs) matchID(id string) bool {
+	if !a.Since.IsZero() && id < auditIDPrefix(a.Since) {
+		return false
+	}
+	if !a.Until.IsZero() {
+		until := auditIDPrefix(a.Until)
+		if len(id) >= len(until) && id[:len(until)] > until {
+			return false
+		}
+	}
+	return true
+}
+
+// match returns whether an audit event passes the filters.
+func (a ListAuditEventsArgs) match(event *AuditEvent) bool {
+	if !a.Since.IsZero() && event.Timestamp.Before(a.Since) {
+		return false
+	}
+	if !a.Until.IsZero() && event.Timestamp.After(a.Until) {
+		return false
+	}
+	if a.Task != "" && event.Task != a.Task {
+		return false
+	}
+	if a.Author != "" && event.Author != a.Author {
+		return false
+	}
+	if a.ObjectType != "" && event.ObjectType != a.ObjectType {
+		return false
+	}
+	if a.ObjectID != "" && event.ObjectID != a.ObjectID {
+		return false
+	}
+	return true
+}
+
+// limit orders events newest first and applies the limit.
+func (a ListAuditEventsArgs) limit(events []*AuditEvent) []*AuditEvent {
+	sort.Sort(auditEventsByNewest(events))
+	if a.Limit > 0 && len(events) > a.Limit {
+		events = events[:a.Limit]
+	}
+	return events
+}
+
+// audited makes a change to the config object stored at key and records an
+// audit event of it. The change is made once it succeeds, so failing to record
+// the event is logged rather than returned.
+func (c *ClusterConf) audited(req *acomm.Request, author, objectType, objectID, key string, change func() error) error {
+	before, err := c.auditState(key)
+	if err != nil {
+		return err
+	}
+	if err := change(); err != nil {
+		return err
+	}
+	c.recordAuditEvent(req, author, objectType, objectID, key, before)
+	return nil
+}
+
+// recordAuditEvent records an audit event of a change made to the config
+// object stored at key, logging any failure.
+func (c *ClusterConf) recordAuditEvent(req *acomm.Request, author, objectType, objectID, key string, before interface{}) {
+	after, err := c.auditState(key)
+	if err == nil {
+		err = c.saveAuditEvent(&AuditEvent{
+			Timestamp:  time.Now(),
+			Task:       req.Task,
+			RequestID:  req.ID,
+			Author:     author,
+			ObjectType: objectType,
+			ObjectID:   objectID,
+			Action:     auditAction(before, after),
+			Changes:    auditChanges("", before, after),
+		})
+	}
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"error":      err,
+			"task":       req.Task,
+			"requestID":  req.ID,
+			"objectType": objectType,
+			"objectID":   objectID,
+		}).Error("failed to record audit event")
+	}
+}
+
+// auditState retrieves the decoded config stored at key, with redacted fields
+// replaced. It is nil if nothing is stored.
+func (c *ClusterConf) auditState(key string) (interface{}, error) {
+	value, err := c.kvGet(key)
+	if err != nil {
+		if strings.Contains(err.Error(), "key not found") {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var state interface{}
+	if err := json.Unmarshal(value.Data, &state); err != nil {
+		return nil, errors.Wrapv(err, map[string]interface{}{"key": key})
+	}
+	if fields, ok := state.(map[string]interface{}); ok {
+		for name, field := range fields {
+			if redactedFields[name] {
+				fields[name] = fmt.Sprintf("sha256:%x", sha256.Sum256([]byte(fmt.Sprint(field))))
+			}
+		}
+	}
+	return state, nil
+}
+
+// saveAuditEvent saves an audit event. Every auditPruneWrites events, those
+// older than the configured retention are pruned.
+func (c *ClusterConf) saveAuditEvent(event *AuditEvent) error {
+	event.ID = auditEventID(event.Timestamp)
+	key := path.Join(auditPrefix, event.ID)
+	if _, err := c.kvUpdate(key, event, 0); err != nil {
+		return errors.Wrapv(err, map[string]interface{}{"task": event.Task, "objectID": event.ObjectID}, "failed to save audit event")
+	}
+
+	if !c.auditPruneDue() {
+		return nil
+	}
+	keys, err := c.kvKeys(auditPrefix)
+	if err != nil {
+		return err
+	}
+	cutoff := auditIDPrefix(event.Timestamp.Add(-c.config.AuditRetention()))
+	for _, key := range keys {
+		if path.Base(key) >= cutoff {
+			continue
+		}
+		if err := c.kvDelete(key, 0); err != nil {
+			return errors.Wrapv(err, map[string]interface{}{"key": key}, "failed to prune audit event")
+		}
+	}
+	return nil
+}
+
+// auditPruneDue counts an audit event write and returns whether expired events
+// should be pruned. The first write prunes, so events expired while the
+// provider wasn't running are cleaned up.
+func (c *ClusterConf) auditPruneDue() bool {
+	c.auditLock.Lock()
+	defer c.auditLock.Unlock()
+
+	due := c.auditWrites%auditPruneWrites == 0
+	c.auditWrites++
+	return due
+}
+
+// auditEventID returns a unique id for an event at the time, prefixed by the
+// zero padded timestamp so ids sort by time.
+func auditEventID(timestamp time.Time) string {
+	return fmt.Sprintf("%s-%s", auditIDPrefix(timestamp), uuid.New())
+}
+
+// auditIDPrefix returns the time prefix of audit event ids.
+func auditIDPrefix(timestamp time.Time) string {
+	return fmt.Sprintf("%020d", timestamp.UnixNano())
+}
+
+func auditAction(before, after interface{}) string {
+	switch {
+	case before == nil:
+		return AuditCreate
+	case after == nil:
+		return AuditDelete
+	default:
+		return AuditUpdate
+	}
+}
+
+// auditChanges returns the changes between two decoded configs. Objects are
+// compared field by field and other values as a whole. ModIndex changes are
+// left out, since every update has one.
+func auditChanges(prefix string, before, after interface{}) []*AuditChange {
+	beforeFields, beforeOK := before.(map[string]interface{})
+	afterFields, afterOK := after.(map[string]interface{})
+	if !beforeOK || !afterOK {
+		if reflect.DeepEqual(before, after) {
+			return nil
+		}
+		return []*AuditChange{{Path: prefix, Before: before, After: after}}
+	}
+
+	names := make([]string, 0, len(beforeFields)+len(afterFields))
+	for name := range beforeFields {
+		names = append(names, name)
+	}
+	for name := range afterFields {
+		if _, ok := beforeFields[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	changes := make([]*AuditChange, 0)
+	for _, name := range names {
+		if prefix == "" && name == "modIndex" {
+			continue
+		}
+		fieldPath := name
+		if prefix != "" {
+			fieldPath = prefix + "." + name
+		}
+		changes = append(changes, auditChanges(fieldPath, beforeFields[name], afterFields[name])...)
+	}
+	return changes
+}
+
+type auditEventsByNewest []*AuditEvent
+
+func (e auditEventsByNewest) Len() int           { return len(e) }
+func (e auditEventsByNewest) Swap(i, j int)      { e[i], e[j] = e[j], e[i] }
+func (e auditEventsByNewest) Less(i, j int) bool { return e[i].ID > e[j].ID }
@@ -0,0 +1,128 @@
+package clusterconf_test
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/cerana/cerana/acomm"
+	"github.com/cerana/cerana/providers/clusterconf"
+)
+
+func (s *clusterConf) TestListAuditEvents() {
+	start := time.Now()
+	bundle, err := s.addBundle()
+	s.Require().NoError(err)
+	bundleID := strconv.FormatUint(bundle.ID, 10)
+
+	bundle.Redundancy = 3
+	bundle = s.updateBundle(bundle, "alice")
+
+	dataset, err := s.addDataset()
+	s.Require().NoError(err)
+	req, err := acomm.NewRequest(acomm.RequestOptions{
+		Task: "delete-dataset",
+		Args: &clusterconf.DeleteArgs{ID: dataset.ID, Author: "bob"},
+	})
+	s.Require().NoError(err)
+	_, _, err = s.clusterConf.DeleteDataset(req)
+	s.Require().NoError(err)
+
+	tests := []struct {
+		desc   string
+		args   *clusterconf.ListAuditEventsArgs
+		err    string
+		events []string
+	}{
+		{"invalid limit", &clusterconf.ListAuditEventsArgs{Limit: -1}, "invalid arg: limit", nil},
+		{"all", &clusterconf.ListAuditEventsArgs{}, "", []string{dataset.ID, bundleID}},
+		{"limit", &clusterconf.ListAuditEventsArgs{Limit: 1}, "", []string{dataset.ID}},
+		{"author", &clusterconf.ListAuditEventsArgs{Author: "alice"}, "", []string{bundleID}},
+		{"task", &clusterconf.ListAuditEventsArgs{Task: "delete-dataset"}, "", []string{dataset.ID}},
+		{"object", &clusterconf.ListAuditEventsArgs{ObjectType: "bundle", ObjectID: bundleID}, "", []string{bundleID}},
+		{"since", &clusterconf.ListAuditEventsArgs{Since: time.Now()}, "", []string{}},
+		{"until", &clusterconf.ListAuditEventsArgs{Until: start}, "", []string{}},
+	}
+
+	for _, test := range tests {
+		req, err := acomm.NewRequest(acomm.RequestOptions{
+			Task: "list-audit-events",
+			Args: test.args,
+		})
+		s.Require().NoError(err, test.desc)
+		result, streamURL, err := s.clusterConf.ListAuditEvents(req)
+		s.Nil(streamURL, test.desc)
+		if test.err != "" {
+			s.Contains(err.Error(), test.err, test.desc)
+			s.Nil(result, test.desc)
+			continue
+		}
+		if !s.NoError(err, test.desc) {
+			continue
+		}
+		events := result.(*clusterconf.AuditEventListResult).Events
+		ids := make([]string, len(events))
+		for i, event := range events {
+			ids[i] = event.ObjectID
+		}
+		s.Equal(test.events, ids, test.desc)
+	}
+
+	events := s.listAuditEvents(&clusterconf.ListAuditEventsArgs{})
+	s.Require().Len(events, 2)
+
+	deleted := events[0]
+	s.Equal(clusterconf.AuditDelete, deleted.Action)
+	s.Equal("bob", deleted.Author)
+	s.Equal(req.ID, deleted.RequestID)
+	if s.Len(deleted.Changes, 1) {
+		s.Empty(deleted.Changes[0].Path)
+		s.Nil(deleted.Changes[0].After)
+	}
+
+	updated := events[1]
+	s.Equal(clusterconf.AuditUpdate, updated.Action)
+	s.Equal("update-bundle", updated.Task)
+	s.Equal("alice", updated.Author)
+	if s.Len(updated.Changes, 1) {
+		s.Equal("redundancy", updated.Changes[0].Path)
+		s.Equal(float64(0), updated.Changes[0].Before)
+		s.Equal(float64(3), updated.Changes[0].After)
+	}
+}
+
+func (s *clusterConf) TestAuditEventPruning() {
+	bundle, err := s.addBundle()
+	s.Require().NoError(err)
+	bundle.Redundancy = 3
+	bundle = s.updateBundle(bundle, "alice")
+	s.Require().Len(s.listAuditEvents(&clusterconf.ListAuditEventsArgs{}), 1)
+
+	defer s.viper.Set("audit_retention", "")
+	s.viper.Set("audit_retention", "1ms")
+	time.Sleep(10 * time.Millisecond)
+
+	// a new instance prunes on its first write
+	req, err := acomm.NewRequest(acomm.RequestOptions{
+		Task: "delete-bundle",
+		Args: &clusterconf.DeleteArgs{ID: strconv.FormatUint(bundle.ID, 10), Author: "bob"},
+	})
+	s.Require().NoError(err)
+	_, _, err = clusterconf.New(s.config, s.tracker).DeleteBundle(req)
+	s.Require().NoError(err)
+
+	events := s.listAuditEvents(&clusterconf.ListAuditEventsArgs{})
+	if s.Len(events, 1) {
+		s.Equal("delete-bundle", events[0].Task)
+	}
+}
+
+func (s *clusterConf) listAuditEvents(args *clusterconf.ListAuditEventsArgs) []*clusterconf.AuditEvent {
+	req, err := acomm.NewRequest(acomm.RequestOptions{
+		Task: "list-audit-events",
+		Args: args,
+	})
+	s.Require().NoError(err)
+	result, _, err := s.clusterConf.ListAuditEvents(req)
+	s.Require().NoError(err)
+	return result.(*clusterconf.AuditEventListResult).Events
+}
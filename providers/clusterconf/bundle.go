@@ -36,10 +36,28 @@ type Bundle struct {
 	Services   map[string]BundleService `json:"services"`
 	Redundancy uint64                   `json:"redundancy"`
 	Ports      BundlePorts              `json:"ports"`
+	Placement  BundlePlacement          `json:"placement"`
+	// Project is the project the bundle belongs to, if any. Its services
+	// and datasets must be shared or belong to the same project.
+	Project string `json:"project"`
+	// Template is set for bundles instantiated from a bundle template.
+	Template *BundleTemplateRef `json:"template,omitempty"`
 	// ModIndex should be treated as opaque, but passed back on updates.
 	ModIndex uint64 `json:"modIndex"`
 }
 
+// BundlePlacement is constraints on the nodes a bundle is placed on.
+type BundlePlacement struct {
+	// NodeSelector is labels a node must have, with matching values.
+	NodeSelector map[string]string `json:"nodeSelector"`
+	// Tolerations are node taints that don't keep the bundle off of a node.
+	Tolerations []Taint `json:"tolerations"`
+	// AntiAffinity is a node label, e.g. "rack", that must have a different
+	// value on each node running a copy of the bundle. Nodes without the
+	// label share the empty value.
+	AntiAffinity string `json:"antiAffinity"`
+}
+
 // BundlePorts is a map of port numbers to port information.
 type BundlePorts map[int]BundlePort
 
@@ -56,19 +74,19 @@ func (p BundlePorts) MarshalJSON() ([]byte, error) {
 
 // UnmarshalJSON unmarshals JSON into a BundlePorts, converting string keys to
 // ints.
-func (p BundlePorts) UnmarshalJSON(data []byte) error {
+func (p *BundlePorts) UnmarshalJSON(data []byte) error {
 	ports := make(map[string]BundlePort)
 	if err := json.Unmarshal(data, &ports); err != nil {
 		return errors.Wrapv(err, map[string]interface{}{"json": string(data)})
 	}
 
-	p = make(BundlePorts)
+	*p = make(BundlePorts)
 	for port, value := range ports {
 		portI, err := strconv.Atoi(port)
 		if err != nil {
 			return errors.Wrapv(err, map[string]interface{}{"port": port})
 		}
-		p[portI] = value
+		(*p)[portI] = value
 	}
 	return nil
 }
@@ -128,6 +146,7 @@ func (s BundleService) overlayOn(base *Service) (BundleService, error) {
 	}
 
 	// overlay data
+	result.Project = base.Project
 	if result.Dataset == "" {
 		result.Dataset = base.Dataset
 	}
@@ -181,6 +200,8 @@ type BundlePort struct {
 // DeleteBundleArgs are args for bundle delete task.
 type DeleteBundleArgs struct {
 	ID uint64 `json:"id"`
+	// Author identifies who made the change, recorded in the audit log.
+	Author string `json:"author,omitempty"`
 }
 
 // GetBundleArgs are args for retrieving a bundle.
@@ -192,12 +213,18 @@ type GetBundleArgs struct {
 // ListBundleArgs are args for retrieving a bundle list.
 type ListBundleArgs struct {
 	CombinedOverlay bool `json:"overlay"`
+	// Project filters on bundles of the project.
+	Project string `json:"project"`
 }
 
 // BundlePayload can be used for task args or result when a bundle object needs
 // to be sent.
 type BundlePayload struct {
 	Bundle *Bundle `json:"bundle"`
+	// Author identifies who made a change, recorded in the revision history.
+	Author string `json:"author,omitempty"`
+	// Force skips checking that referenced datasets and services exist.
+	Force bool `json:"force,omitempty"`
 }
 
 // BundleListResult is the result from listing bundles.
@@ -225,7 +252,7 @@ func (c *ClusterConf) GetBundle(req *acomm.Request) (interface{}, *url.URL, erro
 			return nil, nil, err
 		}
 	}
-	return &BundlePayload{bundle}, nil, nil
+	return &BundlePayload{Bundle: bundle}, nil, nil
 }
 
 // ListBundles retrieves a list of all bundles.
@@ -235,10 +262,25 @@ func (c *ClusterConf) ListBundles(req *acomm.Request) (interface{}, *url.URL, er
 		return nil, nil, err
 	}
 
-	keys, err := c.kvKeys(bundlesPrefix)
+	bundles, err := c.getBundles(args.CombinedOverlay)
 	if err != nil {
 		return nil, nil, err
 	}
+	if args.Project != "" {
+		bundles = projectBundles(bundles, args.Project)
+	}
+
+	return &BundleListResult{
+		Bundles: bundles,
+	}, nil, nil
+}
+
+// getBundles retrieves all bundles, optionally as their combined overlays.
+func (c *ClusterConf) getBundles(combined bool) ([]*Bundle, error) {
+	keys, err := c.kvKeys(bundlesPrefix)
+	if err != nil {
+		return nil, err
+	}
 	// extract and deduplicate the bundle ids
 	ids := make(map[uint64]bool)
 	keyFormat := filepath.Join(bundlesPrefix, "%d")
@@ -246,7 +288,7 @@ func (c *ClusterConf) ListBundles(req *acomm.Request) (interface{}, *url.URL, er
 		var id uint64
 		_, err := fmt.Sscanf(key, keyFormat, &id)
 		if err != nil {
-			return nil, nil, errors.Newv("failed to extract valid bundle id", map[string]interface{}{"key": key, "keyFormat": keyFormat})
+			return nil, errors.Newv("failed to extract valid bundle id", map[string]interface{}{"key": key, "keyFormat": keyFormat})
 		}
 		ids[id] = true
 	}
@@ -263,7 +305,7 @@ func (c *ClusterConf) ListBundles(req *acomm.Request) (interface{}, *url.URL, er
 				errChan <- err
 				return
 			}
-			if args.CombinedOverlay {
+			if combined {
 				bundle, err = bundle.combinedOverlay()
 				if err != nil {
 					errChan <- err
@@ -280,19 +322,19 @@ func (c *ClusterConf) ListBundles(req *acomm.Request) (interface{}, *url.URL, er
 
 	if len(errChan) > 0 {
 		err := <-errChan
-		return nil, nil, err
+		return nil, err
 	}
 	bundles := make([]*Bundle, 0, len(bundleChan))
 	for bundle := range bundleChan {
 		bundles = append(bundles, bundle)
 	}
 
-	return &BundleListResult{
-		Bundles: bundles,
-	}, nil, nil
+	return bundles, nil
 }
 
 // UpdateBundle creates or updates a bundle config. When updating, a Get should first be performed and the modified Bundle passed back.
+// Public ports without an external port are allocated one. External ports must
+// be within the configured port range.
 func (c *ClusterConf) UpdateBundle(req *acomm.Request) (interface{}, *url.URL, error) {
 	var args BundlePayload
 	if err := req.UnmarshalArgs(&args); err != nil {
@@ -308,10 +350,33 @@ func (c *ClusterConf) UpdateBundle(req *acomm.Request) (interface{}, *url.URL, e
 		args.Bundle.ID = uint64(rand.Int63())
 	}
 
-	if err := args.Bundle.update(); err != nil {
+	if err := args.Bundle.validateHealthChecks(); err != nil {
+		return nil, nil, err
+	}
+	unlock, err := c.lockChanges()
+	if err != nil {
+		return nil, nil, err
+	}
+	defer unlock()
+	if !args.Force {
+		if err := args.Bundle.checkReferences(); err != nil {
+			return nil, nil, err
+		}
+	}
+	if err := args.Bundle.checkProject(); err != nil {
 		return nil, nil, err
 	}
-	return &BundlePayload{args.Bundle}, nil, nil
+	if err := args.Bundle.allocatePorts(); err != nil {
+		return nil, nil, err
+	}
+	bundleID := strconv.FormatUint(args.Bundle.ID, 10)
+	if err := c.audited(req, args.Author, bundleReference, bundleID, path.Join(bundleKey(args.Bundle.ID), "config"), args.Bundle.update); err != nil {
+		return nil, nil, err
+	}
+	if err := c.saveRevision(bundleKey(args.Bundle.ID), args.Bundle.ModIndex, args.Author, args.Bundle); err != nil {
+		return nil, nil, err
+	}
+	return &BundlePayload{Bundle: args.Bundle}, nil, nil
 }
 
 // DeleteBundle deletes a bundle config.
@@ -332,7 +397,7 @@ func (c *ClusterConf) DeleteBundle(req *acomm.Request) (interface{}, *url.URL, e
 		return nil, nil, err
 	}
 
-	return nil, nil, bundle.delete()
+	return nil, nil, c.audited(req, args.Author, bundleReference, strconv.FormatUint(args.ID, 10), path.Join(bundleKey(args.ID), "config"), bundle.delete)
 }
 
 func (c *ClusterConf) getBundle(id uint64) (*Bundle, error) {
@@ -367,7 +432,7 @@ func (b *Bundle) reload() error {
 
 func (b *Bundle) delete() error {
 	key := path.Join(bundlesPrefix, strconv.FormatUint(b.ID, 10))
-	return errors.Wrapv(b.c.kvDelete(key, b.ModIndex), map[string]interface{}{"bundleID": b.ID})
+	return errors.Wrapv(b.c.kvDeleteObject(key, b.ModIndex), map[string]interface{}{"bundleID": b.ID})
 }
 
 // update saves the core bundle config.
@@ -384,6 +449,7 @@ func (b *Bundle) update() error {
 }
 
 // combinedOverlay will create a new *Bundle object containing the base configurations of datasets and services with the bundle values overlayed on top.
+// Values still unset are taken from the cluster defaults.
 // Note: Attempting to save a combined overlay bundle will result in an error.
 func (b *Bundle) combinedOverlay() (*Bundle, error) {
 	var wg sync.WaitGroup
@@ -452,6 +518,11 @@ func (b *Bundle) combinedOverlay() (*Bundle, error) {
 	wg.Wait()
 
 	if len(errorChan) == 0 {
+		defaults, err := b.c.getDefaults()
+		if err != nil {
+			return nil, err
+		}
+		defaults.applyToBundle(&result)
 		return &result, nil
 	}
 
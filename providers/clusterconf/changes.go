@@ -0,0 +1,483 @@
+package clusterconf
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"net/url"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/cerana/cerana/acomm"
+	"github.com/cerana/cerana/pkg/errors"
+	kvp "github.com/cerana/cerana/providers/kv"
+	"github.com/pborman/uuid"
+)
+
+const (
+	changesLockKey string = "locks/cluster-changes"
+	changesLockTTL        = time.Minute
+	// changesLockWait is how long to wait for another change to release the
+	// cluster changes lock.
+	changesLockWait  = 30 * time.Second
+	changesLockRetry = 100 * time.Millisecond
+)
+
+// Cluster change actions.
+const (
+	// ChangeUpdate creates or updates the object.
+	ChangeUpdate = "update"
+	// ChangeDelete deletes the object.
+	ChangeDelete = "delete"
+)
+
+// ClusterChange is a change to a bundle, service, or dataset. Exactly one of
+// the objects is set. Objects to delete only need their id.
+type ClusterChange struct {
+	Action  string   `json:"action"`
+	Bundle  *Bundle  `json:"bundle,omitempty"`
+	Service *Service `json:"service,omitempty"`
+	Dataset *Dataset `json:"dataset,omitempty"`
+}
+
+// ApplyChangesArgs are arguments for ApplyClusterChanges.
+type ApplyChangesArgs struct {
+	Changes []*ClusterChange `json:"changes"`
+	// Author identifies who made the changes, recorded in the revision
+	// history.
+	Author string `json:"author,omitempty"`
+	// Force skips checking that the bundles' datasets and services exist
+	// after the changes.
+	Force bool `json:"force,omitempty"`
+}
+
+// ApplyChangesResult is the result of applying cluster changes. The changes'
+// objects have their ids and new ModIndexes.
+type ApplyChangesResult struct {
+	Changes []*ClusterChange `json:"changes"`
+}
+
+// ApplyClusterChanges creates, updates, and deletes bundles, services, and
+// datasets together. The changes are checked together, as the resulting
+// config, e.g. a new bundle may include a service created in the same batch.
+// Either all changes are applied or, if one fails, those already applied are
+// reverted. Batches are applied under the cluster changes lock, one at a time.
+func (c *ClusterConf) ApplyClusterChanges(req *acomm.Request) (interface{}, *url.URL, error) {
+	var args ApplyChangesArgs
+	if err := req.UnmarshalArgs(&args); err != nil {
+		return nil, nil, err
+	}
+	if len(args.Changes) == 0 {
+		return nil, nil, errors.Newv("missing arg: changes", map[string]interface{}{"args": args})
+	}
+
+	batch, err := c.newChangeBatch(args.Changes)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	unlock, err := c.lockChanges()
+	if err != nil {
+		return nil, nil, err
+	}
+	defer unlock()
+
+	state, err := c.getProjectState(true)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := batch.check(state, args.Force); err != nil {
+		return nil, nil, err
+	}
+	if err := batch.commit(req, args.Author); err != nil {
+		return nil, nil, err
+	}
+	return &ApplyChangesResult{Changes: args.Changes}, nil, nil
+}
+
+// lockChanges takes the cluster changes lock, waiting for another change to
+// release it, and keeps it renewed until the returned func releases it. The
+// lock serializes changes that are checked against the rest of the cluster
+// config. Changes to a single object without such checks only rely on the
+// object's ModIndex to detect concurrent updates.
+func (c *ClusterConf) lockChanges() (func(), error) {
+	ctx := context.Background()
+	args := kvp.LockArgs{Key: changesLockKey, TTL: changesLockTTL}
+	deadline := time.Now().Add(changesLockWait)
+	cookie, err := c.kv().Lock(ctx, args)
+	for err != nil && strings.Contains(err.Error(), "lock held by another client") && time.Now().Before(deadline) {
+		time.Sleep(changesLockRetry)
+		cookie, err = c.kv().Lock(ctx, args)
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to lock cluster changes")
+	}
+
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(changesLockTTL / 3)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				if err := c.kv().Renew(ctx, cookie); err != nil {
+					logrus.WithField("error", err).Error("failed to renew cluster changes lock")
+				}
+			case <-stop:
+				return
+			}
+		}
+	}()
+
+	return func() {
+		close(stop)
+		<-done
+		_ = c.kv().Unlock(ctx, cookie)
+	}, nil
+}
+
+// changeBatch is a batch of cluster changes to apply together.
+type changeBatch struct {
+	c       *ClusterConf
+	changes []*batchChange
+}
+
+// batchChange is a cluster change with the details needed to apply and revert
+// it.
+type batchChange struct {
+	*ClusterChange
+	objectType string
+	objectID   string
+	// object is the bundle, service, or dataset of the change.
+	object interface{}
+	// previous is the object's config before the change, nil if it is
+	// created.
+	previous interface{}
+}
+
+// newChangeBatch checks the changes on their own and assigns ids to new
+// objects.
+func (c *ClusterConf) newChangeBatch(changes []*ClusterChange) (*changeBatch, error) {
+	batch := &changeBatch{c: c, changes: make([]*batchChange, 0, len(changes))}
+	seen := make(map[string]bool)
+	for i, change := range changes {
+		if change == nil {
+			return nil, errors.Newv("missing arg: change", map[string]interface{}{"index": i})
+		}
+		if change.Action != ChangeUpdate && change.Action != ChangeDelete {
+			return nil, errors.Newv("invalid arg: action", map[string]interface{}{"index": i, "action": change.Action})
+		}
+
+		objects := 0
+		bc := &batchChange{ClusterChange: change}
+		if change.Bundle != nil {
+			objects++
+			change.Bundle.c = c
+			if change.Bundle.ID == 0 && change.Action == ChangeUpdate {
+				rand.Seed(time.Now().UnixNano())
+				change.Bundle.ID = uint64(rand.Int63())
+			}
+			if err := change.Bundle.validateHealthChecks(); err != nil {
+				return nil, err
+			}
+			bc.objectType, bc.objectID, bc.object = bundleReference, strconv.FormatUint(change.Bundle.ID, 10), change.Bundle
+		}
+		if change.Service != nil {
+			objects++
+			change.Service.c = c
+			if change.Service.ID == "" && change.Action == ChangeUpdate {
+				change.Service.ID = uuid.New()
+			}
+			if err := validateHealthChecks(change.Service.HealthChecks); err != nil {
+				return nil, err
+			}
+			bc.objectType, bc.objectID, bc.object = serviceReference, change.Service.ID, change.Service
+		}
+		if change.Dataset != nil {
+			objects++
+			change.Dataset.c = c
+			if change.Dataset.ID == "" && change.Action == ChangeUpdate {
+				change.Dataset.ID = uuid.New()
+			}
+			bc.objectType, bc.objectID, bc.object = datasetReference, change.Dataset.ID, change.Dataset
+		}
+		if objects != 1 {
+			return nil, errors.Newv("change must have exactly one object", map[string]interface{}{"index": i})
+		}
+		if bc.objectID == "" || bc.objectID == "0" {
+			return nil, errors.Newv("missing arg: id", map[string]interface{}{"index": i, "type": bc.objectType})
+		}
+
+		key := bc.objectType + "/" + bc.objectID
+		if seen[key] {
+			return nil, errors.Newv("object changed more than once", map[string]interface{}{"type": bc.objectType, "id": bc.objectID})
+		}
+		seen[key] = true
+		batch.changes = append(batch.changes, bc)
+	}
+	return batch, nil
+}
+
+// check makes the changes to the current state and checks the result: deleted
+// objects must exist, bundles are allocated ports, and the changes may not
+// add project or, unless forced, reference problems.
+func (b *changeBatch) check(state *projectState, force bool) error {
+	for _, change := range b.changes {
+		switch object := change.object.(type) {
+		case *Bundle:
+			if previous, ok := state.bundles[object.ID]; ok {
+				change.previous = previous
+			}
+		case *Service:
+			if previous, ok := state.services[object.ID]; ok {
+				change.previous = previous
+			}
+		case *Dataset:
+			if previous, ok := state.datasets[object.ID]; ok {
+				change.previous = previous
+			}
+		}
+		if change.Action == ChangeDelete && change.previous == nil {
+			return errors.Newv(change.objectType+" config not found", map[string]interface{}{"id": change.objectID})
+		}
+	}
+
+	previousProblems := state.referenceProblems()
+	if err := state.checkChange(b.apply); err != nil {
+		return err
+	}
+	if err := b.allocatePorts(state); err != nil {
+		return err
+	}
+	if force {
+		return nil
+	}
+
+	problems := make(configProblems, 0)
+	for key, problem := range state.referenceProblems() {
+		if _, ok := previousProblems[key]; !ok {
+			problems = append(problems, problem)
+		}
+	}
+	if len(problems) > 0 {
+		sort.Sort(problems)
+		return errors.Newv("cluster changes have invalid references", map[string]interface{}{"problems": problems})
+	}
+	return nil
+}
+
+// apply makes the changes to the state.
+func (b *changeBatch) apply(s *projectState) {
+	for _, change := range b.changes {
+		switch object := change.object.(type) {
+		case *Bundle:
+			if change.Action == ChangeDelete {
+				delete(s.bundles, object.ID)
+			} else {
+				s.bundles[object.ID] = object
+			}
+		case *Service:
+			if change.Action == ChangeDelete {
+				delete(s.services, object.ID)
+			} else {
+				s.services[object.ID] = object
+			}
+		case *Dataset:
+			if change.Action == ChangeDelete {
+				delete(s.datasets, object.ID)
+			} else {
+				s.datasets[object.ID] = object
+			}
+		}
+	}
+}
+
+// allocatePorts allocates external ports to the updated bundles, in order, so
+// bundles of the batch don't get the same ports.
+func (b *changeBatch) allocatePorts(state *projectState) error {
+	min, max, err := b.c.config.PortRange()
+	if err != nil {
+		return err
+	}
+
+	for _, change := range b.changes {
+		bundle, ok := change.object.(*Bundle)
+		if !ok || change.Action == ChangeDelete {
+			continue
+		}
+		bundles := make([]*Bundle, 0, len(state.bundles))
+		for _, other := range state.bundles {
+			bundles = append(bundles, other)
+		}
+		used := make(map[int]uint64)
+		for _, mapping := range portMap(bundles) {
+			if mapping.BundleID != bundle.ID {
+				used[mapping.ExternalPort] = mapping.BundleID
+			}
+		}
+		if err := bundle.assignPorts(used, min, max); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// commit saves the changes' configs, updates before deletes. If a save fails,
+// the configs already saved are restored. Revisions and audit events are only
+// recorded once all of the configs are saved, and failing to record them is
+// logged rather than returned, since the changes have been made.
+func (b *changeBatch) commit(req *acomm.Request, author string) error {
+	changes := make([]*batchChange, 0, len(b.changes))
+	for _, action := range []string{ChangeUpdate, ChangeDelete} {
+		for _, change := range b.changes {
+			if change.Action == action {
+				changes = append(changes, change)
+			}
+		}
+	}
+
+	auditStates := make([]interface{}, len(changes))
+	for i, change := range changes {
+		auditState, err := b.c.auditState(change.configKey())
+		if err == nil {
+			auditStates[i] = auditState
+			object := change.object
+			if change.Action == ChangeDelete {
+				object = change.previous
+			}
+			err = b.save(object, change.Action)
+		}
+		if err == nil {
+			continue
+		}
+
+		revertErrs := make([]string, 0)
+		for j := i - 1; j >= 0; j-- {
+			if revertErr := b.revert(changes[j]); revertErr != nil {
+				revertErrs = append(revertErrs, revertErr.Error())
+			}
+		}
+		return errors.Wrapv(err, map[string]interface{}{
+			"type":         change.objectType,
+			"id":           change.objectID,
+			"action":       change.Action,
+			"revertErrors": revertErrs,
+		}, "failed to apply cluster change")
+	}
+
+	for i, change := range changes {
+		if change.Action == ChangeUpdate {
+			if err := b.c.saveRevision(change.objectKey(), *objectModIndex(change.object), author, change.object); err != nil {
+				logrus.WithFields(logrus.Fields{
+					"error": err,
+					"type":  change.objectType,
+					"id":    change.objectID,
+				}).Error("failed to save cluster change revision")
+			}
+		}
+		b.c.recordAuditEvent(req, author, change.objectType, change.objectID, change.configKey(), auditStates[i])
+	}
+	return nil
+}
+
+// revert undoes a saved change, restoring the previous config. Deleted
+// bundles only have their config restored, not their node assignments or
+// revisions.
+func (b *changeBatch) revert(change *batchChange) error {
+	var err error
+	if change.previous == nil {
+		err = b.save(change.object, ChangeDelete)
+	} else {
+		var modIndex uint64
+		if change.Action == ChangeUpdate {
+			modIndex = *objectModIndex(change.object)
+		}
+		*objectModIndex(change.previous) = modIndex
+		err = b.save(change.previous, ChangeUpdate)
+	}
+	return errors.Wrapv(err, map[string]interface{}{"type": change.objectType, "id": change.objectID}, "failed to revert cluster change")
+}
+
+// save updates or deletes the config of a bundle, service, or dataset.
+func (b *changeBatch) save(object interface{}, action string) error {
+	switch object := object.(type) {
+	case *Bundle:
+		if action == ChangeDelete {
+			return object.delete()
+		}
+		return object.update()
+	case *Service:
+		if action == ChangeDelete {
+			return object.delete()
+		}
+		return object.update()
+	case *Dataset:
+		if action == ChangeDelete {
+			return object.delete()
+		}
+		return object.update()
+	}
+	return errors.New("unknown object type")
+}
+
+// objectKey returns the key the change's object is stored under.
+func (change *batchChange) objectKey() string {
+	switch change.objectType {
+	case bundleReference:
+		return path.Join(bundlesPrefix, change.objectID)
+	case serviceReference:
+		return path.Join(servicesPrefix, change.objectID)
+	}
+	return path.Join(datasetsPrefix, change.objectID)
+}
+
+// configKey returns the key the config of the change's object is stored at.
+func (change *batchChange) configKey() string {
+	return path.Join(change.objectKey(), "config")
+}
+
+// objectModIndex returns the ModIndex field of a bundle, service, or dataset.
+func objectModIndex(object interface{}) *uint64 {
+	switch object := object.(type) {
+	case *Bundle:
+		return &object.ModIndex
+	case *Service:
+		return &object.ModIndex
+	case *Dataset:
+		return &object.ModIndex
+	}
+	return new(uint64)
+}
+
+// referenceProblems returns the problems of the bundles in the state, keyed
+// by a description of the problem.
+func (s *projectState) referenceProblems() map[string]*ConfigProblem {
+	ids := map[string]map[string]bool{
+		bundleReference:  make(map[string]bool),
+		serviceReference: make(map[string]bool),
+		datasetReference: make(map[string]bool),
+	}
+	for id := range s.bundles {
+		ids[bundleReference][strconv.FormatUint(id, 10)] = true
+	}
+	for id := range s.services {
+		ids[serviceReference][id] = true
+	}
+	for id := range s.datasets {
+		ids[datasetReference][id] = true
+	}
+
+	problems := make(map[string]*ConfigProblem)
+	for _, bundle := range s.bundles {
+		for _, problem := range bundle.problems(ids[datasetReference], ids[serviceReference], ids[bundleReference]) {
+			problems[fmt.Sprintf("%d %s %s %s", problem.BundleID, problem.Type, problem.ID, problem.Message)] = problem
+		}
+	}
+	return problems
+}
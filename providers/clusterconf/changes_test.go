@@ -0,0 +1,90 @@
+package clusterconf_test
+
+import (
+	"github.com/cerana/cerana/acomm"
+	"github.com/cerana/cerana/providers/clusterconf"
+)
+
+func (s *clusterConf) TestApplyClusterChanges() {
+	dataset := &clusterconf.Dataset{Quota: 5}
+	service := &clusterconf.Service{ServiceConf: clusterconf.ServiceConf{Dataset: "testds"}}
+
+	tests := []struct {
+		desc    string
+		changes []*clusterconf.ClusterChange
+		err     string
+	}{
+		{"missing changes", nil, "missing arg: changes"},
+		{"invalid action", []*clusterconf.ClusterChange{{Action: "foo", Dataset: dataset}}, "invalid arg: action"},
+		{"missing object", []*clusterconf.ClusterChange{{Action: clusterconf.ChangeUpdate}}, "change must have exactly one object"},
+		{"missing reference", []*clusterconf.ClusterChange{
+			{Action: clusterconf.ChangeUpdate, Bundle: &clusterconf.Bundle{
+				Services: map[string]clusterconf.BundleService{"foo": {ServiceConf: clusterconf.ServiceConf{ID: "foo"}}},
+			}},
+		}, "cluster changes have invalid references"},
+		{"unknown delete", []*clusterconf.ClusterChange{{Action: clusterconf.ChangeDelete, Service: &clusterconf.Service{ServiceConf: clusterconf.ServiceConf{ID: "foo"}}}}, "service config not found"},
+		{"valid", []*clusterconf.ClusterChange{
+			{Action: clusterconf.ChangeUpdate, Dataset: dataset},
+			{Action: clusterconf.ChangeUpdate, Service: service},
+		}, ""},
+	}
+
+	for _, test := range tests {
+		result, err := s.applyClusterChanges(test.changes)
+		if test.err != "" {
+			s.Contains(err.Error(), test.err, test.desc)
+			continue
+		}
+		if s.NoError(err, test.desc) {
+			for _, change := range result.Changes {
+				if change.Dataset != nil {
+					s.NotZero(change.Dataset.ModIndex, test.desc)
+				}
+				if change.Service != nil {
+					s.NotZero(change.Service.ModIndex, test.desc)
+				}
+			}
+		}
+	}
+
+	// A bundle can be created with the dataset and service it includes
+	newDataset := &clusterconf.Dataset{Quota: 5}
+	newService := &clusterconf.Service{ServiceConf: clusterconf.ServiceConf{ID: "web"}}
+	bundle := &clusterconf.Bundle{
+		Services: map[string]clusterconf.BundleService{"web": {ServiceConf: clusterconf.ServiceConf{ID: "web"}}},
+	}
+	result, err := s.applyClusterChanges([]*clusterconf.ClusterChange{
+		{Action: clusterconf.ChangeUpdate, Dataset: newDataset},
+		{Action: clusterconf.ChangeUpdate, Service: newService},
+		{Action: clusterconf.ChangeUpdate, Bundle: bundle},
+	})
+	s.Require().NoError(err)
+	s.Len(result.Changes, 3)
+	s.NotZero(s.getBundle(bundle.ID).ModIndex)
+
+	// Services still included in bundles can't be deleted
+	_, err = s.applyClusterChanges([]*clusterconf.ClusterChange{
+		{Action: clusterconf.ChangeDelete, Service: newService},
+	})
+	s.Contains(err.Error(), "cluster changes have invalid references")
+
+	_, err = s.applyClusterChanges([]*clusterconf.ClusterChange{
+		{Action: clusterconf.ChangeDelete, Bundle: bundle},
+		{Action: clusterconf.ChangeDelete, Service: newService},
+	})
+	s.NoError(err)
+}
+
+func (s *clusterConf) applyClusterChanges(changes []*clusterconf.ClusterChange) (*clusterconf.ApplyChangesResult, error) {
+	req, err := acomm.NewRequest(acomm.RequestOptions{
+		Task: "apply-cluster-changes",
+		Args: &clusterconf.ApplyChangesArgs{Changes: changes},
+	})
+	s.Require().NoError(err)
+	result, streamURL, err := s.clusterConf.ApplyClusterChanges(req)
+	s.Nil(streamURL)
+	if err != nil {
+		return nil, err
+	}
+	return result.(*clusterconf.ApplyChangesResult), nil
+}
@@ -0,0 +1,814 @@
+// Code generated by clientgen. DO NOT EDIT.
+
+package clusterconf
+
+import (
+	"net/url"
+
+	"github.com/cerana/cerana/acomm"
+	kvp "github.com/cerana/cerana/providers/kv"
+	"golang.org/x/net/context"
+)
+
+// Client makes requests for the clusterconf provider's tasks through a
+// coordinator, handling request tracking and result decoding.
+type Client struct {
+	tracker     *acomm.Tracker
+	coordinator *url.URL
+}
+
+// NewClient creates a new Client. Requests are sent to the coordinator and
+// tracked by the tracker, which must already be started.
+func NewClient(tracker *acomm.Tracker, coordinator *url.URL) *Client {
+	return &Client{
+		tracker:     tracker,
+		coordinator: coordinator,
+	}
+}
+
+// ApplyClusterChanges makes a `apply-cluster-changes` request.
+func (c *Client) ApplyClusterChanges(ctx context.Context, args ApplyChangesArgs) (*ApplyChangesResult, error) {
+	opts := acomm.RequestOptions{
+		Task: "apply-cluster-changes",
+		Args: args,
+	}
+	var result *ApplyChangesResult
+	_, err := c.tracker.Call(ctx, c.coordinator, opts, &result)
+	return result, err
+}
+
+// BundleHeartbeat makes a `bundle-heartbeat` request.
+func (c *Client) BundleHeartbeat(ctx context.Context, args BundleHeartbeatArgs) error {
+	opts := acomm.RequestOptions{
+		Task: "bundle-heartbeat",
+		Args: args,
+	}
+	_, err := c.tracker.Call(ctx, c.coordinator, opts, nil)
+	return err
+}
+
+// DatasetHeartbeat makes a `dataset-heartbeat` request.
+func (c *Client) DatasetHeartbeat(ctx context.Context, args DatasetHeartbeatArgs) error {
+	opts := acomm.RequestOptions{
+		Task: "dataset-heartbeat",
+		Args: args,
+	}
+	_, err := c.tracker.Call(ctx, c.coordinator, opts, nil)
+	return err
+}
+
+// DeleteBundle makes a `delete-bundle` request.
+func (c *Client) DeleteBundle(ctx context.Context, args DeleteBundleArgs) error {
+	opts := acomm.RequestOptions{
+		Task: "delete-bundle",
+		Args: args,
+	}
+	_, err := c.tracker.Call(ctx, c.coordinator, opts, nil)
+	return err
+}
+
+// DeleteBundleTemplate makes a `delete-bundle-template` request.
+func (c *Client) DeleteBundleTemplate(ctx context.Context, args DeleteArgs) error {
+	opts := acomm.RequestOptions{
+		Task: "delete-bundle-template",
+		Args: args,
+	}
+	_, err := c.tracker.Call(ctx, c.coordinator, opts, nil)
+	return err
+}
+
+// DeleteDataset makes a `delete-dataset` request.
+func (c *Client) DeleteDataset(ctx context.Context, args DeleteArgs) error {
+	opts := acomm.RequestOptions{
+		Task: "delete-dataset",
+		Args: args,
+	}
+	_, err := c.tracker.Call(ctx, c.coordinator, opts, nil)
+	return err
+}
+
+// DeleteNodeConfig makes a `delete-node-config` request.
+func (c *Client) DeleteNodeConfig(ctx context.Context, args IDArgs) error {
+	opts := acomm.RequestOptions{
+		Task: "delete-node-config",
+		Args: args,
+	}
+	_, err := c.tracker.Call(ctx, c.coordinator, opts, nil)
+	return err
+}
+
+// DeleteProject makes a `delete-project` request.
+func (c *Client) DeleteProject(ctx context.Context, args IDArgs) error {
+	opts := acomm.RequestOptions{
+		Task: "delete-project",
+		Args: args,
+	}
+	_, err := c.tracker.Call(ctx, c.coordinator, opts, nil)
+	return err
+}
+
+// DeleteSecret makes a `delete-secret` request.
+func (c *Client) DeleteSecret(ctx context.Context, args IDArgs) error {
+	opts := acomm.RequestOptions{
+		Task: "delete-secret",
+		Args: args,
+	}
+	_, err := c.tracker.Call(ctx, c.coordinator, opts, nil)
+	return err
+}
+
+// DeleteService makes a `delete-service` request.
+func (c *Client) DeleteService(ctx context.Context, args DeleteArgs) error {
+	opts := acomm.RequestOptions{
+		Task: "delete-service",
+		Args: args,
+	}
+	_, err := c.tracker.Call(ctx, c.coordinator, opts, nil)
+	return err
+}
+
+// ExportClusterConfig makes a `export-cluster-config` request.
+func (c *Client) ExportClusterConfig(ctx context.Context) (*url.URL, error) {
+	opts := acomm.RequestOptions{
+		Task: "export-cluster-config",
+	}
+	return c.tracker.Call(ctx, c.coordinator, opts, nil)
+}
+
+// FindSnapshotNode makes a `find-snapshot-node` request.
+func (c *Client) FindSnapshotNode(ctx context.Context, args FindSnapshotNodeArgs) (*SnapshotNodeResult, error) {
+	opts := acomm.RequestOptions{
+		Task: "find-snapshot-node",
+		Args: args,
+	}
+	var result *SnapshotNodeResult
+	_, err := c.tracker.Call(ctx, c.coordinator, opts, &result)
+	return result, err
+}
+
+// GetBundle makes a `get-bundle` request.
+func (c *Client) GetBundle(ctx context.Context, args GetBundleArgs) (*BundlePayload, error) {
+	opts := acomm.RequestOptions{
+		Task: "get-bundle",
+		Args: args,
+	}
+	var result *BundlePayload
+	_, err := c.tracker.Call(ctx, c.coordinator, opts, &result)
+	return result, err
+}
+
+// GetBundleAssignment makes a `get-bundle-assignment` request.
+func (c *Client) GetBundleAssignment(ctx context.Context, args BundleAssignmentArgs) (*BundleAssignmentPayload, error) {
+	opts := acomm.RequestOptions{
+		Task: "get-bundle-assignment",
+		Args: args,
+	}
+	var result *BundleAssignmentPayload
+	_, err := c.tracker.Call(ctx, c.coordinator, opts, &result)
+	return result, err
+}
+
+// GetBundleRevision makes a `get-bundle-revision` request.
+func (c *Client) GetBundleRevision(ctx context.Context, args BundleRevisionArgs) (*BundleRevisionResult, error) {
+	opts := acomm.RequestOptions{
+		Task: "get-bundle-revision",
+		Args: args,
+	}
+	var result *BundleRevisionResult
+	_, err := c.tracker.Call(ctx, c.coordinator, opts, &result)
+	return result, err
+}
+
+// GetBundleStatus makes a `get-bundle-status` request.
+func (c *Client) GetBundleStatus(ctx context.Context, args BundleStatusArgs) (*BundleStatusPayload, error) {
+	opts := acomm.RequestOptions{
+		Task: "get-bundle-status",
+		Args: args,
+	}
+	var result *BundleStatusPayload
+	_, err := c.tracker.Call(ctx, c.coordinator, opts, &result)
+	return result, err
+}
+
+// GetBundleTemplate makes a `get-bundle-template` request.
+func (c *Client) GetBundleTemplate(ctx context.Context, args IDArgs) (*BundleTemplatePayload, error) {
+	opts := acomm.RequestOptions{
+		Task: "get-bundle-template",
+		Args: args,
+	}
+	var result *BundleTemplatePayload
+	_, err := c.tracker.Call(ctx, c.coordinator, opts, &result)
+	return result, err
+}
+
+// GetDHCPConfig makes a `get-dhcp-config` request.
+func (c *Client) GetDHCPConfig(ctx context.Context) (DHCPConfig, error) {
+	opts := acomm.RequestOptions{
+		Task: "get-dhcp-config",
+	}
+	var result DHCPConfig
+	_, err := c.tracker.Call(ctx, c.coordinator, opts, &result)
+	return result, err
+}
+
+// GetDataset makes a `get-dataset` request.
+func (c *Client) GetDataset(ctx context.Context, args IDArgs) (*DatasetPayload, error) {
+	opts := acomm.RequestOptions{
+		Task: "get-dataset",
+		Args: args,
+	}
+	var result *DatasetPayload
+	_, err := c.tracker.Call(ctx, c.coordinator, opts, &result)
+	return result, err
+}
+
+// GetDatasetLineage makes a `get-dataset-lineage` request.
+func (c *Client) GetDatasetLineage(ctx context.Context, args IDArgs) (*DatasetLineageResult, error) {
+	opts := acomm.RequestOptions{
+		Task: "get-dataset-lineage",
+		Args: args,
+	}
+	var result *DatasetLineageResult
+	_, err := c.tracker.Call(ctx, c.coordinator, opts, &result)
+	return result, err
+}
+
+// GetDatasetReplication makes a `get-dataset-replication` request.
+func (c *Client) GetDatasetReplication(ctx context.Context, args IDArgs) (*DatasetReplicationPayload, error) {
+	opts := acomm.RequestOptions{
+		Task: "get-dataset-replication",
+		Args: args,
+	}
+	var result *DatasetReplicationPayload
+	_, err := c.tracker.Call(ctx, c.coordinator, opts, &result)
+	return result, err
+}
+
+// GetDatasetRevision makes a `get-dataset-revision` request.
+func (c *Client) GetDatasetRevision(ctx context.Context, args RevisionArgs) (*DatasetRevisionResult, error) {
+	opts := acomm.RequestOptions{
+		Task: "get-dataset-revision",
+		Args: args,
+	}
+	var result *DatasetRevisionResult
+	_, err := c.tracker.Call(ctx, c.coordinator, opts, &result)
+	return result, err
+}
+
+// GetDefaultOptions makes a `get-default-options` request.
+func (c *Client) GetDefaultOptions(ctx context.Context) (*DefaultsPayload, error) {
+	opts := acomm.RequestOptions{
+		Task: "get-default-options",
+	}
+	var result *DefaultsPayload
+	_, err := c.tracker.Call(ctx, c.coordinator, opts, &result)
+	return result, err
+}
+
+// GetNode makes a `get-node` request.
+func (c *Client) GetNode(ctx context.Context, args IDArgs) (*NodePayload, error) {
+	opts := acomm.RequestOptions{
+		Task: "get-node",
+		Args: args,
+	}
+	var result *NodePayload
+	_, err := c.tracker.Call(ctx, c.coordinator, opts, &result)
+	return result, err
+}
+
+// GetNodeConfig makes a `get-node-config` request.
+func (c *Client) GetNodeConfig(ctx context.Context, args IDArgs) (*NodeConfigPayload, error) {
+	opts := acomm.RequestOptions{
+		Task: "get-node-config",
+		Args: args,
+	}
+	var result *NodeConfigPayload
+	_, err := c.tracker.Call(ctx, c.coordinator, opts, &result)
+	return result, err
+}
+
+// GetNodesHistory makes a `get-nodes-history` request.
+func (c *Client) GetNodesHistory(ctx context.Context, args NodeHistoryArgs) (*NodesHistoryResult, error) {
+	opts := acomm.RequestOptions{
+		Task: "get-nodes-history",
+		Args: args,
+	}
+	var result *NodesHistoryResult
+	_, err := c.tracker.Call(ctx, c.coordinator, opts, &result)
+	return result, err
+}
+
+// GetPortMap makes a `get-port-map` request.
+func (c *Client) GetPortMap(ctx context.Context) (*PortMapResult, error) {
+	opts := acomm.RequestOptions{
+		Task: "get-port-map",
+	}
+	var result *PortMapResult
+	_, err := c.tracker.Call(ctx, c.coordinator, opts, &result)
+	return result, err
+}
+
+// GetProject makes a `get-project` request.
+func (c *Client) GetProject(ctx context.Context, args IDArgs) (*ProjectPayload, error) {
+	opts := acomm.RequestOptions{
+		Task: "get-project",
+		Args: args,
+	}
+	var result *ProjectPayload
+	_, err := c.tracker.Call(ctx, c.coordinator, opts, &result)
+	return result, err
+}
+
+// GetSecret makes a `get-secret` request.
+func (c *Client) GetSecret(ctx context.Context, args IDArgs) (*SecretPayload, error) {
+	opts := acomm.RequestOptions{
+		Task: "get-secret",
+		Args: args,
+	}
+	var result *SecretPayload
+	_, err := c.tracker.Call(ctx, c.coordinator, opts, &result)
+	return result, err
+}
+
+// GetService makes a `get-service` request.
+func (c *Client) GetService(ctx context.Context, args IDArgs) (*ServicePayload, error) {
+	opts := acomm.RequestOptions{
+		Task: "get-service",
+		Args: args,
+	}
+	var result *ServicePayload
+	_, err := c.tracker.Call(ctx, c.coordinator, opts, &result)
+	return result, err
+}
+
+// GetServiceRevision makes a `get-service-revision` request.
+func (c *Client) GetServiceRevision(ctx context.Context, args RevisionArgs) (*ServiceRevisionResult, error) {
+	opts := acomm.RequestOptions{
+		Task: "get-service-revision",
+		Args: args,
+	}
+	var result *ServiceRevisionResult
+	_, err := c.tracker.Call(ctx, c.coordinator, opts, &result)
+	return result, err
+}
+
+// ImportClusterConfig makes a `import-cluster-config` request.
+func (c *Client) ImportClusterConfig(ctx context.Context, args ImportArgs) (*ImportResult, error) {
+	opts := acomm.RequestOptions{
+		Task: "import-cluster-config",
+		Args: args,
+	}
+	var result *ImportResult
+	_, err := c.tracker.Call(ctx, c.coordinator, opts, &result)
+	return result, err
+}
+
+// InstantiateBundle makes a `instantiate-bundle` request.
+func (c *Client) InstantiateBundle(ctx context.Context, args InstantiateBundleArgs) (*BundlePayload, error) {
+	opts := acomm.RequestOptions{
+		Task: "instantiate-bundle",
+		Args: args,
+	}
+	var result *BundlePayload
+	_, err := c.tracker.Call(ctx, c.coordinator, opts, &result)
+	return result, err
+}
+
+// ListAuditEvents makes a `list-audit-events` request.
+func (c *Client) ListAuditEvents(ctx context.Context, args ListAuditEventsArgs) (*AuditEventListResult, error) {
+	opts := acomm.RequestOptions{
+		Task: "list-audit-events",
+		Args: args,
+	}
+	var result *AuditEventListResult
+	_, err := c.tracker.Call(ctx, c.coordinator, opts, &result)
+	return result, err
+}
+
+// ListBundleAssignments makes a `list-bundle-assignments` request.
+func (c *Client) ListBundleAssignments(ctx context.Context, args ListBundleAssignmentsArgs) (*BundleAssignmentListResult, error) {
+	opts := acomm.RequestOptions{
+		Task: "list-bundle-assignments",
+		Args: args,
+	}
+	var result *BundleAssignmentListResult
+	_, err := c.tracker.Call(ctx, c.coordinator, opts, &result)
+	return result, err
+}
+
+// ListBundleHeartbeats makes a `list-bundle-heartbeats` request.
+func (c *Client) ListBundleHeartbeats(ctx context.Context) (BundleHeartbeatList, error) {
+	opts := acomm.RequestOptions{
+		Task: "list-bundle-heartbeats",
+	}
+	var result BundleHeartbeatList
+	_, err := c.tracker.Call(ctx, c.coordinator, opts, &result)
+	return result, err
+}
+
+// ListBundleRevisions makes a `list-bundle-revisions` request.
+func (c *Client) ListBundleRevisions(ctx context.Context, args BundleRevisionArgs) (*RevisionListResult, error) {
+	opts := acomm.RequestOptions{
+		Task: "list-bundle-revisions",
+		Args: args,
+	}
+	var result *RevisionListResult
+	_, err := c.tracker.Call(ctx, c.coordinator, opts, &result)
+	return result, err
+}
+
+// ListBundleStatus makes a `list-bundle-status` request.
+func (c *Client) ListBundleStatus(ctx context.Context) (*BundleStatusListResult, error) {
+	opts := acomm.RequestOptions{
+		Task: "list-bundle-status",
+	}
+	var result *BundleStatusListResult
+	_, err := c.tracker.Call(ctx, c.coordinator, opts, &result)
+	return result, err
+}
+
+// ListBundleTemplates makes a `list-bundle-templates` request.
+func (c *Client) ListBundleTemplates(ctx context.Context) (*BundleTemplateListResult, error) {
+	opts := acomm.RequestOptions{
+		Task: "list-bundle-templates",
+	}
+	var result *BundleTemplateListResult
+	_, err := c.tracker.Call(ctx, c.coordinator, opts, &result)
+	return result, err
+}
+
+// ListBundles makes a `list-bundles` request.
+func (c *Client) ListBundles(ctx context.Context, args ListBundleArgs) (*BundleListResult, error) {
+	opts := acomm.RequestOptions{
+		Task: "list-bundles",
+		Args: args,
+	}
+	var result *BundleListResult
+	_, err := c.tracker.Call(ctx, c.coordinator, opts, &result)
+	return result, err
+}
+
+// ListDatasetHeartbeats makes a `list-dataset-heartbeats` request.
+func (c *Client) ListDatasetHeartbeats(ctx context.Context) (DatasetHeartbeatList, error) {
+	opts := acomm.RequestOptions{
+		Task: "list-dataset-heartbeats",
+	}
+	var result DatasetHeartbeatList
+	_, err := c.tracker.Call(ctx, c.coordinator, opts, &result)
+	return result, err
+}
+
+// ListDatasetReplications makes a `list-dataset-replications` request.
+func (c *Client) ListDatasetReplications(ctx context.Context) (*DatasetReplicationListResult, error) {
+	opts := acomm.RequestOptions{
+		Task: "list-dataset-replications",
+	}
+	var result *DatasetReplicationListResult
+	_, err := c.tracker.Call(ctx, c.coordinator, opts, &result)
+	return result, err
+}
+
+// ListDatasetRevisions makes a `list-dataset-revisions` request.
+func (c *Client) ListDatasetRevisions(ctx context.Context, args RevisionArgs) (*RevisionListResult, error) {
+	opts := acomm.RequestOptions{
+		Task: "list-dataset-revisions",
+		Args: args,
+	}
+	var result *RevisionListResult
+	_, err := c.tracker.Call(ctx, c.coordinator, opts, &result)
+	return result, err
+}
+
+// ListDatasetSnapshots makes a `list-dataset-snapshots` request.
+func (c *Client) ListDatasetSnapshots(ctx context.Context, args IDArgs) (*SnapshotCatalogResult, error) {
+	opts := acomm.RequestOptions{
+		Task: "list-dataset-snapshots",
+		Args: args,
+	}
+	var result *SnapshotCatalogResult
+	_, err := c.tracker.Call(ctx, c.coordinator, opts, &result)
+	return result, err
+}
+
+// ListDatasets makes a `list-datasets` request.
+func (c *Client) ListDatasets(ctx context.Context, args ListDatasetsArgs) (*DatasetListResult, error) {
+	opts := acomm.RequestOptions{
+		Task: "list-datasets",
+		Args: args,
+	}
+	var result *DatasetListResult
+	_, err := c.tracker.Call(ctx, c.coordinator, opts, &result)
+	return result, err
+}
+
+// ListNodeConfigs makes a `list-node-configs` request.
+func (c *Client) ListNodeConfigs(ctx context.Context) (*NodeConfigListResult, error) {
+	opts := acomm.RequestOptions{
+		Task: "list-node-configs",
+	}
+	var result *NodeConfigListResult
+	_, err := c.tracker.Call(ctx, c.coordinator, opts, &result)
+	return result, err
+}
+
+// ListNodes makes a `list-nodes` request.
+func (c *Client) ListNodes(ctx context.Context) (*ListNodesResult, error) {
+	opts := acomm.RequestOptions{
+		Task: "list-nodes",
+	}
+	var result *ListNodesResult
+	_, err := c.tracker.Call(ctx, c.coordinator, opts, &result)
+	return result, err
+}
+
+// ListProjects makes a `list-projects` request.
+func (c *Client) ListProjects(ctx context.Context) (*ProjectListResult, error) {
+	opts := acomm.RequestOptions{
+		Task: "list-projects",
+	}
+	var result *ProjectListResult
+	_, err := c.tracker.Call(ctx, c.coordinator, opts, &result)
+	return result, err
+}
+
+// ListSecrets makes a `list-secrets` request.
+func (c *Client) ListSecrets(ctx context.Context) (*SecretListResult, error) {
+	opts := acomm.RequestOptions{
+		Task: "list-secrets",
+	}
+	var result *SecretListResult
+	_, err := c.tracker.Call(ctx, c.coordinator, opts, &result)
+	return result, err
+}
+
+// ListServiceRevisions makes a `list-service-revisions` request.
+func (c *Client) ListServiceRevisions(ctx context.Context, args RevisionArgs) (*RevisionListResult, error) {
+	opts := acomm.RequestOptions{
+		Task: "list-service-revisions",
+		Args: args,
+	}
+	var result *RevisionListResult
+	_, err := c.tracker.Call(ctx, c.coordinator, opts, &result)
+	return result, err
+}
+
+// ListServices makes a `list-services` request.
+func (c *Client) ListServices(ctx context.Context, args ListServicesArgs) (*ServiceListResult, error) {
+	opts := acomm.RequestOptions{
+		Task: "list-services",
+		Args: args,
+	}
+	var result *ServiceListResult
+	_, err := c.tracker.Call(ctx, c.coordinator, opts, &result)
+	return result, err
+}
+
+// ListTemplateInstances makes a `list-template-instances` request.
+func (c *Client) ListTemplateInstances(ctx context.Context, args IDArgs) (*TemplateInstanceListResult, error) {
+	opts := acomm.RequestOptions{
+		Task: "list-template-instances",
+		Args: args,
+	}
+	var result *TemplateInstanceListResult
+	_, err := c.tracker.Call(ctx, c.coordinator, opts, &result)
+	return result, err
+}
+
+// NodeHeartbeat makes a `node-heartbeat` request.
+func (c *Client) NodeHeartbeat(ctx context.Context, args NodePayload) error {
+	opts := acomm.RequestOptions{
+		Task: "node-heartbeat",
+		Args: args,
+	}
+	_, err := c.tracker.Call(ctx, c.coordinator, opts, nil)
+	return err
+}
+
+// ResolveSecrets makes a `resolve-secrets` request.
+func (c *Client) ResolveSecrets(ctx context.Context, args ResolveSecretsArgs) (*ResolveSecretsResult, error) {
+	opts := acomm.RequestOptions{
+		Task: "resolve-secrets",
+		Args: args,
+	}
+	var result *ResolveSecretsResult
+	_, err := c.tracker.Call(ctx, c.coordinator, opts, &result)
+	return result, err
+}
+
+// RollbackBundle makes a `rollback-bundle` request.
+func (c *Client) RollbackBundle(ctx context.Context, args BundleRevisionArgs) (*BundlePayload, error) {
+	opts := acomm.RequestOptions{
+		Task: "rollback-bundle",
+		Args: args,
+	}
+	var result *BundlePayload
+	_, err := c.tracker.Call(ctx, c.coordinator, opts, &result)
+	return result, err
+}
+
+// RollbackDataset makes a `rollback-dataset` request.
+func (c *Client) RollbackDataset(ctx context.Context, args RevisionArgs) (*DatasetPayload, error) {
+	opts := acomm.RequestOptions{
+		Task: "rollback-dataset",
+		Args: args,
+	}
+	var result *DatasetPayload
+	_, err := c.tracker.Call(ctx, c.coordinator, opts, &result)
+	return result, err
+}
+
+// RollbackService makes a `rollback-service` request.
+func (c *Client) RollbackService(ctx context.Context, args RevisionArgs) (*ServicePayload, error) {
+	opts := acomm.RequestOptions{
+		Task: "rollback-service",
+		Args: args,
+	}
+	var result *ServicePayload
+	_, err := c.tracker.Call(ctx, c.coordinator, opts, &result)
+	return result, err
+}
+
+// RolloutBundleTemplate makes a `rollout-bundle-template` request.
+func (c *Client) RolloutBundleTemplate(ctx context.Context, args RolloutArgs) (*RolloutResult, error) {
+	opts := acomm.RequestOptions{
+		Task: "rollout-bundle-template",
+		Args: args,
+	}
+	var result *RolloutResult
+	_, err := c.tracker.Call(ctx, c.coordinator, opts, &result)
+	return result, err
+}
+
+// SetDHCPConfig makes a `set-dhcp-config` request.
+func (c *Client) SetDHCPConfig(ctx context.Context, args SetDHCPArgs) error {
+	opts := acomm.RequestOptions{
+		Task: "set-dhcp-config",
+		Args: args,
+	}
+	_, err := c.tracker.Call(ctx, c.coordinator, opts, nil)
+	return err
+}
+
+// SetDefaultOptions makes a `set-default-options` request.
+func (c *Client) SetDefaultOptions(ctx context.Context, args DefaultsPayload) (*DefaultsPayload, error) {
+	opts := acomm.RequestOptions{
+		Task: "set-default-options",
+		Args: args,
+	}
+	var result *DefaultsPayload
+	_, err := c.tracker.Call(ctx, c.coordinator, opts, &result)
+	return result, err
+}
+
+// UpdateBundle makes a `update-bundle` request.
+func (c *Client) UpdateBundle(ctx context.Context, args BundlePayload) (*BundlePayload, error) {
+	opts := acomm.RequestOptions{
+		Task: "update-bundle",
+		Args: args,
+	}
+	var result *BundlePayload
+	_, err := c.tracker.Call(ctx, c.coordinator, opts, &result)
+	return result, err
+}
+
+// UpdateBundleAssignment makes a `update-bundle-assignment` request.
+func (c *Client) UpdateBundleAssignment(ctx context.Context, args BundleAssignmentPayload) (*BundleAssignmentPayload, error) {
+	opts := acomm.RequestOptions{
+		Task: "update-bundle-assignment",
+		Args: args,
+	}
+	var result *BundleAssignmentPayload
+	_, err := c.tracker.Call(ctx, c.coordinator, opts, &result)
+	return result, err
+}
+
+// UpdateBundleTemplate makes a `update-bundle-template` request.
+func (c *Client) UpdateBundleTemplate(ctx context.Context, args BundleTemplatePayload) (*BundleTemplatePayload, error) {
+	opts := acomm.RequestOptions{
+		Task: "update-bundle-template",
+		Args: args,
+	}
+	var result *BundleTemplatePayload
+	_, err := c.tracker.Call(ctx, c.coordinator, opts, &result)
+	return result, err
+}
+
+// UpdateDataset makes a `update-dataset` request.
+func (c *Client) UpdateDataset(ctx context.Context, args DatasetPayload) (*DatasetPayload, error) {
+	opts := acomm.RequestOptions{
+		Task: "update-dataset",
+		Args: args,
+	}
+	var result *DatasetPayload
+	_, err := c.tracker.Call(ctx, c.coordinator, opts, &result)
+	return result, err
+}
+
+// UpdateDatasetReplication makes a `update-dataset-replication` request.
+func (c *Client) UpdateDatasetReplication(ctx context.Context, args DatasetReplicationPayload) (*DatasetReplicationPayload, error) {
+	opts := acomm.RequestOptions{
+		Task: "update-dataset-replication",
+		Args: args,
+	}
+	var result *DatasetReplicationPayload
+	_, err := c.tracker.Call(ctx, c.coordinator, opts, &result)
+	return result, err
+}
+
+// UpdateNodeConfig makes a `update-node-config` request.
+func (c *Client) UpdateNodeConfig(ctx context.Context, args NodeConfigPayload) (*NodeConfigPayload, error) {
+	opts := acomm.RequestOptions{
+		Task: "update-node-config",
+		Args: args,
+	}
+	var result *NodeConfigPayload
+	_, err := c.tracker.Call(ctx, c.coordinator, opts, &result)
+	return result, err
+}
+
+// UpdateProject makes a `update-project` request.
+func (c *Client) UpdateProject(ctx context.Context, args ProjectPayload) (*ProjectPayload, error) {
+	opts := acomm.RequestOptions{
+		Task: "update-project",
+		Args: args,
+	}
+	var result *ProjectPayload
+	_, err := c.tracker.Call(ctx, c.coordinator, opts, &result)
+	return result, err
+}
+
+// UpdateSecret makes a `update-secret` request.
+func (c *Client) UpdateSecret(ctx context.Context, args SecretPayload) (*SecretPayload, error) {
+	opts := acomm.RequestOptions{
+		Task: "update-secret",
+		Args: args,
+	}
+	var result *SecretPayload
+	_, err := c.tracker.Call(ctx, c.coordinator, opts, &result)
+	return result, err
+}
+
+// UpdateService makes a `update-service` request.
+func (c *Client) UpdateService(ctx context.Context, args ServicePayload) (*ServicePayload, error) {
+	opts := acomm.RequestOptions{
+		Task: "update-service",
+		Args: args,
+	}
+	var result *ServicePayload
+	_, err := c.tracker.Call(ctx, c.coordinator, opts, &result)
+	return result, err
+}
+
+// ValidateClusterConfig makes a `validate-cluster-config` request.
+func (c *Client) ValidateClusterConfig(ctx context.Context) (*ValidateResult, error) {
+	opts := acomm.RequestOptions{
+		Task: "validate-cluster-config",
+	}
+	var result *ValidateResult
+	_, err := c.tracker.Call(ctx, c.coordinator, opts, &result)
+	return result, err
+}
+
+// WatchBundles makes a `watch-bundles` request.
+func (c *Client) WatchBundles(ctx context.Context, args WatchArgs) (kvp.Cookie, *url.URL, error) {
+	opts := acomm.RequestOptions{
+		Task: "watch-bundles",
+		Args: args,
+	}
+	var result kvp.Cookie
+	respStreamURL, err := c.tracker.Call(ctx, c.coordinator, opts, &result)
+	return result, respStreamURL, err
+}
+
+// WatchDatasets makes a `watch-datasets` request.
+func (c *Client) WatchDatasets(ctx context.Context, args WatchArgs) (kvp.Cookie, *url.URL, error) {
+	opts := acomm.RequestOptions{
+		Task: "watch-datasets",
+		Args: args,
+	}
+	var result kvp.Cookie
+	respStreamURL, err := c.tracker.Call(ctx, c.coordinator, opts, &result)
+	return result, respStreamURL, err
+}
+
+// WatchNodes makes a `watch-nodes` request.
+func (c *Client) WatchNodes(ctx context.Context, args WatchArgs) (kvp.Cookie, *url.URL, error) {
+	opts := acomm.RequestOptions{
+		Task: "watch-nodes",
+		Args: args,
+	}
+	var result kvp.Cookie
+	respStreamURL, err := c.tracker.Call(ctx, c.coordinator, opts, &result)
+	return result, respStreamURL, err
+}
+
+// WatchServices makes a `watch-services` request.
+func (c *Client) WatchServices(ctx context.Context, args WatchArgs) (kvp.Cookie, *url.URL, error) {
+	opts := acomm.RequestOptions{
+		Task: "watch-services",
+		Args: args,
+	}
+	var result kvp.Cookie
+	respStreamURL, err := c.tracker.Call(ctx, c.coordinator, opts, &result)
+	return result, respStreamURL, err
+}
@@ -2,18 +2,30 @@ package clusterconf
 
 import (
 	"encoding/json"
+	"path"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/cerana/cerana/acomm"
 	"github.com/cerana/cerana/pkg/errors"
 	"github.com/cerana/cerana/pkg/kv"
 	"github.com/cerana/cerana/provider"
+	kvp "github.com/cerana/cerana/providers/kv"
+	"golang.org/x/net/context"
 )
 
 // ClusterConf is a provider of cluster configuration functionality.
 type ClusterConf struct {
 	config  *Config
 	tracker *acomm.Tracker
+
+	pruneLock sync.Mutex // Protects lastPrune
+	// lastPrune is when the heartbeat history of each node was last pruned
+	lastPrune map[string]time.Time
+
+	auditLock   sync.Mutex // Protects auditWrites
+	auditWrites int
 }
 
 // IDArgs are arguments for operations requiring only an ID.
@@ -21,14 +33,30 @@ type IDArgs struct {
 	ID string `json:"id"`
 }
 
+// DeleteArgs are arguments for deleting an object that bundles may reference.
+type DeleteArgs struct {
+	ID string `json:"id"`
+	// Force deletes the object even if bundles still reference it.
+	Force bool `json:"force"`
+	// Cascade removes references to the object from bundles before deleting
+	// it.
+	Cascade bool `json:"cascade"`
+	// Author identifies who made the change, recorded in the audit log and
+	// the revision history of bundles updated by a cascade.
+	Author string `json:"author,omitempty"`
+}
+
 // New creates a new instance of ClusterConf
 func New(config *Config, tracker *acomm.Tracker) *ClusterConf {
 	return &ClusterConf{
-		config:  config,
-		tracker: tracker,
+		config:    config,
+		tracker:   tracker,
+		lastPrune: make(map[string]time.Time),
 	}
 }
 
+//go:generate clientgen
+
 // RegisterTasks registers all of Systemd's task handlers with the server.
 func (c *ClusterConf) RegisterTasks(server *provider.Server) {
 	server.RegisterTask("get-bundle", c.GetBundle)
@@ -37,6 +65,23 @@ func (c *ClusterConf) RegisterTasks(server *provider.Server) {
 	server.RegisterTask("delete-bundle", c.DeleteBundle)
 	server.RegisterTask("bundle-heartbeat", c.BundleHeartbeat)
 	server.RegisterTask("list-bundle-heartbeats", c.ListBundleHeartbeats)
+	server.RegisterTask("get-bundle-status", c.GetBundleStatus)
+	server.RegisterTask("list-bundle-status", c.ListBundleStatus)
+	server.RegisterTask("get-port-map", c.GetPortMap)
+	server.RegisterTask("get-bundle-assignment", c.GetBundleAssignment)
+	server.RegisterTask("list-bundle-assignments", c.ListBundleAssignments)
+	server.RegisterTask("update-bundle-assignment", c.UpdateBundleAssignment)
+	server.RegisterTask("watch-bundles", c.WatchBundles)                // clientgen:result kvp.Cookie; stream
+	server.RegisterTask("list-bundle-revisions", c.ListBundleRevisions) // clientgen:result *RevisionListResult
+	server.RegisterTask("get-bundle-revision", c.GetBundleRevision)
+	server.RegisterTask("rollback-bundle", c.RollbackBundle)
+	server.RegisterTask("get-bundle-template", c.GetBundleTemplate)
+	server.RegisterTask("list-bundle-templates", c.ListBundleTemplates)
+	server.RegisterTask("update-bundle-template", c.UpdateBundleTemplate)
+	server.RegisterTask("delete-bundle-template", c.DeleteBundleTemplate)
+	server.RegisterTask("instantiate-bundle", c.InstantiateBundle)
+	server.RegisterTask("list-template-instances", c.ListTemplateInstances)
+	server.RegisterTask("rollout-bundle-template", c.RolloutBundleTemplate) // clientgen:result *RolloutResult
 
 	server.RegisterTask("get-dataset", c.GetDataset)
 	server.RegisterTask("list-datasets", c.ListDatasets)
@@ -44,6 +89,16 @@ func (c *ClusterConf) RegisterTasks(server *provider.Server) {
 	server.RegisterTask("delete-dataset", c.DeleteDataset)
 	server.RegisterTask("dataset-heartbeat", c.DatasetHeartbeat)
 	server.RegisterTask("list-dataset-heartbeats", c.ListDatasetHeartbeats)
+	server.RegisterTask("watch-datasets", c.WatchDatasets)                // clientgen:result kvp.Cookie; stream
+	server.RegisterTask("list-dataset-revisions", c.ListDatasetRevisions) // clientgen:result *RevisionListResult
+	server.RegisterTask("get-dataset-revision", c.GetDatasetRevision)
+	server.RegisterTask("rollback-dataset", c.RollbackDataset)
+	server.RegisterTask("list-dataset-snapshots", c.ListDatasetSnapshots)
+	server.RegisterTask("get-dataset-lineage", c.GetDatasetLineage) // clientgen:result *DatasetLineageResult
+	server.RegisterTask("find-snapshot-node", c.FindSnapshotNode)   // clientgen:result *SnapshotNodeResult
+	server.RegisterTask("get-dataset-replication", c.GetDatasetReplication)
+	server.RegisterTask("list-dataset-replications", c.ListDatasetReplications)
+	server.RegisterTask("update-dataset-replication", c.UpdateDatasetReplication)
 
 	server.RegisterTask("get-default-options", c.GetDefaults)
 	server.RegisterTask("set-default-options", c.UpdateDefaults)
@@ -52,135 +107,151 @@ func (c *ClusterConf) RegisterTasks(server *provider.Server) {
 	server.RegisterTask("get-node", c.GetNode)
 	server.RegisterTask("list-nodes", c.ListNodes)
 	server.RegisterTask("get-nodes-history", c.GetNodesHistory)
+	server.RegisterTask("watch-nodes", c.WatchNodes) // clientgen:result kvp.Cookie; stream
+	server.RegisterTask("get-node-config", c.GetNodeConfig)
+	server.RegisterTask("list-node-configs", c.ListNodeConfigs)
+	server.RegisterTask("update-node-config", c.UpdateNodeConfig)
+	server.RegisterTask("delete-node-config", c.DeleteNodeConfig)
 
 	server.RegisterTask("get-service", c.GetService)
+	server.RegisterTask("list-services", c.ListServices)
 	server.RegisterTask("update-service", c.UpdateService)
 	server.RegisterTask("delete-service", c.DeleteService)
+	server.RegisterTask("watch-services", c.WatchServices)                // clientgen:result kvp.Cookie; stream
+	server.RegisterTask("list-service-revisions", c.ListServiceRevisions) // clientgen:result *RevisionListResult
+	server.RegisterTask("get-service-revision", c.GetServiceRevision)
+	server.RegisterTask("rollback-service", c.RollbackService)
 
 	server.RegisterTask("get-dhcp-config", c.GetDHCP)
 	server.RegisterTask("set-dhcp-config", c.SetDHCP)
-}
-
-func (c *ClusterConf) kvReq(task string, args map[string]interface{}) (*acomm.Response, error) {
-	respChan := make(chan *acomm.Response, 1)
-	defer close(respChan)
-	rh := func(_ *acomm.Request, resp *acomm.Response) {
-		respChan <- resp
-	}
 
-	errData := map[string]interface{}{"task": task, "args": args}
+	server.RegisterTask("get-secret", c.GetSecret)
+	server.RegisterTask("list-secrets", c.ListSecrets)
+	server.RegisterTask("update-secret", c.UpdateSecret)
+	server.RegisterTask("delete-secret", c.DeleteSecret)
+	server.RegisterTask("resolve-secrets", c.ResolveSecrets)
 
-	if val, ok := args["value"]; ok {
-		valJSON, err := json.Marshal(val)
-		if err != nil {
-			return nil, errors.Wrapv(err, errData, "failed to json marshal value")
-		}
-		args["value"] = string(valJSON)
-	}
+	server.RegisterTask("get-project", c.GetProject)
+	server.RegisterTask("list-projects", c.ListProjects)
+	server.RegisterTask("update-project", c.UpdateProject)
+	server.RegisterTask("delete-project", c.DeleteProject)
 
-	req, err := acomm.NewRequest(acomm.RequestOptions{
-		Task:           task,
-		ResponseHook:   c.tracker.URL(),
-		Args:           args,
-		SuccessHandler: rh,
-		ErrorHandler:   rh,
-	})
-	if err != nil {
-		return nil, err
-	}
-	if err := c.tracker.TrackRequest(req, 0); err != nil {
-		return nil, err
-	}
-	if err := acomm.Send(c.config.CoordinatorURL(), req); err != nil {
-		c.tracker.RemoveRequest(req)
-		return nil, err
-	}
+	server.RegisterTask("validate-cluster-config", c.ValidateClusterConfig)
+	server.RegisterTask("export-cluster-config", c.ExportClusterConfig) // clientgen:stream
+	server.RegisterTask("import-cluster-config", c.ImportClusterConfig) // clientgen:result *ImportResult
+	server.RegisterTask("list-audit-events", c.ListAuditEvents)
+	server.RegisterTask("apply-cluster-changes", c.ApplyClusterChanges)
+}
 
-	resp := <-respChan
-	return resp, errors.Wrapv(errors.ResetStack(resp.Error), errData)
+// kv returns a client for the kv provider.
+func (c *ClusterConf) kv() *kvp.Client {
+	return kvp.NewClient(c.tracker, c.config.CoordinatorURL())
 }
 
 func (c *ClusterConf) kvKeys(prefix string) ([]string, error) {
-	args := map[string]interface{}{
-		"key": prefix,
-	}
+	args := kvp.GetArgs{Key: prefix}
+	values, err := c.kv().Keys(context.Background(), args)
+	return values, errors.Wrapv(err, map[string]interface{}{"args": args})
+}
 
-	resp, err := c.kvReq("kv-keys", args)
+// objectIDs returns the deduplicated ids of the objects stored under a prefix.
+func (c *ClusterConf) objectIDs(prefix string) (map[string]bool, error) {
+	keys, err := c.kvKeys(prefix)
 	if err != nil {
 		return nil, err
 	}
-	var values []string
-	if err := resp.UnmarshalResult(&values); err != nil {
-		return nil, errors.Wrapv(err, map[string]interface{}{"args": args})
+	ids := make(map[string]bool)
+	for _, key := range keys {
+		id := strings.Trim(strings.TrimPrefix(key, prefix), "/")
+		if id == "" || strings.Contains(id, "/") {
+			return nil, errors.Newv("failed to extract valid id", map[string]interface{}{"key": key, "prefix": prefix})
+		}
+		ids[id] = true
 	}
-	return values, nil
+	return ids, nil
 }
 
 func (c *ClusterConf) kvGetAll(key string) (map[string]kv.Value, error) {
-	args := map[string]interface{}{
-		"key": key,
-	}
-
-	resp, err := c.kvReq("kv-getAll", args)
+	args := kvp.GetArgs{Key: key}
+	result, err := c.kv().GetAll(context.Background(), args)
 	if err != nil {
-		return nil, err
-	}
-	values := make(map[string]kv.Value)
-	if err := resp.UnmarshalResult(&values); err != nil {
 		return nil, errors.Wrapv(err, map[string]interface{}{"args": args})
 	}
+
+	values := make(map[string]kv.Value, len(result))
+	for key, value := range result {
+		values[key] = kv.Value(value)
+	}
 	return values, nil
 }
 
 func (c *ClusterConf) kvGet(key string) (kv.Value, error) {
-	var value kv.Value
-	args := map[string]interface{}{
-		"key": key,
-	}
+	args := kvp.GetArgs{Key: key}
+	value, err := c.kv().Get(context.Background(), args)
+	return kv.Value(value), errors.Wrapv(err, map[string]interface{}{"args": args})
+}
 
-	resp, err := c.kvReq("kv-get", args)
-	if err != nil {
-		return value, err
+// kvDelete deletes a single key. With a modIndex, the key is only deleted if
+// it hasn't been updated since.
+func (c *ClusterConf) kvDelete(key string, modIndex uint64) error {
+	if modIndex != 0 {
+		args := kvp.RemoveArgs{
+			Key:   key,
+			Index: modIndex,
+		}
+		return errors.Wrapv(c.kv().Remove(context.Background(), args), map[string]interface{}{"args": args})
 	}
+	args := kvp.DeleteArgs{Key: key}
+	return errors.Wrapv(c.kv().Delete(context.Background(), args), map[string]interface{}{"args": args})
+}
 
-	if err := resp.UnmarshalResult(&value); err != nil {
-		return value, errors.Wrapv(err, map[string]interface{}{"args": args})
+// kvDeleteObject deletes an object's config, only if it hasn't been updated
+// since modIndex, and then the rest of the object's keys.
+func (c *ClusterConf) kvDeleteObject(key string, modIndex uint64) error {
+	if err := c.kvDelete(path.Join(key, "config"), modIndex); err != nil {
+		return err
 	}
-	return value, nil
+	return c.kvDeleteTree(key)
 }
 
-func (c *ClusterConf) kvDelete(key string, modIndex uint64) error {
-	args := map[string]interface{}{
-		"key":     key,
-		"recurse": true,
+// kvDeleteTree deletes a key and all keys under it.
+func (c *ClusterConf) kvDeleteTree(key string) error {
+	args := kvp.DeleteArgs{
+		Key:       key,
+		Recursive: true,
 	}
-	_, err := c.kvReq("kv-delete", args)
-	return errors.Wrapv(err, map[string]interface{}{"args": args})
+	return errors.Wrapv(c.kv().Delete(context.Background(), args), map[string]interface{}{"args": args})
 }
 
 func (c *ClusterConf) kvUpdate(key string, value interface{}, modIndex uint64) (uint64, error) {
-	args := map[string]interface{}{
-		"key":   key,
-		"value": value,
-		"index": modIndex,
-	}
-	resp, err := c.kvReq("kv-update", args)
+	errData := map[string]interface{}{"key": key, "value": value, "index": modIndex}
+
+	valueJSON, err := json.Marshal(value)
 	if err != nil {
-		return 0, err
+		return 0, errors.Wrapv(err, errData, "failed to json marshal value")
 	}
-	result := make(map[string]uint64)
-	if err := resp.UnmarshalResult(&result); err != nil {
-		return 0, errors.Wrapv(err, map[string]interface{}{"args": args})
+
+	args := kvp.UpdateArgs{
+		Key:   key,
+		Value: string(valueJSON),
+		Index: modIndex,
 	}
-	return result["index"], nil
+	result, err := c.kv().Update(context.Background(), args)
+	return result.Index, errors.Wrapv(err, errData)
 }
 
 func (c *ClusterConf) kvEphemeral(key string, value interface{}, ttl time.Duration) error {
-	args := map[string]interface{}{
-		"key":   key,
-		"value": value,
-		"ttl":   ttl,
+	errData := map[string]interface{}{"key": key, "value": value, "ttl": ttl}
+
+	valueJSON, err := json.Marshal(value)
+	if err != nil {
+		return errors.Wrapv(err, errData, "failed to json marshal value")
+	}
+
+	args := kvp.EphemeralSetArgs{
+		Key:   key,
+		Value: string(valueJSON),
+		TTL:   ttl,
 	}
-	_, err := c.kvReq("kv-ephemeral-set", args)
-	return errors.Wrapv(err, map[string]interface{}{"args": args})
+	return errors.Wrapv(c.kv().EphemeralSet(context.Background(), args), errData)
 }
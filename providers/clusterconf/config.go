@@ -1,6 +1,11 @@
 package clusterconf
 
 import (
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strings"
 	"time"
 
 	"github.com/cerana/cerana/pkg/errors"
@@ -20,6 +25,62 @@ type ConfigData struct {
 	DatasetTTL string `json:"datasetTTL"`
 	BundleTTL  string `json:"bundleTTL"`
 	NodeTTL    string `json:"nodeTTL"`
+	// RevisionLimit is the number of revisions kept for each bundle,
+	// service, and dataset. Defaults to 10.
+	RevisionLimit uint64 `json:"revisionLimit"`
+	// NodeHistoryTiers configure the downsampling of node heartbeat
+	// history. Defaults to every heartbeat for an hour, one per minute for
+	// a day, and one per hour after.
+	NodeHistoryTiers []HistoryTierData `json:"nodeHistoryTiers"`
+	// NodeHistoryMaxAge is how long node heartbeat history is kept. History
+	// is kept forever if unset.
+	NodeHistoryMaxAge string `json:"nodeHistoryMaxAge"`
+	// PortRange is the range external ports of public bundle ports are
+	// allocated from, e.g. "30000-32767", which is the default.
+	PortRange string `json:"portRange"`
+	// SecretKeyFile is the path of a file containing the hex encoded 256 bit
+	// cluster key secrets are encrypted with. Secrets are unavailable if
+	// unset.
+	SecretKeyFile string `json:"secretKeyFile"`
+	// AuditRetention is how long audit events of configuration changes are
+	// kept. Defaults to 30 days.
+	AuditRetention string `json:"auditRetention"`
+}
+
+// HistoryTierData is the config data for a HistoryTier.
+type HistoryTierData struct {
+	Age      string `json:"age"`
+	Interval string `json:"interval"`
+}
+
+// HistoryTier is a downsampling tier of node heartbeat history. Heartbeats
+// older than Age are thinned to at most one per Interval, until the Age of the
+// next tier.
+type HistoryTier struct {
+	Age      time.Duration
+	Interval time.Duration
+}
+
+// defaultRevisionLimit is used when a revision limit is not configured.
+const defaultRevisionLimit uint64 = 10
+
+// defaultAuditRetention is used when an audit retention is not configured.
+const defaultAuditRetention = 30 * 24 * time.Hour
+
+// Default range for allocating external ports.
+const (
+	defaultPortMin = 30000
+	defaultPortMax = 32767
+)
+
+// secretKeySize is the size in bytes of the cluster key for encrypting
+// secrets, for AES-256.
+const secretKeySize = 32
+
+// defaultHistoryTiers are used when node history tiers are not configured.
+var defaultHistoryTiers = []HistoryTier{
+	{Age: time.Hour, Interval: time.Minute},
+	{Age: 24 * time.Hour, Interval: time.Hour},
 }
 
 // NewConfig creates a new instance of Config.
@@ -57,6 +118,119 @@ func (c *Config) NodeTTL() time.Duration {
 	return ttl
 }
 
+// RevisionLimit returns the number of revisions kept for each config object.
+func (c *Config) RevisionLimit() uint64 {
+	var limit uint64
+	_ = c.UnmarshalKey("revision_limit", &limit)
+	if limit == 0 {
+		return defaultRevisionLimit
+	}
+	return limit
+}
+
+// NodeHistoryTiers returns the downsampling tiers of node heartbeat history,
+// ordered by age.
+func (c *Config) NodeHistoryTiers() ([]HistoryTier, error) {
+	var tierData []HistoryTierData
+	if err := c.UnmarshalKey("node_history_tiers", &tierData); err != nil {
+		return nil, errors.Wrap(err, "invalid node_history_tiers")
+	}
+	if len(tierData) == 0 {
+		return defaultHistoryTiers, nil
+	}
+
+	tiers := make([]HistoryTier, len(tierData))
+	for i, data := range tierData {
+		age, err := time.ParseDuration(data.Age)
+		if err != nil {
+			return nil, errors.Wrapv(err, map[string]interface{}{"tier": data}, "invalid node_history_tiers")
+		}
+		interval, err := time.ParseDuration(data.Interval)
+		if err != nil {
+			return nil, errors.Wrapv(err, map[string]interface{}{"tier": data}, "invalid node_history_tiers")
+		}
+		if age < 0 || interval <= 0 {
+			return nil, errors.Newv("invalid node_history_tiers", map[string]interface{}{"tier": data})
+		}
+		tiers[i] = HistoryTier{Age: age, Interval: interval}
+	}
+	sort.Sort(historyTiers(tiers))
+	return tiers, nil
+}
+
+// NodeHistoryMaxAge returns how long node heartbeat history is kept. A zero
+// value means forever.
+func (c *Config) NodeHistoryMaxAge() time.Duration {
+	var maxAgeString string
+	_ = c.UnmarshalKey("node_history_max_age", &maxAgeString)
+	// Since errors lead to a 0 value and 0 is the default, safe to ignore
+	// the error.
+	maxAge, _ := time.ParseDuration(maxAgeString)
+	return maxAge
+}
+
+// AuditRetention returns how long audit events are kept.
+func (c *Config) AuditRetention() time.Duration {
+	var retentionString string
+	_ = c.UnmarshalKey("audit_retention", &retentionString)
+	// Since errors lead to a 0 value and 0 is the default, safe to ignore
+	// the error.
+	retention, _ := time.ParseDuration(retentionString)
+	if retention == 0 {
+		return defaultAuditRetention
+	}
+	return retention
+}
+
+// PortRange returns the inclusive range external ports of public bundle ports
+// are allocated from.
+func (c *Config) PortRange() (int, int, error) {
+	var portRange string
+	_ = c.UnmarshalKey("port_range", &portRange)
+	if portRange == "" {
+		return defaultPortMin, defaultPortMax, nil
+	}
+
+	var min, max int
+	if _, err := fmt.Sscanf(portRange, "%d-%d", &min, &max); err != nil {
+		return 0, 0, errors.Wrapv(err, map[string]interface{}{"portRange": portRange}, "invalid port_range")
+	}
+	if min <= 0 || max > 65535 || min > max {
+		return 0, 0, errors.Newv("invalid port_range", map[string]interface{}{"portRange": portRange})
+	}
+	return min, max, nil
+}
+
+// SecretKey returns the cluster key secrets are encrypted with, read from the
+// configured file. It is nil if no file is configured.
+func (c *Config) SecretKey() ([]byte, error) {
+	var keyFile string
+	_ = c.UnmarshalKey("secret_key_file", &keyFile)
+	if keyFile == "" {
+		return nil, nil
+	}
+
+	data, err := ioutil.ReadFile(keyFile)
+	if err != nil {
+		return nil, errors.Wrapv(err, map[string]interface{}{"secretKeyFile": keyFile}, "invalid secret_key_file")
+	}
+	key, err := hex.DecodeString(strings.TrimSpace(string(data)))
+	if err != nil {
+		return nil, errors.Wrapv(err, map[string]interface{}{"secretKeyFile": keyFile}, "invalid secret_key_file")
+	}
+	if len(key) != secretKeySize {
+		return nil, errors.Newv("invalid secret_key_file", map[string]interface{}{"secretKeyFile": keyFile, "size": len(key)})
+	}
+	return key, nil
+}
+
+// historyTiers sorts tiers by age.
+type historyTiers []HistoryTier
+
+func (t historyTiers) Len() int           { return len(t) }
+func (t historyTiers) Swap(i, j int)      { t[i], t[j] = t[j], t[i] }
+func (t historyTiers) Less(i, j int) bool { return t[i].Age < t[j].Age }
+
 // Validate returns whether the config is valid, containing necessary values.
 func (c *Config) Validate() error {
 	if err := c.Config.Validate(); err != nil {
@@ -72,6 +246,21 @@ func (c *Config) Validate() error {
 	if c.NodeTTL() <= 0 {
 		return errors.New("invalid node_ttl")
 	}
+	if _, err := c.NodeHistoryTiers(); err != nil {
+		return err
+	}
+	if c.NodeHistoryMaxAge() < 0 {
+		return errors.New("invalid node_history_max_age")
+	}
+	if c.AuditRetention() < 0 {
+		return errors.New("invalid audit_retention")
+	}
+	if _, _, err := c.PortRange(); err != nil {
+		return err
+	}
+	if _, err := c.SecretKey(); err != nil {
+		return err
+	}
 
 	return nil
 }
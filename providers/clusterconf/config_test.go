@@ -2,6 +2,10 @@ package clusterconf_test
 
 import (
 	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
 	"time"
 
 	"github.com/cerana/cerana/providers/clusterconf"
@@ -23,6 +27,122 @@ func (s *clusterConf) TestConfigTTLs() {
 	}
 }
 
+func (s *clusterConf) TestConfigRevisionLimit() {
+	limit := s.config.RevisionLimit()
+	defer s.viper.Set("revision_limit", limit)
+
+	s.viper.Set("revision_limit", 0)
+	s.Equal(uint64(10), s.config.RevisionLimit(), "default")
+	s.viper.Set("revision_limit", 3)
+	s.Equal(uint64(3), s.config.RevisionLimit(), "configured")
+}
+
+func (s *clusterConf) TestConfigNodeHistory() {
+	defer s.viper.Set("node_history_tiers", nil)
+	defer s.viper.Set("node_history_max_age", "")
+
+	tiers, err := s.config.NodeHistoryTiers()
+	s.NoError(err, "default")
+	s.Len(tiers, 2, "default")
+	s.Equal(time.Duration(0), s.config.NodeHistoryMaxAge(), "default")
+
+	s.viper.Set("node_history_tiers", []map[string]string{
+		{"age": "24h", "interval": "1h"},
+		{"age": "10m", "interval": "1m"},
+	})
+	s.viper.Set("node_history_max_age", "720h")
+	tiers, err = s.config.NodeHistoryTiers()
+	s.NoError(err, "configured")
+	s.Equal([]clusterconf.HistoryTier{
+		{Age: 10 * time.Minute, Interval: time.Minute},
+		{Age: 24 * time.Hour, Interval: time.Hour},
+	}, tiers, "configured")
+	s.Equal(720*time.Hour, s.config.NodeHistoryMaxAge(), "configured")
+	s.NoError(s.config.Validate(), "configured")
+
+	s.viper.Set("node_history_tiers", []map[string]string{{"age": "1h", "interval": "0"}})
+	s.Contains(s.config.Validate().Error(), "invalid node_history_tiers", "invalid")
+}
+
+func (s *clusterConf) TestConfigAuditRetention() {
+	defer s.viper.Set("audit_retention", "")
+
+	s.Equal(30*24*time.Hour, s.config.AuditRetention(), "default")
+	s.viper.Set("audit_retention", "48h")
+	s.Equal(48*time.Hour, s.config.AuditRetention(), "configured")
+	s.NoError(s.config.Validate(), "configured")
+	s.viper.Set("audit_retention", "-1h")
+	s.Contains(s.config.Validate().Error(), "invalid audit_retention", "negative")
+}
+
+func (s *clusterConf) TestConfigPortRange() {
+	defer s.viper.Set("port_range", "")
+
+	tests := []struct {
+		portRange string
+		min       int
+		max       int
+		err       string
+	}{
+		{"", 30000, 32767, ""},
+		{"40000-40100", 40000, 40100, ""},
+		{"40100-40000", 0, 0, "invalid port_range"},
+		{"0-100", 0, 0, "invalid port_range"},
+		{"foo", 0, 0, "invalid port_range"},
+	}
+
+	for _, test := range tests {
+		s.viper.Set("port_range", test.portRange)
+		min, max, err := s.config.PortRange()
+		if test.err != "" {
+			s.Contains(err.Error(), test.err, test.portRange)
+			continue
+		}
+		s.NoError(err, test.portRange)
+		s.Equal(test.min, min, test.portRange)
+		s.Equal(test.max, max, test.portRange)
+	}
+}
+
+func (s *clusterConf) TestConfigSecretKey() {
+	defer s.viper.Set("secret_key_file", "")
+
+	key, err := s.config.SecretKey()
+	s.NoError(err, "unset")
+	s.Nil(key, "unset")
+
+	dir, err := ioutil.TempDir("", "clusterconf-")
+	s.Require().NoError(err)
+	defer func() { _ = os.RemoveAll(dir) }()
+
+	tests := []struct {
+		desc     string
+		contents string
+		err      string
+	}{
+		{"valid", strings.Repeat("ab", 32) + "\n", ""},
+		{"short", strings.Repeat("ab", 16), "invalid secret_key_file"},
+		{"not hex", strings.Repeat("zz", 32), "invalid secret_key_file"},
+	}
+
+	for _, test := range tests {
+		keyFile := filepath.Join(dir, test.desc)
+		s.Require().NoError(ioutil.WriteFile(keyFile, []byte(test.contents), 0600), test.desc)
+		s.viper.Set("secret_key_file", keyFile)
+		key, err := s.config.SecretKey()
+		if test.err != "" {
+			s.Contains(err.Error(), test.err, test.desc)
+			s.Contains(s.config.Validate().Error(), test.err, test.desc)
+			continue
+		}
+		s.NoError(err, test.desc)
+		s.Len(key, 32, test.desc)
+	}
+
+	s.viper.Set("secret_key_file", filepath.Join(dir, "missing"))
+	s.Contains(s.config.Validate().Error(), "invalid secret_key_file", "missing")
+}
+
 func (s *clusterConf) TestValidate() {
 	datasetTTL := s.config.DatasetTTL()
 	bundleTTL := s.config.DatasetTTL()
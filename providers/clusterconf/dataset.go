@@ -2,10 +2,8 @@ package clusterconf
 
 import (
 	"encoding/json"
-	"fmt"
 	"net/url"
 	"path"
-	"path/filepath"
 	"strings"
 	"sync"
 
@@ -26,6 +24,8 @@ type Dataset struct {
 	NFS               bool   `json:"nfs"`
 	Redundancy        uint64 `json:"redundancy"`
 	Quota             uint64 `json:"quota"`
+	// Project is the project the dataset belongs to, if any.
+	Project string `json:"project"`
 	// ModIndex should be treated as opaque, but passed back on updates.
 	ModIndex uint64 `json:"modIndex"`
 }
@@ -34,6 +34,14 @@ type Dataset struct {
 // needs to be sent.
 type DatasetPayload struct {
 	Dataset *Dataset `json:"dataset"`
+	// Author identifies who made a change, recorded in the revision history.
+	Author string `json:"author,omitempty"`
+}
+
+// ListDatasetsArgs are arguments for ListDatasets.
+type ListDatasetsArgs struct {
+	// Project filters on datasets of the project.
+	Project string `json:"project"`
 }
 
 // DatasetListResult is the result for listing datasets.
@@ -41,7 +49,8 @@ type DatasetListResult struct {
 	Datasets []*Dataset `json:"datasets"`
 }
 
-// GetDataset retrieves a dataset.
+// GetDataset retrieves a dataset. An unset quota or redundancy is set from the
+// cluster defaults.
 func (c *ClusterConf) GetDataset(req *acomm.Request) (interface{}, *url.URL, error) {
 	var args IDArgs
 	if err := req.UnmarshalArgs(&args); err != nil {
@@ -55,62 +64,44 @@ func (c *ClusterConf) GetDataset(req *acomm.Request) (interface{}, *url.URL, err
 	if err != nil {
 		return nil, nil, err
 	}
-	return &DatasetPayload{dataset}, nil, nil
+	defaults, err := c.getDefaults()
+	if err != nil {
+		return nil, nil, err
+	}
+	defaults.applyToDataset(dataset)
+	return &DatasetPayload{Dataset: dataset}, nil, nil
 }
 
-// ListDatasets returns a list of all Datasets.
+// ListDatasets returns a list of all Datasets, optionally only those of a
+// project. Unset quotas and redundancies are set from the cluster defaults.
 func (c *ClusterConf) ListDatasets(req *acomm.Request) (interface{}, *url.URL, error) {
-	keys, err := c.kvKeys(datasetsPrefix)
-	if err != nil {
+	var args ListDatasetsArgs
+	if err := req.UnmarshalArgs(&args); err != nil {
 		return nil, nil, err
 	}
-	// extract and deduplicate the dataset ids
-	ids := make(map[string]bool)
-	keyFormat := filepath.Join(datasetsPrefix, "%s")
-	for _, key := range keys {
-		var id string
-		_, err := fmt.Sscanf(key, keyFormat, &id)
-		if err != nil {
-			return nil, nil, errors.Newv("failed to extract valid dataset id", map[string]interface{}{"key": key, "keyFormat": keyFormat})
-		}
-		ids[id] = true
-	}
 
-	var wg sync.WaitGroup
-	dsChan := make(chan *Dataset, len(ids))
-	errChan := make(chan error, len(ids))
-	for id := range ids {
-		wg.Add(1)
-		go func(id string) {
-			defer wg.Done()
-			ds, err := c.getDataset(id)
-			if err != nil {
-				errChan <- err
-				return
-			}
-			dsChan <- ds
-		}(id)
+	datasets, err := c.getDatasets()
+	if err != nil {
+		return nil, nil, err
 	}
-	wg.Wait()
-
-	close(dsChan)
-	close(errChan)
-
-	if len(errChan) > 0 {
-		err := <-errChan
+	if args.Project != "" {
+		datasets = projectDatasets(datasets, args.Project)
+	}
+	defaults, err := c.getDefaults()
+	if err != nil {
 		return nil, nil, err
 	}
-	datasets := make([]*Dataset, 0, len(dsChan))
-	for ds := range dsChan {
-		datasets = append(datasets, ds)
+	for _, dataset := range datasets {
+		defaults.applyToDataset(dataset)
 	}
-
 	return &DatasetListResult{
 		Datasets: datasets,
 	}, nil, nil
 }
 
 // UpdateDataset creates or updates a dataset config. When updating, a Get should first be performed and the modified Dataset passed back.
+// An unset quota or redundancy is left unset and read from the cluster
+// defaults, so changes to the defaults apply to the dataset.
 func (c *ClusterConf) UpdateDataset(req *acomm.Request) (interface{}, *url.URL, error) {
 	var args DatasetPayload
 	if err := req.UnmarshalArgs(&args); err != nil {
@@ -124,16 +115,28 @@ func (c *ClusterConf) UpdateDataset(req *acomm.Request) (interface{}, *url.URL,
 	if args.Dataset.ID == "" {
 		args.Dataset.ID = uuid.New()
 	}
+	unlock, err := c.lockChanges()
+	if err != nil {
+		return nil, nil, err
+	}
+	defer unlock()
+	if err := args.Dataset.checkProject(); err != nil {
+		return nil, nil, err
+	}
 
-	if err := args.Dataset.update(); err != nil {
+	if err := c.audited(req, args.Author, datasetReference, args.Dataset.ID, path.Join(datasetsPrefix, args.Dataset.ID, "config"), args.Dataset.update); err != nil {
 		return nil, nil, err
 	}
-	return &DatasetPayload{args.Dataset}, nil, nil
+	if err := c.saveRevision(path.Join(datasetsPrefix, args.Dataset.ID), args.Dataset.ModIndex, args.Author, args.Dataset); err != nil {
+		return nil, nil, err
+	}
+	return &DatasetPayload{Dataset: args.Dataset}, nil, nil
 }
 
-// DeleteDataset deletes a dataset config.
+// DeleteDataset deletes a dataset config. Datasets still used by bundles are
+// only deleted when forced or when cascading removes them from the bundles.
 func (c *ClusterConf) DeleteDataset(req *acomm.Request) (interface{}, *url.URL, error) {
-	var args IDArgs
+	var args DeleteArgs
 	if err := req.UnmarshalArgs(&args); err != nil {
 		return nil, nil, err
 	}
@@ -141,6 +144,12 @@ func (c *ClusterConf) DeleteDataset(req *acomm.Request) (interface{}, *url.URL,
 		return nil, nil, errors.Newv("missing arg: id", map[string]interface{}{"args": args})
 	}
 
+	unlock, err := c.lockChanges()
+	if err != nil {
+		return nil, nil, err
+	}
+	defer unlock()
+
 	dataset, err := c.getDataset(args.ID)
 	if err != nil {
 		if strings.Contains(err.Error(), "dataset config not found") {
@@ -149,7 +158,10 @@ func (c *ClusterConf) DeleteDataset(req *acomm.Request) (interface{}, *url.URL,
 		return nil, nil, err
 	}
 
-	return nil, nil, dataset.delete()
+	if err := c.removeReferences(req, datasetReference, args); err != nil {
+		return nil, nil, err
+	}
+	return nil, nil, c.audited(req, args.Author, datasetReference, args.ID, path.Join(datasetsPrefix, args.ID, "config"), dataset.delete)
 }
 
 func (c *ClusterConf) getDataset(id string) (*Dataset, error) {
@@ -163,6 +175,44 @@ func (c *ClusterConf) getDataset(id string) (*Dataset, error) {
 	return dataset, nil
 }
 
+// getDatasets retrieves all datasets.
+func (c *ClusterConf) getDatasets() ([]*Dataset, error) {
+	ids, err := c.objectIDs(datasetsPrefix)
+	if err != nil {
+		return nil, err
+	}
+
+	var wg sync.WaitGroup
+	dsChan := make(chan *Dataset, len(ids))
+	errChan := make(chan error, len(ids))
+	for id := range ids {
+		wg.Add(1)
+		go func(id string) {
+			defer wg.Done()
+			ds, err := c.getDataset(id)
+			if err != nil {
+				errChan <- err
+				return
+			}
+			dsChan <- ds
+		}(id)
+	}
+	wg.Wait()
+
+	close(dsChan)
+	close(errChan)
+
+	if len(errChan) > 0 {
+		err := <-errChan
+		return nil, err
+	}
+	datasets := make([]*Dataset, 0, len(dsChan))
+	for ds := range dsChan {
+		datasets = append(datasets, ds)
+	}
+	return datasets, nil
+}
+
 func (d *Dataset) reload() error {
 	var err error
 	key := path.Join(datasetsPrefix, d.ID, "config")
@@ -183,7 +233,7 @@ func (d *Dataset) reload() error {
 
 func (d *Dataset) delete() error {
 	key := path.Join(datasetsPrefix, d.ID)
-	return errors.Wrapv(d.c.kvDelete(key, d.ModIndex), map[string]interface{}{"datasetID": d.ID})
+	return errors.Wrapv(d.c.kvDeleteObject(key, d.ModIndex), map[string]interface{}{"datasetID": d.ID})
 }
 
 // update saves the core dataset config.
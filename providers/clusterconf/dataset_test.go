@@ -45,6 +45,42 @@ func (s *clusterConf) TestGetDataset() {
 	}
 }
 
+func (s *clusterConf) TestGetDatasetDefaults() {
+	dataset, err := s.addDataset()
+	s.Require().NoError(err)
+
+	getDataset := func() *clusterconf.Dataset {
+		req, err := acomm.NewRequest(acomm.RequestOptions{
+			Task: "get-dataset",
+			Args: &clusterconf.IDArgs{ID: dataset.ID},
+		})
+		s.Require().NoError(err)
+		result, _, err := s.clusterConf.GetDataset(req)
+		s.Require().NoError(err)
+		return result.(*clusterconf.DatasetPayload).Dataset
+	}
+
+	// Defaults apply to unset values when read, so later changes to the
+	// defaults apply to existing datasets
+	defaults := &clusterconf.Defaults{}
+	for _, redundancy := range []uint64{2, 3} {
+		defaults.DatasetQuota = 10
+		defaults.DatasetRedundancy = redundancy
+		req, err := acomm.NewRequest(acomm.RequestOptions{
+			Task: "update-defaults",
+			Args: &clusterconf.DefaultsPayload{Defaults: defaults},
+		})
+		s.Require().NoError(err)
+		result, _, err := s.clusterConf.UpdateDefaults(req)
+		s.Require().NoError(err)
+		defaults = result.(*clusterconf.DefaultsPayload).Defaults
+
+		current := getDataset()
+		s.Equal(redundancy, current.Redundancy)
+		s.Equal(dataset.Quota, current.Quota)
+	}
+}
+
 func (s *clusterConf) TestUpdateDataset() {
 	dataset, err := s.addDataset()
 	s.Require().NoError(err)
@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"net/url"
 	"strings"
+	"time"
 
 	"github.com/cerana/cerana/acomm"
 	"github.com/cerana/cerana/pkg/errors"
@@ -18,27 +19,48 @@ type Defaults struct {
 	ModIndex uint64 `json:"modIndex"`
 }
 
-// DefaultsConf is the configuration for the cluster.
+// DefaultsConf is the configuration for the cluster. Unset values leave the
+// built in behavior.
 type DefaultsConf struct {
+	// ZFSManual leaves ZFS pool setup on nodes to the operator.
 	ZFSManual bool `json:"zfsManual"`
+	// Limits are the resource limits of bundle services when neither the
+	// bundle nor the service set them.
+	Limits ResourceLimits `json:"limits"`
+	// DatasetQuota is the quota of datasets and bundle datasets that don't
+	// set one.
+	DatasetQuota uint64 `json:"datasetQuota"`
+	// DatasetRedundancy is the redundancy of datasets that don't set one.
+	DatasetRedundancy uint64 `json:"datasetRedundancy"`
+	// HealthCheckInterval is the interval of bundle service health checks
+	// that don't set one.
+	HealthCheckInterval time.Duration `json:"healthCheckInterval"`
+	// DatasetTTL, BundleTTL, and NodeTTL are the TTLs of dataset, bundle, and
+	// node heartbeats. They override the provider's configured TTLs.
+	DatasetTTL time.Duration `json:"datasetTTL"`
+	BundleTTL  time.Duration `json:"bundleTTL"`
+	NodeTTL    time.Duration `json:"nodeTTL"`
 }
 
 // DefaultsPayload can be used for task args or result when a cluster object
 // needs to be sent.
 type DefaultsPayload struct {
 	Defaults *Defaults `json:"defaults"`
+	// Author identifies who made a change, recorded in the audit log.
+	Author string `json:"author,omitempty"`
 }
 
-// GetDefaults retrieves the cluster config.
+// GetDefaults retrieves the cluster config. See DefaultsConf for the
+// description of each option.
 func (c *ClusterConf) GetDefaults(req *acomm.Request) (interface{}, *url.URL, error) {
 	defaults, err := c.getDefaults()
 	if err != nil {
 		return nil, nil, err
 	}
-	return &DefaultsPayload{defaults}, nil, nil
+	return &DefaultsPayload{Defaults: defaults}, nil, nil
 }
 
-// UpdateDefaults sets or updates
+// UpdateDefaults sets or updates the cluster config.
 func (c *ClusterConf) UpdateDefaults(req *acomm.Request) (interface{}, *url.URL, error) {
 	var args DefaultsPayload
 	if err := req.UnmarshalArgs(&args); err != nil {
@@ -47,13 +69,16 @@ func (c *ClusterConf) UpdateDefaults(req *acomm.Request) (interface{}, *url.URL,
 	if args.Defaults == nil {
 		return nil, nil, errors.Newv("missing arg: defaults", map[string]interface{}{"args": args})
 	}
+	if err := args.Defaults.validate(); err != nil {
+		return nil, nil, err
+	}
 
 	args.Defaults.c = c
 
-	if err := args.Defaults.update(); err != nil {
+	if err := c.audited(req, args.Author, defaultsObject, defaultsPrefix, defaultsPrefix, args.Defaults.update); err != nil {
 		return nil, nil, err
 	}
-	return &DefaultsPayload{args.Defaults}, nil, nil
+	return &DefaultsPayload{Defaults: args.Defaults}, nil, nil
 }
 
 func (c *ClusterConf) getDefaults() (*Defaults, error) {
@@ -92,3 +117,76 @@ func (d *Defaults) update() error {
 
 	return nil
 }
+
+// validate checks that none of the defaults are negative.
+func (d DefaultsConf) validate() error {
+	invalid := func(field string) error {
+		return errors.Newv("invalid arg: defaults."+field, map[string]interface{}{"defaults": d})
+	}
+	switch {
+	case d.Limits.CPU < 0:
+		return invalid("limits.cpu")
+	case d.Limits.Memory < 0:
+		return invalid("limits.memory")
+	case d.Limits.Processes < 0:
+		return invalid("limits.processes")
+	case d.HealthCheckInterval < 0:
+		return invalid("healthCheckInterval")
+	case d.DatasetTTL < 0:
+		return invalid("datasetTTL")
+	case d.BundleTTL < 0:
+		return invalid("bundleTTL")
+	case d.NodeTTL < 0:
+		return invalid("nodeTTL")
+	}
+	return nil
+}
+
+// applyToDataset sets the dataset's unset quota and redundancy. Defaults are
+// applied when datasets are read rather than saved with them.
+func (d DefaultsConf) applyToDataset(dataset *Dataset) {
+	if dataset.Quota == 0 {
+		dataset.Quota = d.DatasetQuota
+	}
+	if dataset.Redundancy == 0 {
+		dataset.Redundancy = d.DatasetRedundancy
+	}
+}
+
+// applyToBundle sets the unset values of a combined overlay bundle's datasets
+// and services.
+func (d DefaultsConf) applyToBundle(bundle *Bundle) {
+	for id, dataset := range bundle.Datasets {
+		if dataset.Quota == 0 {
+			dataset.Quota = d.DatasetQuota
+			bundle.Datasets[id] = dataset
+		}
+	}
+	for id, service := range bundle.Services {
+		if service.Limits.CPU <= 0 {
+			service.Limits.CPU = d.Limits.CPU
+		}
+		if service.Limits.Memory <= 0 {
+			service.Limits.Memory = d.Limits.Memory
+		}
+		if service.Limits.Processes <= 0 {
+			service.Limits.Processes = d.Limits.Processes
+		}
+		for healthID, healthCheck := range service.HealthChecks {
+			if healthCheck.Interval == 0 {
+				healthCheck.Interval = d.HealthCheckInterval
+				service.HealthChecks[healthID] = healthCheck
+			}
+		}
+		bundle.Services[id] = service
+	}
+}
+
+// heartbeatTTL returns the TTL set in the defaults, or else the configured
+// one.
+func heartbeatTTL(ttl, configured time.Duration) time.Duration {
+	if ttl > 0 {
+		return ttl
+	}
+	return configured
+}
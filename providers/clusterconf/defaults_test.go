@@ -2,6 +2,7 @@ package clusterconf_test
 
 import (
 	"path"
+	"time"
 
 	"github.com/cerana/cerana/acomm"
 	"github.com/cerana/cerana/providers/clusterconf"
@@ -73,6 +74,51 @@ func (s *clusterConf) TestUpdateDefaults() {
 	}
 }
 
+func (s *clusterConf) TestUpdateDefaultsInvalid() {
+	tests := []struct {
+		desc string
+		conf clusterconf.DefaultsConf
+		err  string
+	}{
+		{"negative memory", clusterconf.DefaultsConf{Limits: clusterconf.ResourceLimits{Memory: -1}}, "invalid arg: defaults.limits.memory"},
+		{"negative interval", clusterconf.DefaultsConf{HealthCheckInterval: -time.Second}, "invalid arg: defaults.healthCheckInterval"},
+		{"negative ttl", clusterconf.DefaultsConf{BundleTTL: -time.Second}, "invalid arg: defaults.bundleTTL"},
+	}
+
+	for _, test := range tests {
+		req, err := acomm.NewRequest(acomm.RequestOptions{
+			Task: "update-defaults",
+			Args: &clusterconf.DefaultsPayload{Defaults: &clusterconf.Defaults{DefaultsConf: test.conf}},
+		})
+		s.Require().NoError(err, test.desc)
+		_, _, err = s.clusterConf.UpdateDefaults(req)
+		s.Contains(err.Error(), test.err, test.desc)
+	}
+}
+
+func (s *clusterConf) TestCombinedOverlayDefaults() {
+	_, err := s.loadData(map[string]interface{}{
+		"cluster": clusterconf.DefaultsConf{
+			Limits:              clusterconf.ResourceLimits{Memory: 100},
+			HealthCheckInterval: time.Minute,
+		},
+	})
+	s.Require().NoError(err)
+	bundle, err := s.addBundle()
+	s.Require().NoError(err)
+
+	req, err := acomm.NewRequest(acomm.RequestOptions{
+		Task: "get-bundle",
+		Args: &clusterconf.GetBundleArgs{ID: bundle.ID, CombinedOverlay: true},
+	})
+	s.Require().NoError(err)
+	result, _, err := s.clusterConf.GetBundle(req)
+	s.Require().NoError(err)
+	for _, service := range result.(*clusterconf.BundlePayload).Bundle.Services {
+		s.Equal(int64(100), service.Limits.Memory)
+	}
+}
+
 func (s *clusterConf) setDefaultZFSManual(value bool) (*clusterconf.Defaults, error) {
 	defaults := &clusterconf.Defaults{DefaultsConf: clusterconf.DefaultsConf{ZFSManual: value}}
 	key := path.Join("cluster")
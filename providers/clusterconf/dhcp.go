@@ -20,6 +20,13 @@ type DHCPConfig struct {
 	Net      string   `json:"net"`
 }
 
+// SetDHCPArgs are args for setting the DHCP settings.
+type SetDHCPArgs struct {
+	DHCPConfig
+	// Author identifies who made the change, recorded in the audit log.
+	Author string `json:"author,omitempty"`
+}
+
 // Validate validates the DHCPConfig settings.
 func (c *DHCPConfig) Validate() error {
 	duration, err := time.ParseDuration(c.Duration)
@@ -75,18 +82,20 @@ func (c *ClusterConf) GetDHCP(*acomm.Request) (interface{}, *url.URL, error) {
 
 // SetDHCP updates the cluster DHCP settings.
 func (c *ClusterConf) SetDHCP(req *acomm.Request) (interface{}, *url.URL, error) {
-	conf := DHCPConfig{}
-	if err := req.UnmarshalArgs(&conf); err != nil {
+	var args SetDHCPArgs
+	if err := req.UnmarshalArgs(&args); err != nil {
 		return nil, nil, err
 	}
 
-	if err := conf.Validate(); err != nil {
+	if err := args.Validate(); err != nil {
 		return nil, nil, err
 	}
 
-	_, err := c.kvUpdate(dhcpPrefix, conf, 0)
-	if err != nil {
-		err = errors.New("dhcp configuration can not be altered")
-	}
+	err := c.audited(req, args.Author, dhcpObject, dhcpPrefix, dhcpPrefix, func() error {
+		if _, err := c.kvUpdate(dhcpPrefix, args.DHCPConfig, 0); err != nil {
+			return errors.New("dhcp configuration can not be altered")
+		}
+		return nil
+	})
 	return nil, nil, err
 }
@@ -0,0 +1,498 @@
+package clusterconf
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/url"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cerana/cerana/acomm"
+	"github.com/cerana/cerana/pkg/errors"
+)
+
+// ExportVersion is the version of the cluster config document format written
+// by exports. Imports only accept documents of this version.
+const ExportVersion = 1
+
+// Import modes.
+const (
+	// ImportMerge creates and updates the objects in the document, leaving
+	// other objects in the cluster as they are.
+	ImportMerge = "merge"
+	// ImportReplace also deletes the bundles, services, datasets, and node
+	// configs that are not in the document.
+	ImportReplace = "replace"
+)
+
+// Types of objects in a cluster config document, in addition to the ones
+// referenced by bundles.
+const (
+	nodeConfigObject = "nodeConfig"
+	defaultsObject   = "defaults"
+	dhcpObject       = "dhcp"
+)
+
+// Import change actions.
+const (
+	importCreate = "create"
+	importUpdate = "update"
+	importDelete = "delete"
+)
+
+// ClusterConfigDocument is a versioned document of all of the configuration
+// objects of a cluster. Objects keep the ModIndex they had when exported so
+// imports can detect changes made since.
+type ClusterConfigDocument struct {
+	Version     int           `json:"version"`
+	ExportedAt  time.Time     `json:"exportedAt"`
+	Bundles     []*Bundle     `json:"bundles"`
+	Services    []*Service    `json:"services"`
+	Datasets    []*Dataset    `json:"datasets"`
+	NodeConfigs []*NodeConfig `json:"nodeConfigs"`
+	Defaults    *Defaults     `json:"defaults"`
+	// DHCP is nil if the cluster's dhcp config has not been set.
+	DHCP *DHCPConfig `json:"dhcp,omitempty"`
+}
+
+// ImportArgs are args for importing a cluster config document.
+type ImportArgs struct {
+	Document *ClusterConfigDocument `json:"document"`
+	// Mode is either ImportMerge, the default, or ImportReplace.
+	Mode string `json:"mode"`
+	// DryRun only determines the changes, conflicts, and problems of the
+	// import without applying it.
+	DryRun bool `json:"dryRun"`
+	// Overwrite applies changes to objects that were modified since the
+	// document was exported instead of treating them as conflicts.
+	Overwrite bool `json:"overwrite"`
+	// Force applies the import even if the resulting bundles have problems.
+	Force bool `json:"force"`
+	// Author identifies who made the changes, recorded in the revision
+	// history.
+	Author string `json:"author,omitempty"`
+}
+
+// ImportChange is a change made, or to be made, by an import.
+type ImportChange struct {
+	Type   string `json:"type"`
+	ID     string `json:"id"`
+	Action string `json:"action"`
+	// object is the object to save or delete.
+	object interface{}
+	// modIndex is the current ModIndex of the object in the cluster.
+	modIndex uint64
+}
+
+// ImportConflict is an object that differs from the document and was modified
+// in the cluster since the document was exported.
+type ImportConflict struct {
+	Type string `json:"type"`
+	ID   string `json:"id"`
+	// ModIndex is the object's ModIndex in the document.
+	ModIndex uint64 `json:"modIndex"`
+	// CurrentModIndex is the object's ModIndex in the cluster.
+	CurrentModIndex uint64 `json:"currentModIndex"`
+	Message         string `json:"message"`
+}
+
+// ImportResult is the result of an import. Changes are only applied if it is
+// not a dry run and there are no unresolved conflicts or problems.
+type ImportResult struct {
+	Changes   []*ImportChange   `json:"changes"`
+	Conflicts []*ImportConflict `json:"conflicts"`
+	// Problems are the problems the bundles would have after the import.
+	Problems []*ConfigProblem `json:"problems"`
+	Applied  bool             `json:"applied"`
+}
+
+// ExportClusterConfig streams a document of all of the cluster config
+// objects.
+func (c *ClusterConf) ExportClusterConfig(req *acomm.Request) (interface{}, *url.URL, error) {
+	doc, err := c.exportDocument()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	data, err := json.Marshal(doc)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "failed to json marshal cluster config document")
+	}
+	addr, err := c.tracker.NewStreamUnix(c.config.StreamDir(req.Task), ioutil.NopCloser(bytes.NewReader(data)))
+	if err != nil {
+		return nil, nil, err
+	}
+	return nil, addr, nil
+}
+
+// ImportClusterConfig restores the objects of a cluster config document.
+func (c *ClusterConf) ImportClusterConfig(req *acomm.Request) (interface{}, *url.URL, error) {
+	var args ImportArgs
+	if err := req.UnmarshalArgs(&args); err != nil {
+		return nil, nil, err
+	}
+	if err := args.validate(); err != nil {
+		return nil, nil, err
+	}
+
+	unlock, err := c.lockChanges()
+	if err != nil {
+		return nil, nil, err
+	}
+	defer unlock()
+
+	current, err := c.exportDocument()
+	if err != nil {
+		return nil, nil, err
+	}
+	result := planImport(current, args)
+	if !result.Applied {
+		return result, nil, nil
+	}
+	if err := c.checkProjects(result.applyProjectChanges); err != nil {
+		return nil, nil, err
+	}
+
+	for _, change := range result.Changes {
+		apply := func() error { return c.applyImportChange(change, args.Author) }
+		if err := c.audited(req, args.Author, change.Type, change.ID, change.configKey(), apply); err != nil {
+			return nil, nil, errors.Wrapv(err, map[string]interface{}{"type": change.Type, "id": change.ID, "action": change.Action})
+		}
+	}
+	return result, nil, nil
+}
+
+// exportDocument creates a document of the current cluster config objects.
+func (c *ClusterConf) exportDocument() (*ClusterConfigDocument, error) {
+	doc := &ClusterConfigDocument{
+		Version:    ExportVersion,
+		ExportedAt: time.Now(),
+	}
+
+	var err error
+	if doc.Bundles, err = c.getBundles(false); err != nil {
+		return nil, err
+	}
+	if doc.Services, err = c.getServices(); err != nil {
+		return nil, err
+	}
+	if doc.Datasets, err = c.getDatasets(); err != nil {
+		return nil, err
+	}
+	if doc.NodeConfigs, err = c.getNodeConfigs(); err != nil {
+		return nil, err
+	}
+	if doc.Defaults, err = c.getDefaults(); err != nil {
+		return nil, err
+	}
+
+	value, err := c.kvGet(dhcpPrefix)
+	if err != nil {
+		if !strings.Contains(err.Error(), "key not found") {
+			return nil, err
+		}
+	} else {
+		doc.DHCP = &DHCPConfig{}
+		if err := json.Unmarshal(value.Data, doc.DHCP); err != nil {
+			return nil, errors.Wrapv(err, map[string]interface{}{"json": string(value.Data)})
+		}
+	}
+
+	doc.sort()
+	return doc, nil
+}
+
+// applyImportChange saves or deletes the object of an import change.
+func (c *ClusterConf) applyImportChange(change *ImportChange, author string) error {
+	if change.Action == importDelete {
+		switch object := change.object.(type) {
+		case *Bundle:
+			return object.delete()
+		case *Service:
+			return object.delete()
+		case *Dataset:
+			return object.delete()
+		case *NodeConfig:
+			return c.kvDelete(path.Join(nodeConfigsPrefix, object.ID), object.ModIndex)
+		}
+		return errors.New("object can not be deleted")
+	}
+
+	switch object := change.object.(type) {
+	case *Bundle:
+		object.c = c
+		object.ModIndex = change.modIndex
+		if err := object.update(); err != nil {
+			return err
+		}
+		return c.saveRevision(bundleKey(object.ID), object.ModIndex, author, object)
+	case *Service:
+		object.c = c
+		object.ModIndex = change.modIndex
+		if err := object.update(); err != nil {
+			return err
+		}
+		return c.saveRevision(path.Join(servicesPrefix, object.ID), object.ModIndex, author, object)
+	case *Dataset:
+		object.c = c
+		object.ModIndex = change.modIndex
+		if err := object.update(); err != nil {
+			return err
+		}
+		return c.saveRevision(path.Join(datasetsPrefix, object.ID), object.ModIndex, author, object)
+	case *NodeConfig:
+		object.c = c
+		object.ModIndex = change.modIndex
+		return object.update()
+	case *Defaults:
+		object.c = c
+		object.ModIndex = change.modIndex
+		return object.update()
+	case *DHCPConfig:
+		_, err := c.kvUpdate(dhcpPrefix, object, 0)
+		return err
+	}
+	return errors.New("unknown object type")
+}
+
+// configKey returns the key the config of the object of an import change is
+// stored at.
+func (change *ImportChange) configKey() string {
+	switch change.Type {
+	case bundleReference:
+		return path.Join(bundlesPrefix, change.ID, "config")
+	case serviceReference:
+		return path.Join(servicesPrefix, change.ID, "config")
+	case datasetReference:
+		return path.Join(datasetsPrefix, change.ID, "config")
+	case nodeConfigObject:
+		return path.Join(nodeConfigsPrefix, change.ID)
+	case defaultsObject:
+		return defaultsPrefix
+	}
+	return dhcpPrefix
+}
+
+func (a ImportArgs) validate() error {
+	if a.Document == nil {
+		return errors.Newv("missing arg: document", map[string]interface{}{"args": a})
+	}
+	if a.Document.Version != ExportVersion {
+		return errors.Newv("unsupported document version", map[string]interface{}{"version": a.Document.Version, "supported": ExportVersion})
+	}
+	if a.Mode != "" && a.Mode != ImportMerge && a.Mode != ImportReplace {
+		return errors.Newv("invalid arg: mode", map[string]interface{}{"mode": a.Mode})
+	}
+	seen := make(map[string]bool)
+	for _, object := range a.Document.objects() {
+		if object.id == "" || object.id == "0" {
+			return errors.Newv("document object missing id", map[string]interface{}{"type": object.objectType})
+		}
+		if seen[object.key()] {
+			return errors.Newv("duplicate document object", map[string]interface{}{"type": object.objectType, "id": object.id})
+		}
+		seen[object.key()] = true
+	}
+	if a.Document.DHCP != nil {
+		if err := a.Document.DHCP.Validate(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// documentObject is an object of a cluster config document.
+type documentObject struct {
+	objectType string
+	id         string
+	modIndex   uint64
+	object     interface{}
+	// config is the part of the object that is compared between documents.
+	config interface{}
+}
+
+func (o *documentObject) key() string {
+	return o.objectType + "/" + o.id
+}
+
+// objects returns the objects of the document in the order they should be
+// created or updated, so bundles come after the objects they reference.
+func (d *ClusterConfigDocument) objects() []*documentObject {
+	objects := make([]*documentObject, 0)
+	for _, dataset := range d.Datasets {
+		config := *dataset
+		config.c = nil
+		config.ModIndex = 0
+		objects = append(objects, &documentObject{datasetReference, dataset.ID, dataset.ModIndex, dataset, config})
+	}
+	for _, service := range d.Services {
+		objects = append(objects, &documentObject{serviceReference, service.ID, service.ModIndex, service, service.ServiceConf})
+	}
+	for _, bundle := range d.Bundles {
+		config := *bundle
+		config.c = nil
+		config.ModIndex = 0
+		objects = append(objects, &documentObject{bundleReference, strconv.FormatUint(bundle.ID, 10), bundle.ModIndex, bundle, config})
+	}
+	for _, nodeConfig := range d.NodeConfigs {
+		config := *nodeConfig
+		config.c = nil
+		config.ModIndex = 0
+		objects = append(objects, &documentObject{nodeConfigObject, nodeConfig.ID, nodeConfig.ModIndex, nodeConfig, config})
+	}
+	// Unset defaults have no ModIndex and are treated as absent.
+	if d.Defaults != nil && (d.Defaults.ModIndex != 0 || d.Defaults.DefaultsConf != DefaultsConf{}) {
+		objects = append(objects, &documentObject{defaultsObject, defaultsPrefix, d.Defaults.ModIndex, d.Defaults, d.Defaults.DefaultsConf})
+	}
+	if d.DHCP != nil {
+		objects = append(objects, &documentObject{dhcpObject, dhcpPrefix, 0, d.DHCP, d.DHCP})
+	}
+	return objects
+}
+
+// sort orders the document's objects by id for stable output.
+func (d *ClusterConfigDocument) sort() {
+	sort.Sort(bundlesByID(d.Bundles))
+	sort.Sort(servicesByID(d.Services))
+	sort.Sort(datasetsByID(d.Datasets))
+	sort.Sort(nodeConfigsByID(d.NodeConfigs))
+}
+
+// planImport determines the changes needed to import the document into the
+// current cluster config, along with any conflicts and resulting problems.
+func planImport(current *ClusterConfigDocument, args ImportArgs) *ImportResult {
+	result := &ImportResult{
+		Changes:   make([]*ImportChange, 0),
+		Conflicts: make([]*ImportConflict, 0),
+		Problems:  make([]*ConfigProblem, 0),
+	}
+
+	existing := make(map[string]*documentObject)
+	for _, object := range current.objects() {
+		existing[object.key()] = object
+	}
+
+	imported := make(map[string]bool)
+	for _, object := range args.Document.objects() {
+		imported[object.key()] = true
+		cur, ok := existing[object.key()]
+		if !ok {
+			result.Changes = append(result.Changes, &ImportChange{Type: object.objectType, ID: object.id, Action: importCreate, object: object.object})
+			continue
+		}
+		if sameConfig(cur.config, object.config) {
+			continue
+		}
+
+		switch {
+		case object.objectType == dhcpObject:
+			result.Conflicts = append(result.Conflicts, &ImportConflict{
+				Type:    object.objectType,
+				ID:      object.id,
+				Message: "dhcp configuration can not be altered",
+			})
+			continue
+		case object.modIndex != cur.modIndex && !args.Overwrite:
+			result.Conflicts = append(result.Conflicts, &ImportConflict{
+				Type:            object.objectType,
+				ID:              object.id,
+				ModIndex:        object.modIndex,
+				CurrentModIndex: cur.modIndex,
+				Message:         "modified since export",
+			})
+		}
+		result.Changes = append(result.Changes, &ImportChange{Type: object.objectType, ID: object.id, Action: importUpdate, object: object.object, modIndex: cur.modIndex})
+	}
+
+	deleted := make(map[string]bool)
+	if args.Mode == ImportReplace {
+		// Delete in reverse order, so bundles go before what they reference
+		objects := current.objects()
+		for i := len(objects) - 1; i >= 0; i-- {
+			object := objects[i]
+			if imported[object.key()] || object.objectType == defaultsObject || object.objectType == dhcpObject {
+				continue
+			}
+			deleted[object.key()] = true
+			result.Changes = append(result.Changes, &ImportChange{Type: object.objectType, ID: object.id, Action: importDelete, object: object.object})
+		}
+	}
+
+	result.Problems = importProblems(current, args.Document, deleted)
+	result.Applied = !args.DryRun && len(result.Conflicts) == 0 && (len(result.Problems) == 0 || args.Force)
+	return result
+}
+
+// importProblems returns the problems the bundles would have after importing
+// the document.
+func importProblems(current, doc *ClusterConfigDocument, deleted map[string]bool) []*ConfigProblem {
+	ids := map[string]map[string]bool{
+		bundleReference:  make(map[string]bool),
+		serviceReference: make(map[string]bool),
+		datasetReference: make(map[string]bool),
+	}
+	bundles := make(map[string]*Bundle)
+	for _, d := range []*ClusterConfigDocument{current, doc} {
+		for _, object := range d.objects() {
+			if deleted[object.key()] {
+				continue
+			}
+			if objectIDs, ok := ids[object.objectType]; ok {
+				objectIDs[object.id] = true
+			}
+			if bundle, ok := object.object.(*Bundle); ok {
+				bundles[object.id] = bundle
+			}
+		}
+	}
+
+	problems := make(configProblems, 0)
+	bundleList := make([]*Bundle, 0, len(bundles))
+	for _, bundle := range bundles {
+		problems = append(problems, bundle.problems(ids[datasetReference], ids[serviceReference], ids[bundleReference])...)
+		bundleList = append(bundleList, bundle)
+	}
+	problems = append(problems, portConflicts(bundleList)...)
+	for _, service := range doc.Services {
+		if err := validateHealthChecks(service.HealthChecks); err != nil {
+			problems = append(problems, &ConfigProblem{Type: serviceReference, ID: service.ID, Message: err.Error()})
+		}
+	}
+	sort.Sort(problems)
+	return problems
+}
+
+// sameConfig returns whether two configs are the same once serialized.
+func sameConfig(a, b interface{}) bool {
+	aJSON, aErr := json.Marshal(a)
+	bJSON, bErr := json.Marshal(b)
+	return aErr == nil && bErr == nil && bytes.Equal(aJSON, bJSON)
+}
+
+type bundlesByID []*Bundle
+
+func (b bundlesByID) Len() int           { return len(b) }
+func (b bundlesByID) Swap(i, j int)      { b[i], b[j] = b[j], b[i] }
+func (b bundlesByID) Less(i, j int) bool { return b[i].ID < b[j].ID }
+
+type servicesByID []*Service
+
+func (s servicesByID) Len() int           { return len(s) }
+func (s servicesByID) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+func (s servicesByID) Less(i, j int) bool { return s[i].ID < s[j].ID }
+
+type datasetsByID []*Dataset
+
+func (d datasetsByID) Len() int           { return len(d) }
+func (d datasetsByID) Swap(i, j int)      { d[i], d[j] = d[j], d[i] }
+func (d datasetsByID) Less(i, j int) bool { return d[i].ID < d[j].ID }
+
+type nodeConfigsByID []*NodeConfig
+
+func (n nodeConfigsByID) Len() int           { return len(n) }
+func (n nodeConfigsByID) Swap(i, j int)      { n[i], n[j] = n[j], n[i] }
+func (n nodeConfigsByID) Less(i, j int) bool { return n[i].ID < n[j].ID }
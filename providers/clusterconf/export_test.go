@@ -0,0 +1,146 @@
+package clusterconf_test
+
+import (
+	"encoding/json"
+	"net"
+
+	"github.com/cerana/cerana/acomm"
+	"github.com/cerana/cerana/providers/clusterconf"
+)
+
+func (s *clusterConf) TestExportClusterConfig() {
+	bundle, err := s.addBundle()
+	s.Require().NoError(err)
+
+	doc := s.exportClusterConfig()
+	s.Equal(clusterconf.ExportVersion, doc.Version)
+	s.False(doc.ExportedAt.IsZero())
+	if s.Len(doc.Bundles, 1) {
+		s.Equal(bundle.ID, doc.Bundles[0].ID)
+		s.Equal(bundle.ModIndex, doc.Bundles[0].ModIndex)
+	}
+	s.Len(doc.Services, len(bundle.Services))
+	s.Len(doc.Datasets, len(bundle.Datasets))
+}
+
+func (s *clusterConf) TestImportClusterConfig() {
+	bundle, err := s.addBundle()
+	s.Require().NoError(err)
+	doc := s.exportClusterConfig()
+
+	tests := []struct {
+		desc string
+		args *clusterconf.ImportArgs
+		err  string
+	}{
+		{"missing document", &clusterconf.ImportArgs{}, "missing arg: document"},
+		{"unsupported version", &clusterconf.ImportArgs{Document: &clusterconf.ClusterConfigDocument{Version: 1000}}, "unsupported document version"},
+		{"invalid mode", &clusterconf.ImportArgs{Document: doc, Mode: "foo"}, "invalid arg: mode"},
+		{"unchanged", &clusterconf.ImportArgs{Document: doc}, ""},
+	}
+
+	for _, test := range tests {
+		result, err := s.importClusterConfig(test.args)
+		if test.err != "" {
+			s.Contains(err.Error(), test.err, test.desc)
+			continue
+		}
+		if s.NoError(err, test.desc) {
+			s.Empty(result.Changes, test.desc)
+			s.True(result.Applied, test.desc)
+		}
+	}
+
+	// Changes made since the export are conflicts
+	bundle.Redundancy = 5
+	bundle = s.updateBundle(bundle, "")
+	result, err := s.importClusterConfig(&clusterconf.ImportArgs{Document: doc})
+	s.Require().NoError(err)
+	s.False(result.Applied)
+	if s.Len(result.Conflicts, 1) {
+		s.Equal(doc.Bundles[0].ModIndex, result.Conflicts[0].ModIndex)
+		s.Equal(bundle.ModIndex, result.Conflicts[0].CurrentModIndex)
+	}
+
+	result, err = s.importClusterConfig(&clusterconf.ImportArgs{Document: doc, Overwrite: true, DryRun: true})
+	s.Require().NoError(err)
+	s.False(result.Applied)
+	s.Len(result.Changes, 1)
+	s.Equal(uint64(5), s.getBundle(bundle.ID).Redundancy)
+
+	result, err = s.importClusterConfig(&clusterconf.ImportArgs{Document: doc, Overwrite: true, Author: "alice"})
+	s.Require().NoError(err)
+	s.True(result.Applied)
+	s.Equal(doc.Bundles[0].Redundancy, s.getBundle(bundle.ID).Redundancy)
+	revisions := s.listBundleRevisions(bundle.ID)
+	if s.NotEmpty(revisions) {
+		s.Equal("alice", revisions[0].Author)
+	}
+
+	// Replacing deletes the objects that aren't in the document, but not
+	// when the remaining bundles would reference them
+	extra, err := s.addService()
+	s.Require().NoError(err)
+	result, err = s.importClusterConfig(&clusterconf.ImportArgs{Document: doc, Mode: clusterconf.ImportReplace})
+	s.Require().NoError(err)
+	s.True(result.Applied)
+	if s.Len(result.Changes, 1) {
+		s.Equal(extra.ID, result.Changes[0].ID)
+		s.Equal("delete", result.Changes[0].Action)
+	}
+
+	// Deleted node configs stay deleted, so importing again changes nothing
+	req, err := acomm.NewRequest(acomm.RequestOptions{
+		Task: "update-node-config",
+		Args: &clusterconf.NodeConfigPayload{NodeConfig: &clusterconf.NodeConfig{ID: "10.0.0.1", Cordoned: true}},
+	})
+	s.Require().NoError(err)
+	_, _, err = s.clusterConf.UpdateNodeConfig(req)
+	s.Require().NoError(err)
+	result, err = s.importClusterConfig(&clusterconf.ImportArgs{Document: doc, Mode: clusterconf.ImportReplace})
+	s.Require().NoError(err)
+	s.True(result.Applied)
+	if s.Len(result.Changes, 1) {
+		s.Equal("10.0.0.1", result.Changes[0].ID)
+		s.Equal("delete", result.Changes[0].Action)
+	}
+	result, err = s.importClusterConfig(&clusterconf.ImportArgs{Document: doc, Mode: clusterconf.ImportReplace})
+	s.Require().NoError(err)
+	s.Empty(result.Changes)
+
+	doc.Services = nil
+	result, err = s.importClusterConfig(&clusterconf.ImportArgs{Document: doc, Mode: clusterconf.ImportReplace})
+	s.Require().NoError(err)
+	s.False(result.Applied)
+	s.NotEmpty(result.Problems)
+}
+
+func (s *clusterConf) exportClusterConfig() *clusterconf.ClusterConfigDocument {
+	req, err := acomm.NewRequest(acomm.RequestOptions{Task: "export-cluster-config"})
+	s.Require().NoError(err)
+	result, streamURL, err := s.clusterConf.ExportClusterConfig(req)
+	s.Require().NoError(err)
+	s.Nil(result)
+	s.Require().NotNil(streamURL)
+
+	conn, err := net.Dial("unix", streamURL.RequestURI())
+	s.Require().NoError(err)
+	defer func() { _ = conn.Close() }()
+	var doc clusterconf.ClusterConfigDocument
+	s.Require().NoError(json.NewDecoder(conn).Decode(&doc))
+	return &doc
+}
+
+func (s *clusterConf) importClusterConfig(args *clusterconf.ImportArgs) (*clusterconf.ImportResult, error) {
+	req, err := acomm.NewRequest(acomm.RequestOptions{
+		Task: "import-cluster-config",
+		Args: args,
+	})
+	s.Require().NoError(err)
+	result, streamURL, err := s.clusterConf.ImportClusterConfig(req)
+	s.Nil(streamURL)
+	if err != nil {
+		return nil, err
+	}
+	return result.(*clusterconf.ImportResult), nil
+}
@@ -0,0 +1,111 @@
+package clusterconf
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/url"
+	"regexp"
+
+	"github.com/cerana/cerana/pkg/errors"
+	"github.com/cerana/cerana/providers/health"
+)
+
+// healthCheckArgs returns new args for each of the known health check tasks.
+var healthCheckArgs = map[string]func() interface{}{
+	"health-file":         func() interface{} { return &health.FileArgs{} },
+	"health-http-status":  func() interface{} { return &health.HTTPStatusArgs{} },
+	"health-tcp-response": func() interface{} { return &health.TCPResponseArgs{} },
+	"health-uptime":       func() interface{} { return &health.UptimeArgs{} },
+}
+
+// validateHealthChecks validates a set of health checks, keyed by id.
+func validateHealthChecks(healthChecks map[string]HealthCheck) error {
+	for id, healthCheck := range healthChecks {
+		if healthCheck.ID != "" && healthCheck.ID != id {
+			return errors.Newv("health check id does not match key", map[string]interface{}{"key": id, "healthCheckID": healthCheck.ID})
+		}
+		if err := healthCheck.validate(); err != nil {
+			return errors.Wrapv(err, map[string]interface{}{"healthCheckID": id})
+		}
+	}
+	return nil
+}
+
+// validateHealthChecks validates the health checks of each of the bundle's
+// services.
+func (b *Bundle) validateHealthChecks() error {
+	for id, service := range b.Services {
+		if err := validateHealthChecks(service.HealthChecks); err != nil {
+			return errors.Wrapv(err, map[string]interface{}{"bundleID": b.ID, "serviceID": id})
+		}
+	}
+	return nil
+}
+
+// validate checks that the health check is of a known type, that its args
+// are valid for the type, and that its timing is consistent.
+func (h HealthCheck) validate() error {
+	newArgs, ok := healthCheckArgs[h.Type]
+	if !ok {
+		return errors.Newv("unknown health check type", map[string]interface{}{"type": h.Type})
+	}
+	if h.Interval < 0 || h.Timeout < 0 || h.FailureThreshold < 0 {
+		return errors.Newv("health check interval, timeout, and failure threshold must not be negative", map[string]interface{}{"interval": h.Interval, "timeout": h.Timeout, "failureThreshold": h.FailureThreshold})
+	}
+	if h.Interval > 0 && h.Timeout > h.Interval {
+		return errors.Newv("health check timeout exceeds interval", map[string]interface{}{"interval": h.Interval, "timeout": h.Timeout})
+	}
+
+	// Args are decoded strictly so misspelled fields are caught
+	data, err := json.Marshal(h.Args)
+	if err != nil {
+		return errors.Wrapv(err, map[string]interface{}{"type": h.Type}, "invalid health check args")
+	}
+	args := newArgs()
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(args); err != nil {
+		return errors.Wrapv(err, map[string]interface{}{"type": h.Type, "args": string(data)}, "invalid health check args")
+	}
+
+	return validateHealthCheckArgs(h.Type, args)
+}
+
+// validateHealthCheckArgs checks for the args each health check task requires.
+func validateHealthCheckArgs(checkType string, args interface{}) error {
+	var missing string
+	switch args := args.(type) {
+	case *health.FileArgs:
+		if args.Path == "" {
+			missing = "path"
+		}
+	case *health.HTTPStatusArgs:
+		if args.URL == "" {
+			missing = "url"
+			break
+		}
+		if _, err := url.ParseRequestURI(args.URL); err != nil {
+			return errors.Wrapv(err, map[string]interface{}{"type": checkType, "url": args.URL}, "invalid health check args")
+		}
+	case *health.TCPResponseArgs:
+		if args.Address == "" {
+			missing = "address"
+			break
+		}
+		if args.Regexp == "" {
+			missing = "regexp"
+			break
+		}
+		if _, err := regexp.Compile(args.Regexp); err != nil {
+			return errors.Wrapv(err, map[string]interface{}{"type": checkType, "regexp": args.Regexp}, "invalid health check args")
+		}
+	case *health.UptimeArgs:
+		if args.Name == "" {
+			missing = "name"
+		}
+	}
+	if missing != "" {
+		return errors.Newv("missing health check arg: "+missing, map[string]interface{}{"type": checkType})
+	}
+	return nil
+}
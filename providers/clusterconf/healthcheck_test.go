@@ -0,0 +1,74 @@
+package clusterconf_test
+
+import (
+	"time"
+
+	"github.com/cerana/cerana/acomm"
+	"github.com/cerana/cerana/providers/clusterconf"
+	"github.com/cerana/cerana/providers/health"
+)
+
+func (s *clusterConf) TestUpdateServiceHealthChecks() {
+	tests := []struct {
+		desc        string
+		healthCheck clusterconf.HealthCheck
+		err         string
+	}{
+		{"unknown type", clusterconf.HealthCheck{Type: "health-foo"}, "unknown health check type"},
+		{"unknown arg", clusterconf.HealthCheck{Type: "health-file", Args: map[string]interface{}{"pth": "/foo"}}, "invalid health check args"},
+		{"wrong arg type", clusterconf.HealthCheck{Type: "health-file", Args: map[string]interface{}{"path": 1}}, "invalid health check args"},
+		{"missing arg", clusterconf.HealthCheck{Type: "health-uptime"}, "missing health check arg: name"},
+		{"invalid regexp", clusterconf.HealthCheck{Type: "health-tcp-response", Args: health.TCPResponseArgs{Address: "localhost:80", Regexp: "("}}, "invalid health check args"},
+		{"negative interval", clusterconf.HealthCheck{Type: "health-file", Args: health.FileArgs{Path: "/foo"}, Interval: -time.Second}, "must not be negative"},
+		{"timeout exceeds interval", clusterconf.HealthCheck{Type: "health-file", Args: health.FileArgs{Path: "/foo"}, Interval: time.Second, Timeout: time.Minute}, "health check timeout exceeds interval"},
+		{"mismatched id", clusterconf.HealthCheck{ID: "bar", Type: "health-file", Args: health.FileArgs{Path: "/foo"}}, "health check id does not match key"},
+		{"valid", clusterconf.HealthCheck{ID: "foo", Type: "health-http-status", Args: health.HTTPStatusArgs{URL: "http://localhost/health"}, Interval: time.Minute, Timeout: time.Second, FailureThreshold: 3}, ""},
+	}
+
+	for _, test := range tests {
+		req, err := acomm.NewRequest(acomm.RequestOptions{
+			Task: "update-service",
+			Args: &clusterconf.ServicePayload{
+				Service: &clusterconf.Service{
+					ServiceConf: clusterconf.ServiceConf{
+						HealthChecks: map[string]clusterconf.HealthCheck{"foo": test.healthCheck},
+					},
+				},
+			},
+		})
+		s.Require().NoError(err, test.desc)
+		result, streamURL, err := s.clusterConf.UpdateService(req)
+		s.Nil(streamURL, test.desc)
+		if test.err != "" {
+			if s.Error(err, test.desc) {
+				s.Contains(err.Error(), test.err, test.desc)
+			}
+			s.Nil(result, test.desc)
+			continue
+		}
+		if s.NoError(err, test.desc) {
+			s.Equal(test.healthCheck.FailureThreshold, result.(*clusterconf.ServicePayload).Service.HealthChecks["foo"].FailureThreshold, test.desc)
+		}
+	}
+}
+
+func (s *clusterConf) TestUpdateBundleHealthChecks() {
+	bundle, err := s.addBundle()
+	s.Require().NoError(err)
+	for id, service := range bundle.Services {
+		service.HealthChecks = map[string]clusterconf.HealthCheck{
+			"foo": {Type: "health-uptime", Args: map[string]interface{}{"nmae": "foo"}},
+		}
+		bundle.Services[id] = service
+	}
+
+	req, err := acomm.NewRequest(acomm.RequestOptions{
+		Task: "update-bundle",
+		Args: &clusterconf.BundlePayload{Bundle: bundle, Force: true},
+	})
+	s.Require().NoError(err)
+	_, _, err = s.clusterConf.UpdateBundle(req)
+	if s.Error(err) {
+		s.Contains(err.Error(), "invalid health check args")
+	}
+}
@@ -6,6 +6,8 @@ import (
 	"net/url"
 	"path"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/cerana/cerana/acomm"
 	"github.com/cerana/cerana/pkg/errors"
@@ -18,12 +20,15 @@ type DatasetHeartbeatArgs struct {
 	ID    string `json:"id"`
 	IP    net.IP `json:"ip"`
 	InUse bool   `json:"inUse"`
+	// Snapshots are the snapshots of the dataset on the node.
+	Snapshots []DatasetSnapshot `json:"snapshots,omitempty"`
 }
 
 // DatasetHeartbeat is dataset heartbeat information.
 type DatasetHeartbeat struct {
-	IP    net.IP `json:"ip"`
-	InUse bool   `json:"inUse"`
+	IP        net.IP            `json:"ip"`
+	InUse     bool              `json:"inUse"`
+	Snapshots []DatasetSnapshot `json:"snapshots,omitempty"`
 }
 
 // DatasetHeartbeatList is the result of a ListDatasetHeartbeats.
@@ -44,36 +49,57 @@ func (c *ClusterConf) DatasetHeartbeat(req *acomm.Request) (interface{}, *url.UR
 		return nil, nil, errors.Newv("missing arg: ip", map[string]interface{}{"args": args})
 	}
 
+	defaults, err := c.getDefaults()
+	if err != nil {
+		return nil, nil, err
+	}
+	ttl := heartbeatTTL(defaults.DatasetTTL, c.config.DatasetTTL())
+
+	heartbeat := DatasetHeartbeat{
+		IP:        args.IP,
+		InUse:     args.InUse,
+		Snapshots: args.Snapshots,
+	}
 	key := path.Join(heartbeatPrefix, datasetsPrefix, args.ID, args.IP.String())
-	return nil, nil, errors.Wrapv(c.kvEphemeral(key, args.InUse, c.config.DatasetTTL()), map[string]interface{}{"datasetID": args.ID})
+	return nil, nil, errors.Wrapv(c.kvEphemeral(key, heartbeat, ttl), map[string]interface{}{"datasetID": args.ID})
 }
 
 // ListDatasetHeartbeats returns a list of all active dataset heartbeats.
 func (c *ClusterConf) ListDatasetHeartbeats(req *acomm.Request) (interface{}, *url.URL, error) {
-	base := path.Join(heartbeatPrefix, datasetsPrefix)
-	values, err := c.kvGetAll(base)
+	heartbeats, err := c.getDatasetHeartbeats("")
 	if err != nil {
 		return nil, nil, err
 	}
+	return DatasetHeartbeatList{heartbeats}, nil, nil
+}
+
+// getDatasetHeartbeats retrieves the active heartbeats of a dataset, or of
+// all datasets without an id, keyed by dataset id and node ip.
+func (c *ClusterConf) getDatasetHeartbeats(id string) (map[string]map[string]DatasetHeartbeat, error) {
+	base := path.Join(heartbeatPrefix, datasetsPrefix)
+	values, err := c.kvGetAll(path.Join(base, id) + "/")
+	if err != nil {
+		return nil, err
+	}
 	heartbeats := make(map[string]map[string]DatasetHeartbeat)
 	for key, value := range values {
-		if key == base {
+		// key: {base}/{id}/{ip}
+		parts := strings.Split(strings.TrimPrefix(key, base+"/"), "/")
+		if len(parts) != 2 || parts[1] == "" {
 			continue
 		}
-		// key: {base}/{id}/{ip}
-		id := path.Base(path.Dir(key))
-		ip := net.ParseIP(path.Base(key))
-		var inUse bool
-		if err := json.Unmarshal(value.Data, &inUse); err != nil {
-			return nil, nil, errors.Wrapv(err, map[string]interface{}{"json": string(value.Data)})
+		datasetID := parts[0]
+		var heartbeat DatasetHeartbeat
+		if err := json.Unmarshal(value.Data, &heartbeat); err != nil {
+			return nil, errors.Wrapv(err, map[string]interface{}{"json": string(value.Data)})
 		}
-		if _, ok := heartbeats[id]; !ok {
-			heartbeats[id] = make(map[string]DatasetHeartbeat)
+		heartbeat.IP = net.ParseIP(path.Base(key))
+		if _, ok := heartbeats[datasetID]; !ok {
+			heartbeats[datasetID] = make(map[string]DatasetHeartbeat)
 		}
-		heartbeats[id][ip.String()] = DatasetHeartbeat{IP: ip, InUse: inUse}
+		heartbeats[datasetID][heartbeat.IP.String()] = heartbeat
 	}
-
-	return DatasetHeartbeatList{heartbeats}, nil, nil
+	return heartbeats, nil
 }
 
 // BundleHeartbeatArgs are argumenst for updating a bundle heartbeat.
@@ -84,10 +110,51 @@ type BundleHeartbeatArgs struct {
 	HealthErrors map[string]error `json:"healthErrors"`
 }
 
+// MarshalJSON marshals BundleHeartbeatArgs into a JSON map, converting error
+// values to strings.
+func (b BundleHeartbeatArgs) MarshalJSON() ([]byte, error) {
+	type Alias BundleHeartbeatArgs
+	errs := make(map[string]string)
+	for key, err := range b.HealthErrors {
+		errs[key] = err.Error()
+	}
+	j, err := json.Marshal(&struct {
+		HealthErrors map[string]string `json:"healthErrors"`
+		Alias
+	}{
+		HealthErrors: errs,
+		Alias:        (Alias)(b),
+	})
+	return j, errors.Wrap(err)
+}
+
+// UnmarshalJSON unmarshals JSON into a BundleHeartbeatArgs, converting string
+// values to errors.
+func (b *BundleHeartbeatArgs) UnmarshalJSON(data []byte) error {
+	type Alias BundleHeartbeatArgs
+	aux := &struct {
+		HealthErrors map[string]string `json:"healthErrors"`
+		*Alias
+	}{
+		Alias: (*Alias)(b),
+	}
+	if err := json.Unmarshal(data, &aux); err != nil {
+		return errors.Wrapv(err, map[string]interface{}{"json": string(data)})
+	}
+	b.HealthErrors = make(map[string]error)
+	for key, errS := range aux.HealthErrors {
+		b.HealthErrors[key] = errors.New(errS)
+	}
+	return nil
+}
+
 // BundleHeartbeat is bundle heartbeat information.
 type BundleHeartbeat struct {
 	IP           net.IP           `json:"ip"`
 	HealthErrors map[string]error `json:"healthErrors"`
+	// FailingSince is when each health check with an error started failing,
+	// carried over between consecutive heartbeats.
+	FailingSince map[string]time.Time `json:"failingSince,omitempty"`
 }
 
 // MarshalJSON marshals BundleHeartbeat into a JSON map, converting error
@@ -202,30 +269,76 @@ func (c *ClusterConf) BundleHeartbeat(req *acomm.Request) (interface{}, *url.URL
 	}
 
 	key := path.Join(heartbeatPrefix, bundlesPrefix, strconv.FormatUint(args.ID, 10), args.Serial)
-	return nil, nil, errors.Wrapv(c.kvEphemeral(key, heartbeat, c.config.BundleTTL()), map[string]interface{}{"bundleID": args.ID})
+	var previous BundleHeartbeat
+	if len(args.HealthErrors) > 0 {
+		value, err := c.kvGet(key)
+		if err != nil && !strings.Contains(err.Error(), "key not found") {
+			return nil, nil, errors.Wrapv(err, map[string]interface{}{"bundleID": args.ID})
+		}
+		if err == nil {
+			// a previous heartbeat that can't be read only loses the
+			// failure start times
+			_ = json.Unmarshal(value.Data, &previous)
+		}
+	}
+	heartbeat.FailingSince = failingSince(previous, args.HealthErrors, time.Now())
+
+	defaults, err := c.getDefaults()
+	if err != nil {
+		return nil, nil, err
+	}
+	ttl := heartbeatTTL(defaults.BundleTTL, c.config.BundleTTL())
+	return nil, nil, errors.Wrapv(c.kvEphemeral(key, heartbeat, ttl), map[string]interface{}{"bundleID": args.ID})
+}
+
+// failingSince returns when each of the failing health checks started failing,
+// keeping the start times of checks that were already failing.
+func failingSince(previous BundleHeartbeat, healthErrors map[string]error, now time.Time) map[string]time.Time {
+	if len(healthErrors) == 0 {
+		return nil
+	}
+	since := make(map[string]time.Time, len(healthErrors))
+	for id := range healthErrors {
+		start, ok := previous.FailingSince[id]
+		if !ok {
+			start = now
+		}
+		since[id] = start
+	}
+	return since
 }
 
 // ListBundleHeartbeats returns a list of all active bundle heartbeats.
 func (c *ClusterConf) ListBundleHeartbeats(req *acomm.Request) (interface{}, *url.URL, error) {
-	base := path.Join(heartbeatPrefix, bundlesPrefix)
-	values, err := c.kvGetAll(base)
+	heartbeats, err := c.getBundleHeartbeats(path.Join(heartbeatPrefix, bundlesPrefix))
 	if err != nil {
 		return nil, nil, err
 	}
+	return BundleHeartbeatList{heartbeats}, nil, nil
+}
+
+// getBundleHeartbeats returns the active bundle heartbeats under the base,
+// either all bundles or a single bundle.
+func (c *ClusterConf) getBundleHeartbeats(base string) (map[uint64]BundleHeartbeats, error) {
+	// trailing separator to avoid matching bundle ids sharing the prefix
+	values, err := c.kvGetAll(base + "/")
+	if err != nil {
+		return nil, err
+	}
 	heartbeats := make(map[uint64]BundleHeartbeats)
 	for key, value := range values {
-		if key == base {
+		if key == base || key == base+"/" {
 			continue
 		}
-		// key: {base}/{id}/{serial}
+		// key: heartbeats/bundles/{id}/{serial}
 		id, err := strconv.ParseUint(path.Base(path.Dir(key)), 10, 64)
 		if err != nil {
-			return nil, nil, errors.Wrapv(err, map[string]interface{}{"id": path.Base(path.Dir(key))})
+			return nil, errors.Wrapv(err, map[string]interface{}{"id": path.Base(path.Dir(key))})
 		}
 		serial := path.Base(key)
 		var hb BundleHeartbeat
 		if err := json.Unmarshal(value.Data, &hb); err != nil {
-			return nil, nil, errors.Wrapv(err, map[string]interface{}{"json": string(value.Data)})
+			return nil, errors.Wrapv(err, map[string]interface{}{"json": string(value.Data)})
 		}
 		if _, ok := heartbeats[id]; !ok {
 			heartbeats[id] = make(BundleHeartbeats)
@@ -233,5 +346,5 @@ func (c *ClusterConf) ListBundleHeartbeats(req *acomm.Request) (interface{}, *ur
 		heartbeats[id][serial] = hb
 	}
 
-	return BundleHeartbeatList{heartbeats}, nil, nil
+	return heartbeats, nil
 }
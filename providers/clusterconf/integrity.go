@@ -0,0 +1,247 @@
+package clusterconf
+
+import (
+	"fmt"
+	"net/url"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/cerana/cerana/acomm"
+	"github.com/cerana/cerana/pkg/errors"
+)
+
+// Types of objects a ConfigProblem can concern.
+const (
+	bundleReference  = "bundle"
+	datasetReference = "dataset"
+	serviceReference = "service"
+	portReference    = "port"
+)
+
+// ConfigProblem is a dangling reference or inconsistency in the stored
+// configuration of a bundle.
+type ConfigProblem struct {
+	BundleID uint64 `json:"bundleID"`
+	// Type is the type of object the problem concerns: bundle, dataset,
+	// service, or port.
+	Type string `json:"type"`
+	// ID is the id of the object the problem concerns, if not the bundle
+	// itself.
+	ID      string `json:"id,omitempty"`
+	Message string `json:"message"`
+}
+
+// ValidateResult is the result of validating the cluster configuration.
+type ValidateResult struct {
+	Problems []*ConfigProblem `json:"problems"`
+}
+
+// configProblems sorts problems by bundle, type, and id.
+type configProblems []*ConfigProblem
+
+func (p configProblems) Len() int      { return len(p) }
+func (p configProblems) Swap(i, j int) { p[i], p[j] = p[j], p[i] }
+func (p configProblems) Less(i, j int) bool {
+	if p[i].BundleID != p[j].BundleID {
+		return p[i].BundleID < p[j].BundleID
+	}
+	if p[i].Type != p[j].Type {
+		return p[i].Type < p[j].Type
+	}
+	return p[i].ID < p[j].ID
+}
+
+// ValidateClusterConfig reports all dangling references and inconsistencies
+// in the stored bundle configs.
+func (c *ClusterConf) ValidateClusterConfig(req *acomm.Request) (interface{}, *url.URL, error) {
+	datasets, err := c.objectIDs(datasetsPrefix)
+	if err != nil {
+		return nil, nil, err
+	}
+	services, err := c.objectIDs(servicesPrefix)
+	if err != nil {
+		return nil, nil, err
+	}
+	bundleIDs, err := c.objectIDs(bundlesPrefix)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	problems := make(configProblems, 0)
+	bundles := make([]*Bundle, 0, len(bundleIDs))
+	for key := range bundleIDs {
+		id, err := strconv.ParseUint(key, 10, 64)
+		if err != nil {
+			problems = append(problems, &ConfigProblem{Type: bundleReference, ID: key, Message: "invalid bundle id"})
+			continue
+		}
+		bundle, err := c.getBundle(id)
+		if err != nil {
+			if !strings.Contains(err.Error(), "bundle config not found") {
+				return nil, nil, err
+			}
+			// e.g. an assignment left behind without its bundle
+			problems = append(problems, &ConfigProblem{BundleID: id, Type: bundleReference, Message: "bundle config not found"})
+			continue
+		}
+		problems = append(problems, bundle.problems(datasets, services, bundleIDs)...)
+		bundles = append(bundles, bundle)
+	}
+	problems = append(problems, portConflicts(bundles)...)
+	sort.Sort(problems)
+
+	return &ValidateResult{Problems: problems}, nil, nil
+}
+
+// problems returns the dangling references and inconsistencies of the bundle,
+// given the ids of the existing datasets, services, and bundles.
+func (b *Bundle) problems(datasets, services, bundles map[string]bool) []*ConfigProblem {
+	problems := make([]*ConfigProblem, 0)
+	add := func(objectType, id, message string) {
+		problems = append(problems, &ConfigProblem{
+			BundleID: b.ID,
+			Type:     objectType,
+			ID:       id,
+			Message:  message,
+		})
+	}
+
+	names := make(map[string]bool)
+	for id, dataset := range b.Datasets {
+		names[dataset.Name] = true
+		if dataset.ID != id {
+			add(datasetReference, id, "dataset id does not match key")
+		}
+		if !datasets[id] {
+			add(datasetReference, id, "dataset config not found")
+		}
+	}
+	for id, service := range b.Services {
+		if service.ID != id {
+			add(serviceReference, id, "service id does not match key")
+		}
+		if !services[id] {
+			add(serviceReference, id, "service config not found")
+		}
+		for _, mount := range service.Datasets {
+			if !names[mount.Name] {
+				add(serviceReference, id, fmt.Sprintf("service mounts unknown bundle dataset %q", mount.Name))
+			}
+		}
+		for checkID, healthCheck := range service.HealthChecks {
+			if err := healthCheck.validate(); err != nil {
+				add(serviceReference, id, fmt.Sprintf("health check %q: %s", checkID, err))
+			}
+		}
+	}
+	self := strconv.FormatUint(b.ID, 10)
+	for port, bundlePort := range b.Ports {
+		for _, connected := range bundlePort.ConnectedBundles {
+			if connected != self && !bundles[connected] {
+				add(bundleReference, connected, fmt.Sprintf("port %d connected bundle not found", port))
+			}
+		}
+	}
+	return problems
+}
+
+// checkReferences returns an error if the bundle has any dangling references
+// or inconsistencies.
+func (b *Bundle) checkReferences() error {
+	datasets, err := b.c.objectIDs(datasetsPrefix)
+	if err != nil {
+		return err
+	}
+	services, err := b.c.objectIDs(servicesPrefix)
+	if err != nil {
+		return err
+	}
+	bundles, err := b.c.objectIDs(bundlesPrefix)
+	if err != nil {
+		return err
+	}
+
+	problems := b.problems(datasets, services, bundles)
+	if len(problems) > 0 {
+		sort.Sort(configProblems(problems))
+		return errors.Newv("bundle has invalid references", map[string]interface{}{"bundleID": b.ID, "problems": problems})
+	}
+	return nil
+}
+
+// references returns whether the bundle references the dataset or service.
+func (b *Bundle) references(objectType, id string) bool {
+	switch objectType {
+	case datasetReference:
+		_, ok := b.Datasets[id]
+		return ok
+	case serviceReference:
+		_, ok := b.Services[id]
+		return ok
+	}
+	return false
+}
+
+// removeReference removes the dataset or service from the bundle. Removing a
+// dataset also removes service mounts of it.
+func (b *Bundle) removeReference(objectType, id string) {
+	switch objectType {
+	case datasetReference:
+		name := b.Datasets[id].Name
+		delete(b.Datasets, id)
+		for serviceID, service := range b.Services {
+			for key, mount := range service.Datasets {
+				if mount.Name == name {
+					delete(service.Datasets, key)
+				}
+			}
+			b.Services[serviceID] = service
+		}
+	case serviceReference:
+		delete(b.Services, id)
+	}
+}
+
+// removeReferences checks whether any bundles reference the dataset or service
+// being deleted. Referenced objects can only be deleted when forced, or when
+// cascading, which first removes them from the bundles. Callers hold the
+// changes lock until the object is deleted, so no bundle can start referencing
+// it in between.
+func (c *ClusterConf) removeReferences(req *acomm.Request, objectType string, args DeleteArgs) error {
+	if args.Force {
+		return nil
+	}
+
+	bundles, err := c.getBundles(false)
+	if err != nil {
+		return err
+	}
+	referencing := make([]*Bundle, 0)
+	bundleIDs := make([]uint64, 0)
+	for _, bundle := range bundles {
+		if bundle.references(objectType, args.ID) {
+			referencing = append(referencing, bundle)
+			bundleIDs = append(bundleIDs, bundle.ID)
+		}
+	}
+	if len(referencing) == 0 {
+		return nil
+	}
+	if !args.Cascade {
+		sort.Sort(uint64Slice(bundleIDs))
+		return errors.Newv(objectType+" is referenced by bundles", map[string]interface{}{"id": args.ID, "bundleIDs": bundleIDs})
+	}
+
+	for _, bundle := range referencing {
+		bundle.removeReference(objectType, args.ID)
+		if err := c.audited(req, args.Author, bundleReference, strconv.FormatUint(bundle.ID, 10), path.Join(bundleKey(bundle.ID), "config"), bundle.update); err != nil {
+			return err
+		}
+		if err := c.saveRevision(bundleKey(bundle.ID), bundle.ModIndex, args.Author, bundle); err != nil {
+			return err
+		}
+	}
+	return nil
+}
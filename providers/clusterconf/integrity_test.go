@@ -0,0 +1,147 @@
+package clusterconf_test
+
+import (
+	"github.com/cerana/cerana/acomm"
+	"github.com/cerana/cerana/providers/clusterconf"
+	"github.com/pborman/uuid"
+)
+
+func (s *clusterConf) TestUpdateBundleReferences() {
+	service, err := s.addService()
+	s.Require().NoError(err)
+	missing := uuid.New()
+
+	tests := []struct {
+		desc   string
+		bundle *clusterconf.Bundle
+		force  bool
+		err    string
+	}{
+		{"valid", &clusterconf.Bundle{
+			Services: map[string]clusterconf.BundleService{
+				service.ID: {ServiceConf: clusterconf.ServiceConf{ID: service.ID}},
+			},
+		}, false, ""},
+		{"missing dataset", &clusterconf.Bundle{
+			Datasets: map[string]clusterconf.BundleDataset{
+				missing: {ID: missing, Name: "data"},
+			},
+		}, false, "bundle has invalid references"},
+		{"mismatched service id", &clusterconf.Bundle{
+			Services: map[string]clusterconf.BundleService{
+				service.ID: {ServiceConf: clusterconf.ServiceConf{ID: missing}},
+			},
+		}, false, "bundle has invalid references"},
+		{"unknown service mount", &clusterconf.Bundle{
+			Services: map[string]clusterconf.BundleService{
+				service.ID: {
+					ServiceConf: clusterconf.ServiceConf{ID: service.ID},
+					Datasets: map[string]clusterconf.ServiceDataset{
+						"data": {Name: "data", MountPoint: "/data"},
+					},
+				},
+			},
+		}, false, "bundle has invalid references"},
+		{"forced missing dataset", &clusterconf.Bundle{
+			Datasets: map[string]clusterconf.BundleDataset{
+				missing: {ID: missing, Name: "data"},
+			},
+		}, true, ""},
+	}
+
+	for _, test := range tests {
+		req, err := acomm.NewRequest(acomm.RequestOptions{
+			Task: "update-bundle",
+			Args: &clusterconf.BundlePayload{Bundle: test.bundle, Force: test.force},
+		})
+		s.Require().NoError(err, test.desc)
+		result, _, err := s.clusterConf.UpdateBundle(req)
+		if test.err != "" {
+			s.Contains(err.Error(), test.err, test.desc)
+			s.Nil(result, test.desc)
+		} else {
+			s.NoError(err, test.desc)
+			s.NotNil(result, test.desc)
+		}
+	}
+}
+
+func (s *clusterConf) TestDeleteReferenced() {
+	bundle, err := s.addBundle()
+	s.Require().NoError(err)
+	var datasetID, serviceID string
+	for id := range bundle.Datasets {
+		datasetID = id
+	}
+	for id := range bundle.Services {
+		serviceID = id
+	}
+
+	req, err := acomm.NewRequest(acomm.RequestOptions{
+		Task: "delete-dataset",
+		Args: &clusterconf.DeleteArgs{ID: datasetID},
+	})
+	s.Require().NoError(err)
+	_, _, err = s.clusterConf.DeleteDataset(req)
+	if s.Error(err) {
+		s.Contains(err.Error(), "dataset is referenced by bundles")
+	}
+
+	req, err = acomm.NewRequest(acomm.RequestOptions{
+		Task: "delete-dataset",
+		Args: &clusterconf.DeleteArgs{ID: datasetID, Cascade: true, Author: "alice"},
+	})
+	s.Require().NoError(err)
+	_, _, err = s.clusterConf.DeleteDataset(req)
+	s.Require().NoError(err)
+	s.Empty(s.getBundle(bundle.ID).Datasets)
+	revisions := s.listBundleRevisions(bundle.ID)
+	if s.Len(revisions, 1) {
+		s.Equal("alice", revisions[0].Author)
+	}
+
+	req, err = acomm.NewRequest(acomm.RequestOptions{
+		Task: "delete-service",
+		Args: &clusterconf.DeleteArgs{ID: serviceID, Force: true},
+	})
+	s.Require().NoError(err)
+	_, _, err = s.clusterConf.DeleteService(req)
+	s.Require().NoError(err)
+	s.Contains(s.getBundle(bundle.ID).Services, serviceID)
+}
+
+func (s *clusterConf) TestValidateClusterConfig() {
+	bundle, err := s.addBundle()
+	s.Require().NoError(err)
+	_, err = s.addBundle()
+	s.Require().NoError(err)
+	var serviceID string
+	for id := range bundle.Services {
+		serviceID = id
+	}
+	s.Require().NoError(s.deleteKey("services/" + serviceID))
+
+	req, err := acomm.NewRequest(acomm.RequestOptions{Task: "validate-cluster-config"})
+	s.Require().NoError(err)
+	result, streamURL, err := s.clusterConf.ValidateClusterConfig(req)
+	s.Nil(streamURL)
+	s.Require().NoError(err)
+	problems := result.(*clusterconf.ValidateResult).Problems
+	if s.Len(problems, 1) {
+		s.Equal(bundle.ID, problems[0].BundleID)
+		s.Equal("service", problems[0].Type)
+		s.Equal(serviceID, problems[0].ID)
+		s.Equal("service config not found", problems[0].Message)
+	}
+}
+
+func (s *clusterConf) getBundle(id uint64) *clusterconf.Bundle {
+	req, err := acomm.NewRequest(acomm.RequestOptions{
+		Task: "get-bundle",
+		Args: &clusterconf.GetBundleArgs{ID: id},
+	})
+	s.Require().NoError(err)
+	result, _, err := s.clusterConf.GetBundle(req)
+	s.Require().NoError(err)
+	return result.(*clusterconf.BundlePayload).Bundle
+}
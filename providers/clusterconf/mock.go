@@ -1,13 +1,21 @@
 package clusterconf
 
 import (
+	"bytes"
+	"encoding/json"
 	"errors"
+	"io/ioutil"
 	"math/rand"
 	"net/url"
+	"path"
+	"sort"
+	"strconv"
 	"time"
 
 	"github.com/cerana/cerana/acomm"
+	"github.com/cerana/cerana/pkg/kv"
 	"github.com/cerana/cerana/provider"
+	kvp "github.com/cerana/cerana/providers/kv"
 	"github.com/pborman/uuid"
 )
 
@@ -18,28 +26,55 @@ type MockClusterConf struct {
 
 // MockClusterData is the in-memory data structure for a MockClusterConf.
 type MockClusterData struct {
-	Services   map[string]*Service
-	Bundles    map[uint64]*Bundle
-	BundlesHB  map[uint64]BundleHeartbeats
-	Datasets   map[string]*Dataset
-	DatasetsHB map[string]map[string]DatasetHeartbeat
-	Nodes      map[string]*Node
-	History    NodesHistory
-	Defaults   *Defaults
-	DHCP       *DHCPConfig
+	Services    map[string]*Service
+	Bundles     map[uint64]*Bundle
+	BundlesHB   map[uint64]BundleHeartbeats
+	Assignments map[uint64]*BundleAssignment
+	Datasets    map[string]*Dataset
+	DatasetsHB  map[string]map[string]DatasetHeartbeat
+	// Replications are keyed by dataset id.
+	Replications map[string]*DatasetReplication
+	Nodes        map[string]*Node
+	NodeConfigs  map[string]*NodeConfig
+	History      NodesHistory
+	Defaults     *Defaults
+	DHCP         *DHCPConfig
+	// Secrets are kept with their plain text values.
+	Secrets         map[string]*Secret
+	BundleTemplates map[string]*BundleTemplate
+	Projects        map[string]*Project
+	// Revisions are keyed by the object's kv key, e.g. "bundles/1".
+	Revisions map[string][]*MockRevision
+	// AuditEvents are listed by list-audit-events, but not recorded by the
+	// mock's tasks.
+	AuditEvents []*AuditEvent
+}
+
+// MockRevision is a saved config in the mock revision history.
+type MockRevision struct {
+	Revision
+	Config json.RawMessage
 }
 
 // NewMockClusterConf creates a new MockClusterConf.
 func NewMockClusterConf() *MockClusterConf {
 	return &MockClusterConf{
 		Data: &MockClusterData{
-			Services:   make(map[string]*Service),
-			Bundles:    make(map[uint64]*Bundle),
-			BundlesHB:  make(map[uint64]BundleHeartbeats),
-			Datasets:   make(map[string]*Dataset),
-			DatasetsHB: make(map[string]map[string]DatasetHeartbeat),
-			Nodes:      make(map[string]*Node),
-			History:    make(NodesHistory),
+			Services:        make(map[string]*Service),
+			Bundles:         make(map[uint64]*Bundle),
+			BundlesHB:       make(map[uint64]BundleHeartbeats),
+			Assignments:     make(map[uint64]*BundleAssignment),
+			Datasets:        make(map[string]*Dataset),
+			DatasetsHB:      make(map[string]map[string]DatasetHeartbeat),
+			Replications:    make(map[string]*DatasetReplication),
+			Nodes:           make(map[string]*Node),
+			NodeConfigs:     make(map[string]*NodeConfig),
+			History:         make(NodesHistory),
+			Revisions:       make(map[string][]*MockRevision),
+			Secrets:         make(map[string]*Secret),
+			BundleTemplates: make(map[string]*BundleTemplate),
+			Projects:        make(map[string]*Project),
+			AuditEvents:     make([]*AuditEvent, 0),
 		},
 	}
 }
@@ -49,9 +84,15 @@ func (c *MockClusterConf) RegisterTasks(server *provider.Server) {
 	server.RegisterTask("get-bundle", c.GetBundle)
 	server.RegisterTask("list-bundles", c.ListBundles)
 	server.RegisterTask("list-bundle-heartbeats", c.ListBundleHeartbeats)
+	server.RegisterTask("get-bundle-status", c.GetBundleStatus)
+	server.RegisterTask("list-bundle-status", c.ListBundleStatus)
+	server.RegisterTask("get-port-map", c.GetPortMap)
 	server.RegisterTask("update-bundle", c.UpdateBundle)
 	server.RegisterTask("delete-bundle", c.DeleteBundle)
 	server.RegisterTask("bundle-heartbeat", c.BundleHeartbeat)
+	server.RegisterTask("get-bundle-assignment", c.GetBundleAssignment)
+	server.RegisterTask("list-bundle-assignments", c.ListBundleAssignments)
+	server.RegisterTask("update-bundle-assignment", c.UpdateBundleAssignment)
 	server.RegisterTask("get-dataset", c.GetDataset)
 	server.RegisterTask("list-datasets", c.ListDatasets)
 	server.RegisterTask("list-dataset-heartbeats", c.ListDatasetHeartbeats)
@@ -64,12 +105,58 @@ func (c *MockClusterConf) RegisterTasks(server *provider.Server) {
 	server.RegisterTask("get-node", c.GetNode)
 	server.RegisterTask("list-nodes", c.ListNodes)
 	server.RegisterTask("get-nodes-history", c.GetNodesHistory)
+	server.RegisterTask("get-node-config", c.GetNodeConfig)
+	server.RegisterTask("list-node-configs", c.ListNodeConfigs)
+	server.RegisterTask("update-node-config", c.UpdateNodeConfig)
+	server.RegisterTask("delete-node-config", c.DeleteNodeConfig)
 	server.RegisterTask("get-service", c.GetService)
+	server.RegisterTask("list-services", c.ListServices)
 	server.RegisterTask("update-service", c.UpdateService)
 	server.RegisterTask("delete-service", c.DeleteService)
+	server.RegisterTask("watch-bundles", c.WatchBundles)
+	server.RegisterTask("watch-datasets", c.WatchDatasets)
+	server.RegisterTask("watch-nodes", c.WatchNodes)
+	server.RegisterTask("watch-services", c.WatchServices)
+	server.RegisterTask("list-bundle-revisions", c.ListBundleRevisions)
+	server.RegisterTask("get-bundle-revision", c.GetBundleRevision)
+	server.RegisterTask("rollback-bundle", c.RollbackBundle)
+	server.RegisterTask("get-bundle-template", c.GetBundleTemplate)
+	server.RegisterTask("list-bundle-templates", c.ListBundleTemplates)
+	server.RegisterTask("update-bundle-template", c.UpdateBundleTemplate)
+	server.RegisterTask("delete-bundle-template", c.DeleteBundleTemplate)
+	server.RegisterTask("instantiate-bundle", c.InstantiateBundle)
+	server.RegisterTask("list-template-instances", c.ListTemplateInstances)
+	server.RegisterTask("rollout-bundle-template", c.RolloutBundleTemplate)
+	server.RegisterTask("list-dataset-revisions", c.ListDatasetRevisions)
+	server.RegisterTask("get-dataset-revision", c.GetDatasetRevision)
+	server.RegisterTask("rollback-dataset", c.RollbackDataset)
+	server.RegisterTask("list-dataset-snapshots", c.ListDatasetSnapshots)
+	server.RegisterTask("get-dataset-lineage", c.GetDatasetLineage)
+	server.RegisterTask("find-snapshot-node", c.FindSnapshotNode)
+	server.RegisterTask("get-dataset-replication", c.GetDatasetReplication)
+	server.RegisterTask("list-dataset-replications", c.ListDatasetReplications)
+	server.RegisterTask("update-dataset-replication", c.UpdateDatasetReplication)
+	server.RegisterTask("list-service-revisions", c.ListServiceRevisions)
+	server.RegisterTask("get-service-revision", c.GetServiceRevision)
+	server.RegisterTask("rollback-service", c.RollbackService)
+	server.RegisterTask("get-secret", c.GetSecret)
+	server.RegisterTask("list-secrets", c.ListSecrets)
+	server.RegisterTask("update-secret", c.UpdateSecret)
+	server.RegisterTask("delete-secret", c.DeleteSecret)
+	server.RegisterTask("resolve-secrets", c.ResolveSecrets)
+	server.RegisterTask("get-project", c.GetProject)
+	server.RegisterTask("list-projects", c.ListProjects)
+	server.RegisterTask("update-project", c.UpdateProject)
+	server.RegisterTask("delete-project", c.DeleteProject)
+	server.RegisterTask("validate-cluster-config", c.ValidateClusterConfig)
+	server.RegisterTask("export-cluster-config", c.ExportClusterConfig)
+	server.RegisterTask("import-cluster-config", c.ImportClusterConfig)
+	server.RegisterTask("list-audit-events", c.ListAuditEvents)
+	server.RegisterTask("apply-cluster-changes", c.ApplyClusterChanges)
 }
 
-// GetBundle retrieves a mock bundle.
+// GetBundle retrieves a mock bundle. A combined overlay fails if the bundle
+// references a missing dataset.
 func (c *MockClusterConf) GetBundle(req *acomm.Request) (interface{}, *url.URL, error) {
 	var args GetBundleArgs
 	if err := req.UnmarshalArgs(&args); err != nil {
@@ -82,15 +169,30 @@ func (c *MockClusterConf) GetBundle(req *acomm.Request) (interface{}, *url.URL,
 	if !ok {
 		return nil, nil, errors.New("bundle config not found")
 	}
-	return &BundlePayload{bundle}, nil, nil
+	if args.CombinedOverlay {
+		for id := range bundle.Datasets {
+			if _, ok := c.Data.Datasets[id]; !ok {
+				return nil, nil, errors.New("dataset config not found")
+			}
+		}
+	}
+	return &BundlePayload{Bundle: bundle}, nil, nil
 }
 
 // ListBundles retrieves all mock bundles.
 func (c *MockClusterConf) ListBundles(req *acomm.Request) (interface{}, *url.URL, error) {
+	var args ListBundleArgs
+	if err := req.UnmarshalArgs(&args); err != nil {
+		return nil, nil, err
+	}
+
 	bundles := make([]*Bundle, 0, len(c.Data.Bundles))
 	for _, bundle := range c.Data.Bundles {
 		bundles = append(bundles, bundle)
 	}
+	if args.Project != "" {
+		bundles = projectBundles(bundles, args.Project)
+	}
 	return &BundleListResult{bundles}, nil, nil
 }
 
@@ -103,6 +205,9 @@ func (c *MockClusterConf) UpdateBundle(req *acomm.Request) (interface{}, *url.UR
 	if args.Bundle == nil {
 		return nil, nil, errors.New("missing arg: bundle")
 	}
+	if err := args.Bundle.validateHealthChecks(); err != nil {
+		return nil, nil, err
+	}
 
 	if args.Bundle.ID == 0 {
 		rand.Seed(time.Now().UnixNano())
@@ -111,7 +216,8 @@ func (c *MockClusterConf) UpdateBundle(req *acomm.Request) (interface{}, *url.UR
 
 	args.Bundle.ModIndex++
 	c.Data.Bundles[args.Bundle.ID] = args.Bundle
-	return &BundlePayload{args.Bundle}, nil, nil
+	c.addRevision(bundleKey(args.Bundle.ID), args.Bundle.ModIndex, args.Author, args.Bundle)
+	return &BundlePayload{Bundle: args.Bundle}, nil, nil
 }
 
 // DeleteBundle removes a mock bundle.
@@ -147,7 +253,12 @@ func (c *MockClusterConf) BundleHeartbeat(req *acomm.Request) (interface{}, *url
 	if _, ok := c.Data.BundlesHB[args.ID]; !ok {
 		c.Data.BundlesHB[args.ID] = make(BundleHeartbeats)
 	}
-	c.Data.BundlesHB[args.ID][args.Serial] = BundleHeartbeat{IP: args.IP, HealthErrors: args.HealthErrors}
+	previous := c.Data.BundlesHB[args.ID][args.Serial]
+	c.Data.BundlesHB[args.ID][args.Serial] = BundleHeartbeat{
+		IP:           args.IP,
+		HealthErrors: args.HealthErrors,
+		FailingSince: failingSince(previous, args.HealthErrors, time.Now()),
+	}
 	return nil, nil, nil
 }
 
@@ -156,6 +267,92 @@ func (c *MockClusterConf) ListBundleHeartbeats(req *acomm.Request) (interface{},
 	return BundleHeartbeatList{c.Data.BundlesHB}, nil, nil
 }
 
+// GetBundleStatus retrieves the status of a mock bundle.
+func (c *MockClusterConf) GetBundleStatus(req *acomm.Request) (interface{}, *url.URL, error) {
+	var args BundleStatusArgs
+	if err := req.UnmarshalArgs(&args); err != nil {
+		return nil, nil, err
+	}
+	if args.ID == 0 {
+		return nil, nil, errors.New("missing arg: id")
+	}
+
+	bundle, ok := c.Data.Bundles[args.ID]
+	if !ok {
+		return nil, nil, errors.New("bundle config not found")
+	}
+	return &BundleStatusPayload{bundleStatus(bundle, c.Data.BundlesHB[args.ID])}, nil, nil
+}
+
+// ListBundleStatus lists the status of all mock bundles.
+func (c *MockClusterConf) ListBundleStatus(req *acomm.Request) (interface{}, *url.URL, error) {
+	bundles := make([]*Bundle, 0, len(c.Data.Bundles))
+	for _, bundle := range c.Data.Bundles {
+		bundles = append(bundles, bundle)
+	}
+	return &BundleStatusListResult{bundleStatuses(bundles, c.Data.BundlesHB)}, nil, nil
+}
+
+// GetPortMap retrieves the external ports of the mock bundles.
+func (c *MockClusterConf) GetPortMap(req *acomm.Request) (interface{}, *url.URL, error) {
+	bundles := make([]*Bundle, 0, len(c.Data.Bundles))
+	for _, bundle := range c.Data.Bundles {
+		bundles = append(bundles, bundle)
+	}
+	return &PortMapResult{portMap(bundles)}, nil, nil
+}
+
+// GetBundleAssignment retrieves a mock bundle assignment.
+func (c *MockClusterConf) GetBundleAssignment(req *acomm.Request) (interface{}, *url.URL, error) {
+	var args BundleAssignmentArgs
+	if err := req.UnmarshalArgs(&args); err != nil {
+		return nil, nil, err
+	}
+	if args.BundleID == 0 {
+		return nil, nil, errors.New("missing arg: bundleID")
+	}
+	assignment, ok := c.Data.Assignments[args.BundleID]
+	if !ok {
+		assignment = &BundleAssignment{BundleID: args.BundleID, Nodes: []string{}}
+	}
+	return &BundleAssignmentPayload{assignment}, nil, nil
+}
+
+// ListBundleAssignments lists all mock bundle assignments.
+func (c *MockClusterConf) ListBundleAssignments(req *acomm.Request) (interface{}, *url.URL, error) {
+	var args ListBundleAssignmentsArgs
+	if err := req.UnmarshalArgs(&args); err != nil {
+		return nil, nil, err
+	}
+
+	assignments := make([]*BundleAssignment, 0, len(c.Data.Assignments))
+	for _, assignment := range c.Data.Assignments {
+		if args.NodeID != "" && !assignment.HasNode(args.NodeID) {
+			continue
+		}
+		assignments = append(assignments, assignment)
+	}
+	return &BundleAssignmentListResult{assignments}, nil, nil
+}
+
+// UpdateBundleAssignment updates a mock bundle assignment.
+func (c *MockClusterConf) UpdateBundleAssignment(req *acomm.Request) (interface{}, *url.URL, error) {
+	var args BundleAssignmentPayload
+	if err := req.UnmarshalArgs(&args); err != nil {
+		return nil, nil, err
+	}
+	if args.Assignment == nil {
+		return nil, nil, errors.New("missing arg: assignment")
+	}
+	if _, ok := c.Data.Bundles[args.Assignment.BundleID]; !ok {
+		return nil, nil, errors.New("bundle config not found")
+	}
+
+	args.Assignment.ModIndex++
+	c.Data.Assignments[args.Assignment.BundleID] = args.Assignment
+	return &BundleAssignmentPayload{args.Assignment}, nil, nil
+}
+
 // GetDataset retrieves a mock dataset.
 func (c *MockClusterConf) GetDataset(req *acomm.Request) (interface{}, *url.URL, error) {
 	var args IDArgs
@@ -169,18 +366,36 @@ func (c *MockClusterConf) GetDataset(req *acomm.Request) (interface{}, *url.URL,
 	if !ok {
 		return nil, nil, errors.New("dataset config not found")
 	}
-	return &DatasetPayload{dataset}, nil, nil
+	return &DatasetPayload{Dataset: c.withDefaults(dataset)}, nil, nil
 }
 
 // ListDatasets lists all mock datasets.
 func (c *MockClusterConf) ListDatasets(req *acomm.Request) (interface{}, *url.URL, error) {
+	var args ListDatasetsArgs
+	if err := req.UnmarshalArgs(&args); err != nil {
+		return nil, nil, err
+	}
+
 	datasets := make([]*Dataset, 0, len(c.Data.Datasets))
 	for _, dataset := range c.Data.Datasets {
-		datasets = append(datasets, dataset)
+		datasets = append(datasets, c.withDefaults(dataset))
+	}
+	if args.Project != "" {
+		datasets = projectDatasets(datasets, args.Project)
 	}
 	return &DatasetListResult{datasets}, nil, nil
 }
 
+// withDefaults returns a copy of the mock dataset with the mock defaults
+// applied.
+func (c *MockClusterConf) withDefaults(dataset *Dataset) *Dataset {
+	result := *dataset
+	if c.Data.Defaults != nil {
+		c.Data.Defaults.applyToDataset(&result)
+	}
+	return &result
+}
+
 // UpdateDataset updates a mock dataset.
 func (c *MockClusterConf) UpdateDataset(req *acomm.Request) (interface{}, *url.URL, error) {
 	var args DatasetPayload
@@ -197,12 +412,13 @@ func (c *MockClusterConf) UpdateDataset(req *acomm.Request) (interface{}, *url.U
 
 	args.Dataset.ModIndex++
 	c.Data.Datasets[args.Dataset.ID] = args.Dataset
-	return &DatasetPayload{args.Dataset}, nil, nil
+	c.addRevision(path.Join(datasetsPrefix, args.Dataset.ID), args.Dataset.ModIndex, args.Author, args.Dataset)
+	return &DatasetPayload{Dataset: args.Dataset}, nil, nil
 }
 
 // DeleteDataset removes a mock dataset.
 func (c *MockClusterConf) DeleteDataset(req *acomm.Request) (interface{}, *url.URL, error) {
-	var args IDArgs
+	var args DeleteArgs
 	if err := req.UnmarshalArgs(&args); err != nil {
 		return nil, nil, err
 	}
@@ -210,7 +426,14 @@ func (c *MockClusterConf) DeleteDataset(req *acomm.Request) (interface{}, *url.U
 		return nil, nil, errors.New("missing arg: id")
 	}
 
+	if _, ok := c.Data.Datasets[args.ID]; !ok {
+		return nil, nil, nil
+	}
+	if err := c.removeReferences(datasetReference, args); err != nil {
+		return nil, nil, err
+	}
 	delete(c.Data.Datasets, args.ID)
+	delete(c.Data.Replications, args.ID)
 	return nil, nil, nil
 }
 
@@ -229,7 +452,7 @@ func (c *MockClusterConf) DatasetHeartbeat(req *acomm.Request) (interface{}, *ur
 	if _, ok := c.Data.DatasetsHB[args.ID]; !ok {
 		c.Data.DatasetsHB[args.ID] = make(map[string]DatasetHeartbeat)
 	}
-	c.Data.DatasetsHB[args.ID][args.IP.String()] = DatasetHeartbeat{IP: args.IP, InUse: args.InUse}
+	c.Data.DatasetsHB[args.ID][args.IP.String()] = DatasetHeartbeat{IP: args.IP, InUse: args.InUse, Snapshots: args.Snapshots}
 	return nil, nil, nil
 }
 
@@ -238,9 +461,108 @@ func (c *MockClusterConf) ListDatasetHeartbeats(req *acomm.Request) (interface{}
 	return DatasetHeartbeatList{c.Data.DatasetsHB}, nil, nil
 }
 
+// ListDatasetSnapshots retrieves the mock catalog of a dataset's snapshots.
+func (c *MockClusterConf) ListDatasetSnapshots(req *acomm.Request) (interface{}, *url.URL, error) {
+	var args IDArgs
+	if err := req.UnmarshalArgs(&args); err != nil {
+		return nil, nil, err
+	}
+	if args.ID == "" {
+		return nil, nil, errors.New("missing arg: id")
+	}
+	return &SnapshotCatalogResult{snapshotCatalog(c.Data.DatasetsHB[args.ID])}, nil, nil
+}
+
+// GetDatasetLineage retrieves the ancestors and children of a mock dataset.
+func (c *MockClusterConf) GetDatasetLineage(req *acomm.Request) (interface{}, *url.URL, error) {
+	var args IDArgs
+	if err := req.UnmarshalArgs(&args); err != nil {
+		return nil, nil, err
+	}
+	if args.ID == "" {
+		return nil, nil, errors.New("missing arg: id")
+	}
+
+	datasets := make([]*Dataset, 0, len(c.Data.Datasets))
+	for _, dataset := range c.Data.Datasets {
+		datasets = append(datasets, dataset)
+	}
+	result, err := datasetLineage(args.ID, datasets)
+	if err != nil {
+		return nil, nil, err
+	}
+	return result, nil, nil
+}
+
+// FindSnapshotNode finds the best mock node to read a dataset snapshot from.
+func (c *MockClusterConf) FindSnapshotNode(req *acomm.Request) (interface{}, *url.URL, error) {
+	var args FindSnapshotNodeArgs
+	if err := req.UnmarshalArgs(&args); err != nil {
+		return nil, nil, err
+	}
+	if args.ID == "" {
+		return nil, nil, errors.New("missing arg: id")
+	}
+	if args.Snapshot == "" {
+		return nil, nil, errors.New("missing arg: snapshot")
+	}
+	result, err := findSnapshotNode(args, c.Data.DatasetsHB[args.ID])
+	if err != nil {
+		return nil, nil, err
+	}
+	return result, nil, nil
+}
+
+// GetDatasetReplication retrieves a mock dataset replication status.
+func (c *MockClusterConf) GetDatasetReplication(req *acomm.Request) (interface{}, *url.URL, error) {
+	var args IDArgs
+	if err := req.UnmarshalArgs(&args); err != nil {
+		return nil, nil, err
+	}
+	if args.ID == "" {
+		return nil, nil, errors.New("missing arg: id")
+	}
+	replication, ok := c.Data.Replications[args.ID]
+	if !ok {
+		replication = &DatasetReplication{DatasetID: args.ID, Nodes: []string{}, Stale: []string{}}
+	}
+	return &DatasetReplicationPayload{replication}, nil, nil
+}
+
+// ListDatasetReplications lists all mock dataset replication statuses.
+func (c *MockClusterConf) ListDatasetReplications(req *acomm.Request) (interface{}, *url.URL, error) {
+	replications := make([]*DatasetReplication, 0, len(c.Data.Replications))
+	for _, replication := range c.Data.Replications {
+		replications = append(replications, replication)
+	}
+	return &DatasetReplicationListResult{replications}, nil, nil
+}
+
+// UpdateDatasetReplication updates a mock dataset replication status.
+func (c *MockClusterConf) UpdateDatasetReplication(req *acomm.Request) (interface{}, *url.URL, error) {
+	var args DatasetReplicationPayload
+	if err := req.UnmarshalArgs(&args); err != nil {
+		return nil, nil, err
+	}
+	if args.Replication == nil {
+		return nil, nil, errors.New("missing arg: replication")
+	}
+	if err := args.Replication.State.validate(); err != nil {
+		return nil, nil, err
+	}
+	if _, ok := c.Data.Datasets[args.Replication.DatasetID]; !ok {
+		return nil, nil, errors.New("dataset config not found")
+	}
+
+	args.Replication.normalize()
+	args.Replication.ModIndex++
+	c.Data.Replications[args.Replication.DatasetID] = args.Replication
+	return &DatasetReplicationPayload{args.Replication}, nil, nil
+}
+
 // GetDefaults retrieves the mock default values.
 func (c *MockClusterConf) GetDefaults(req *acomm.Request) (interface{}, *url.URL, error) {
-	return &DefaultsPayload{c.Data.Defaults}, nil, nil
+	return &DefaultsPayload{Defaults: c.Data.Defaults}, nil, nil
 }
 
 // UpdateDefaults updates the mock default values.
@@ -252,10 +574,13 @@ func (c *MockClusterConf) UpdateDefaults(req *acomm.Request) (interface{}, *url.
 	if args.Defaults == nil {
 		return nil, nil, errors.New("missing arg: defaults")
 	}
+	if err := args.Defaults.validate(); err != nil {
+		return nil, nil, err
+	}
 
 	args.Defaults.ModIndex++
 	c.Data.Defaults = args.Defaults
-	return &DefaultsPayload{args.Defaults}, nil, nil
+	return &DefaultsPayload{Defaults: args.Defaults}, nil, nil
 }
 
 // NodeHeartbeat adds a mock node heartbeat.
@@ -309,7 +634,7 @@ func (c *MockClusterConf) ListNodes(req *acomm.Request) (interface{}, *url.URL,
 // GetNodesHistory retrieves mock nodes history.
 func (c *MockClusterConf) GetNodesHistory(req *acomm.Request) (interface{}, *url.URL, error) {
 	var args NodeHistoryArgs
-	if err := req.UnmarshalArgs(args); err != nil {
+	if err := req.UnmarshalArgs(&args); err != nil {
 		return nil, nil, err
 	}
 
@@ -321,10 +646,8 @@ func (c *MockClusterConf) GetNodesHistory(req *acomm.Request) (interface{}, *url
 	history := make(NodesHistory)
 	for _, savedNodeHistory := range c.Data.History {
 		for _, node := range savedNodeHistory {
-			for _, fn := range filters {
-				if !fn(*node) {
-					continue
-				}
+			if !matchNode(*node, filters...) {
+				continue
 			}
 
 			nodeHistory, ok := history[node.ID]
@@ -339,6 +662,64 @@ func (c *MockClusterConf) GetNodesHistory(req *acomm.Request) (interface{}, *url
 	return &NodesHistoryResult{history}, nil, nil
 }
 
+// GetNodeConfig retrieves a mock node config.
+func (c *MockClusterConf) GetNodeConfig(req *acomm.Request) (interface{}, *url.URL, error) {
+	var args IDArgs
+	if err := req.UnmarshalArgs(&args); err != nil {
+		return nil, nil, err
+	}
+	if args.ID == "" {
+		return nil, nil, errors.New("missing arg: id")
+	}
+
+	config, ok := c.Data.NodeConfigs[args.ID]
+	if !ok {
+		config = &NodeConfig{ID: args.ID, Labels: map[string]string{}, Taints: []Taint{}}
+	}
+	return &NodeConfigPayload{NodeConfig: config}, nil, nil
+}
+
+// ListNodeConfigs lists all mock node configs.
+func (c *MockClusterConf) ListNodeConfigs(req *acomm.Request) (interface{}, *url.URL, error) {
+	configs := make([]*NodeConfig, 0, len(c.Data.NodeConfigs))
+	for _, config := range c.Data.NodeConfigs {
+		configs = append(configs, config)
+	}
+	return &NodeConfigListResult{configs}, nil, nil
+}
+
+// UpdateNodeConfig updates a mock node config.
+func (c *MockClusterConf) UpdateNodeConfig(req *acomm.Request) (interface{}, *url.URL, error) {
+	var args NodeConfigPayload
+	if err := req.UnmarshalArgs(&args); err != nil {
+		return nil, nil, err
+	}
+	if args.NodeConfig == nil {
+		return nil, nil, errors.New("missing arg: nodeConfig")
+	}
+	if args.NodeConfig.ID == "" {
+		return nil, nil, errors.New("missing arg: nodeConfig.id")
+	}
+
+	args.NodeConfig.ModIndex++
+	c.Data.NodeConfigs[args.NodeConfig.ID] = args.NodeConfig
+	return &NodeConfigPayload{NodeConfig: args.NodeConfig}, nil, nil
+}
+
+// DeleteNodeConfig removes a mock node config.
+func (c *MockClusterConf) DeleteNodeConfig(req *acomm.Request) (interface{}, *url.URL, error) {
+	var args IDArgs
+	if err := req.UnmarshalArgs(&args); err != nil {
+		return nil, nil, err
+	}
+	if args.ID == "" {
+		return nil, nil, errors.New("missing arg: id")
+	}
+
+	delete(c.Data.NodeConfigs, args.ID)
+	return nil, nil, nil
+}
+
 // GetService retrieves a mock service.
 func (c *MockClusterConf) GetService(req *acomm.Request) (interface{}, *url.URL, error) {
 	var args IDArgs
@@ -353,7 +734,36 @@ func (c *MockClusterConf) GetService(req *acomm.Request) (interface{}, *url.URL,
 	if !ok {
 		return nil, nil, errors.New("service config not found")
 	}
-	return &ServicePayload{service}, nil, nil
+	return &ServicePayload{Service: service}, nil, nil
+}
+
+// ListServices lists mock services matching the filters.
+func (c *MockClusterConf) ListServices(req *acomm.Request) (interface{}, *url.URL, error) {
+	var args ListServicesArgs
+	if err := req.UnmarshalArgs(&args); err != nil {
+		return nil, nil, err
+	}
+
+	bundles := make([]*Bundle, 0, len(c.Data.Bundles))
+	for _, bundle := range c.Data.Bundles {
+		bundles = append(bundles, bundle)
+	}
+	references := serviceBundles(bundles)
+
+	result := &ServiceListResult{
+		Services: make([]*Service, 0, len(c.Data.Services)),
+		Bundles:  make(map[string][]uint64),
+	}
+	for id, service := range c.Data.Services {
+		if !args.match(service, references[id]) {
+			continue
+		}
+		result.Services = append(result.Services, service)
+		if len(references[id]) > 0 {
+			result.Bundles[id] = references[id]
+		}
+	}
+	return result, nil, nil
 }
 
 // UpdateService updates a mock service.
@@ -365,6 +775,9 @@ func (c *MockClusterConf) UpdateService(req *acomm.Request) (interface{}, *url.U
 	if args.Service == nil {
 		return nil, nil, errors.New("missing arg: service")
 	}
+	if err := validateHealthChecks(args.Service.HealthChecks); err != nil {
+		return nil, nil, err
+	}
 
 	if args.Service.ID == "" {
 		args.Service.ID = uuid.New()
@@ -372,12 +785,13 @@ func (c *MockClusterConf) UpdateService(req *acomm.Request) (interface{}, *url.U
 
 	args.Service.ModIndex++
 	c.Data.Services[args.Service.ID] = args.Service
-	return &ServicePayload{args.Service}, nil, nil
+	c.addRevision(path.Join(servicesPrefix, args.Service.ID), args.Service.ModIndex, args.Author, args.Service)
+	return &ServicePayload{Service: args.Service}, nil, nil
 }
 
 // DeleteService removes a mock service.
 func (c *MockClusterConf) DeleteService(req *acomm.Request) (interface{}, *url.URL, error) {
-	var args IDArgs
+	var args DeleteArgs
 	if err := req.UnmarshalArgs(&args); err != nil {
 		return nil, nil, err
 	}
@@ -385,25 +799,866 @@ func (c *MockClusterConf) DeleteService(req *acomm.Request) (interface{}, *url.U
 		return nil, nil, errors.New("missing arg: id")
 	}
 
+	if _, ok := c.Data.Services[args.ID]; !ok {
+		return nil, nil, nil
+	}
+	if err := c.removeReferences(serviceReference, args); err != nil {
+		return nil, nil, err
+	}
 	delete(c.Data.Services, args.ID)
 	return nil, nil, nil
 }
 
-// GetDHCP retrieves mock DHCP settings.
-func (c *MockClusterConf) GetDHCP(req *acomm.Request) (interface{}, *url.URL, error) {
-	return c.Data.DHCP, nil, nil
+// removeReferences checks and cascades the removal of a mock dataset or
+// service from the bundles referencing it.
+func (c *MockClusterConf) removeReferences(objectType string, args DeleteArgs) error {
+	if args.Force {
+		return nil
+	}
+
+	bundleIDs := make([]uint64, 0)
+	for id, bundle := range c.Data.Bundles {
+		if bundle.references(objectType, args.ID) {
+			bundleIDs = append(bundleIDs, id)
+		}
+	}
+	if len(bundleIDs) == 0 {
+		return nil
+	}
+	if !args.Cascade {
+		return errors.New(objectType + " is referenced by bundles")
+	}
+
+	for _, id := range bundleIDs {
+		bundle := c.Data.Bundles[id]
+		bundle.removeReference(objectType, args.ID)
+		bundle.ModIndex++
+		c.addRevision(bundleKey(id), bundle.ModIndex, args.Author, bundle)
+	}
+	return nil
 }
 
-// SetDHCP updates mock DHCP settings.
-func (c *MockClusterConf) SetDHCP(req *acomm.Request) (interface{}, *url.URL, error) {
-	conf := &DHCPConfig{}
-	if err := req.UnmarshalArgs(conf); err != nil {
+// ValidateClusterConfig reports the dangling references and inconsistencies
+// of the mock bundles.
+func (c *MockClusterConf) ValidateClusterConfig(req *acomm.Request) (interface{}, *url.URL, error) {
+	datasets := make(map[string]bool)
+	for id := range c.Data.Datasets {
+		datasets[id] = true
+	}
+	services := make(map[string]bool)
+	for id := range c.Data.Services {
+		services[id] = true
+	}
+
+	bundleIDs := make(map[string]bool)
+	bundles := make([]*Bundle, 0, len(c.Data.Bundles))
+	for id, bundle := range c.Data.Bundles {
+		bundleIDs[strconv.FormatUint(id, 10)] = true
+		bundles = append(bundles, bundle)
+	}
+
+	problems := make(configProblems, 0)
+	for _, bundle := range bundles {
+		problems = append(problems, bundle.problems(datasets, services, bundleIDs)...)
+	}
+	problems = append(problems, portConflicts(bundles)...)
+	sort.Sort(problems)
+	return &ValidateResult{Problems: problems}, nil, nil
+}
+
+// GetSecret retrieves a mock secret, without its value.
+func (c *MockClusterConf) GetSecret(req *acomm.Request) (interface{}, *url.URL, error) {
+	var args IDArgs
+	if err := req.UnmarshalArgs(&args); err != nil {
+		return nil, nil, err
+	}
+	if args.ID == "" {
+		return nil, nil, errors.New("missing arg: id")
+	}
+
+	secret, ok := c.Data.Secrets[args.ID]
+	if !ok {
+		return nil, nil, errors.New("secret not found")
+	}
+	return &SecretPayload{Secret: secret.withoutValue()}, nil, nil
+}
+
+// ListSecrets lists the mock secrets, without their values.
+func (c *MockClusterConf) ListSecrets(req *acomm.Request) (interface{}, *url.URL, error) {
+	secrets := make([]*Secret, 0, len(c.Data.Secrets))
+	for _, secret := range c.Data.Secrets {
+		secrets = append(secrets, secret.withoutValue())
+	}
+	return &SecretListResult{secrets}, nil, nil
+}
+
+// UpdateSecret updates a mock secret.
+func (c *MockClusterConf) UpdateSecret(req *acomm.Request) (interface{}, *url.URL, error) {
+	var args SecretPayload
+	if err := req.UnmarshalArgs(&args); err != nil {
 		return nil, nil, err
 	}
+	if args.Secret == nil {
+		return nil, nil, errors.New("missing arg: secret")
+	}
+	if args.Secret.Name == "" {
+		return nil, nil, errors.New("invalid arg: secret.name")
+	}
+
+	if args.Secret.Value == "" {
+		current, ok := c.Data.Secrets[args.Secret.Name]
+		if !ok {
+			return nil, nil, errors.New("missing arg: secret.value")
+		}
+		args.Secret.Value = current.Value
+	}
+	args.Secret.ModIndex++
+	c.Data.Secrets[args.Secret.Name] = args.Secret
+	return &SecretPayload{Secret: args.Secret.withoutValue()}, nil, nil
+}
 
-	if err := conf.Validate(); err != nil {
+// DeleteSecret removes a mock secret.
+func (c *MockClusterConf) DeleteSecret(req *acomm.Request) (interface{}, *url.URL, error) {
+	var args IDArgs
+	if err := req.UnmarshalArgs(&args); err != nil {
 		return nil, nil, err
 	}
-	c.Data.DHCP = conf
+	if args.ID == "" {
+		return nil, nil, errors.New("missing arg: id")
+	}
+
+	delete(c.Data.Secrets, args.ID)
 	return nil, nil, nil
 }
+
+// ResolveSecrets retrieves the values of mock secrets for a bundle.
+func (c *MockClusterConf) ResolveSecrets(req *acomm.Request) (interface{}, *url.URL, error) {
+	var args ResolveSecretsArgs
+	if err := req.UnmarshalArgs(&args); err != nil {
+		return nil, nil, err
+	}
+	if args.BundleID == 0 {
+		return nil, nil, errors.New("missing arg: bundleID")
+	}
+
+	values := make(map[string]string, len(args.Names))
+	for _, name := range args.Names {
+		secret, ok := c.Data.Secrets[name]
+		if !ok {
+			return nil, nil, errors.New("secret not found")
+		}
+		if !secret.permits(args.BundleID) {
+			return nil, nil, errors.New("secret access denied")
+		}
+		values[name] = secret.Value
+	}
+	return &ResolveSecretsResult{values}, nil, nil
+}
+
+// withoutValue returns a copy of a mock secret without its value.
+func (s *Secret) withoutValue() *Secret {
+	secret := *s
+	secret.Value = ""
+	return &secret
+}
+
+// GetProject retrieves a mock project and its usage.
+func (c *MockClusterConf) GetProject(req *acomm.Request) (interface{}, *url.URL, error) {
+	var args IDArgs
+	if err := req.UnmarshalArgs(&args); err != nil {
+		return nil, nil, err
+	}
+	if args.ID == "" {
+		return nil, nil, errors.New("missing arg: id")
+	}
+
+	project, ok := c.Data.Projects[args.ID]
+	if !ok {
+		return nil, nil, errors.New("project not found")
+	}
+	return &ProjectPayload{Project: project, Usage: c.projectState().usage(args.ID)}, nil, nil
+}
+
+// ListProjects lists the mock projects and their usage.
+func (c *MockClusterConf) ListProjects(req *acomm.Request) (interface{}, *url.URL, error) {
+	state := c.projectState()
+	result := &ProjectListResult{
+		Projects: make([]*Project, 0, len(c.Data.Projects)),
+		Usage:    make(map[string]*ProjectUsage, len(c.Data.Projects)),
+	}
+	for id, project := range c.Data.Projects {
+		result.Projects = append(result.Projects, project)
+		result.Usage[id] = state.usage(id)
+	}
+	return result, nil, nil
+}
+
+// UpdateProject updates a mock project.
+func (c *MockClusterConf) UpdateProject(req *acomm.Request) (interface{}, *url.URL, error) {
+	var args ProjectPayload
+	if err := req.UnmarshalArgs(&args); err != nil {
+		return nil, nil, err
+	}
+	if args.Project == nil {
+		return nil, nil, errors.New("missing arg: project")
+	}
+	if args.Project.ID == "" {
+		return nil, nil, errors.New("invalid arg: project.id")
+	}
+
+	usage := c.projectState().usage(args.Project.ID)
+	if !args.Force {
+		if err := args.Project.checkQuota(usage, nil); err != nil {
+			return nil, nil, err
+		}
+	}
+	args.Project.ModIndex++
+	c.Data.Projects[args.Project.ID] = args.Project
+	return &ProjectPayload{Project: args.Project, Usage: usage}, nil, nil
+}
+
+// DeleteProject removes a mock project without objects.
+func (c *MockClusterConf) DeleteProject(req *acomm.Request) (interface{}, *url.URL, error) {
+	var args IDArgs
+	if err := req.UnmarshalArgs(&args); err != nil {
+		return nil, nil, err
+	}
+	if args.ID == "" {
+		return nil, nil, errors.New("missing arg: id")
+	}
+
+	if _, ok := c.Data.Projects[args.ID]; !ok {
+		return nil, nil, errors.New("project not found")
+	}
+	if usage := c.projectState().usage(args.ID); usage.Bundles+usage.Services+usage.Datasets > 0 {
+		return nil, nil, errors.New("project still has objects")
+	}
+	delete(c.Data.Projects, args.ID)
+	return nil, nil, nil
+}
+
+// projectState returns the mock projects and objects for measuring usage.
+func (c *MockClusterConf) projectState() *projectState {
+	state := &projectState{
+		projects: c.Data.Projects,
+		bundles:  c.Data.Bundles,
+		services: c.Data.Services,
+		datasets: c.Data.Datasets,
+	}
+	if c.Data.Defaults != nil {
+		state.defaults = c.Data.Defaults.DefaultsConf
+	}
+	return state
+}
+
+// ApplyClusterChanges applies a batch of changes to the mock config objects.
+// The changes are checked before any are applied.
+func (c *MockClusterConf) ApplyClusterChanges(req *acomm.Request) (interface{}, *url.URL, error) {
+	var args ApplyChangesArgs
+	if err := req.UnmarshalArgs(&args); err != nil {
+		return nil, nil, err
+	}
+	if len(args.Changes) == 0 {
+		return nil, nil, errors.New("missing arg: changes")
+	}
+
+	for _, change := range args.Changes {
+		switch {
+		case change == nil:
+			return nil, nil, errors.New("missing arg: change")
+		case change.Action != ChangeUpdate && change.Action != ChangeDelete:
+			return nil, nil, errors.New("invalid arg: action")
+		case change.Bundle != nil:
+			if err := change.Bundle.validateHealthChecks(); err != nil {
+				return nil, nil, err
+			}
+		case change.Service != nil:
+			if err := validateHealthChecks(change.Service.HealthChecks); err != nil {
+				return nil, nil, err
+			}
+		case change.Dataset == nil:
+			return nil, nil, errors.New("change must have exactly one object")
+		}
+	}
+
+	for _, change := range args.Changes {
+		switch {
+		case change.Bundle != nil:
+			if change.Action == ChangeDelete {
+				delete(c.Data.Bundles, change.Bundle.ID)
+				continue
+			}
+			if change.Bundle.ID == 0 {
+				change.Bundle.ID = uint64(rand.Int63())
+			}
+			change.Bundle.ModIndex++
+			c.Data.Bundles[change.Bundle.ID] = change.Bundle
+			c.addRevision(bundleKey(change.Bundle.ID), change.Bundle.ModIndex, args.Author, change.Bundle)
+		case change.Service != nil:
+			if change.Action == ChangeDelete {
+				delete(c.Data.Services, change.Service.ID)
+				continue
+			}
+			if change.Service.ID == "" {
+				change.Service.ID = uuid.New()
+			}
+			change.Service.ModIndex++
+			c.Data.Services[change.Service.ID] = change.Service
+			c.addRevision(path.Join(servicesPrefix, change.Service.ID), change.Service.ModIndex, args.Author, change.Service)
+		default:
+			if change.Action == ChangeDelete {
+				delete(c.Data.Datasets, change.Dataset.ID)
+				continue
+			}
+			if change.Dataset.ID == "" {
+				change.Dataset.ID = uuid.New()
+			}
+			change.Dataset.ModIndex++
+			c.Data.Datasets[change.Dataset.ID] = change.Dataset
+			c.addRevision(path.Join(datasetsPrefix, change.Dataset.ID), change.Dataset.ModIndex, args.Author, change.Dataset)
+		}
+	}
+	return &ApplyChangesResult{Changes: args.Changes}, nil, nil
+}
+
+// ExportClusterConfig streams a document of the mock config objects.
+func (c *MockClusterConf) ExportClusterConfig(req *acomm.Request) (interface{}, *url.URL, error) {
+	data, err := json.Marshal(c.exportDocument())
+	if err != nil {
+		return nil, nil, err
+	}
+
+	tracker, err := acomm.NewTracker("", nil, nil, 0)
+	if err != nil {
+		return nil, nil, err
+	}
+	addr, err := tracker.NewStreamUnix("", ioutil.NopCloser(bytes.NewReader(data)))
+	if err != nil {
+		return nil, nil, err
+	}
+	return nil, addr, nil
+}
+
+// ImportClusterConfig restores the mock config objects from a document.
+func (c *MockClusterConf) ImportClusterConfig(req *acomm.Request) (interface{}, *url.URL, error) {
+	var args ImportArgs
+	if err := req.UnmarshalArgs(&args); err != nil {
+		return nil, nil, err
+	}
+	if err := args.validate(); err != nil {
+		return nil, nil, err
+	}
+
+	result := planImport(c.exportDocument(), args)
+	if !result.Applied {
+		return result, nil, nil
+	}
+
+	for _, change := range result.Changes {
+		switch object := change.object.(type) {
+		case *Bundle:
+			if change.Action == importDelete {
+				delete(c.Data.Bundles, object.ID)
+				continue
+			}
+			object.ModIndex = change.modIndex + 1
+			c.Data.Bundles[object.ID] = object
+			c.addRevision(bundleKey(object.ID), object.ModIndex, args.Author, object)
+		case *Service:
+			if change.Action == importDelete {
+				delete(c.Data.Services, object.ID)
+				continue
+			}
+			object.ModIndex = change.modIndex + 1
+			c.Data.Services[object.ID] = object
+			c.addRevision(path.Join(servicesPrefix, object.ID), object.ModIndex, args.Author, object)
+		case *Dataset:
+			if change.Action == importDelete {
+				delete(c.Data.Datasets, object.ID)
+				continue
+			}
+			object.ModIndex = change.modIndex + 1
+			c.Data.Datasets[object.ID] = object
+			c.addRevision(path.Join(datasetsPrefix, object.ID), object.ModIndex, args.Author, object)
+		case *NodeConfig:
+			if change.Action == importDelete {
+				delete(c.Data.NodeConfigs, object.ID)
+				continue
+			}
+			object.ModIndex = change.modIndex + 1
+			c.Data.NodeConfigs[object.ID] = object
+		case *Defaults:
+			object.ModIndex = change.modIndex + 1
+			c.Data.Defaults = object
+		case *DHCPConfig:
+			c.Data.DHCP = object
+		}
+	}
+	return result, nil, nil
+}
+
+// ListAuditEvents lists the mock audit events.
+func (c *MockClusterConf) ListAuditEvents(req *acomm.Request) (interface{}, *url.URL, error) {
+	var args ListAuditEventsArgs
+	if err := req.UnmarshalArgs(&args); err != nil {
+		return nil, nil, err
+	}
+	if args.Limit < 0 {
+		return nil, nil, errors.New("invalid arg: limit")
+	}
+
+	events := make([]*AuditEvent, 0, len(c.Data.AuditEvents))
+	for _, event := range c.Data.AuditEvents {
+		if args.match(event) {
+			events = append(events, event)
+		}
+	}
+	return &AuditEventListResult{args.limit(events)}, nil, nil
+}
+
+func (c *MockClusterConf) exportDocument() *ClusterConfigDocument {
+	doc := &ClusterConfigDocument{
+		Version:     ExportVersion,
+		ExportedAt:  time.Now(),
+		Bundles:     make([]*Bundle, 0, len(c.Data.Bundles)),
+		Services:    make([]*Service, 0, len(c.Data.Services)),
+		Datasets:    make([]*Dataset, 0, len(c.Data.Datasets)),
+		NodeConfigs: make([]*NodeConfig, 0, len(c.Data.NodeConfigs)),
+		Defaults:    c.Data.Defaults,
+		DHCP:        c.Data.DHCP,
+	}
+	for _, bundle := range c.Data.Bundles {
+		doc.Bundles = append(doc.Bundles, bundle)
+	}
+	for _, service := range c.Data.Services {
+		doc.Services = append(doc.Services, service)
+	}
+	for _, dataset := range c.Data.Datasets {
+		doc.Datasets = append(doc.Datasets, dataset)
+	}
+	for _, nodeConfig := range c.Data.NodeConfigs {
+		doc.NodeConfigs = append(doc.NodeConfigs, nodeConfig)
+	}
+	doc.sort()
+	return doc
+}
+
+// GetDHCP retrieves mock DHCP settings.
+func (c *MockClusterConf) GetDHCP(req *acomm.Request) (interface{}, *url.URL, error) {
+	return c.Data.DHCP, nil, nil
+}
+
+// SetDHCP updates mock DHCP settings.
+func (c *MockClusterConf) SetDHCP(req *acomm.Request) (interface{}, *url.URL, error) {
+	var args SetDHCPArgs
+	if err := req.UnmarshalArgs(&args); err != nil {
+		return nil, nil, err
+	}
+
+	if err := args.Validate(); err != nil {
+		return nil, nil, err
+	}
+	c.Data.DHCP = &args.DHCPConfig
+	return nil, nil, nil
+}
+
+// WatchBundles streams the mock bundles as create events.
+func (c *MockClusterConf) WatchBundles(req *acomm.Request) (interface{}, *url.URL, error) {
+	events := make([]*WatchEvent, 0, len(c.Data.Bundles))
+	for id, bundle := range c.Data.Bundles {
+		events = append(events, &WatchEvent{Type: kv.Create, ID: strconv.FormatUint(id, 10), ModIndex: bundle.ModIndex, Bundle: bundle})
+	}
+	return mockWatch(events)
+}
+
+// WatchDatasets streams the mock datasets as create events.
+func (c *MockClusterConf) WatchDatasets(req *acomm.Request) (interface{}, *url.URL, error) {
+	events := make([]*WatchEvent, 0, len(c.Data.Datasets))
+	for id, dataset := range c.Data.Datasets {
+		events = append(events, &WatchEvent{Type: kv.Create, ID: id, ModIndex: dataset.ModIndex, Dataset: dataset})
+	}
+	return mockWatch(events)
+}
+
+// WatchNodes streams the mock nodes as create events.
+func (c *MockClusterConf) WatchNodes(req *acomm.Request) (interface{}, *url.URL, error) {
+	events := make([]*WatchEvent, 0, len(c.Data.Nodes))
+	for id, node := range c.Data.Nodes {
+		events = append(events, &WatchEvent{Type: kv.Create, ID: id, Node: node})
+	}
+	return mockWatch(events)
+}
+
+// WatchServices streams the mock services as create events.
+func (c *MockClusterConf) WatchServices(req *acomm.Request) (interface{}, *url.URL, error) {
+	events := make([]*WatchEvent, 0, len(c.Data.Services))
+	for id, service := range c.Data.Services {
+		events = append(events, &WatchEvent{Type: kv.Create, ID: id, ModIndex: service.ModIndex, Service: service})
+	}
+	return mockWatch(events)
+}
+
+// mockWatch streams events, ending once they have all been sent.
+func mockWatch(events []*WatchEvent) (interface{}, *url.URL, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	for _, event := range events {
+		if err := enc.Encode(event); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	tracker, err := acomm.NewTracker("", nil, nil, 0)
+	if err != nil {
+		return nil, nil, err
+	}
+	addr, err := tracker.NewStreamUnix("", ioutil.NopCloser(&buf))
+	if err != nil {
+		return nil, nil, err
+	}
+	return kvp.Cookie{Cookie: uint64(rand.Int63())}, addr, nil
+}
+
+// ListBundleRevisions lists mock bundle revisions.
+func (c *MockClusterConf) ListBundleRevisions(req *acomm.Request) (interface{}, *url.URL, error) {
+	var args BundleRevisionArgs
+	if err := req.UnmarshalArgs(&args); err != nil {
+		return nil, nil, err
+	}
+	return c.listRevisions(bundleKey(args.ID)), nil, nil
+}
+
+// GetBundleRevision retrieves a mock bundle revision.
+func (c *MockClusterConf) GetBundleRevision(req *acomm.Request) (interface{}, *url.URL, error) {
+	var args BundleRevisionArgs
+	if err := req.UnmarshalArgs(&args); err != nil {
+		return nil, nil, err
+	}
+	revision, err := c.getRevision(bundleKey(args.ID), args.Revision)
+	if err != nil {
+		return nil, nil, err
+	}
+	var bundle Bundle
+	if err := json.Unmarshal(revision.Config, &bundle); err != nil {
+		return nil, nil, err
+	}
+	return &BundleRevisionResult{Revision: &revision.Revision, Bundle: &bundle}, nil, nil
+}
+
+// RollbackBundle replaces a mock bundle with a revision.
+func (c *MockClusterConf) RollbackBundle(req *acomm.Request) (interface{}, *url.URL, error) {
+	var args BundleRevisionArgs
+	if err := req.UnmarshalArgs(&args); err != nil {
+		return nil, nil, err
+	}
+	// A deleted mock bundle is recreated from the revision
+	var modIndex uint64
+	if current, ok := c.Data.Bundles[args.ID]; ok {
+		modIndex = current.ModIndex
+	}
+	revision, err := c.getRevision(bundleKey(args.ID), args.Revision)
+	if err != nil {
+		return nil, nil, err
+	}
+	var bundle Bundle
+	if err := json.Unmarshal(revision.Config, &bundle); err != nil {
+		return nil, nil, err
+	}
+
+	bundle.ModIndex = modIndex + 1
+	c.Data.Bundles[bundle.ID] = &bundle
+	c.addRevision(bundleKey(bundle.ID), bundle.ModIndex, args.Author, &bundle)
+	return &BundlePayload{Bundle: &bundle}, nil, nil
+}
+
+// GetBundleTemplate retrieves a mock bundle template.
+func (c *MockClusterConf) GetBundleTemplate(req *acomm.Request) (interface{}, *url.URL, error) {
+	var args IDArgs
+	if err := req.UnmarshalArgs(&args); err != nil {
+		return nil, nil, err
+	}
+	if args.ID == "" {
+		return nil, nil, errors.New("missing arg: id")
+	}
+
+	template, ok := c.Data.BundleTemplates[args.ID]
+	if !ok {
+		return nil, nil, errors.New("bundle template not found")
+	}
+	return &BundleTemplatePayload{Template: template}, nil, nil
+}
+
+// ListBundleTemplates lists the mock bundle templates.
+func (c *MockClusterConf) ListBundleTemplates(req *acomm.Request) (interface{}, *url.URL, error) {
+	templates := make([]*BundleTemplate, 0, len(c.Data.BundleTemplates))
+	for _, template := range c.Data.BundleTemplates {
+		templates = append(templates, template)
+	}
+	return &BundleTemplateListResult{templates}, nil, nil
+}
+
+// UpdateBundleTemplate updates a mock bundle template.
+func (c *MockClusterConf) UpdateBundleTemplate(req *acomm.Request) (interface{}, *url.URL, error) {
+	var args BundleTemplatePayload
+	if err := req.UnmarshalArgs(&args); err != nil {
+		return nil, nil, err
+	}
+	if args.Template == nil {
+		return nil, nil, errors.New("missing arg: template")
+	}
+	if err := args.Template.validate(); err != nil {
+		return nil, nil, err
+	}
+
+	args.Template.ModIndex++
+	c.Data.BundleTemplates[args.Template.ID] = args.Template
+	return &BundleTemplatePayload{Template: args.Template}, nil, nil
+}
+
+// DeleteBundleTemplate removes a mock bundle template.
+func (c *MockClusterConf) DeleteBundleTemplate(req *acomm.Request) (interface{}, *url.URL, error) {
+	var args DeleteArgs
+	if err := req.UnmarshalArgs(&args); err != nil {
+		return nil, nil, err
+	}
+	if args.ID == "" {
+		return nil, nil, errors.New("missing arg: id")
+	}
+	if !args.Force && len(templateInstances(c.bundleList(), args.ID, 0)) > 0 {
+		return nil, nil, errors.New("bundle template is referenced by bundles")
+	}
+
+	delete(c.Data.BundleTemplates, args.ID)
+	return nil, nil, nil
+}
+
+// InstantiateBundle creates or updates a mock bundle from a template.
+func (c *MockClusterConf) InstantiateBundle(req *acomm.Request) (interface{}, *url.URL, error) {
+	var args InstantiateBundleArgs
+	if err := req.UnmarshalArgs(&args); err != nil {
+		return nil, nil, err
+	}
+	if args.TemplateID == "" {
+		return nil, nil, errors.New("missing arg: templateID")
+	}
+
+	template, ok := c.Data.BundleTemplates[args.TemplateID]
+	if !ok {
+		return nil, nil, errors.New("bundle template not found")
+	}
+	var current *Bundle
+	if args.BundleID != 0 {
+		if current, ok = c.Data.Bundles[args.BundleID]; !ok {
+			return nil, nil, errors.New("bundle config not found")
+		}
+	}
+
+	bundle, err := c.instantiate(template, current, args.Parameters, args.Author)
+	if err != nil {
+		return nil, nil, err
+	}
+	return &BundlePayload{Bundle: bundle}, nil, nil
+}
+
+// ListTemplateInstances lists the mock bundles instantiated from a template.
+func (c *MockClusterConf) ListTemplateInstances(req *acomm.Request) (interface{}, *url.URL, error) {
+	var args IDArgs
+	if err := req.UnmarshalArgs(&args); err != nil {
+		return nil, nil, err
+	}
+	if args.ID == "" {
+		return nil, nil, errors.New("missing arg: id")
+	}
+
+	template, ok := c.Data.BundleTemplates[args.ID]
+	if !ok {
+		return nil, nil, errors.New("bundle template not found")
+	}
+	return &TemplateInstanceListResult{templateInstances(c.bundleList(), template.ID, template.ModIndex)}, nil, nil
+}
+
+// RolloutBundleTemplate re-instantiates the outdated mock instances of a
+// template.
+func (c *MockClusterConf) RolloutBundleTemplate(req *acomm.Request) (interface{}, *url.URL, error) {
+	var args RolloutArgs
+	if err := req.UnmarshalArgs(&args); err != nil {
+		return nil, nil, err
+	}
+	if args.ID == "" {
+		return nil, nil, errors.New("missing arg: id")
+	}
+
+	template, ok := c.Data.BundleTemplates[args.ID]
+	if !ok {
+		return nil, nil, errors.New("bundle template not found")
+	}
+
+	result := &RolloutResult{Updated: make([]uint64, 0)}
+	for _, instance := range templateInstances(c.bundleList(), template.ID, template.ModIndex) {
+		if instance.Current {
+			continue
+		}
+		if _, err := c.instantiate(template, c.Data.Bundles[instance.BundleID], nil, args.Author); err != nil {
+			return nil, nil, err
+		}
+		result.Updated = append(result.Updated, instance.BundleID)
+	}
+	return result, nil, nil
+}
+
+func (c *MockClusterConf) instantiate(template *BundleTemplate, current *Bundle, params map[string]string, author string) (*Bundle, error) {
+	bundle, err := template.instance(current, params)
+	if err != nil {
+		return nil, err
+	}
+	if err := bundle.validateHealthChecks(); err != nil {
+		return nil, err
+	}
+	bundle.ModIndex++
+	c.Data.Bundles[bundle.ID] = bundle
+	c.addRevision(bundleKey(bundle.ID), bundle.ModIndex, author, bundle)
+	return bundle, nil
+}
+
+func (c *MockClusterConf) bundleList() []*Bundle {
+	bundles := make([]*Bundle, 0, len(c.Data.Bundles))
+	for _, bundle := range c.Data.Bundles {
+		bundles = append(bundles, bundle)
+	}
+	return bundles
+}
+
+// ListDatasetRevisions lists mock dataset revisions.
+func (c *MockClusterConf) ListDatasetRevisions(req *acomm.Request) (interface{}, *url.URL, error) {
+	var args RevisionArgs
+	if err := req.UnmarshalArgs(&args); err != nil {
+		return nil, nil, err
+	}
+	return c.listRevisions(path.Join(datasetsPrefix, args.ID)), nil, nil
+}
+
+// GetDatasetRevision retrieves a mock dataset revision.
+func (c *MockClusterConf) GetDatasetRevision(req *acomm.Request) (interface{}, *url.URL, error) {
+	var args RevisionArgs
+	if err := req.UnmarshalArgs(&args); err != nil {
+		return nil, nil, err
+	}
+	revision, err := c.getRevision(path.Join(datasetsPrefix, args.ID), args.Revision)
+	if err != nil {
+		return nil, nil, err
+	}
+	var dataset Dataset
+	if err := json.Unmarshal(revision.Config, &dataset); err != nil {
+		return nil, nil, err
+	}
+	return &DatasetRevisionResult{Revision: &revision.Revision, Dataset: &dataset}, nil, nil
+}
+
+// RollbackDataset replaces a mock dataset with a revision.
+func (c *MockClusterConf) RollbackDataset(req *acomm.Request) (interface{}, *url.URL, error) {
+	var args RevisionArgs
+	if err := req.UnmarshalArgs(&args); err != nil {
+		return nil, nil, err
+	}
+	// A deleted mock dataset is recreated from the revision
+	var modIndex uint64
+	if current, ok := c.Data.Datasets[args.ID]; ok {
+		modIndex = current.ModIndex
+	}
+	revision, err := c.getRevision(path.Join(datasetsPrefix, args.ID), args.Revision)
+	if err != nil {
+		return nil, nil, err
+	}
+	var dataset Dataset
+	if err := json.Unmarshal(revision.Config, &dataset); err != nil {
+		return nil, nil, err
+	}
+
+	dataset.ModIndex = modIndex + 1
+	c.Data.Datasets[dataset.ID] = &dataset
+	c.addRevision(path.Join(datasetsPrefix, dataset.ID), dataset.ModIndex, args.Author, &dataset)
+	return &DatasetPayload{Dataset: &dataset}, nil, nil
+}
+
+// ListServiceRevisions lists mock service revisions.
+func (c *MockClusterConf) ListServiceRevisions(req *acomm.Request) (interface{}, *url.URL, error) {
+	var args RevisionArgs
+	if err := req.UnmarshalArgs(&args); err != nil {
+		return nil, nil, err
+	}
+	return c.listRevisions(path.Join(servicesPrefix, args.ID)), nil, nil
+}
+
+// GetServiceRevision retrieves a mock service revision.
+func (c *MockClusterConf) GetServiceRevision(req *acomm.Request) (interface{}, *url.URL, error) {
+	var args RevisionArgs
+	if err := req.UnmarshalArgs(&args); err != nil {
+		return nil, nil, err
+	}
+	revision, err := c.getRevision(path.Join(servicesPrefix, args.ID), args.Revision)
+	if err != nil {
+		return nil, nil, err
+	}
+	var service Service
+	if err := json.Unmarshal(revision.Config, &service); err != nil {
+		return nil, nil, err
+	}
+	return &ServiceRevisionResult{Revision: &revision.Revision, Service: &service}, nil, nil
+}
+
+// RollbackService replaces a mock service with a revision.
+func (c *MockClusterConf) RollbackService(req *acomm.Request) (interface{}, *url.URL, error) {
+	var args RevisionArgs
+	if err := req.UnmarshalArgs(&args); err != nil {
+		return nil, nil, err
+	}
+	// A deleted mock service is recreated from the revision
+	var modIndex uint64
+	if current, ok := c.Data.Services[args.ID]; ok {
+		modIndex = current.ModIndex
+	}
+	revision, err := c.getRevision(path.Join(servicesPrefix, args.ID), args.Revision)
+	if err != nil {
+		return nil, nil, err
+	}
+	var service Service
+	if err := json.Unmarshal(revision.Config, &service); err != nil {
+		return nil, nil, err
+	}
+
+	service.ModIndex = modIndex + 1
+	c.Data.Services[service.ID] = &service
+	c.addRevision(path.Join(servicesPrefix, service.ID), service.ModIndex, args.Author, &service)
+	return &ServicePayload{Service: &service}, nil, nil
+}
+
+// addRevision records a mock revision, newest first.
+func (c *MockClusterConf) addRevision(key string, modIndex uint64, author string, config interface{}) {
+	configJSON, _ := json.Marshal(config)
+	revision := &MockRevision{
+		Revision: Revision{
+			Revision:  modIndex,
+			Author:    author,
+			Timestamp: time.Now(),
+		},
+		Config: configJSON,
+	}
+	c.Data.Revisions[key] = append([]*MockRevision{revision}, c.Data.Revisions[key]...)
+}
+
+func (c *MockClusterConf) listRevisions(key string) *RevisionListResult {
+	revisions := make([]*Revision, len(c.Data.Revisions[key]))
+	for i, revision := range c.Data.Revisions[key] {
+		revisions[i] = &revision.Revision
+	}
+	return &RevisionListResult{revisions}
+}
+
+func (c *MockClusterConf) getRevision(key string, modIndex uint64) (*MockRevision, error) {
+	for _, revision := range c.Data.Revisions[key] {
+		if revision.Revision.Revision == modIndex {
+			return revision, nil
+		}
+	}
+	return nil, errors.New("revision not found")
+}
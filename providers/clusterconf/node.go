@@ -4,6 +4,8 @@ import (
 	"encoding/json"
 	"net/url"
 	"path"
+	"sort"
+	"strings"
 	"time"
 
 	"github.com/cerana/cerana/acomm"
@@ -97,22 +99,19 @@ func (c *ClusterConf) ListNodes(req *acomm.Request) (interface{}, *url.URL, erro
 	return &ListNodesResult{nodes}, nil, nil
 }
 
-// GetNodesHistory gets the heartbeat history for one or more nodes.
+// GetNodesHistory gets the heartbeat history for one or more nodes. Without
+// IDs the history of all nodes is returned.
 func (c *ClusterConf) GetNodesHistory(req *acomm.Request) (interface{}, *url.URL, error) {
 	var args NodeHistoryArgs
-	if err := req.UnmarshalArgs(args); err != nil {
+	if err := req.UnmarshalArgs(&args); err != nil {
 		return nil, nil, err
 	}
 
-	history, err := c.getNodesHistory(
-		nodeFilterID(args.IDs...),
-		nodeFilterHeartbeat(args.Before, args.After),
-	)
+	history, err := c.getNodesHistory(args)
 	if err != nil {
 		return nil, nil, err
 	}
 	return &NodesHistoryResult{*history}, nil, nil
-
 }
 
 func (c *ClusterConf) getNode(id string) (*Node, error) {
@@ -153,6 +152,9 @@ func (c *ClusterConf) getNodes() ([]Node, error) {
 
 func nodeFilterID(ids ...string) nodeFilter {
 	return func(n Node) bool {
+		if len(ids) == 0 {
+			return true
+		}
 		for _, id := range ids {
 			if n.ID == id {
 				return true
@@ -174,41 +176,205 @@ func nodeFilterHeartbeat(before, after time.Time) nodeFilter {
 	}
 }
 
-func (c *ClusterConf) getNodesHistory(filters ...nodeFilter) (*NodesHistory, error) {
-	values, err := c.kvGetAll(historicalPrefix)
-	if err != nil {
-		return nil, err
+// matchNode returns whether a node passes all of the filters.
+func matchNode(n Node, filters ...nodeFilter) bool {
+	for _, fn := range filters {
+		if !fn(n) {
+			return false
+		}
 	}
+	return true
+}
+
+// getNodesHistory retrieves the history of the requested nodes. Heartbeat times
+// are also encoded in the keys, so entries outside of the requested range are
+// skipped without being decoded.
+func (c *ClusterConf) getNodesHistory(args NodeHistoryArgs) (*NodesHistory, error) {
+	ids := args.IDs
+	if len(ids) == 0 {
+		nodeIDs, err := c.objectIDs(historicalPrefix)
+		if err != nil {
+			return nil, err
+		}
+		for id := range nodeIDs {
+			ids = append(ids, id)
+		}
+	}
+
+	// key times are truncated to the second, so the lower bound is loosened
+	// to include heartbeats that are within it
+	keyAfter := args.After
+	if !keyAfter.IsZero() {
+		keyAfter = keyAfter.Add(-time.Second)
+	}
+	keyFilter := nodeFilterHeartbeat(args.Before, keyAfter)
+	filter := nodeFilterHeartbeat(args.Before, args.After)
 
 	history := make(NodesHistory)
-	for _, value := range values {
-		var node Node
-		if err := json.Unmarshal(value.Data, &node); err != nil {
-			return nil, errors.Wrapv(err, map[string]interface{}{"json": string(value.Data)})
+	for _, id := range ids {
+		// trailing separator to avoid matching ids sharing the prefix
+		keys, err := c.kvKeys(path.Join(historicalPrefix, id) + "/")
+		if err != nil {
+			return nil, err
 		}
 
-		for _, fn := range filters {
-			if !fn(node) {
+		nodeHistory := make(NodeHistory)
+		for _, key := range keys {
+			heartbeat, err := historyKeyTime(key)
+			if err != nil || !keyFilter(Node{Heartbeat: heartbeat}) {
 				continue
 			}
-		}
 
-		nodeHistory, ok := history[node.ID]
-		if !ok {
-			nodeHistory = make(NodeHistory)
-			history[node.ID] = nodeHistory
+			value, err := c.kvGet(key)
+			if err != nil {
+				if strings.Contains(err.Error(), "key not found") {
+					// pruned since the keys were listed
+					continue
+				}
+				return nil, err
+			}
+
+			var node Node
+			if err := json.Unmarshal(value.Data, &node); err != nil {
+				return nil, errors.Wrapv(err, map[string]interface{}{"json": string(value.Data)})
+			}
+			if !filter(node) {
+				continue
+			}
+			node.c = c
+			nodeHistory[node.Heartbeat] = &node
+		}
+		if len(nodeHistory) > 0 {
+			history[id] = nodeHistory
 		}
-		nodeHistory[node.Heartbeat] = &node
 	}
 
 	return &history, nil
 }
 
+// historyKey returns the key of a historical heartbeat.
+func historyKey(id string, heartbeat time.Time) string {
+	return path.Join(historicalPrefix, id, heartbeat.Format(time.RFC3339))
+}
+
+// historyKeyTime returns the heartbeat time encoded in a historical key.
+func historyKeyTime(key string) (time.Time, error) {
+	t, err := time.Parse(time.RFC3339, path.Base(key))
+	return t, errors.Wrapv(err, map[string]interface{}{"key": key})
+}
+
+// pruneNodeHistory removes heartbeats of a node that are past the max age or
+// thinned out by the downsampling tiers. A node's history is pruned at most
+// once per the shortest tier interval, since nothing expires sooner.
+func (c *ClusterConf) pruneNodeHistory(id string, now time.Time) error {
+	tiers, err := c.config.NodeHistoryTiers()
+	if err != nil {
+		return err
+	}
+
+	if !c.startPrune(id, now, tiers) {
+		return nil
+	}
+
+	keys, err := c.kvKeys(path.Join(historicalPrefix, id) + "/")
+	if err != nil {
+		return err
+	}
+	heartbeats := make([]time.Time, 0, len(keys))
+	for _, key := range keys {
+		heartbeat, err := historyKeyTime(key)
+		if err != nil {
+			continue
+		}
+		heartbeats = append(heartbeats, heartbeat)
+	}
+
+	for _, heartbeat := range expiredHeartbeats(heartbeats, now, tiers, c.config.NodeHistoryMaxAge()) {
+		if err := c.kvDelete(historyKey(id, heartbeat), 0); err != nil {
+			return errors.Wrapv(err, map[string]interface{}{"nodeID": id})
+		}
+	}
+	return nil
+}
+
+// startPrune returns whether a node's history is due to be pruned, recording
+// the prune if so.
+func (c *ClusterConf) startPrune(id string, now time.Time, tiers []HistoryTier) bool {
+	var interval time.Duration
+	for _, tier := range tiers {
+		if interval == 0 || tier.Interval < interval {
+			interval = tier.Interval
+		}
+	}
+
+	c.pruneLock.Lock()
+	defer c.pruneLock.Unlock()
+
+	if last, ok := c.lastPrune[id]; ok && now.Sub(last) < interval {
+		return false
+	}
+	c.lastPrune[id] = now
+	return true
+}
+
+// heartbeatTimes sorts times from oldest to newest.
+type heartbeatTimes []time.Time
+
+func (t heartbeatTimes) Len() int           { return len(t) }
+func (t heartbeatTimes) Swap(i, j int)      { t[i], t[j] = t[j], t[i] }
+func (t heartbeatTimes) Less(i, j int) bool { return t[i].Before(t[j]) }
+
+// expiredHeartbeats returns the heartbeats that are no longer retained. Within
+// a tier, the oldest heartbeat of each interval is kept, so the same heartbeat
+// survives as it ages through the tiers.
+func expiredHeartbeats(heartbeats []time.Time, now time.Time, tiers []HistoryTier, maxAge time.Duration) []time.Time {
+	type bucket struct {
+		tier  int
+		start time.Time
+	}
+
+	sorted := make(heartbeatTimes, len(heartbeats))
+	copy(sorted, heartbeats)
+	sort.Sort(sorted)
+
+	expired := make([]time.Time, 0)
+	kept := make(map[bucket]bool)
+	for _, heartbeat := range sorted {
+		age := now.Sub(heartbeat)
+		if maxAge > 0 && age > maxAge {
+			expired = append(expired, heartbeat)
+			continue
+		}
+
+		tier := -1
+		for i := range tiers {
+			if age >= tiers[i].Age {
+				tier = i
+			}
+		}
+		if tier < 0 {
+			continue
+		}
+
+		b := bucket{tier: tier, start: heartbeat.Truncate(tiers[tier].Interval)}
+		if kept[b] {
+			expired = append(expired, heartbeat)
+			continue
+		}
+		kept[b] = true
+	}
+	return expired
+}
+
 func (n *Node) update() error {
 	currentKey := path.Join(nodesPrefix, n.ID)
-	historicalKey := path.Join(historicalPrefix, n.ID, n.Heartbeat.Format(time.RFC3339))
+	historicalKey := historyKey(n.ID, n.Heartbeat)
 
-	if err := n.c.kvEphemeral(currentKey, n, n.c.config.NodeTTL()); err != nil {
+	defaults, err := n.c.getDefaults()
+	if err != nil {
+		return err
+	}
+	if err := n.c.kvEphemeral(currentKey, n, heartbeatTTL(defaults.NodeTTL, n.c.config.NodeTTL())); err != nil {
 		return errors.Wrapv(err, map[string]interface{}{"nodeID": n.ID})
 	}
 
@@ -216,5 +382,5 @@ func (n *Node) update() error {
 		return errors.Wrapv(err, map[string]interface{}{"nodeID": n.ID})
 	}
 
-	return nil
+	return n.c.pruneNodeHistory(n.ID, time.Now())
 }
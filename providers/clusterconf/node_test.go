@@ -0,0 +1,107 @@
+package clusterconf_test
+
+import (
+	"time"
+
+	"github.com/cerana/cerana/acomm"
+	"github.com/cerana/cerana/providers/clusterconf"
+)
+
+func (s *clusterConf) TestGetNodesHistory() {
+	now := time.Now()
+	s.heartbeat("10.0.0.1", now.Add(-3*time.Minute))
+	s.heartbeat("10.0.0.1", now.Add(-time.Minute))
+	s.heartbeat("10.0.0.11", now.Add(-time.Minute))
+
+	tests := []struct {
+		desc     string
+		args     *clusterconf.NodeHistoryArgs
+		expected map[string]int
+	}{
+		{"all", &clusterconf.NodeHistoryArgs{}, map[string]int{"10.0.0.1": 2, "10.0.0.11": 1}},
+		{"id", &clusterconf.NodeHistoryArgs{IDs: []string{"10.0.0.1"}}, map[string]int{"10.0.0.1": 2}},
+		{"after", &clusterconf.NodeHistoryArgs{After: now.Add(-2 * time.Minute)}, map[string]int{"10.0.0.1": 1, "10.0.0.11": 1}},
+		{"before", &clusterconf.NodeHistoryArgs{Before: now.Add(-2 * time.Minute)}, map[string]int{"10.0.0.1": 1}},
+		{"none", &clusterconf.NodeHistoryArgs{After: now}, map[string]int{}},
+	}
+
+	for _, test := range tests {
+		req, err := acomm.NewRequest(acomm.RequestOptions{
+			Task: "get-nodes-history",
+			Args: test.args,
+		})
+		s.Require().NoError(err, test.desc)
+		result, streamURL, err := s.clusterConf.GetNodesHistory(req)
+		s.Nil(streamURL, test.desc)
+		if !s.NoError(err, test.desc) {
+			continue
+		}
+		history := result.(*clusterconf.NodesHistoryResult).History
+		s.Len(history, len(test.expected), test.desc)
+		for id, count := range test.expected {
+			s.Len(history[id], count, test.desc)
+		}
+	}
+}
+
+func (s *clusterConf) TestNodeHistoryPruning() {
+	now := time.Now()
+	minute := now.Add(-2 * time.Hour).Truncate(time.Minute)
+	heartbeats := []time.Time{
+		// downsampled to one per minute
+		minute.Add(time.Second),
+		minute.Add(10 * time.Second),
+		minute.Add(time.Minute),
+		// raw
+		now.Add(-10 * time.Minute),
+		now.Add(-10*time.Minute + 5*time.Second),
+	}
+	last := len(heartbeats) - 1
+	for _, heartbeat := range heartbeats[:last] {
+		s.heartbeat("10.0.0.2", heartbeat)
+	}
+	// a node sharing the ID prefix is left alone
+	s.heartbeat("10.0.0.20", minute)
+	// pruning is throttled, so nothing has been removed yet
+	s.Len(s.nodeHistory("10.0.0.2"), last)
+
+	// a new instance hasn't pruned the node yet
+	s.heartbeatWith(clusterconf.New(s.config, s.tracker), "10.0.0.2", heartbeats[last])
+	history := s.nodeHistory("10.0.0.2")
+	s.Len(history, 4)
+	for heartbeat := range history {
+		s.NotEqual(minute.Add(10*time.Second).Unix(), heartbeat.Unix())
+	}
+
+	defer s.viper.Set("node_history_max_age", "")
+	s.viper.Set("node_history_max_age", "1h")
+	s.heartbeatWith(clusterconf.New(s.config, s.tracker), "10.0.0.2", now)
+	s.Len(s.nodeHistory("10.0.0.2"), 3)
+	// only the pruned heartbeats were deleted
+	s.Len(s.nodeHistory("10.0.0.20"), 1)
+}
+
+func (s *clusterConf) heartbeat(id string, heartbeat time.Time) {
+	s.heartbeatWith(s.clusterConf, id, heartbeat)
+}
+
+func (s *clusterConf) heartbeatWith(c *clusterconf.ClusterConf, id string, heartbeat time.Time) {
+	req, err := acomm.NewRequest(acomm.RequestOptions{
+		Task: "node-heartbeat",
+		Args: &clusterconf.NodePayload{Node: &clusterconf.Node{ID: id, Heartbeat: heartbeat}},
+	})
+	s.Require().NoError(err)
+	_, _, err = c.NodeHeartbeat(req)
+	s.Require().NoError(err)
+}
+
+func (s *clusterConf) nodeHistory(id string) clusterconf.NodeHistory {
+	req, err := acomm.NewRequest(acomm.RequestOptions{
+		Task: "get-nodes-history",
+		Args: &clusterconf.NodeHistoryArgs{IDs: []string{id}},
+	})
+	s.Require().NoError(err)
+	result, _, err := s.clusterConf.GetNodesHistory(req)
+	s.Require().NoError(err)
+	return result.(*clusterconf.NodesHistoryResult).History[id]
+}
@@ -0,0 +1,207 @@
+package clusterconf
+
+import (
+	"encoding/json"
+	"net/url"
+	"path"
+	"strings"
+
+	"github.com/cerana/cerana/acomm"
+	"github.com/cerana/cerana/pkg/errors"
+)
+
+const nodeConfigsPrefix string = "node-configs"
+
+// NodeConfig is operator managed metadata about a node. Unlike Node, it is not
+// reported by the node itself and doesn't expire.
+type NodeConfig struct {
+	c      *ClusterConf
+	ID     string            `json:"id"`
+	Labels map[string]string `json:"labels"`
+	Taints []Taint           `json:"taints"`
+	// Cordoned nodes are not assigned any additional bundles, e.g. while
+	// under maintenance. Bundles already assigned to them are kept.
+	Cordoned bool `json:"cordoned"`
+	// ModIndex should be treated as opaque, but passed back on updates.
+	ModIndex uint64 `json:"modIndex"`
+}
+
+// Taint keeps bundles off of a node unless they tolerate it.
+type Taint struct {
+	Key   string `json:"key"`
+	Value string `json:"value"`
+}
+
+// NodeConfigPayload can be used for task args or result when a node config
+// object needs to be sent.
+type NodeConfigPayload struct {
+	NodeConfig *NodeConfig `json:"nodeConfig"`
+	// Author identifies who made a change, recorded in the audit log.
+	Author string `json:"author,omitempty"`
+}
+
+// NodeConfigListResult is the result from listing node configs.
+type NodeConfigListResult struct {
+	NodeConfigs []*NodeConfig `json:"nodeConfigs"`
+}
+
+// GetNodeConfig retrieves the config for a node. Nodes without a saved config
+// have an empty one.
+func (c *ClusterConf) GetNodeConfig(req *acomm.Request) (interface{}, *url.URL, error) {
+	var args IDArgs
+	if err := req.UnmarshalArgs(&args); err != nil {
+		return nil, nil, err
+	}
+	if args.ID == "" {
+		return nil, nil, errors.Newv("missing arg: id", map[string]interface{}{"args": args})
+	}
+
+	config, err := c.getNodeConfig(args.ID)
+	if err != nil {
+		return nil, nil, err
+	}
+	return &NodeConfigPayload{NodeConfig: config}, nil, nil
+}
+
+// ListNodeConfigs retrieves all saved node configs.
+func (c *ClusterConf) ListNodeConfigs(req *acomm.Request) (interface{}, *url.URL, error) {
+	configs, err := c.getNodeConfigs()
+	if err != nil {
+		return nil, nil, err
+	}
+	return &NodeConfigListResult{configs}, nil, nil
+}
+
+// UpdateNodeConfig creates or updates the config for a node. When updating, a
+// Get should first be performed and the modified NodeConfig passed back.
+func (c *ClusterConf) UpdateNodeConfig(req *acomm.Request) (interface{}, *url.URL, error) {
+	var args NodeConfigPayload
+	if err := req.UnmarshalArgs(&args); err != nil {
+		return nil, nil, err
+	}
+	if args.NodeConfig == nil {
+		return nil, nil, errors.Newv("missing arg: nodeConfig", map[string]interface{}{"args": args})
+	}
+	if args.NodeConfig.ID == "" {
+		return nil, nil, errors.Newv("missing arg: nodeConfig.id", map[string]interface{}{"args": args})
+	}
+	args.NodeConfig.c = c
+
+	if err := c.audited(req, args.Author, nodeConfigObject, args.NodeConfig.ID, path.Join(nodeConfigsPrefix, args.NodeConfig.ID), args.NodeConfig.update); err != nil {
+		return nil, nil, err
+	}
+	return &NodeConfigPayload{NodeConfig: args.NodeConfig}, nil, nil
+}
+
+// DeleteNodeConfig deletes the config for a node.
+func (c *ClusterConf) DeleteNodeConfig(req *acomm.Request) (interface{}, *url.URL, error) {
+	var args IDArgs
+	if err := req.UnmarshalArgs(&args); err != nil {
+		return nil, nil, err
+	}
+	if args.ID == "" {
+		return nil, nil, errors.Newv("missing arg: id", map[string]interface{}{"args": args})
+	}
+
+	key := path.Join(nodeConfigsPrefix, args.ID)
+	err := c.audited(req, "", nodeConfigObject, args.ID, key, func() error {
+		return errors.Wrapv(c.kvDelete(key, 0), map[string]interface{}{"nodeID": args.ID})
+	})
+	return nil, nil, err
+}
+
+func (c *ClusterConf) getNodeConfig(id string) (*NodeConfig, error) {
+	config := &NodeConfig{
+		c:      c,
+		ID:     id,
+		Labels: map[string]string{},
+		Taints: []Taint{},
+	}
+
+	key := path.Join(nodeConfigsPrefix, id)
+	value, err := c.kvGet(key)
+	if err != nil {
+		if strings.Contains(err.Error(), "key not found") {
+			return config, nil
+		}
+		return nil, errors.Wrapv(err, map[string]interface{}{"nodeID": id})
+	}
+
+	if err := json.Unmarshal(value.Data, config); err != nil {
+		return nil, errors.Wrapv(err, map[string]interface{}{"json": string(value.Data)})
+	}
+	config.ModIndex = value.Index
+	return config, nil
+}
+
+// getNodeConfigs retrieves all saved node configs.
+func (c *ClusterConf) getNodeConfigs() ([]*NodeConfig, error) {
+	values, err := c.kvGetAll(nodeConfigsPrefix)
+	if err != nil {
+		return nil, err
+	}
+
+	configs := make([]*NodeConfig, 0, len(values))
+	for key, value := range values {
+		if key == nodeConfigsPrefix {
+			continue
+		}
+		config := &NodeConfig{c: c}
+		if err := json.Unmarshal(value.Data, config); err != nil {
+			return nil, errors.Wrapv(err, map[string]interface{}{"json": string(value.Data)})
+		}
+		config.ModIndex = value.Index
+		configs = append(configs, config)
+	}
+	return configs, nil
+}
+
+// update saves the node config.
+func (n *NodeConfig) update() error {
+	key := path.Join(nodeConfigsPrefix, n.ID)
+
+	index, err := n.c.kvUpdate(key, n, n.ModIndex)
+	if err != nil {
+		return errors.Wrapv(err, map[string]interface{}{"nodeID": n.ID})
+	}
+	n.ModIndex = index
+	return nil
+}
+
+// Label returns the value of a node label. A nil config has no labels.
+func (n *NodeConfig) Label(key string) string {
+	if n == nil {
+		return ""
+	}
+	return n.Labels[key]
+}
+
+// Permits returns whether the labels and taints of a node allow a bundle with
+// the placement constraints to run on it. A nil config is a node without labels
+// or taints. Cordoning is not considered, since it only prevents additional
+// bundles from being placed on a node.
+func (p BundlePlacement) Permits(node *NodeConfig) bool {
+	for key, value := range p.NodeSelector {
+		if node.Label(key) != value {
+			return false
+		}
+	}
+	if node == nil {
+		return true
+	}
+	for _, taint := range node.Taints {
+		if !p.tolerates(taint) {
+			return false
+		}
+	}
+	return true
+}
+
+func (p BundlePlacement) tolerates(taint Taint) bool {
+	for _, toleration := range p.Tolerations {
+		if toleration == taint {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,105 @@
+package clusterconf_test
+
+import (
+	"github.com/cerana/cerana/acomm"
+	"github.com/cerana/cerana/providers/clusterconf"
+)
+
+func (s *clusterConf) TestNodeConfig() {
+	req, err := acomm.NewRequest(acomm.RequestOptions{
+		Task: "get-node-config",
+		Args: &clusterconf.IDArgs{ID: "10.0.0.1"},
+	})
+	s.Require().NoError(err)
+	result, _, err := s.clusterConf.GetNodeConfig(req)
+	s.Require().NoError(err)
+	config := result.(*clusterconf.NodeConfigPayload).NodeConfig
+	s.Equal("10.0.0.1", config.ID)
+	s.Empty(config.Labels)
+	s.False(config.Cordoned)
+
+	config.Labels["rack"] = "1"
+	config.Cordoned = true
+	req, err = acomm.NewRequest(acomm.RequestOptions{
+		Task: "update-node-config",
+		Args: &clusterconf.NodeConfigPayload{NodeConfig: config},
+	})
+	s.Require().NoError(err)
+	result, _, err = s.clusterConf.UpdateNodeConfig(req)
+	s.Require().NoError(err)
+	updated := result.(*clusterconf.NodeConfigPayload).NodeConfig
+	s.NotEqual(uint64(0), updated.ModIndex)
+
+	// Stale updates fail
+	config.ModIndex = 0
+	req, err = acomm.NewRequest(acomm.RequestOptions{
+		Task: "update-node-config",
+		Args: &clusterconf.NodeConfigPayload{NodeConfig: config},
+	})
+	s.Require().NoError(err)
+	_, _, err = s.clusterConf.UpdateNodeConfig(req)
+	s.Error(err)
+
+	req, err = acomm.NewRequest(acomm.RequestOptions{Task: "list-node-configs"})
+	s.Require().NoError(err)
+	result, _, err = s.clusterConf.ListNodeConfigs(req)
+	s.Require().NoError(err)
+	configs := result.(*clusterconf.NodeConfigListResult).NodeConfigs
+	if s.Len(configs, 1) {
+		s.Equal("1", configs[0].Labels["rack"])
+		s.True(configs[0].Cordoned)
+		s.Equal(updated.ModIndex, configs[0].ModIndex)
+	}
+
+	req, err = acomm.NewRequest(acomm.RequestOptions{
+		Task: "delete-node-config",
+		Args: &clusterconf.IDArgs{ID: "10.0.0.1"},
+	})
+	s.Require().NoError(err)
+	_, _, err = s.clusterConf.DeleteNodeConfig(req)
+	s.Require().NoError(err)
+
+	// A deleted config reads as the empty default
+	req, err = acomm.NewRequest(acomm.RequestOptions{
+		Task: "get-node-config",
+		Args: &clusterconf.IDArgs{ID: "10.0.0.1"},
+	})
+	s.Require().NoError(err)
+	result, _, err = s.clusterConf.GetNodeConfig(req)
+	s.Require().NoError(err)
+	config = result.(*clusterconf.NodeConfigPayload).NodeConfig
+	s.Empty(config.Labels)
+	s.False(config.Cordoned)
+	s.Equal(uint64(0), config.ModIndex)
+}
+
+func (s *clusterConf) TestPlacementPermits() {
+	gpu := clusterconf.Taint{Key: "gpu", Value: "true"}
+	node := &clusterconf.NodeConfig{
+		Labels: map[string]string{"disk": "ssd"},
+		Taints: []clusterconf.Taint{gpu},
+	}
+
+	tests := []struct {
+		desc      string
+		placement clusterconf.BundlePlacement
+		node      *clusterconf.NodeConfig
+		expected  bool
+	}{
+		{"no constraints", clusterconf.BundlePlacement{}, nil, true},
+		{"selector without config", clusterconf.BundlePlacement{NodeSelector: map[string]string{"disk": "ssd"}}, nil, false},
+		{"untolerated taint", clusterconf.BundlePlacement{NodeSelector: map[string]string{"disk": "ssd"}}, node, false},
+		{"tolerated taint", clusterconf.BundlePlacement{
+			NodeSelector: map[string]string{"disk": "ssd"},
+			Tolerations:  []clusterconf.Taint{gpu},
+		}, node, true},
+		{"selector mismatch", clusterconf.BundlePlacement{
+			NodeSelector: map[string]string{"disk": "hdd"},
+			Tolerations:  []clusterconf.Taint{gpu},
+		}, node, false},
+	}
+
+	for _, test := range tests {
+		s.Equal(test.expected, test.placement.Permits(test.node), test.desc)
+	}
+}
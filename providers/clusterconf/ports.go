@@ -0,0 +1,171 @@
+package clusterconf
+
+import (
+	"net/url"
+	"sort"
+	"strconv"
+
+	"github.com/cerana/cerana/acomm"
+	"github.com/cerana/cerana/pkg/errors"
+)
+
+// PortMapping is an external port of the cluster and the bundle port it is
+// mapped to.
+type PortMapping struct {
+	ExternalPort int    `json:"externalPort"`
+	BundleID     uint64 `json:"bundleID"`
+	Port         int    `json:"port"`
+}
+
+// PortMapResult is the result from retrieving the port map.
+type PortMapResult struct {
+	Ports []*PortMapping `json:"ports"`
+}
+
+// GetPortMap retrieves the external ports of all public bundle ports, ordered
+// by external port.
+func (c *ClusterConf) GetPortMap(req *acomm.Request) (interface{}, *url.URL, error) {
+	bundles, err := c.getBundles(false)
+	if err != nil {
+		return nil, nil, err
+	}
+	return &PortMapResult{portMap(bundles)}, nil, nil
+}
+
+// allocatePorts assigns external ports to the public ports of a bundle that
+// don't have one, from the configured range, and checks that the external
+// ports are in the range and aren't used by any other bundles. It must be
+// called with the cluster changes lock held, through saving the bundle.
+func (b *Bundle) allocatePorts() error {
+	min, max, err := b.c.config.PortRange()
+	if err != nil {
+		return err
+	}
+	bundles, err := b.c.getBundles(false)
+	if err != nil {
+		return err
+	}
+
+	used := make(map[int]uint64)
+	for _, mapping := range portMap(bundles) {
+		if mapping.BundleID != b.ID {
+			used[mapping.ExternalPort] = mapping.BundleID
+		}
+	}
+	return b.assignPorts(used, min, max)
+}
+
+// assignPorts checks the external ports of the bundle's public ports against
+// the range and those in use by other bundles and assigns the lowest free port
+// in the range to the public ports without one.
+func (b *Bundle) assignPorts(used map[int]uint64, min, max int) error {
+	// Ports are handled in order so allocations are deterministic
+	ports := make([]int, 0, len(b.Ports))
+	for port := range b.Ports {
+		ports = append(ports, port)
+	}
+	sort.Ints(ports)
+
+	for _, port := range ports {
+		bundlePort := b.Ports[port]
+		if !bundlePort.Public || bundlePort.ExternalPort == 0 {
+			continue
+		}
+		if bundlePort.ExternalPort < min || bundlePort.ExternalPort > max {
+			return errors.Newv("invalid external port", map[string]interface{}{
+				"bundleID":     b.ID,
+				"port":         port,
+				"externalPort": bundlePort.ExternalPort,
+				"min":          min,
+				"max":          max,
+			})
+		}
+		if bundleID, ok := used[bundlePort.ExternalPort]; ok {
+			return errors.Newv("external port conflict", map[string]interface{}{
+				"bundleID":            b.ID,
+				"port":                port,
+				"externalPort":        bundlePort.ExternalPort,
+				"conflictingBundleID": bundleID,
+			})
+		}
+		used[bundlePort.ExternalPort] = b.ID
+	}
+
+	next := min
+	for _, port := range ports {
+		bundlePort := b.Ports[port]
+		if !bundlePort.Public || bundlePort.ExternalPort != 0 {
+			continue
+		}
+		for ; next <= max; next++ {
+			if _, ok := used[next]; !ok {
+				break
+			}
+		}
+		if next > max {
+			return errors.Newv("no external ports available", map[string]interface{}{
+				"bundleID": b.ID,
+				"port":     port,
+				"min":      min,
+				"max":      max,
+			})
+		}
+		bundlePort.ExternalPort = next
+		b.Ports[port] = bundlePort
+		used[next] = b.ID
+	}
+	return nil
+}
+
+// portMap returns the external ports of the bundles' public ports, ordered by
+// external port.
+func portMap(bundles []*Bundle) []*PortMapping {
+	mappings := make([]*PortMapping, 0)
+	for _, bundle := range bundles {
+		for port, bundlePort := range bundle.Ports {
+			if !bundlePort.Public || bundlePort.ExternalPort == 0 {
+				continue
+			}
+			mappings = append(mappings, &PortMapping{
+				ExternalPort: bundlePort.ExternalPort,
+				BundleID:     bundle.ID,
+				Port:         port,
+			})
+		}
+	}
+	sort.Sort(portMappings(mappings))
+	return mappings
+}
+
+// portConflicts returns problems for external ports used by more than one
+// bundle port.
+func portConflicts(bundles []*Bundle) []*ConfigProblem {
+	problems := make([]*ConfigProblem, 0)
+	mappings := portMap(bundles)
+	for i := 1; i < len(mappings); i++ {
+		if mappings[i].ExternalPort != mappings[i-1].ExternalPort {
+			continue
+		}
+		problems = append(problems, &ConfigProblem{
+			BundleID: mappings[i].BundleID,
+			Type:     portReference,
+			ID:       strconv.Itoa(mappings[i].ExternalPort),
+			Message:  "external port used by multiple bundle ports",
+		})
+	}
+	return problems
+}
+
+type portMappings []*PortMapping
+
+func (p portMappings) Len() int      { return len(p) }
+func (p portMappings) Swap(i, j int) { p[i], p[j] = p[j], p[i] }
+func (p portMappings) Less(i, j int) bool {
+	if p[i].ExternalPort != p[j].ExternalPort {
+		return p[i].ExternalPort < p[j].ExternalPort
+	}
+	if p[i].BundleID != p[j].BundleID {
+		return p[i].BundleID < p[j].BundleID
+	}
+	return p[i].Port < p[j].Port
+}
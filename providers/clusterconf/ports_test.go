@@ -0,0 +1,76 @@
+package clusterconf_test
+
+import (
+	"encoding/json"
+
+	"github.com/cerana/cerana/acomm"
+	"github.com/cerana/cerana/providers/clusterconf"
+)
+
+func (s *clusterConf) TestBundlePortsJSON() {
+	ports := clusterconf.BundlePorts{
+		80: {Port: 80, Public: true, ExternalPort: 30080, ConnectedBundles: []string{"1"}},
+	}
+	j, err := json.Marshal(ports)
+	s.Require().NoError(err)
+
+	var ports2 clusterconf.BundlePorts
+	s.NoError(json.Unmarshal(j, &ports2))
+	s.Equal(ports, ports2)
+}
+
+func (s *clusterConf) TestUpdateBundlePorts() {
+	portRange, _ := s.viper.Get("port_range").(string)
+	defer s.viper.Set("port_range", portRange)
+	s.viper.Set("port_range", "40000-40001")
+
+	public := func(externalPort int) clusterconf.BundlePorts {
+		return clusterconf.BundlePorts{80: {Port: 80, Public: true, ExternalPort: externalPort}}
+	}
+
+	tests := []struct {
+		desc     string
+		ports    clusterconf.BundlePorts
+		expected int
+		err      string
+	}{
+		{"private", clusterconf.BundlePorts{80: {Port: 80}}, 0, ""},
+		{"allocated", public(0), 40000, ""},
+		{"conflict", public(40000), 0, "external port conflict"},
+		{"outside range", public(8080), 0, "invalid external port"},
+		{"invalid port", public(70000), 0, "invalid external port"},
+		{"allocated next", public(0), 40001, ""},
+		{"exhausted", public(0), 0, "no external ports available"},
+		{"connected bundle", clusterconf.BundlePorts{80: {Port: 80, ConnectedBundles: []string{"12345"}}}, 0, "bundle has invalid references"},
+	}
+
+	for _, test := range tests {
+		req, err := acomm.NewRequest(acomm.RequestOptions{
+			Task: "update-bundle",
+			Args: &clusterconf.BundlePayload{Bundle: &clusterconf.Bundle{Ports: test.ports}},
+		})
+		s.Require().NoError(err, test.desc)
+		result, _, err := s.clusterConf.UpdateBundle(req)
+		if test.err != "" {
+			s.Contains(err.Error(), test.err, test.desc)
+			continue
+		}
+		if !s.NoError(err, test.desc) {
+			continue
+		}
+		bundle := result.(*clusterconf.BundlePayload).Bundle
+		s.Equal(test.expected, bundle.Ports[80].ExternalPort, test.desc)
+	}
+
+	req, err := acomm.NewRequest(acomm.RequestOptions{Task: "get-port-map"})
+	s.Require().NoError(err)
+	result, streamURL, err := s.clusterConf.GetPortMap(req)
+	s.Nil(streamURL)
+	s.Require().NoError(err)
+	ports := result.(*clusterconf.PortMapResult).Ports
+	if s.Len(ports, 2) {
+		s.Equal(40000, ports[0].ExternalPort)
+		s.Equal(40001, ports[1].ExternalPort)
+		s.Equal(80, ports[1].Port)
+	}
+}
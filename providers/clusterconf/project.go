@@ -0,0 +1,519 @@
+package clusterconf
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"path"
+	"strings"
+
+	"github.com/cerana/cerana/acomm"
+	"github.com/cerana/cerana/pkg/errors"
+)
+
+const projectsPrefix string = "projects"
+
+const projectObject = "project"
+
+// Project is a tenant of the cluster. Bundles, services, and datasets can be
+// scoped to a project, which limits the resources they use with its quota.
+// Objects without a project are shared and not limited.
+type Project struct {
+	c           *ClusterConf
+	ID          string       `json:"id"`
+	Description string       `json:"description"`
+	Quota       ProjectQuota `json:"quota"`
+	// ModIndex should be treated as opaque, but passed back on updates.
+	ModIndex uint64 `json:"modIndex"`
+}
+
+// ProjectQuota is the resource limits of a project. A zero limit is
+// unlimited.
+type ProjectQuota struct {
+	// Redundancy is the total redundancy of the project's bundles.
+	Redundancy uint64 `json:"redundancy"`
+	// Memory is the total memory limit of the services of the project's
+	// bundles, across all of their copies. With a memory quota, each bundle
+	// service needs a memory limit from the bundle, the service, or the
+	// cluster defaults.
+	Memory int64 `json:"memory"`
+	// DatasetQuota is the total quota of the project's datasets.
+	DatasetQuota uint64 `json:"datasetQuota"`
+}
+
+// ProjectUsage is the number of objects of a project and the resources they
+// use, measured as for the project's quota.
+type ProjectUsage struct {
+	Bundles      int    `json:"bundles"`
+	Services     int    `json:"services"`
+	Datasets     int    `json:"datasets"`
+	Redundancy   uint64 `json:"redundancy"`
+	Memory       int64  `json:"memory"`
+	DatasetQuota uint64 `json:"datasetQuota"`
+}
+
+// ProjectPayload can be used for task args or result when a project object
+// needs to be sent.
+type ProjectPayload struct {
+	Project *Project `json:"project"`
+	// Usage is the project's current usage, only set in results.
+	Usage *ProjectUsage `json:"usage,omitempty"`
+	// Author identifies who made a change, recorded in the audit log.
+	Author string `json:"author,omitempty"`
+	// Force allows setting a quota below the project's current usage.
+	Force bool `json:"force,omitempty"`
+}
+
+// ProjectListResult is the result from listing projects.
+type ProjectListResult struct {
+	Projects []*Project `json:"projects"`
+	// Usage is the current usage of each project, keyed by project ID.
+	Usage map[string]*ProjectUsage `json:"usage"`
+}
+
+// GetProject retrieves a project and its usage.
+func (c *ClusterConf) GetProject(req *acomm.Request) (interface{}, *url.URL, error) {
+	var args IDArgs
+	if err := req.UnmarshalArgs(&args); err != nil {
+		return nil, nil, err
+	}
+	if args.ID == "" {
+		return nil, nil, errors.Newv("missing arg: id", map[string]interface{}{"args": args})
+	}
+
+	state, err := c.getProjectState(true)
+	if err != nil {
+		return nil, nil, err
+	}
+	project, ok := state.projects[args.ID]
+	if !ok {
+		return nil, nil, errors.Newv("project not found", map[string]interface{}{"project": args.ID})
+	}
+	return &ProjectPayload{Project: project, Usage: state.usage(args.ID)}, nil, nil
+}
+
+// ListProjects retrieves all projects and their usage.
+func (c *ClusterConf) ListProjects(req *acomm.Request) (interface{}, *url.URL, error) {
+	state, err := c.getProjectState(true)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	result := &ProjectListResult{
+		Projects: make([]*Project, 0, len(state.projects)),
+		Usage:    make(map[string]*ProjectUsage, len(state.projects)),
+	}
+	for id, project := range state.projects {
+		result.Projects = append(result.Projects, project)
+		result.Usage[id] = state.usage(id)
+	}
+	return result, nil, nil
+}
+
+// UpdateProject creates or updates a project. When updating, a Get should
+// first be performed and the modified Project passed back.
+func (c *ClusterConf) UpdateProject(req *acomm.Request) (interface{}, *url.URL, error) {
+	var args ProjectPayload
+	if err := req.UnmarshalArgs(&args); err != nil {
+		return nil, nil, err
+	}
+	if args.Project == nil {
+		return nil, nil, errors.Newv("missing arg: project", map[string]interface{}{"args": args})
+	}
+	if args.Project.ID == "" || strings.Contains(args.Project.ID, "/") {
+		return nil, nil, errors.Newv("invalid arg: project.id", map[string]interface{}{"id": args.Project.ID})
+	}
+	if args.Project.Quota.Memory < 0 {
+		return nil, nil, errors.Newv("invalid arg: project.quota.memory", map[string]interface{}{"quota": args.Project.Quota})
+	}
+	args.Project.c = c
+
+	unlock, err := c.lockChanges()
+	if err != nil {
+		return nil, nil, err
+	}
+	defer unlock()
+
+	state, err := c.getProjectState(true)
+	if err != nil {
+		return nil, nil, err
+	}
+	usage := state.usage(args.Project.ID)
+	if !args.Force {
+		if err := args.Project.checkQuota(usage, nil); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	if err := c.audited(req, args.Author, projectObject, args.Project.ID, path.Join(projectsPrefix, args.Project.ID), args.Project.update); err != nil {
+		return nil, nil, err
+	}
+	return &ProjectPayload{Project: args.Project, Usage: usage}, nil, nil
+}
+
+// DeleteProject deletes a project. Projects are only deleted once none of the
+// bundles, services, or datasets belong to them.
+func (c *ClusterConf) DeleteProject(req *acomm.Request) (interface{}, *url.URL, error) {
+	var args IDArgs
+	if err := req.UnmarshalArgs(&args); err != nil {
+		return nil, nil, err
+	}
+	if args.ID == "" {
+		return nil, nil, errors.Newv("missing arg: id", map[string]interface{}{"args": args})
+	}
+
+	unlock, err := c.lockChanges()
+	if err != nil {
+		return nil, nil, err
+	}
+	defer unlock()
+
+	state, err := c.getProjectState(true)
+	if err != nil {
+		return nil, nil, err
+	}
+	project, ok := state.projects[args.ID]
+	if !ok {
+		return nil, nil, errors.Newv("project not found", map[string]interface{}{"project": args.ID})
+	}
+	if usage := state.usage(args.ID); usage.Bundles+usage.Services+usage.Datasets > 0 {
+		return nil, nil, errors.Newv("project still has objects", map[string]interface{}{"project": args.ID, "usage": usage})
+	}
+
+	key := path.Join(projectsPrefix, args.ID)
+	err = c.audited(req, "", projectObject, args.ID, key, func() error {
+		return errors.Wrapv(c.kvDelete(key, project.ModIndex), map[string]interface{}{"project": args.ID})
+	})
+	return nil, nil, err
+}
+
+func (c *ClusterConf) getProjects() (map[string]*Project, error) {
+	values, err := c.kvGetAll(projectsPrefix)
+	if err != nil {
+		return nil, err
+	}
+
+	projects := make(map[string]*Project, len(values))
+	for key, value := range values {
+		if key == projectsPrefix {
+			continue
+		}
+		project := &Project{c: c}
+		if err := json.Unmarshal(value.Data, project); err != nil {
+			return nil, errors.Wrapv(err, map[string]interface{}{"key": key})
+		}
+		project.ModIndex = value.Index
+		projects[project.ID] = project
+	}
+	return projects, nil
+}
+
+// update saves the project.
+func (p *Project) update() error {
+	key := path.Join(projectsPrefix, p.ID)
+
+	index, err := p.c.kvUpdate(key, p, p.ModIndex)
+	if err != nil {
+		return errors.Wrapv(err, map[string]interface{}{"project": p.ID})
+	}
+	p.ModIndex = index
+	return nil
+}
+
+// checkQuota returns an error if the usage exceeds the project's quota. With
+// the previous usage, only limits whose usage increased are checked, so
+// changes that don't add to a project already over its quota are allowed.
+func (p *Project) checkQuota(usage, previous *ProjectUsage) error {
+	exceeded := func(resource string, quota, used interface{}) error {
+		return errors.Newv("project quota exceeded", map[string]interface{}{
+			"project":  p.ID,
+			"resource": resource,
+			"quota":    quota,
+			"usage":    used,
+		})
+	}
+	if p.Quota.Redundancy > 0 && usage.Redundancy > p.Quota.Redundancy && (previous == nil || usage.Redundancy > previous.Redundancy) {
+		return exceeded("redundancy", p.Quota.Redundancy, usage.Redundancy)
+	}
+	if p.Quota.Memory > 0 && usage.Memory > p.Quota.Memory && (previous == nil || usage.Memory > previous.Memory) {
+		return exceeded("memory", p.Quota.Memory, usage.Memory)
+	}
+	if p.Quota.DatasetQuota > 0 && usage.DatasetQuota > p.Quota.DatasetQuota && (previous == nil || usage.DatasetQuota > previous.DatasetQuota) {
+		return exceeded("datasetQuota", p.Quota.DatasetQuota, usage.DatasetQuota)
+	}
+	return nil
+}
+
+// projectBundles returns the bundles of a project.
+func projectBundles(bundles []*Bundle, project string) []*Bundle {
+	result := make([]*Bundle, 0, len(bundles))
+	for _, bundle := range bundles {
+		if bundle.Project == project {
+			result = append(result, bundle)
+		}
+	}
+	return result
+}
+
+// projectDatasets returns the datasets of a project.
+func projectDatasets(datasets []*Dataset, project string) []*Dataset {
+	result := make([]*Dataset, 0, len(datasets))
+	for _, dataset := range datasets {
+		if dataset.Project == project {
+			result = append(result, dataset)
+		}
+	}
+	return result
+}
+
+// projectState is the projects and the config objects that can belong to
+// them.
+type projectState struct {
+	projects map[string]*Project
+	bundles  map[uint64]*Bundle
+	services map[string]*Service
+	datasets map[string]*Dataset
+	defaults DefaultsConf
+}
+
+// getProjectState retrieves the projects and, if there are any or all is set,
+// the bundles, services, datasets, and cluster defaults. Without projects, no
+// object can be checked against others.
+func (c *ClusterConf) getProjectState(all bool) (*projectState, error) {
+	projects, err := c.getProjects()
+	if err != nil {
+		return nil, err
+	}
+	state := &projectState{
+		projects: projects,
+		bundles:  make(map[uint64]*Bundle),
+		services: make(map[string]*Service),
+		datasets: make(map[string]*Dataset),
+	}
+	if len(projects) == 0 && !all {
+		return state, nil
+	}
+
+	defaults, err := c.getDefaults()
+	if err != nil {
+		return nil, err
+	}
+	state.defaults = defaults.DefaultsConf
+	bundles, err := c.getBundles(false)
+	if err != nil {
+		return nil, err
+	}
+	for _, bundle := range bundles {
+		state.bundles[bundle.ID] = bundle
+	}
+	services, err := c.getServices()
+	if err != nil {
+		return nil, err
+	}
+	for _, service := range services {
+		state.services[service.ID] = service
+	}
+	datasets, err := c.getDatasets()
+	if err != nil {
+		return nil, err
+	}
+	for _, dataset := range datasets {
+		state.datasets[dataset.ID] = dataset
+	}
+	return state, nil
+}
+
+// checkProjects checks a change to bundles, services, or datasets against
+// their projects. The change is made to the current state by apply. It fails
+// if the change adds a problem, e.g. an object of an unknown project or a
+// bundle including a service of another project, or increases the usage of a
+// project over its quota.
+func (c *ClusterConf) checkProjects(apply func(*projectState)) error {
+	state, err := c.getProjectState(false)
+	if err != nil {
+		return err
+	}
+	return state.checkChange(apply)
+}
+
+// checkChange makes a change to the state with apply and checks it against
+// the projects, as for checkProjects.
+func (s *projectState) checkChange(apply func(*projectState)) error {
+	previousProblems := s.problems()
+	previousUsage := make(map[string]*ProjectUsage, len(s.projects))
+	for id := range s.projects {
+		previousUsage[id] = s.usage(id)
+	}
+
+	apply(s)
+
+	for key, err := range s.problems() {
+		if _, ok := previousProblems[key]; !ok {
+			return err
+		}
+	}
+	for id, project := range s.projects {
+		if err := project.checkQuota(s.usage(id), previousUsage[id]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkProject checks the bundle's project, the projects of its services and
+// datasets, and the project's quota with the bundle updated.
+func (b *Bundle) checkProject() error {
+	return b.c.checkProjects(func(s *projectState) { s.bundles[b.ID] = b })
+}
+
+// checkProject checks the service's project and the quotas of the projects of
+// the bundles including it with the service updated.
+func (s *Service) checkProject() error {
+	return s.c.checkProjects(func(state *projectState) { state.services[s.ID] = s })
+}
+
+// checkProject checks the dataset's project and its quota with the dataset
+// updated.
+func (d *Dataset) checkProject() error {
+	return d.c.checkProjects(func(s *projectState) { s.datasets[d.ID] = d })
+}
+
+// applyProjectChanges makes the import's bundle, service, and dataset changes
+// to the project state.
+func (r *ImportResult) applyProjectChanges(s *projectState) {
+	for _, change := range r.Changes {
+		switch object := change.object.(type) {
+		case *Bundle:
+			if change.Action == importDelete {
+				delete(s.bundles, object.ID)
+			} else {
+				s.bundles[object.ID] = object
+			}
+		case *Service:
+			if change.Action == importDelete {
+				delete(s.services, object.ID)
+			} else {
+				s.services[object.ID] = object
+			}
+		case *Dataset:
+			if change.Action == importDelete {
+				delete(s.datasets, object.ID)
+			} else {
+				s.datasets[object.ID] = object
+			}
+		}
+	}
+}
+
+// problems returns the objects of unknown projects and the bundles including
+// services or datasets of other projects, keyed by a description of the
+// problem.
+func (s *projectState) problems() map[string]error {
+	problems := make(map[string]error)
+	unknown := func(objectType, id, project string) {
+		if project == "" {
+			return
+		}
+		if _, ok := s.projects[project]; !ok {
+			problems[fmt.Sprintf("%s %s project", objectType, id)] = errors.Newv("project not found", map[string]interface{}{"project": project, "type": objectType, "id": id})
+		}
+	}
+	other := func(bundle *Bundle, objectType, id, project string) {
+		if project == "" || project == bundle.Project {
+			return
+		}
+		problems[fmt.Sprintf("bundle %d %s %s", bundle.ID, objectType, id)] = errors.Newv("bundle references object of another project", map[string]interface{}{
+			"bundleID":      bundle.ID,
+			"project":       bundle.Project,
+			"type":          objectType,
+			"id":            id,
+			"objectProject": project,
+		})
+	}
+
+	// Services without a memory limit can't be counted against a memory
+	// quota.
+	unlimited := func(bundle *Bundle, serviceID string) {
+		project, ok := s.projects[bundle.Project]
+		if !ok || project.Quota.Memory <= 0 || s.memoryLimit(bundle, serviceID) > 0 {
+			return
+		}
+		problems[fmt.Sprintf("bundle %d %s %s memory", bundle.ID, serviceReference, serviceID)] = errors.Newv("bundle service has no memory limit in project with memory quota", map[string]interface{}{
+			"bundleID":  bundle.ID,
+			"project":   bundle.Project,
+			"serviceID": serviceID,
+		})
+	}
+
+	for id, service := range s.services {
+		unknown(serviceReference, id, service.Project)
+	}
+	for id, dataset := range s.datasets {
+		unknown(datasetReference, id, dataset.Project)
+	}
+	for id, bundle := range s.bundles {
+		unknown(bundleReference, fmt.Sprint(id), bundle.Project)
+		for serviceID := range bundle.Services {
+			if service, ok := s.services[serviceID]; ok {
+				other(bundle, serviceReference, serviceID, service.Project)
+			}
+			unlimited(bundle, serviceID)
+		}
+		for datasetID := range bundle.Datasets {
+			if dataset, ok := s.datasets[datasetID]; ok {
+				other(bundle, datasetReference, datasetID, dataset.Project)
+			}
+		}
+	}
+	return problems
+}
+
+// usage returns the usage of a project. A bundle's memory is the memory limit
+// of each of its services times its redundancy, where an unset redundancy
+// counts as one copy.
+func (s *projectState) usage(project string) *ProjectUsage {
+	usage := &ProjectUsage{}
+	for _, bundle := range s.bundles {
+		if bundle.Project != project {
+			continue
+		}
+		usage.Bundles++
+		// Every bundle runs on at least one node.
+		redundancy := bundle.Redundancy
+		if redundancy == 0 {
+			redundancy = 1
+		}
+		usage.Redundancy += redundancy
+		for id := range bundle.Services {
+			usage.Memory += s.memoryLimit(bundle, id) * int64(redundancy)
+		}
+	}
+	for _, service := range s.services {
+		if service.Project == project {
+			usage.Services++
+		}
+	}
+	for _, dataset := range s.datasets {
+		if dataset.Project == project {
+			usage.Datasets++
+			quota := dataset.Quota
+			if quota == 0 {
+				quota = s.defaults.DatasetQuota
+			}
+			usage.DatasetQuota += quota
+		}
+	}
+	return usage
+}
+
+// memoryLimit returns the memory limit of a bundle's service, from the bundle,
+// the service, or else the cluster defaults.
+func (s *projectState) memoryLimit(bundle *Bundle, serviceID string) int64 {
+	if memory := bundle.Services[serviceID].Limits.Memory; memory > 0 {
+		return memory
+	}
+	if service, ok := s.services[serviceID]; ok && service.Limits.Memory > 0 {
+		return service.Limits.Memory
+	}
+	return s.defaults.Limits.Memory
+}
@@ -0,0 +1,165 @@
+package clusterconf_test
+
+import (
+	"github.com/cerana/cerana/acomm"
+	"github.com/cerana/cerana/providers/clusterconf"
+)
+
+func (s *clusterConf) TestUpdateProject() {
+	tests := []struct {
+		desc    string
+		project *clusterconf.Project
+		err     string
+	}{
+		{"missing project", nil, "missing arg: project"},
+		{"missing id", &clusterconf.Project{}, "invalid arg: project.id"},
+		{"invalid id", &clusterconf.Project{ID: "a/b"}, "invalid arg: project.id"},
+		{"negative memory", &clusterconf.Project{ID: "foo", Quota: clusterconf.ProjectQuota{Memory: -1}}, "invalid arg: project.quota.memory"},
+		{"valid", &clusterconf.Project{ID: "foo", Quota: clusterconf.ProjectQuota{Redundancy: 2}}, ""},
+	}
+
+	for _, test := range tests {
+		result, err := s.updateProject(test.project, false)
+		if test.err != "" {
+			s.Contains(err.Error(), test.err, test.desc)
+			continue
+		}
+		if s.NoError(err, test.desc) {
+			s.NotZero(result.ModIndex, test.desc)
+		}
+	}
+}
+
+func (s *clusterConf) TestProjectQuota() {
+	project, err := s.updateProject(&clusterconf.Project{ID: "foo", Quota: clusterconf.ProjectQuota{Redundancy: 2}}, false)
+	s.Require().NoError(err)
+
+	bundle, err := s.addBundle()
+	s.Require().NoError(err)
+	bundle.Project = "bar"
+	_, err = s.tryUpdateBundle(bundle)
+	s.Contains(err.Error(), "project not found")
+
+	bundle.Project = project.ID
+	bundle.Redundancy = 3
+	_, err = s.tryUpdateBundle(bundle)
+	s.Contains(err.Error(), "project quota exceeded")
+
+	bundle.Redundancy = 2
+	bundle, err = s.tryUpdateBundle(bundle)
+	s.Require().NoError(err)
+
+	// The bundle's services can't be moved to another project
+	_, err = s.updateProject(&clusterconf.Project{ID: "bar"}, false)
+	s.Require().NoError(err)
+	for id := range bundle.Services {
+		service := &clusterconf.Service{ServiceConf: clusterconf.ServiceConf{ID: id, Project: "bar"}}
+		req, err := acomm.NewRequest(acomm.RequestOptions{
+			Task: "update-service",
+			Args: &clusterconf.ServicePayload{Service: service},
+		})
+		s.Require().NoError(err)
+		_, _, err = s.clusterConf.UpdateService(req)
+		s.Contains(err.Error(), "bundle references object of another project")
+	}
+
+	// Quotas can only be lowered below the usage when forced
+	project.Quota.Redundancy = 1
+	_, err = s.updateProject(project, false)
+	s.Contains(err.Error(), "project quota exceeded")
+	_, err = s.updateProject(project, true)
+	s.NoError(err)
+
+	result := s.listProjects()
+	s.Equal(uint64(2), result.Usage[project.ID].Redundancy)
+	s.Equal(1, result.Usage[project.ID].Bundles)
+
+	req, err := acomm.NewRequest(acomm.RequestOptions{
+		Task: "delete-project",
+		Args: &clusterconf.IDArgs{ID: project.ID},
+	})
+	s.Require().NoError(err)
+	_, _, err = s.clusterConf.DeleteProject(req)
+	s.Contains(err.Error(), "project still has objects")
+}
+
+func (s *clusterConf) TestDeleteProject() {
+	project, err := s.updateProject(&clusterconf.Project{ID: "foo"}, false)
+	s.Require().NoError(err)
+
+	for _, expectedErr := range []string{"", "project not found"} {
+		req, err := acomm.NewRequest(acomm.RequestOptions{
+			Task: "delete-project",
+			Args: &clusterconf.IDArgs{ID: project.ID},
+		})
+		s.Require().NoError(err)
+		_, _, err = s.clusterConf.DeleteProject(req)
+		if expectedErr != "" {
+			s.Contains(err.Error(), expectedErr)
+			continue
+		}
+		s.Require().NoError(err)
+		s.Empty(s.listProjects().Projects)
+	}
+}
+
+func (s *clusterConf) TestProjectMemoryQuota() {
+	project, err := s.updateProject(&clusterconf.Project{ID: "mem", Quota: clusterconf.ProjectQuota{Memory: 100}}, false)
+	s.Require().NoError(err)
+
+	bundle, err := s.addBundle()
+	s.Require().NoError(err)
+	bundle.Project = project.ID
+	_, err = s.tryUpdateBundle(bundle)
+	s.Contains(err.Error(), "bundle service has no memory limit in project with memory quota")
+
+	// A bundle without redundancy counts as one copy
+	for id, service := range bundle.Services {
+		service.Limits.Memory = 60
+		bundle.Services[id] = service
+	}
+	bundle, err = s.tryUpdateBundle(bundle)
+	s.Require().NoError(err)
+	usage := s.listProjects().Usage[project.ID]
+	s.Equal(uint64(1), usage.Redundancy)
+	s.Equal(int64(60), usage.Memory)
+
+	bundle.Redundancy = 2
+	_, err = s.tryUpdateBundle(bundle)
+	s.Contains(err.Error(), "project quota exceeded")
+}
+
+func (s *clusterConf) updateProject(project *clusterconf.Project, force bool) (*clusterconf.Project, error) {
+	req, err := acomm.NewRequest(acomm.RequestOptions{
+		Task: "update-project",
+		Args: &clusterconf.ProjectPayload{Project: project, Force: force},
+	})
+	s.Require().NoError(err)
+	result, streamURL, err := s.clusterConf.UpdateProject(req)
+	s.Nil(streamURL)
+	if err != nil {
+		return nil, err
+	}
+	return result.(*clusterconf.ProjectPayload).Project, nil
+}
+
+func (s *clusterConf) listProjects() *clusterconf.ProjectListResult {
+	req, err := acomm.NewRequest(acomm.RequestOptions{Task: "list-projects"})
+	s.Require().NoError(err)
+	result, _, err := s.clusterConf.ListProjects(req)
+	s.Require().NoError(err)
+	return result.(*clusterconf.ProjectListResult)
+}
+
+func (s *clusterConf) tryUpdateBundle(bundle *clusterconf.Bundle) (*clusterconf.Bundle, error) {
+	req, err := acomm.NewRequest(acomm.RequestOptions{
+		Task: "update-bundle",
+		Args: &clusterconf.BundlePayload{Bundle: bundle},
+	})
+	s.Require().NoError(err)
+	result, _, err := s.clusterConf.UpdateBundle(req)
+	if err != nil {
+		return nil, err
+	}
+	return result.(*clusterconf.BundlePayload).Bundle, nil
+}
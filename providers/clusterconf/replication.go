@@ -0,0 +1,197 @@
+package clusterconf
+
+import (
+	"encoding/json"
+	"net/url"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/cerana/cerana/acomm"
+	"github.com/cerana/cerana/pkg/errors"
+)
+
+const replicationKey string = "replication"
+
+// ReplicationState is the state of a dataset's replication.
+type ReplicationState string
+
+// Replication states, as reported by the dataset replicator.
+const (
+	// ReplicationSynced datasets have as many copies as their redundancy,
+	// all holding the latest snapshot.
+	ReplicationSynced ReplicationState = "synced"
+	// ReplicationReplicating datasets are being copied to additional nodes
+	// or are catching up stale copies.
+	ReplicationReplicating ReplicationState = "replicating"
+	// ReplicationDegraded datasets have fewer up to date copies than their
+	// redundancy and can't currently be replicated further.
+	ReplicationDegraded ReplicationState = "degraded"
+	// ReplicationNoSnapshot datasets have no snapshot to replicate.
+	ReplicationNoSnapshot ReplicationState = "no-snapshot"
+)
+
+// DatasetReplication is the replication status of a dataset.
+type DatasetReplication struct {
+	c          *ClusterConf
+	DatasetID  string           `json:"datasetID"`
+	State      ReplicationState `json:"state"`
+	Redundancy uint64           `json:"redundancy"`
+	// Snapshot is the latest snapshot of the dataset.
+	Snapshot string `json:"snapshot"`
+	// Nodes are the nodes holding a copy of the dataset.
+	Nodes []string `json:"nodes"`
+	// Stale are the nodes holding a copy without the latest snapshot.
+	Stale []string `json:"stale"`
+	// Error is the reason the dataset couldn't be fully replicated.
+	Error string `json:"error,omitempty"`
+	// Transfers are the nodes with a transfer of the dataset in flight,
+	// keyed by node ID, with the time each transfer started.
+	Transfers map[string]time.Time `json:"transfers,omitempty"`
+	// ModIndex should be treated as opaque, but passed back on updates.
+	ModIndex uint64 `json:"modIndex"`
+}
+
+// DatasetReplicationPayload can be used for task args or result when a
+// dataset replication object needs to be sent.
+type DatasetReplicationPayload struct {
+	Replication *DatasetReplication `json:"replication"`
+}
+
+// DatasetReplicationListResult is the result from listing dataset
+// replications.
+type DatasetReplicationListResult struct {
+	Replications []*DatasetReplication `json:"replications"`
+}
+
+// GetDatasetReplication retrieves the replication status of a dataset.
+func (c *ClusterConf) GetDatasetReplication(req *acomm.Request) (interface{}, *url.URL, error) {
+	var args IDArgs
+	if err := req.UnmarshalArgs(&args); err != nil {
+		return nil, nil, err
+	}
+	if args.ID == "" {
+		return nil, nil, errors.Newv("missing arg: id", map[string]interface{}{"args": args})
+	}
+
+	replication, err := c.getDatasetReplication(args.ID)
+	if err != nil {
+		return nil, nil, err
+	}
+	return &DatasetReplicationPayload{replication}, nil, nil
+}
+
+// ListDatasetReplications retrieves the replication status of all datasets.
+func (c *ClusterConf) ListDatasetReplications(req *acomm.Request) (interface{}, *url.URL, error) {
+	values, err := c.kvGetAll(datasetsPrefix)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	replications := make([]*DatasetReplication, 0)
+	for key, value := range values {
+		// key: {datasetsPrefix}/{id}/{replicationKey}
+		if path.Base(key) != replicationKey {
+			continue
+		}
+
+		replication := &DatasetReplication{c: c}
+		if err := json.Unmarshal(value.Data, replication); err != nil {
+			return nil, nil, errors.Wrapv(err, map[string]interface{}{"json": string(value.Data)})
+		}
+		replication.ModIndex = value.Index
+		replications = append(replications, replication)
+	}
+
+	return &DatasetReplicationListResult{replications}, nil, nil
+}
+
+// UpdateDatasetReplication creates or updates the replication status of a
+// dataset. When updating, a Get should first be performed and the modified
+// DatasetReplication passed back.
+func (c *ClusterConf) UpdateDatasetReplication(req *acomm.Request) (interface{}, *url.URL, error) {
+	var args DatasetReplicationPayload
+	if err := req.UnmarshalArgs(&args); err != nil {
+		return nil, nil, err
+	}
+	if args.Replication == nil {
+		return nil, nil, errors.Newv("missing arg: replication", map[string]interface{}{"args": args})
+	}
+	if args.Replication.DatasetID == "" {
+		return nil, nil, errors.Newv("missing arg: replication.datasetID", map[string]interface{}{"args": args})
+	}
+	if err := args.Replication.State.validate(); err != nil {
+		return nil, nil, err
+	}
+	args.Replication.c = c
+
+	// Don't leave replication statuses behind for datasets that don't exist
+	if _, err := c.getDataset(args.Replication.DatasetID); err != nil {
+		return nil, nil, err
+	}
+
+	if err := args.Replication.update(); err != nil {
+		return nil, nil, err
+	}
+	return &DatasetReplicationPayload{args.Replication}, nil, nil
+}
+
+func (c *ClusterConf) getDatasetReplication(datasetID string) (*DatasetReplication, error) {
+	replication := &DatasetReplication{
+		c:         c,
+		DatasetID: datasetID,
+		Nodes:     []string{},
+		Stale:     []string{},
+	}
+
+	key := path.Join(datasetsPrefix, datasetID, replicationKey)
+	value, err := c.kvGet(key)
+	if err != nil {
+		if strings.Contains(err.Error(), "key not found") {
+			// A dataset that hasn't been replicated yet has no status
+			return replication, nil
+		}
+		return nil, errors.Wrapv(err, map[string]interface{}{"datasetID": datasetID})
+	}
+
+	if err := json.Unmarshal(value.Data, replication); err != nil {
+		return nil, errors.Wrapv(err, map[string]interface{}{"json": string(value.Data)})
+	}
+	replication.ModIndex = value.Index
+	return replication, nil
+}
+
+// update saves the dataset replication status.
+func (r *DatasetReplication) update() error {
+	key := path.Join(datasetsPrefix, r.DatasetID, replicationKey)
+
+	r.normalize()
+	index, err := r.c.kvUpdate(key, r, r.ModIndex)
+	if err != nil {
+		return errors.Wrapv(err, map[string]interface{}{"datasetID": r.DatasetID})
+	}
+	r.ModIndex = index
+
+	return nil
+}
+
+// normalize sorts the node lists so statuses can be compared.
+func (r *DatasetReplication) normalize() {
+	if r.Nodes == nil {
+		r.Nodes = []string{}
+	}
+	if r.Stale == nil {
+		r.Stale = []string{}
+	}
+	sort.Strings(r.Nodes)
+	sort.Strings(r.Stale)
+}
+
+func (s ReplicationState) validate() error {
+	switch s {
+	case ReplicationSynced, ReplicationReplicating, ReplicationDegraded, ReplicationNoSnapshot:
+		return nil
+	}
+	return errors.Newv("invalid arg: replication.state", map[string]interface{}{"state": s})
+}
@@ -0,0 +1,149 @@
+package clusterconf_test
+
+import (
+	"path"
+
+	"github.com/cerana/cerana/acomm"
+	"github.com/cerana/cerana/providers/clusterconf"
+	"github.com/pborman/uuid"
+)
+
+func (s *clusterConf) TestGetDatasetReplication() {
+	replication, err := s.addDatasetReplication("node1", "node2")
+	s.Require().NoError(err)
+
+	tests := []struct {
+		desc  string
+		id    string
+		state clusterconf.ReplicationState
+		err   string
+	}{
+		{"missing id", "", "", "missing arg: id"},
+		{"unreplicated dataset", uuid.New(), "", ""},
+		{"replicated dataset", replication.DatasetID, clusterconf.ReplicationSynced, ""},
+	}
+
+	for _, test := range tests {
+		req, err := acomm.NewRequest(acomm.RequestOptions{
+			Task: "get-dataset-replication",
+			Args: &clusterconf.IDArgs{ID: test.id},
+		})
+		s.Require().NoError(err, test.desc)
+		result, streamURL, err := s.clusterConf.GetDatasetReplication(req)
+		s.Nil(streamURL, test.desc)
+		if test.err != "" {
+			s.Contains(err.Error(), test.err, test.desc)
+			s.Nil(result, test.desc)
+			continue
+		}
+		if !s.NoError(err, test.desc) {
+			continue
+		}
+		payload, ok := result.(*clusterconf.DatasetReplicationPayload)
+		if !s.True(ok, test.desc) {
+			continue
+		}
+		s.Equal(test.id, payload.Replication.DatasetID, test.desc)
+		s.Equal(test.state, payload.Replication.State, test.desc)
+	}
+}
+
+func (s *clusterConf) TestListDatasetReplications() {
+	replication, err := s.addDatasetReplication("node1")
+	s.Require().NoError(err)
+	replication2, err := s.addDatasetReplication("node2")
+	s.Require().NoError(err)
+
+	req, err := acomm.NewRequest(acomm.RequestOptions{Task: "list-dataset-replications"})
+	s.Require().NoError(err)
+	result, streamURL, err := s.clusterConf.ListDatasetReplications(req)
+	s.Nil(streamURL)
+	s.Require().NoError(err)
+	list, ok := result.(*clusterconf.DatasetReplicationListResult)
+	s.Require().True(ok)
+	datasetIDs := make([]string, 0, len(list.Replications))
+	for _, r := range list.Replications {
+		datasetIDs = append(datasetIDs, r.DatasetID)
+	}
+	s.Len(datasetIDs, 2)
+	s.Contains(datasetIDs, replication.DatasetID)
+	s.Contains(datasetIDs, replication2.DatasetID)
+}
+
+func (s *clusterConf) TestUpdateDatasetReplication() {
+	dataset, err := s.addDataset()
+	s.Require().NoError(err)
+	replication, err := s.addDatasetReplication("node1")
+	s.Require().NoError(err)
+
+	tests := []struct {
+		desc      string
+		datasetID string
+		state     clusterconf.ReplicationState
+		modIndex  uint64
+		err       string
+	}{
+		{"missing id", "", clusterconf.ReplicationSynced, 0, "missing arg: replication.datasetID"},
+		{"invalid state", dataset.ID, "foo", 0, "invalid arg: replication.state"},
+		{"nonexistent dataset", uuid.New(), clusterconf.ReplicationSynced, 0, "dataset config not found"},
+		{"new replication", dataset.ID, clusterconf.ReplicationReplicating, 0, ""},
+		{"create existing replication", replication.DatasetID, clusterconf.ReplicationSynced, 0, "CAS failed"},
+		{"update existing replication", replication.DatasetID, clusterconf.ReplicationDegraded, replication.ModIndex, ""},
+	}
+
+	for _, test := range tests {
+		req, err := acomm.NewRequest(acomm.RequestOptions{
+			Task: "update-dataset-replication",
+			Args: &clusterconf.DatasetReplicationPayload{
+				Replication: &clusterconf.DatasetReplication{
+					DatasetID: test.datasetID,
+					State:     test.state,
+					Nodes:     []string{"node3", "node2"},
+					ModIndex:  test.modIndex,
+				},
+			},
+		})
+		s.Require().NoError(err, test.desc)
+		result, streamURL, err := s.clusterConf.UpdateDatasetReplication(req)
+		s.Nil(streamURL, test.desc)
+		if test.err != "" {
+			s.Contains(err.Error(), test.err, test.desc)
+			s.Nil(result, test.desc)
+			continue
+		}
+		if !s.NoError(err, test.desc) {
+			continue
+		}
+		payload, ok := result.(*clusterconf.DatasetReplicationPayload)
+		if !s.True(ok, test.desc) {
+			continue
+		}
+		s.Equal([]string{"node2", "node3"}, payload.Replication.Nodes, test.desc)
+		s.Equal([]string{}, payload.Replication.Stale, test.desc)
+		s.NotEqual(test.modIndex, payload.Replication.ModIndex, test.desc)
+	}
+}
+
+func (s *clusterConf) addDatasetReplication(nodes ...string) (*clusterconf.DatasetReplication, error) {
+	dataset, err := s.addDataset()
+	if err != nil {
+		return nil, err
+	}
+
+	replication := &clusterconf.DatasetReplication{
+		DatasetID:  dataset.ID,
+		State:      clusterconf.ReplicationSynced,
+		Redundancy: uint64(len(nodes)),
+		Nodes:      nodes,
+		Stale:      []string{},
+	}
+	key := path.Join("datasets", dataset.ID, "replication")
+
+	indexes, err := s.loadData(map[string]interface{}{key: replication})
+	if err != nil {
+		return nil, err
+	}
+	replication.ModIndex = indexes[key]
+
+	return replication, nil
+}
@@ -0,0 +1,452 @@
+package clusterconf
+
+import (
+	"encoding/json"
+	"net/url"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cerana/cerana/acomm"
+	"github.com/cerana/cerana/pkg/errors"
+)
+
+const revisionsKey string = "revisions"
+
+// Revision describes a saved version of a bundle, service, or dataset config.
+type Revision struct {
+	// Revision is the ModIndex the config was saved with.
+	Revision  uint64    `json:"revision"`
+	Author    string    `json:"author"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// storedRevision is a revision along with the config it saved.
+type storedRevision struct {
+	Revision
+	Config json.RawMessage `json:"config"`
+}
+
+// BundleRevisionArgs are args for bundle revision tasks. Revision is not
+// needed for listing and Author is only used for rollbacks.
+type BundleRevisionArgs struct {
+	ID       uint64 `json:"id"`
+	Revision uint64 `json:"revision"`
+	Author   string `json:"author"`
+}
+
+// RevisionArgs are args for service and dataset revision tasks. Revision is
+// not needed for listing and Author is only used for rollbacks.
+type RevisionArgs struct {
+	ID       string `json:"id"`
+	Revision uint64 `json:"revision"`
+	Author   string `json:"author"`
+}
+
+// RevisionListResult is the result from listing revisions, newest first.
+type RevisionListResult struct {
+	Revisions []*Revision `json:"revisions"`
+}
+
+// BundleRevisionResult is the result from retrieving a bundle revision.
+type BundleRevisionResult struct {
+	Revision *Revision `json:"revision"`
+	Bundle   *Bundle   `json:"bundle"`
+}
+
+// ServiceRevisionResult is the result from retrieving a service revision.
+type ServiceRevisionResult struct {
+	Revision *Revision `json:"revision"`
+	Service  *Service  `json:"service"`
+}
+
+// DatasetRevisionResult is the result from retrieving a dataset revision.
+type DatasetRevisionResult struct {
+	Revision *Revision `json:"revision"`
+	Dataset  *Dataset  `json:"dataset"`
+}
+
+type revisionsByNewest []*storedRevision
+
+func (r revisionsByNewest) Len() int      { return len(r) }
+func (r revisionsByNewest) Swap(i, j int) { r[i], r[j] = r[j], r[i] }
+func (r revisionsByNewest) Less(i, j int) bool {
+	return r[i].Revision.Revision > r[j].Revision.Revision
+}
+
+// ListBundleRevisions lists the saved revisions of a bundle.
+func (c *ClusterConf) ListBundleRevisions(req *acomm.Request) (interface{}, *url.URL, error) {
+	var args BundleRevisionArgs
+	if err := req.UnmarshalArgs(&args); err != nil {
+		return nil, nil, err
+	}
+	if args.ID == 0 {
+		return nil, nil, errors.Newv("missing arg: id", map[string]interface{}{"args": args})
+	}
+
+	return c.listRevisions(bundleKey(args.ID))
+}
+
+// GetBundleRevision retrieves a saved revision of a bundle.
+func (c *ClusterConf) GetBundleRevision(req *acomm.Request) (interface{}, *url.URL, error) {
+	var args BundleRevisionArgs
+	if err := req.UnmarshalArgs(&args); err != nil {
+		return nil, nil, err
+	}
+	if args.ID == 0 {
+		return nil, nil, errors.Newv("missing arg: id", map[string]interface{}{"args": args})
+	}
+	if args.Revision == 0 {
+		return nil, nil, errors.Newv("missing arg: revision", map[string]interface{}{"args": args})
+	}
+
+	revision, bundle, err := c.getBundleRevision(args.ID, args.Revision)
+	if err != nil {
+		return nil, nil, err
+	}
+	return &BundleRevisionResult{revision, bundle}, nil, nil
+}
+
+// RollbackBundle replaces a bundle's config with a saved revision, which is
+// recorded as a new revision. A deleted bundle is recreated.
+func (c *ClusterConf) RollbackBundle(req *acomm.Request) (interface{}, *url.URL, error) {
+	var args BundleRevisionArgs
+	if err := req.UnmarshalArgs(&args); err != nil {
+		return nil, nil, err
+	}
+	if args.ID == 0 {
+		return nil, nil, errors.Newv("missing arg: id", map[string]interface{}{"args": args})
+	}
+	if args.Revision == 0 {
+		return nil, nil, errors.Newv("missing arg: revision", map[string]interface{}{"args": args})
+	}
+
+	unlock, err := c.lockChanges()
+	if err != nil {
+		return nil, nil, err
+	}
+	defer unlock()
+
+	// A deleted bundle is recreated from the revision
+	var modIndex uint64
+	current, err := c.getBundle(args.ID)
+	switch {
+	case err == nil:
+		modIndex = current.ModIndex
+	case !strings.Contains(err.Error(), "bundle config not found"):
+		return nil, nil, err
+	}
+	_, bundle, err := c.getBundleRevision(args.ID, args.Revision)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if err := bundle.checkReferences(); err != nil {
+		return nil, nil, err
+	}
+	if err := bundle.checkProject(); err != nil {
+		return nil, nil, err
+	}
+	if err := bundle.allocatePorts(); err != nil {
+		return nil, nil, err
+	}
+	bundle.ModIndex = modIndex
+	if err := c.audited(req, args.Author, bundleReference, strconv.FormatUint(bundle.ID, 10), path.Join(bundleKey(bundle.ID), "config"), bundle.update); err != nil {
+		return nil, nil, err
+	}
+	if err := c.saveRevision(bundleKey(bundle.ID), bundle.ModIndex, args.Author, bundle); err != nil {
+		return nil, nil, err
+	}
+	return &BundlePayload{Bundle: bundle}, nil, nil
+}
+
+// ListServiceRevisions lists the saved revisions of a service.
+func (c *ClusterConf) ListServiceRevisions(req *acomm.Request) (interface{}, *url.URL, error) {
+	var args RevisionArgs
+	if err := req.UnmarshalArgs(&args); err != nil {
+		return nil, nil, err
+	}
+	if args.ID == "" {
+		return nil, nil, errors.Newv("missing arg: id", map[string]interface{}{"args": args})
+	}
+
+	return c.listRevisions(path.Join(servicesPrefix, args.ID))
+}
+
+// GetServiceRevision retrieves a saved revision of a service.
+func (c *ClusterConf) GetServiceRevision(req *acomm.Request) (interface{}, *url.URL, error) {
+	var args RevisionArgs
+	if err := req.UnmarshalArgs(&args); err != nil {
+		return nil, nil, err
+	}
+	if args.ID == "" {
+		return nil, nil, errors.Newv("missing arg: id", map[string]interface{}{"args": args})
+	}
+	if args.Revision == 0 {
+		return nil, nil, errors.Newv("missing arg: revision", map[string]interface{}{"args": args})
+	}
+
+	revision, service, err := c.getServiceRevision(args.ID, args.Revision)
+	if err != nil {
+		return nil, nil, err
+	}
+	return &ServiceRevisionResult{revision, service}, nil, nil
+}
+
+// RollbackService replaces a service's config with a saved revision, which
+// is recorded as a new revision. A deleted service is recreated.
+func (c *ClusterConf) RollbackService(req *acomm.Request) (interface{}, *url.URL, error) {
+	var args RevisionArgs
+	if err := req.UnmarshalArgs(&args); err != nil {
+		return nil, nil, err
+	}
+	if args.ID == "" {
+		return nil, nil, errors.Newv("missing arg: id", map[string]interface{}{"args": args})
+	}
+	if args.Revision == 0 {
+		return nil, nil, errors.Newv("missing arg: revision", map[string]interface{}{"args": args})
+	}
+
+	unlock, err := c.lockChanges()
+	if err != nil {
+		return nil, nil, err
+	}
+	defer unlock()
+
+	// A deleted service is recreated from the revision
+	var modIndex uint64
+	current, err := c.getService(args.ID)
+	switch {
+	case err == nil:
+		modIndex = current.ModIndex
+	case !strings.Contains(err.Error(), "service config not found"):
+		return nil, nil, err
+	}
+	_, service, err := c.getServiceRevision(args.ID, args.Revision)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if err := service.checkProject(); err != nil {
+		return nil, nil, err
+	}
+	service.ModIndex = modIndex
+	if err := c.audited(req, args.Author, serviceReference, service.ID, path.Join(servicesPrefix, service.ID, "config"), service.update); err != nil {
+		return nil, nil, err
+	}
+	if err := c.saveRevision(path.Join(servicesPrefix, service.ID), service.ModIndex, args.Author, service); err != nil {
+		return nil, nil, err
+	}
+	return &ServicePayload{Service: service}, nil, nil
+}
+
+// ListDatasetRevisions lists the saved revisions of a dataset.
+func (c *ClusterConf) ListDatasetRevisions(req *acomm.Request) (interface{}, *url.URL, error) {
+	var args RevisionArgs
+	if err := req.UnmarshalArgs(&args); err != nil {
+		return nil, nil, err
+	}
+	if args.ID == "" {
+		return nil, nil, errors.Newv("missing arg: id", map[string]interface{}{"args": args})
+	}
+
+	return c.listRevisions(path.Join(datasetsPrefix, args.ID))
+}
+
+// GetDatasetRevision retrieves a saved revision of a dataset.
+func (c *ClusterConf) GetDatasetRevision(req *acomm.Request) (interface{}, *url.URL, error) {
+	var args RevisionArgs
+	if err := req.UnmarshalArgs(&args); err != nil {
+		return nil, nil, err
+	}
+	if args.ID == "" {
+		return nil, nil, errors.Newv("missing arg: id", map[string]interface{}{"args": args})
+	}
+	if args.Revision == 0 {
+		return nil, nil, errors.Newv("missing arg: revision", map[string]interface{}{"args": args})
+	}
+
+	revision, dataset, err := c.getDatasetRevision(args.ID, args.Revision)
+	if err != nil {
+		return nil, nil, err
+	}
+	return &DatasetRevisionResult{revision, dataset}, nil, nil
+}
+
+// RollbackDataset replaces a dataset's config with a saved revision, which
+// is recorded as a new revision. A deleted dataset is recreated.
+func (c *ClusterConf) RollbackDataset(req *acomm.Request) (interface{}, *url.URL, error) {
+	var args RevisionArgs
+	if err := req.UnmarshalArgs(&args); err != nil {
+		return nil, nil, err
+	}
+	if args.ID == "" {
+		return nil, nil, errors.Newv("missing arg: id", map[string]interface{}{"args": args})
+	}
+	if args.Revision == 0 {
+		return nil, nil, errors.Newv("missing arg: revision", map[string]interface{}{"args": args})
+	}
+
+	unlock, err := c.lockChanges()
+	if err != nil {
+		return nil, nil, err
+	}
+	defer unlock()
+
+	// A deleted dataset is recreated from the revision
+	var modIndex uint64
+	current, err := c.getDataset(args.ID)
+	switch {
+	case err == nil:
+		modIndex = current.ModIndex
+	case !strings.Contains(err.Error(), "dataset config not found"):
+		return nil, nil, err
+	}
+	_, dataset, err := c.getDatasetRevision(args.ID, args.Revision)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if err := dataset.checkProject(); err != nil {
+		return nil, nil, err
+	}
+	dataset.ModIndex = modIndex
+	if err := c.audited(req, args.Author, datasetReference, dataset.ID, path.Join(datasetsPrefix, dataset.ID, "config"), dataset.update); err != nil {
+		return nil, nil, err
+	}
+	if err := c.saveRevision(path.Join(datasetsPrefix, dataset.ID), dataset.ModIndex, args.Author, dataset); err != nil {
+		return nil, nil, err
+	}
+	return &DatasetPayload{Dataset: dataset}, nil, nil
+}
+
+func (c *ClusterConf) getBundleRevision(id, revision uint64) (*Revision, *Bundle, error) {
+	stored, err := c.getRevision(bundleKey(id), revision)
+	if err != nil {
+		return nil, nil, err
+	}
+	bundle := &Bundle{c: c}
+	if err := json.Unmarshal(stored.Config, bundle); err != nil {
+		return nil, nil, errors.Wrapv(err, map[string]interface{}{"json": string(stored.Config)})
+	}
+	return &stored.Revision, bundle, nil
+}
+
+func (c *ClusterConf) getServiceRevision(id string, revision uint64) (*Revision, *Service, error) {
+	stored, err := c.getRevision(path.Join(servicesPrefix, id), revision)
+	if err != nil {
+		return nil, nil, err
+	}
+	service := &Service{c: c}
+	if err := json.Unmarshal(stored.Config, service); err != nil {
+		return nil, nil, errors.Wrapv(err, map[string]interface{}{"json": string(stored.Config)})
+	}
+	return &stored.Revision, service, nil
+}
+
+func (c *ClusterConf) getDatasetRevision(id string, revision uint64) (*Revision, *Dataset, error) {
+	stored, err := c.getRevision(path.Join(datasetsPrefix, id), revision)
+	if err != nil {
+		return nil, nil, err
+	}
+	dataset := &Dataset{c: c}
+	if err := json.Unmarshal(stored.Config, dataset); err != nil {
+		return nil, nil, errors.Wrapv(err, map[string]interface{}{"json": string(stored.Config)})
+	}
+	return &stored.Revision, dataset, nil
+}
+
+func bundleKey(id uint64) string {
+	return path.Join(bundlesPrefix, strconv.FormatUint(id, 10))
+}
+
+// revisionKey returns the key of an object's revision. Revisions are kept
+// outside of the object's keys so they outlive the object's deletion.
+func revisionKey(objectKey string, revision uint64) string {
+	return path.Join(revisionsKey, objectKey, strconv.FormatUint(revision, 10))
+}
+
+// saveRevision records a config saved with the given ModIndex for the
+// object, pruning the oldest revisions beyond the configured limit.
+func (c *ClusterConf) saveRevision(objectKey string, modIndex uint64, author string, config interface{}) error {
+	errData := map[string]interface{}{"key": objectKey, "revision": modIndex}
+
+	configJSON, err := json.Marshal(config)
+	if err != nil {
+		return errors.Wrapv(err, errData, "failed to json marshal config")
+	}
+	revision := &storedRevision{
+		Revision: Revision{
+			Revision:  modIndex,
+			Author:    author,
+			Timestamp: time.Now(),
+		},
+		Config: configJSON,
+	}
+
+	key := revisionKey(objectKey, modIndex)
+	if _, err := c.kvUpdate(key, revision, 0); err != nil {
+		return errors.Wrapv(err, errData, "failed to save revision")
+	}
+
+	revisions, err := c.getRevisions(objectKey)
+	if err != nil {
+		return err
+	}
+	limit := c.config.RevisionLimit()
+	for i := limit; i < uint64(len(revisions)); i++ {
+		if err := c.kvDelete(revisionKey(objectKey, revisions[i].Revision.Revision), 0); err != nil {
+			return errors.Wrapv(err, errData, "failed to prune revision")
+		}
+	}
+	return nil
+}
+
+func (c *ClusterConf) listRevisions(objectKey string) (interface{}, *url.URL, error) {
+	stored, err := c.getRevisions(objectKey)
+	if err != nil {
+		return nil, nil, err
+	}
+	revisions := make([]*Revision, len(stored))
+	for i, revision := range stored {
+		revisions[i] = &revision.Revision
+	}
+	return &RevisionListResult{revisions}, nil, nil
+}
+
+// getRevisions retrieves the saved revisions of an object, newest first.
+func (c *ClusterConf) getRevisions(objectKey string) ([]*storedRevision, error) {
+	values, err := c.kvGetAll(path.Join(revisionsKey, objectKey) + "/")
+	if err != nil {
+		return nil, err
+	}
+
+	revisions := make(revisionsByNewest, 0, len(values))
+	for _, value := range values {
+		revision := &storedRevision{}
+		if err := json.Unmarshal(value.Data, revision); err != nil {
+			return nil, errors.Wrapv(err, map[string]interface{}{"json": string(value.Data)})
+		}
+		revisions = append(revisions, revision)
+	}
+	sort.Sort(revisions)
+	return revisions, nil
+}
+
+func (c *ClusterConf) getRevision(objectKey string, revision uint64) (*storedRevision, error) {
+	value, err := c.kvGet(revisionKey(objectKey, revision))
+	if err != nil {
+		if strings.Contains(err.Error(), "key not found") {
+			err = errors.Newv("revision not found", map[string]interface{}{"key": objectKey, "revision": revision})
+		}
+		return nil, err
+	}
+
+	stored := &storedRevision{}
+	if err := json.Unmarshal(value.Data, stored); err != nil {
+		return nil, errors.Wrapv(err, map[string]interface{}{"json": string(value.Data)})
+	}
+	return stored, nil
+}
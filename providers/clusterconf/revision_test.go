@@ -0,0 +1,187 @@
+package clusterconf_test
+
+import (
+	"github.com/cerana/cerana/acomm"
+	"github.com/cerana/cerana/providers/clusterconf"
+)
+
+func (s *clusterConf) TestBundleRevisions() {
+	bundle := &clusterconf.Bundle{Redundancy: 1}
+	bundle = s.updateBundle(bundle, "alice")
+	first := bundle.ModIndex
+	bundle.Redundancy = 3
+	bundle = s.updateBundle(bundle, "bob")
+
+	revisions := s.listBundleRevisions(bundle.ID)
+	if s.Len(revisions, 2) {
+		s.Equal(bundle.ModIndex, revisions[0].Revision)
+		s.Equal("bob", revisions[0].Author)
+		s.Equal(first, revisions[1].Revision)
+		s.Equal("alice", revisions[1].Author)
+		s.False(revisions[1].Timestamp.IsZero())
+	}
+
+	tests := []struct {
+		desc     string
+		id       uint64
+		revision uint64
+		err      string
+	}{
+		{"missing id", 0, first, "missing arg: id"},
+		{"missing revision", bundle.ID, 0, "missing arg: revision"},
+		{"nonexistent revision", bundle.ID, first + 1000, "revision not found"},
+		{"existing revision", bundle.ID, first, ""},
+	}
+
+	for _, test := range tests {
+		req, err := acomm.NewRequest(acomm.RequestOptions{
+			Task: "get-bundle-revision",
+			Args: &clusterconf.BundleRevisionArgs{ID: test.id, Revision: test.revision},
+		})
+		s.Require().NoError(err, test.desc)
+		result, streamURL, err := s.clusterConf.GetBundleRevision(req)
+		s.Nil(streamURL, test.desc)
+		if test.err != "" {
+			s.Contains(err.Error(), test.err, test.desc)
+			s.Nil(result, test.desc)
+			continue
+		}
+		if !s.NoError(err, test.desc) {
+			continue
+		}
+		revision, ok := result.(*clusterconf.BundleRevisionResult)
+		if s.True(ok, test.desc) {
+			s.Equal(first, revision.Revision.Revision, test.desc)
+			s.Equal(uint64(1), revision.Bundle.Redundancy, test.desc)
+		}
+	}
+
+	req, err := acomm.NewRequest(acomm.RequestOptions{
+		Task: "rollback-bundle",
+		Args: &clusterconf.BundleRevisionArgs{ID: bundle.ID, Revision: first, Author: "carol"},
+	})
+	s.Require().NoError(err)
+	result, _, err := s.clusterConf.RollbackBundle(req)
+	s.Require().NoError(err)
+	rolledBack := result.(*clusterconf.BundlePayload).Bundle
+	s.Equal(uint64(1), rolledBack.Redundancy)
+	s.True(rolledBack.ModIndex > bundle.ModIndex)
+
+	revisions = s.listBundleRevisions(bundle.ID)
+	if s.Len(revisions, 3) {
+		s.Equal(rolledBack.ModIndex, revisions[0].Revision)
+		s.Equal("carol", revisions[0].Author)
+	}
+}
+
+func (s *clusterConf) TestRevisionPruning() {
+	limit := s.config.RevisionLimit()
+	s.viper.Set("revision_limit", 2)
+	defer s.viper.Set("revision_limit", limit)
+
+	bundle := &clusterconf.Bundle{}
+	for i := 0; i < 3; i++ {
+		bundle = s.updateBundle(bundle, "")
+	}
+
+	revisions := s.listBundleRevisions(bundle.ID)
+	if s.Len(revisions, 2) {
+		s.Equal(bundle.ModIndex, revisions[0].Revision)
+	}
+}
+
+func (s *clusterConf) TestRollbackDeletedBundle() {
+	bundle := s.updateBundle(&clusterconf.Bundle{Redundancy: 2}, "alice")
+
+	req, err := acomm.NewRequest(acomm.RequestOptions{
+		Task: "delete-bundle",
+		Args: &clusterconf.DeleteBundleArgs{ID: bundle.ID},
+	})
+	s.Require().NoError(err)
+	_, _, err = s.clusterConf.DeleteBundle(req)
+	s.Require().NoError(err)
+
+	// Revisions outlive the bundle, so it can be restored
+	s.Len(s.listBundleRevisions(bundle.ID), 1)
+	req, err = acomm.NewRequest(acomm.RequestOptions{
+		Task: "rollback-bundle",
+		Args: &clusterconf.BundleRevisionArgs{ID: bundle.ID, Revision: bundle.ModIndex},
+	})
+	s.Require().NoError(err)
+	result, _, err := s.clusterConf.RollbackBundle(req)
+	s.Require().NoError(err)
+	s.Equal(uint64(2), result.(*clusterconf.BundlePayload).Bundle.Redundancy)
+
+	req, err = acomm.NewRequest(acomm.RequestOptions{
+		Task: "get-bundle",
+		Args: &clusterconf.GetBundleArgs{ID: bundle.ID},
+	})
+	s.Require().NoError(err)
+	result, _, err = s.clusterConf.GetBundle(req)
+	s.Require().NoError(err)
+	s.Equal(uint64(2), result.(*clusterconf.BundlePayload).Bundle.Redundancy)
+}
+
+func (s *clusterConf) TestServiceRollback() {
+	service, err := s.addService()
+	s.Require().NoError(err)
+
+	req, err := acomm.NewRequest(acomm.RequestOptions{
+		Task: "update-service",
+		Args: &clusterconf.ServicePayload{Service: service, Author: "alice"},
+	})
+	s.Require().NoError(err)
+	result, _, err := s.clusterConf.UpdateService(req)
+	s.Require().NoError(err)
+	service = result.(*clusterconf.ServicePayload).Service
+	first := service.ModIndex
+
+	service.Cmd = []string{"changed"}
+	req, err = acomm.NewRequest(acomm.RequestOptions{
+		Task: "update-service",
+		Args: &clusterconf.ServicePayload{Service: service, Author: "bob"},
+	})
+	s.Require().NoError(err)
+	_, _, err = s.clusterConf.UpdateService(req)
+	s.Require().NoError(err)
+
+	req, err = acomm.NewRequest(acomm.RequestOptions{
+		Task: "rollback-service",
+		Args: &clusterconf.RevisionArgs{ID: service.ID, Revision: first},
+	})
+	s.Require().NoError(err)
+	result, _, err = s.clusterConf.RollbackService(req)
+	s.Require().NoError(err)
+	s.Empty(result.(*clusterconf.ServicePayload).Service.Cmd)
+
+	req, err = acomm.NewRequest(acomm.RequestOptions{
+		Task: "list-service-revisions",
+		Args: &clusterconf.RevisionArgs{ID: service.ID},
+	})
+	s.Require().NoError(err)
+	result, _, err = s.clusterConf.ListServiceRevisions(req)
+	s.Require().NoError(err)
+	s.Len(result.(*clusterconf.RevisionListResult).Revisions, 3)
+}
+
+func (s *clusterConf) updateBundle(bundle *clusterconf.Bundle, author string) *clusterconf.Bundle {
+	req, err := acomm.NewRequest(acomm.RequestOptions{
+		Task: "update-bundle",
+		Args: &clusterconf.BundlePayload{Bundle: bundle, Author: author},
+	})
+	s.Require().NoError(err)
+	result, _, err := s.clusterConf.UpdateBundle(req)
+	s.Require().NoError(err)
+	return result.(*clusterconf.BundlePayload).Bundle
+}
+
+func (s *clusterConf) listBundleRevisions(id uint64) []*clusterconf.Revision {
+	req, err := acomm.NewRequest(acomm.RequestOptions{
+		Task: "list-bundle-revisions",
+		Args: &clusterconf.BundleRevisionArgs{ID: id},
+	})
+	s.Require().NoError(err)
+	result, _, err := s.clusterConf.ListBundleRevisions(req)
+	s.Require().NoError(err)
+	return result.(*clusterconf.RevisionListResult).Revisions
+}
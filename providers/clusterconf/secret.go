@@ -0,0 +1,294 @@
+package clusterconf
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"net/url"
+	"path"
+	"strings"
+
+	"github.com/cerana/cerana/acomm"
+	"github.com/cerana/cerana/pkg/errors"
+)
+
+const secretsPrefix string = "secrets"
+
+// SecretEnvPrefix marks a service env value as a reference to a secret, e.g.
+// "secret:db-password". The env var is set to the secret's value when the
+// service is created on a node instead of being stored in its unit.
+const SecretEnvPrefix = "secret:"
+
+// Secret is a value encrypted at rest with the cluster key that bundles can be
+// allowed to use in their service envs.
+type Secret struct {
+	c    *ClusterConf
+	Name string `json:"name"`
+	// Value is the plain text value of the secret. It is only used when
+	// updating and is never returned. An empty value keeps the current one.
+	Value string `json:"value,omitempty"`
+	// Bundles are the ids of the bundles allowed to use the secret.
+	Bundles []uint64 `json:"bundles"`
+	// ModIndex should be treated as opaque, but passed back on updates.
+	ModIndex uint64 `json:"modIndex"`
+}
+
+// storedSecret is a secret as saved in the kv, with its value encrypted.
+type storedSecret struct {
+	Name       string   `json:"name"`
+	Bundles    []uint64 `json:"bundles"`
+	Ciphertext []byte   `json:"ciphertext"`
+}
+
+// SecretPayload can be used for task args or result when a secret object
+// needs to be sent.
+type SecretPayload struct {
+	Secret *Secret `json:"secret"`
+	// Author identifies who made a change, recorded in the audit log.
+	Author string `json:"author,omitempty"`
+}
+
+// SecretListResult is the result from listing secrets.
+type SecretListResult struct {
+	Secrets []*Secret `json:"secrets"`
+}
+
+// ResolveSecretsArgs are args for retrieving the values of secrets for a
+// bundle.
+type ResolveSecretsArgs struct {
+	BundleID uint64   `json:"bundleID"`
+	Names    []string `json:"names"`
+}
+
+// ResolveSecretsResult is the result from resolving secrets, keyed by name.
+type ResolveSecretsResult struct {
+	Values map[string]string `json:"values"`
+}
+
+// SecretName returns the name of the secret an env value references and
+// whether it references one.
+func SecretName(value string) (string, bool) {
+	if !strings.HasPrefix(value, SecretEnvPrefix) {
+		return "", false
+	}
+	return strings.TrimPrefix(value, SecretEnvPrefix), true
+}
+
+// GetSecret retrieves a secret, without its value.
+func (c *ClusterConf) GetSecret(req *acomm.Request) (interface{}, *url.URL, error) {
+	var args IDArgs
+	if err := req.UnmarshalArgs(&args); err != nil {
+		return nil, nil, err
+	}
+	if args.ID == "" {
+		return nil, nil, errors.Newv("missing arg: id", map[string]interface{}{"args": args})
+	}
+
+	secret, _, err := c.getSecret(args.ID)
+	if err != nil {
+		return nil, nil, err
+	}
+	return &SecretPayload{Secret: secret}, nil, nil
+}
+
+// ListSecrets retrieves all secrets, without their values.
+func (c *ClusterConf) ListSecrets(req *acomm.Request) (interface{}, *url.URL, error) {
+	values, err := c.kvGetAll(secretsPrefix)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	secrets := make([]*Secret, 0, len(values))
+	for key, value := range values {
+		if key == secretsPrefix {
+			continue
+		}
+		var stored storedSecret
+		if err := json.Unmarshal(value.Data, &stored); err != nil {
+			return nil, nil, errors.Wrapv(err, map[string]interface{}{"key": key})
+		}
+		secrets = append(secrets, &Secret{Name: stored.Name, Bundles: stored.Bundles, ModIndex: value.Index})
+	}
+	return &SecretListResult{secrets}, nil, nil
+}
+
+// UpdateSecret creates or updates a secret. When updating, a Get should first
+// be performed and the modified Secret passed back.
+func (c *ClusterConf) UpdateSecret(req *acomm.Request) (interface{}, *url.URL, error) {
+	var args SecretPayload
+	if err := req.UnmarshalArgs(&args); err != nil {
+		return nil, nil, err
+	}
+	if args.Secret == nil {
+		return nil, nil, errors.Newv("missing arg: secret", map[string]interface{}{"args": args})
+	}
+	if args.Secret.Name == "" || strings.Contains(args.Secret.Name, "/") {
+		return nil, nil, errors.Newv("invalid arg: secret.name", map[string]interface{}{"name": args.Secret.Name})
+	}
+	args.Secret.c = c
+
+	if err := c.audited(req, args.Author, secretObject, args.Secret.Name, path.Join(secretsPrefix, args.Secret.Name), args.Secret.update); err != nil {
+		return nil, nil, err
+	}
+	args.Secret.Value = ""
+	return &SecretPayload{Secret: args.Secret}, nil, nil
+}
+
+// DeleteSecret deletes a secret.
+func (c *ClusterConf) DeleteSecret(req *acomm.Request) (interface{}, *url.URL, error) {
+	var args IDArgs
+	if err := req.UnmarshalArgs(&args); err != nil {
+		return nil, nil, err
+	}
+	if args.ID == "" {
+		return nil, nil, errors.Newv("missing arg: id", map[string]interface{}{"args": args})
+	}
+
+	key := path.Join(secretsPrefix, args.ID)
+	err := c.audited(req, "", secretObject, args.ID, key, func() error {
+		return errors.Wrapv(c.kvDelete(key, 0), map[string]interface{}{"secret": args.ID})
+	})
+	return nil, nil, err
+}
+
+// ResolveSecrets decrypts the values of secrets for a bundle. It fails unless
+// the bundle is allowed to use all of the secrets. Coordinators only accept
+// the task on their internal socket, so it can't be requested from off the
+// node.
+func (c *ClusterConf) ResolveSecrets(req *acomm.Request) (interface{}, *url.URL, error) {
+	var args ResolveSecretsArgs
+	if err := req.UnmarshalArgs(&args); err != nil {
+		return nil, nil, err
+	}
+	if args.BundleID == 0 {
+		return nil, nil, errors.Newv("missing arg: bundleID", map[string]interface{}{"args": args})
+	}
+
+	values := make(map[string]string, len(args.Names))
+	for _, name := range args.Names {
+		secret, stored, err := c.getSecret(name)
+		if err != nil {
+			return nil, nil, err
+		}
+		if !secret.permits(args.BundleID) {
+			return nil, nil, errors.Newv("secret access denied", map[string]interface{}{"secret": name, "bundleID": args.BundleID})
+		}
+		value, err := c.decryptSecret(stored)
+		if err != nil {
+			return nil, nil, err
+		}
+		values[name] = value
+	}
+	return &ResolveSecretsResult{values}, nil, nil
+}
+
+func (c *ClusterConf) getSecret(name string) (*Secret, *storedSecret, error) {
+	key := path.Join(secretsPrefix, name)
+	value, err := c.kvGet(key)
+	if err != nil {
+		if strings.Contains(err.Error(), "key not found") {
+			err = errors.Newv("secret not found", map[string]interface{}{"secret": name})
+		}
+		return nil, nil, err
+	}
+
+	stored := &storedSecret{}
+	if err := json.Unmarshal(value.Data, stored); err != nil {
+		return nil, nil, errors.Wrapv(err, map[string]interface{}{"key": key})
+	}
+	secret := &Secret{
+		c:        c,
+		Name:     stored.Name,
+		Bundles:  stored.Bundles,
+		ModIndex: value.Index,
+	}
+	return secret, stored, nil
+}
+
+// update encrypts and saves the secret. Without a value, the current
+// ciphertext is kept.
+func (s *Secret) update() error {
+	key := path.Join(secretsPrefix, s.Name)
+	stored := &storedSecret{Name: s.Name, Bundles: s.Bundles}
+
+	if s.Value != "" {
+		ciphertext, err := s.c.encryptSecret(s.Name, s.Value)
+		if err != nil {
+			return err
+		}
+		stored.Ciphertext = ciphertext
+	} else {
+		if s.ModIndex == 0 {
+			return errors.Newv("missing arg: secret.value", map[string]interface{}{"secret": s.Name})
+		}
+		_, current, err := s.c.getSecret(s.Name)
+		if err != nil {
+			return err
+		}
+		stored.Ciphertext = current.Ciphertext
+	}
+
+	index, err := s.c.kvUpdate(key, stored, s.ModIndex)
+	if err != nil {
+		return errors.Wrapv(err, map[string]interface{}{"secret": s.Name})
+	}
+	s.ModIndex = index
+	return nil
+}
+
+func (s *Secret) permits(bundleID uint64) bool {
+	for _, id := range s.Bundles {
+		if id == bundleID {
+			return true
+		}
+	}
+	return false
+}
+
+// secretCipher returns an AEAD cipher using the cluster key.
+func (c *ClusterConf) secretCipher() (cipher.AEAD, error) {
+	key, err := c.config.SecretKey()
+	if err != nil {
+		return nil, err
+	}
+	if key == nil {
+		return nil, errors.New("secrets are not configured: missing secret_key_file")
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, errors.Wrap(err)
+	}
+	aead, err := cipher.NewGCM(block)
+	return aead, errors.Wrap(err)
+}
+
+// encryptSecret encrypts a secret value, binding it to the secret's name. The
+// nonce is prepended to the ciphertext.
+func (c *ClusterConf) encryptSecret(name, value string) ([]byte, error) {
+	aead, err := c.secretCipher()
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, errors.Wrap(err, "failed to generate nonce")
+	}
+	return aead.Seal(nonce, nonce, []byte(value), []byte(name)), nil
+}
+
+func (c *ClusterConf) decryptSecret(stored *storedSecret) (string, error) {
+	aead, err := c.secretCipher()
+	if err != nil {
+		return "", err
+	}
+	if len(stored.Ciphertext) < aead.NonceSize() {
+		return "", errors.Newv("invalid secret ciphertext", map[string]interface{}{"secret": stored.Name})
+	}
+	nonce, ciphertext := stored.Ciphertext[:aead.NonceSize()], stored.Ciphertext[aead.NonceSize():]
+	value, err := aead.Open(nil, nonce, ciphertext, []byte(stored.Name))
+	if err != nil {
+		return "", errors.Wrapv(err, map[string]interface{}{"secret": stored.Name}, "failed to decrypt secret")
+	}
+	return string(value), nil
+}
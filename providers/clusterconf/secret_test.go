@@ -0,0 +1,149 @@
+package clusterconf_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/cerana/cerana/acomm"
+	kvpkg "github.com/cerana/cerana/pkg/kv"
+	"github.com/cerana/cerana/providers/clusterconf"
+	"github.com/cerana/cerana/providers/kv"
+)
+
+func (s *clusterConf) TestSecrets() {
+	dir, err := ioutil.TempDir("", "clusterconf-")
+	s.Require().NoError(err)
+	defer func() { _ = os.RemoveAll(dir) }()
+	keyFile := filepath.Join(dir, "key")
+	s.Require().NoError(ioutil.WriteFile(keyFile, []byte(strings.Repeat("ab", 32)), 0600))
+	s.viper.Set("secret_key_file", keyFile)
+	defer s.viper.Set("secret_key_file", "")
+
+	tests := []struct {
+		desc   string
+		secret *clusterconf.Secret
+		err    string
+	}{
+		{"missing secret", nil, "missing arg: secret"},
+		{"missing name", &clusterconf.Secret{Value: "foo"}, "invalid arg: secret.name"},
+		{"invalid name", &clusterconf.Secret{Name: "a/b", Value: "foo"}, "invalid arg: secret.name"},
+		{"missing value", &clusterconf.Secret{Name: "password"}, "missing arg: secret.value"},
+		{"valid", &clusterconf.Secret{Name: "password", Value: "hunter2", Bundles: []uint64{1}}, ""},
+	}
+
+	var secret *clusterconf.Secret
+	for _, test := range tests {
+		req, err := acomm.NewRequest(acomm.RequestOptions{
+			Task: "update-secret",
+			Args: &clusterconf.SecretPayload{Secret: test.secret},
+		})
+		s.Require().NoError(err, test.desc)
+		result, streamURL, err := s.clusterConf.UpdateSecret(req)
+		s.Nil(streamURL, test.desc)
+		if test.err != "" {
+			s.Contains(err.Error(), test.err, test.desc)
+			s.Nil(result, test.desc)
+			continue
+		}
+		s.Require().NoError(err, test.desc)
+		secret = result.(*clusterconf.SecretPayload).Secret
+		s.Empty(secret.Value, test.desc)
+		s.NotZero(secret.ModIndex, test.desc)
+	}
+
+	// The value is encrypted at rest
+	resp, err := s.tracker.SyncRequest(s.config.CoordinatorURL(), acomm.RequestOptions{
+		Task: "kv-get",
+		Args: kv.GetArgs{Key: "secrets/password"},
+	}, 0)
+	s.Require().NoError(err)
+	s.Require().Nil(resp.Error)
+	var value kvpkg.Value
+	s.Require().NoError(resp.UnmarshalResult(&value))
+	s.NotContains(string(value.Data), "hunter2")
+
+	// Updating without a value keeps it
+	secret.Bundles = []uint64{1, 2}
+	req, err := acomm.NewRequest(acomm.RequestOptions{
+		Task: "update-secret",
+		Args: &clusterconf.SecretPayload{Secret: secret},
+	})
+	s.Require().NoError(err)
+	_, _, err = s.clusterConf.UpdateSecret(req)
+	s.Require().NoError(err)
+
+	resolveTests := []struct {
+		desc     string
+		bundleID uint64
+		names    []string
+		err      string
+	}{
+		{"missing bundle", 0, []string{"password"}, "missing arg: bundleID"},
+		{"not allowed", 3, []string{"password"}, "secret access denied"},
+		{"missing secret", 2, []string{"foo"}, "secret not found"},
+		{"allowed", 2, []string{"password"}, ""},
+	}
+
+	for _, test := range resolveTests {
+		req, err := acomm.NewRequest(acomm.RequestOptions{
+			Task: "resolve-secrets",
+			Args: &clusterconf.ResolveSecretsArgs{BundleID: test.bundleID, Names: test.names},
+		})
+		s.Require().NoError(err, test.desc)
+		result, _, err := s.clusterConf.ResolveSecrets(req)
+		if test.err != "" {
+			s.Contains(err.Error(), test.err, test.desc)
+			continue
+		}
+		s.Require().NoError(err, test.desc)
+		s.Equal(map[string]string{"password": "hunter2"}, result.(*clusterconf.ResolveSecretsResult).Values, test.desc)
+	}
+
+	req, err = acomm.NewRequest(acomm.RequestOptions{Task: "list-secrets"})
+	s.Require().NoError(err)
+	result, _, err := s.clusterConf.ListSecrets(req)
+	s.Require().NoError(err)
+	secrets := result.(*clusterconf.SecretListResult).Secrets
+	if s.Len(secrets, 1) {
+		s.Equal([]uint64{1, 2}, secrets[0].Bundles)
+		s.Empty(secrets[0].Value)
+	}
+
+	// Deleting removes the value from the kv
+	req, err = acomm.NewRequest(acomm.RequestOptions{
+		Task: "delete-secret",
+		Args: &clusterconf.IDArgs{ID: "password"},
+	})
+	s.Require().NoError(err)
+	_, _, err = s.clusterConf.DeleteSecret(req)
+	s.Require().NoError(err)
+
+	resp, err = s.tracker.SyncRequest(s.config.CoordinatorURL(), acomm.RequestOptions{
+		Task: "kv-get",
+		Args: kv.GetArgs{Key: "secrets/password"},
+	}, 0)
+	s.Require().NoError(err)
+	if s.Error(resp.Error) {
+		s.Contains(resp.Error.Error(), "key not found")
+	}
+
+	req, err = acomm.NewRequest(acomm.RequestOptions{
+		Task: "resolve-secrets",
+		Args: &clusterconf.ResolveSecretsArgs{BundleID: 2, Names: []string{"password"}},
+	})
+	s.Require().NoError(err)
+	_, _, err = s.clusterConf.ResolveSecrets(req)
+	if s.Error(err) {
+		s.Contains(err.Error(), "secret not found")
+	}
+}
+
+func (s *clusterConf) TestSecretName() {
+	name, ok := clusterconf.SecretName("secret:password")
+	s.True(ok)
+	s.Equal("password", name)
+	_, ok = clusterconf.SecretName("password")
+	s.False(ok)
+}
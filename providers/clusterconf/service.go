@@ -4,7 +4,10 @@ import (
 	"encoding/json"
 	"net/url"
 	"path"
+	"sort"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/cerana/cerana/acomm"
 	"github.com/cerana/cerana/pkg/errors"
@@ -23,7 +26,10 @@ type Service struct {
 
 // ServiceConf is the configuration of a service.
 type ServiceConf struct {
-	ID           string                 `json:"id"`
+	ID string `json:"id"`
+	// Project is the project the service belongs to, if any. It is taken
+	// from the service for bundle services.
+	Project      string                 `json:"project"`
 	Dataset      string                 `json:"dataset"`
 	HealthChecks map[string]HealthCheck `json:"healthChecks"`
 	Limits       ResourceLimits         `json:"limits"`
@@ -40,15 +46,50 @@ type ResourceLimits struct {
 
 // HealthCheck is configuration for performing a health check.
 type HealthCheck struct {
-	ID   string      `json:"id"`
-	Type string      `json:"type"`
+	ID string `json:"id"`
+	// Type is the health check task, one of the health-* tasks.
+	Type string `json:"type"`
+	// Args are the args of the health check task, e.g. health.FileArgs for
+	// health-file.
 	Args interface{} `json:"args"`
+	// Interval is the minimum time between runs of the check. By default it
+	// runs on every heartbeat.
+	Interval time.Duration `json:"interval"`
+	// Timeout is how long to wait for the check to complete. By default the
+	// request timeout is used.
+	Timeout time.Duration `json:"timeout"`
+	// FailureThreshold is the number of consecutive failures before the
+	// check is reported as failing. By default it is reported on the first.
+	FailureThreshold int `json:"failureThreshold"`
 }
 
 // ServicePayload can be used for task args or result when a service object
 // needs to be sent.
 type ServicePayload struct {
 	Service *Service `json:"service"`
+	// Author identifies who made a change, recorded in the revision history.
+	Author string `json:"author,omitempty"`
+}
+
+// ListServicesArgs are arguments for ListServices. Services are only
+// returned if they match all of the set filters.
+type ListServicesArgs struct {
+	// Dataset filters on the service dataset.
+	Dataset string `json:"dataset"`
+	// BundleID filters on services included in the bundle.
+	BundleID uint64 `json:"bundleID"`
+	// EnvKey filters on services with the env variable set.
+	EnvKey string `json:"envKey"`
+	// Project filters on services of the project.
+	Project string `json:"project"`
+}
+
+// ServiceListResult is the result from listing services.
+type ServiceListResult struct {
+	Services []*Service `json:"services"`
+	// Bundles are the IDs of bundles including each service, keyed by
+	// service ID.
+	Bundles map[string][]uint64 `json:"bundles"`
 }
 
 // GetService retrieves a service.
@@ -65,7 +106,42 @@ func (c *ClusterConf) GetService(req *acomm.Request) (interface{}, *url.URL, err
 	if err != nil {
 		return nil, nil, err
 	}
-	return &ServicePayload{service}, nil, nil
+	return &ServicePayload{Service: service}, nil, nil
+}
+
+// ListServices retrieves a list of services, along with the bundles that
+// include them.
+func (c *ClusterConf) ListServices(req *acomm.Request) (interface{}, *url.URL, error) {
+	var args ListServicesArgs
+	if err := req.UnmarshalArgs(&args); err != nil {
+		return nil, nil, err
+	}
+
+	services, err := c.getServices()
+	if err != nil {
+		return nil, nil, err
+	}
+	bundles, err := c.getBundles(false)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	references := serviceBundles(bundles)
+	result := &ServiceListResult{
+		Services: make([]*Service, 0, len(services)),
+		Bundles:  make(map[string][]uint64),
+	}
+	for _, service := range services {
+		bundleIDs := references[service.ID]
+		if !args.match(service, bundleIDs) {
+			continue
+		}
+		result.Services = append(result.Services, service)
+		if len(bundleIDs) > 0 {
+			result.Bundles[service.ID] = bundleIDs
+		}
+	}
+	return result, nil, nil
 }
 
 // UpdateService creates or updates a service config. When updating, a Get should first be performed and the modified Service passed back.
@@ -78,20 +154,36 @@ func (c *ClusterConf) UpdateService(req *acomm.Request) (interface{}, *url.URL,
 		return nil, nil, errors.Newv("missing arg: service", map[string]interface{}{"args": args})
 	}
 	args.Service.c = c
+	if err := validateHealthChecks(args.Service.HealthChecks); err != nil {
+		return nil, nil, err
+	}
 
 	if args.Service.ID == "" {
 		args.Service.ID = uuid.New()
 	}
+	unlock, err := c.lockChanges()
+	if err != nil {
+		return nil, nil, err
+	}
+	defer unlock()
+	if err := args.Service.checkProject(); err != nil {
+		return nil, nil, err
+	}
 
-	if err := args.Service.update(); err != nil {
+	if err := c.audited(req, args.Author, serviceReference, args.Service.ID, path.Join(servicesPrefix, args.Service.ID, "config"), args.Service.update); err != nil {
 		return nil, nil, err
 	}
-	return &ServicePayload{args.Service}, nil, nil
+	if err := c.saveRevision(path.Join(servicesPrefix, args.Service.ID), args.Service.ModIndex, args.Author, args.Service); err != nil {
+		return nil, nil, err
+	}
+	return &ServicePayload{Service: args.Service}, nil, nil
 }
 
-// DeleteService deletes a service config.
+// DeleteService deletes a service config. Services still included in bundles
+// are only deleted when forced or when cascading removes them from the
+// bundles.
 func (c *ClusterConf) DeleteService(req *acomm.Request) (interface{}, *url.URL, error) {
-	var args IDArgs
+	var args DeleteArgs
 	if err := req.UnmarshalArgs(&args); err != nil {
 		return nil, nil, err
 	}
@@ -99,6 +191,12 @@ func (c *ClusterConf) DeleteService(req *acomm.Request) (interface{}, *url.URL,
 		return nil, nil, errors.Newv("missing arg: id", map[string]interface{}{"args": args})
 	}
 
+	unlock, err := c.lockChanges()
+	if err != nil {
+		return nil, nil, err
+	}
+	defer unlock()
+
 	service, err := c.getService(args.ID)
 	if err != nil {
 		if strings.Contains(err.Error(), "service config not found") {
@@ -107,7 +205,10 @@ func (c *ClusterConf) DeleteService(req *acomm.Request) (interface{}, *url.URL,
 		return nil, nil, err
 	}
 
-	return nil, nil, service.delete()
+	if err := c.removeReferences(req, serviceReference, args); err != nil {
+		return nil, nil, err
+	}
+	return nil, nil, c.audited(req, args.Author, serviceReference, args.ID, path.Join(servicesPrefix, args.ID, "config"), service.delete)
 }
 
 func (c *ClusterConf) getService(id string) (*Service, error) {
@@ -121,6 +222,91 @@ func (c *ClusterConf) getService(id string) (*Service, error) {
 	return service, nil
 }
 
+// uint64Slice sorts ids in increasing order.
+type uint64Slice []uint64
+
+func (p uint64Slice) Len() int           { return len(p) }
+func (p uint64Slice) Swap(i, j int)      { p[i], p[j] = p[j], p[i] }
+func (p uint64Slice) Less(i, j int) bool { return p[i] < p[j] }
+
+// getServices retrieves all services.
+func (c *ClusterConf) getServices() ([]*Service, error) {
+	ids, err := c.objectIDs(servicesPrefix)
+	if err != nil {
+		return nil, err
+	}
+
+	var wg sync.WaitGroup
+	serviceChan := make(chan *Service, len(ids))
+	errChan := make(chan error, len(ids))
+	for id := range ids {
+		wg.Add(1)
+		go func(id string) {
+			defer wg.Done()
+			service, err := c.getService(id)
+			if err != nil {
+				errChan <- err
+				return
+			}
+			serviceChan <- service
+		}(id)
+	}
+	wg.Wait()
+
+	close(serviceChan)
+	close(errChan)
+
+	if len(errChan) > 0 {
+		err := <-errChan
+		return nil, err
+	}
+	services := make([]*Service, 0, len(serviceChan))
+	for service := range serviceChan {
+		services = append(services, service)
+	}
+	return services, nil
+}
+
+// serviceBundles maps service ids to the sorted ids of the bundles including
+// them.
+func serviceBundles(bundles []*Bundle) map[string][]uint64 {
+	references := make(map[string][]uint64)
+	for _, bundle := range bundles {
+		for id := range bundle.Services {
+			references[id] = append(references[id], bundle.ID)
+		}
+	}
+	for _, bundleIDs := range references {
+		sort.Sort(uint64Slice(bundleIDs))
+	}
+	return references
+}
+
+// match returns whether a service, included in the bundles, passes the
+// filters.
+func (a ListServicesArgs) match(service *Service, bundleIDs []uint64) bool {
+	if a.Dataset != "" && service.Dataset != a.Dataset {
+		return false
+	}
+	if a.Project != "" && service.Project != a.Project {
+		return false
+	}
+	if a.EnvKey != "" {
+		if _, ok := service.Env[a.EnvKey]; !ok {
+			return false
+		}
+	}
+	if a.BundleID != 0 {
+		for _, id := range bundleIDs {
+			if id == a.BundleID {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
+
 func (s *Service) reload() error {
 	key := path.Join(servicesPrefix, s.ID, "config")
 	value, err := s.c.kvGet(key)
@@ -140,7 +326,7 @@ func (s *Service) reload() error {
 
 func (s *Service) delete() error {
 	key := path.Join(servicesPrefix, s.ID)
-	return errors.Wrapv(s.c.kvDelete(key, s.ModIndex), map[string]interface{}{"serviceID": s.ID})
+	return errors.Wrapv(s.c.kvDeleteObject(key, s.ModIndex), map[string]interface{}{"serviceID": s.ID})
 }
 
 // update saves the service config.
@@ -44,6 +44,70 @@ func (s *clusterConf) TestGetService() {
 	}
 }
 
+func (s *clusterConf) TestListServices() {
+	bundle, err := s.addBundle()
+	s.Require().NoError(err)
+	var bundled string
+	for id := range bundle.Services {
+		bundled = id
+	}
+	unbundled, err := s.addService()
+	s.Require().NoError(err)
+	unbundled.Env = map[string]string{"FOO": "bar"}
+	req, err := acomm.NewRequest(acomm.RequestOptions{
+		Task: "update-service",
+		Args: &clusterconf.ServicePayload{Service: unbundled},
+	})
+	s.Require().NoError(err)
+	_, _, err = s.clusterConf.UpdateService(req)
+	s.Require().NoError(err)
+
+	tests := []struct {
+		desc     string
+		args     *clusterconf.ListServicesArgs
+		expected []string
+		missing  []string
+	}{
+		{"no filters", &clusterconf.ListServicesArgs{}, []string{bundled, unbundled.ID}, nil},
+		{"dataset", &clusterconf.ListServicesArgs{Dataset: "testds"}, []string{bundled, unbundled.ID}, nil},
+		{"other dataset", &clusterconf.ListServicesArgs{Dataset: "otherds"}, nil, []string{bundled, unbundled.ID}},
+		{"bundle", &clusterconf.ListServicesArgs{BundleID: bundle.ID}, []string{bundled}, []string{unbundled.ID}},
+		{"env key", &clusterconf.ListServicesArgs{EnvKey: "FOO"}, []string{unbundled.ID}, []string{bundled}},
+		{"bundle and env key", &clusterconf.ListServicesArgs{BundleID: bundle.ID, EnvKey: "FOO"}, nil, []string{bundled, unbundled.ID}},
+	}
+
+	for _, test := range tests {
+		req, err := acomm.NewRequest(acomm.RequestOptions{
+			Task: "list-services",
+			Args: test.args,
+		})
+		s.Require().NoError(err, test.desc)
+		result, streamURL, err := s.clusterConf.ListServices(req)
+		s.Nil(streamURL, test.desc)
+		if !s.NoError(err, test.desc) {
+			continue
+		}
+		list, ok := result.(*clusterconf.ServiceListResult)
+		if !s.True(ok, test.desc) {
+			continue
+		}
+		ids := make(map[string]bool)
+		for _, service := range list.Services {
+			ids[service.ID] = true
+		}
+		for _, id := range test.expected {
+			s.True(ids[id], test.desc)
+		}
+		for _, id := range test.missing {
+			s.False(ids[id], test.desc)
+		}
+		if ids[bundled] {
+			s.Contains(list.Bundles[bundled], bundle.ID, test.desc)
+		}
+		s.NotContains(list.Bundles, unbundled.ID, test.desc)
+	}
+}
+
 func (s *clusterConf) TestUpdateService() {
 	service, err := s.addService()
 	s.Require().NoError(err)
@@ -0,0 +1,243 @@
+package clusterconf
+
+import (
+	"bytes"
+	"net"
+	"net/url"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/cerana/cerana/acomm"
+	"github.com/cerana/cerana/pkg/errors"
+)
+
+// DatasetSnapshot is a snapshot of a dataset on a node, as reported by dataset
+// heartbeats.
+type DatasetSnapshot struct {
+	// Name is the snapshot name, without the dataset name.
+	Name string `json:"name"`
+	// GUID identifies the snapshot on all of the nodes it was sent to.
+	GUID    uint64    `json:"guid"`
+	Created time.Time `json:"created"`
+	// Used is the space only used by the snapshot and Referenced the size
+	// of the data it references.
+	Used       uint64 `json:"used"`
+	Referenced uint64 `json:"referenced"`
+}
+
+// CatalogSnapshot is a snapshot of a dataset and the nodes holding it.
+type CatalogSnapshot struct {
+	DatasetSnapshot
+	// Nodes are the IPs of the nodes holding the snapshot.
+	Nodes []net.IP `json:"nodes"`
+}
+
+// SnapshotCatalogResult is the result from listing the snapshots of a
+// dataset, oldest first.
+type SnapshotCatalogResult struct {
+	Snapshots []*CatalogSnapshot `json:"snapshots"`
+}
+
+// DatasetLineageResult is the result from retrieving the lineage of a
+// dataset.
+type DatasetLineageResult struct {
+	Dataset *Dataset `json:"dataset"`
+	// Ancestors are the dataset's parent, its parent, and so on.
+	Ancestors []*Dataset `json:"ancestors"`
+	// MissingParent is set when an ancestor's parent does not exist.
+	MissingParent string `json:"missingParent,omitempty"`
+	// Children are the datasets with the dataset as their parent.
+	Children []*Dataset `json:"children"`
+}
+
+// FindSnapshotNodeArgs are arguments for finding a node holding a dataset
+// snapshot.
+type FindSnapshotNodeArgs struct {
+	ID       string `json:"id"`
+	Snapshot string `json:"snapshot"`
+	// Exclude are nodes not to choose, e.g. the node the snapshot is
+	// going to be sent to.
+	Exclude []net.IP `json:"exclude"`
+}
+
+// SnapshotNodeResult is the result from finding a node holding a dataset
+// snapshot.
+type SnapshotNodeResult struct {
+	IP       net.IP           `json:"ip"`
+	Snapshot *CatalogSnapshot `json:"snapshot"`
+}
+
+// ListDatasetSnapshots retrieves the catalog of a dataset's snapshots on the
+// nodes with active heartbeats.
+func (c *ClusterConf) ListDatasetSnapshots(req *acomm.Request) (interface{}, *url.URL, error) {
+	var args IDArgs
+	if err := req.UnmarshalArgs(&args); err != nil {
+		return nil, nil, err
+	}
+	if args.ID == "" {
+		return nil, nil, errors.Newv("missing arg: id", map[string]interface{}{"args": args})
+	}
+
+	heartbeats, err := c.getDatasetHeartbeats(args.ID)
+	if err != nil {
+		return nil, nil, err
+	}
+	return &SnapshotCatalogResult{snapshotCatalog(heartbeats[args.ID])}, nil, nil
+}
+
+// GetDatasetLineage retrieves the ancestors and children of a dataset.
+func (c *ClusterConf) GetDatasetLineage(req *acomm.Request) (interface{}, *url.URL, error) {
+	var args IDArgs
+	if err := req.UnmarshalArgs(&args); err != nil {
+		return nil, nil, err
+	}
+	if args.ID == "" {
+		return nil, nil, errors.Newv("missing arg: id", map[string]interface{}{"args": args})
+	}
+
+	datasets, err := c.getDatasets()
+	if err != nil {
+		return nil, nil, err
+	}
+	result, err := datasetLineage(args.ID, datasets)
+	if err != nil {
+		return nil, nil, err
+	}
+	return result, nil, nil
+}
+
+// FindSnapshotNode finds the best node to read a dataset snapshot from. Nodes
+// not using the dataset are preferred.
+func (c *ClusterConf) FindSnapshotNode(req *acomm.Request) (interface{}, *url.URL, error) {
+	var args FindSnapshotNodeArgs
+	if err := req.UnmarshalArgs(&args); err != nil {
+		return nil, nil, err
+	}
+	if args.ID == "" {
+		return nil, nil, errors.Newv("missing arg: id", map[string]interface{}{"args": args})
+	}
+	if args.Snapshot == "" {
+		return nil, nil, errors.Newv("missing arg: snapshot", map[string]interface{}{"args": args})
+	}
+
+	heartbeats, err := c.getDatasetHeartbeats(args.ID)
+	if err != nil {
+		return nil, nil, err
+	}
+	result, err := findSnapshotNode(args, heartbeats[args.ID])
+	if err != nil {
+		return nil, nil, err
+	}
+	return result, nil, nil
+}
+
+// snapshotCatalog merges the snapshots reported in the heartbeats of a
+// dataset. Snapshots are identified by their GUID, or their name if the GUID
+// isn't reported.
+func snapshotCatalog(heartbeats map[string]DatasetHeartbeat) []*CatalogSnapshot {
+	snapshots := make(map[string]*CatalogSnapshot)
+	for _, heartbeat := range heartbeats {
+		for _, snapshot := range heartbeat.Snapshots {
+			key := "name:" + snapshot.Name
+			if snapshot.GUID != 0 {
+				key = strconv.FormatUint(snapshot.GUID, 10)
+			}
+			entry, ok := snapshots[key]
+			if !ok {
+				entry = &CatalogSnapshot{DatasetSnapshot: snapshot, Nodes: make([]net.IP, 0, 1)}
+				snapshots[key] = entry
+			}
+			entry.Nodes = append(entry.Nodes, heartbeat.IP)
+		}
+	}
+
+	catalog := make([]*CatalogSnapshot, 0, len(snapshots))
+	for _, snapshot := range snapshots {
+		sort.Sort(ipsByValue(snapshot.Nodes))
+		catalog = append(catalog, snapshot)
+	}
+	sort.Sort(snapshotsByCreation(catalog))
+	return catalog
+}
+
+// datasetLineage returns the lineage of a dataset among all datasets.
+func datasetLineage(id string, datasets []*Dataset) (*DatasetLineageResult, error) {
+	byID := make(map[string]*Dataset, len(datasets))
+	for _, dataset := range datasets {
+		byID[dataset.ID] = dataset
+	}
+	dataset, ok := byID[id]
+	if !ok {
+		return nil, errors.Newv("dataset config not found", map[string]interface{}{"datasetID": id})
+	}
+
+	result := &DatasetLineageResult{
+		Dataset:   dataset,
+		Ancestors: make([]*Dataset, 0),
+		Children:  make([]*Dataset, 0),
+	}
+	seen := map[string]bool{id: true}
+	for parentID := dataset.Parent; parentID != "" && !seen[parentID]; {
+		parent, ok := byID[parentID]
+		if !ok {
+			result.MissingParent = parentID
+			break
+		}
+		seen[parentID] = true
+		result.Ancestors = append(result.Ancestors, parent)
+		parentID = parent.Parent
+	}
+	for _, child := range datasets {
+		if child.Parent == id && child.ID != id {
+			result.Children = append(result.Children, child)
+		}
+	}
+	sort.Sort(datasetsByID(result.Children))
+	return result, nil
+}
+
+// findSnapshotNode chooses the node to read a snapshot from, preferring nodes
+// not using the dataset, then the lowest IP.
+func findSnapshotNode(args FindSnapshotNodeArgs, heartbeats map[string]DatasetHeartbeat) (*SnapshotNodeResult, error) {
+	excluded := make(map[string]bool, len(args.Exclude))
+	for _, ip := range args.Exclude {
+		excluded[ip.String()] = true
+	}
+
+	for _, snapshot := range snapshotCatalog(heartbeats) {
+		if snapshot.Name != args.Snapshot {
+			continue
+		}
+		var best net.IP
+		for _, ip := range snapshot.Nodes {
+			if excluded[ip.String()] {
+				continue
+			}
+			if best == nil || (heartbeats[best.String()].InUse && !heartbeats[ip.String()].InUse) {
+				best = ip
+			}
+		}
+		if best != nil {
+			return &SnapshotNodeResult{IP: best, Snapshot: snapshot}, nil
+		}
+	}
+	return nil, errors.Newv("no node holds snapshot", map[string]interface{}{"datasetID": args.ID, "snapshot": args.Snapshot, "exclude": args.Exclude})
+}
+
+type ipsByValue []net.IP
+
+func (p ipsByValue) Len() int           { return len(p) }
+func (p ipsByValue) Swap(i, j int)      { p[i], p[j] = p[j], p[i] }
+func (p ipsByValue) Less(i, j int) bool { return bytes.Compare(p[i].To16(), p[j].To16()) < 0 }
+
+type snapshotsByCreation []*CatalogSnapshot
+
+func (s snapshotsByCreation) Len() int      { return len(s) }
+func (s snapshotsByCreation) Swap(i, j int) { s[i], s[j] = s[j], s[i] }
+func (s snapshotsByCreation) Less(i, j int) bool {
+	if !s[i].Created.Equal(s[j].Created) {
+		return s[i].Created.Before(s[j].Created)
+	}
+	return s[i].Name < s[j].Name
+}
@@ -0,0 +1,146 @@
+package clusterconf_test
+
+import (
+	"net"
+	"path"
+	"time"
+
+	"github.com/cerana/cerana/acomm"
+	"github.com/cerana/cerana/providers/clusterconf"
+	"github.com/pborman/uuid"
+)
+
+func (s *clusterConf) TestListDatasetSnapshots() {
+	id := uuid.New()
+	created := time.Unix(1000, 0).UTC()
+	older := clusterconf.DatasetSnapshot{Name: "older", GUID: 1, Created: created}
+	newer := clusterconf.DatasetSnapshot{Name: "newer", GUID: 2, Created: created.Add(time.Hour)}
+	s.datasetHeartbeat(id, net.ParseIP("127.0.0.3"), false, older, newer)
+	s.datasetHeartbeat(id, net.ParseIP("127.0.0.2"), true, older)
+
+	req, err := acomm.NewRequest(acomm.RequestOptions{
+		Task: "list-dataset-snapshots",
+		Args: &clusterconf.IDArgs{},
+	})
+	s.Require().NoError(err)
+	_, _, err = s.clusterConf.ListDatasetSnapshots(req)
+	s.Contains(err.Error(), "missing arg: id")
+
+	req, err = acomm.NewRequest(acomm.RequestOptions{
+		Task: "list-dataset-snapshots",
+		Args: &clusterconf.IDArgs{ID: id},
+	})
+	s.Require().NoError(err)
+	result, streamURL, err := s.clusterConf.ListDatasetSnapshots(req)
+	s.Nil(streamURL)
+	s.Require().NoError(err)
+	snapshots := result.(*clusterconf.SnapshotCatalogResult).Snapshots
+	s.Require().Len(snapshots, 2)
+	s.Equal(older.Name, snapshots[0].Name)
+	s.Len(snapshots[0].Nodes, 2)
+	s.Equal("127.0.0.2", snapshots[0].Nodes[0].String())
+	s.Equal(newer.Name, snapshots[1].Name)
+	s.Len(snapshots[1].Nodes, 1)
+}
+
+func (s *clusterConf) TestGetDatasetLineage() {
+	ids := []string{uuid.New(), uuid.New(), uuid.New()}
+	missing := uuid.New()
+	data := map[string]interface{}{
+		path.Join("datasets", ids[0], "config"): &clusterconf.Dataset{ID: ids[0], Parent: missing},
+		path.Join("datasets", ids[1], "config"): &clusterconf.Dataset{ID: ids[1], Parent: ids[0]},
+		path.Join("datasets", ids[2], "config"): &clusterconf.Dataset{ID: ids[2], Parent: ids[1]},
+	}
+	_, err := s.loadData(data)
+	s.Require().NoError(err)
+
+	tests := []struct {
+		id        string
+		ancestors []string
+		children  []string
+		err       string
+	}{
+		{"", nil, nil, "missing arg: id"},
+		{uuid.New(), nil, nil, "dataset config not found"},
+		{ids[0], []string{}, []string{ids[1]}, ""},
+		{ids[2], []string{ids[1], ids[0]}, []string{}, ""},
+	}
+
+	for _, test := range tests {
+		req, err := acomm.NewRequest(acomm.RequestOptions{
+			Task: "get-dataset-lineage",
+			Args: &clusterconf.IDArgs{ID: test.id},
+		})
+		s.Require().NoError(err, test.id)
+		result, streamURL, err := s.clusterConf.GetDatasetLineage(req)
+		s.Nil(streamURL, test.id)
+		if test.err != "" {
+			s.Contains(err.Error(), test.err, test.id)
+			continue
+		}
+		if !s.NoError(err, test.id) {
+			continue
+		}
+		lineage := result.(*clusterconf.DatasetLineageResult)
+		s.Equal(test.ancestors, datasetIDs(lineage.Ancestors), test.id)
+		s.Equal(test.children, datasetIDs(lineage.Children), test.id)
+		s.Equal(missing, lineage.MissingParent, test.id)
+	}
+}
+
+func (s *clusterConf) TestFindSnapshotNode() {
+	id := uuid.New()
+	snapshot := clusterconf.DatasetSnapshot{Name: "snap", GUID: 1, Created: time.Now()}
+	s.datasetHeartbeat(id, net.ParseIP("127.0.0.2"), true, snapshot)
+	s.datasetHeartbeat(id, net.ParseIP("127.0.0.3"), false, snapshot)
+	s.datasetHeartbeat(id, net.ParseIP("127.0.0.4"), false, snapshot)
+
+	tests := []struct {
+		desc     string
+		args     clusterconf.FindSnapshotNodeArgs
+		expected string
+		err      string
+	}{
+		{"missing id", clusterconf.FindSnapshotNodeArgs{Snapshot: snapshot.Name}, "", "missing arg: id"},
+		{"missing snapshot", clusterconf.FindSnapshotNodeArgs{ID: id}, "", "missing arg: snapshot"},
+		{"unknown snapshot", clusterconf.FindSnapshotNodeArgs{ID: id, Snapshot: "foo"}, "", "no node holds snapshot"},
+		{"not in use", clusterconf.FindSnapshotNodeArgs{ID: id, Snapshot: snapshot.Name}, "127.0.0.3", ""},
+		{"excluded", clusterconf.FindSnapshotNodeArgs{ID: id, Snapshot: snapshot.Name, Exclude: []net.IP{net.ParseIP("127.0.0.3")}}, "127.0.0.4", ""},
+		{"in use", clusterconf.FindSnapshotNodeArgs{ID: id, Snapshot: snapshot.Name, Exclude: []net.IP{net.ParseIP("127.0.0.3"), net.ParseIP("127.0.0.4")}}, "127.0.0.2", ""},
+	}
+
+	for _, test := range tests {
+		req, err := acomm.NewRequest(acomm.RequestOptions{
+			Task: "find-snapshot-node",
+			Args: &test.args,
+		})
+		s.Require().NoError(err, test.desc)
+		result, streamURL, err := s.clusterConf.FindSnapshotNode(req)
+		s.Nil(streamURL, test.desc)
+		if test.err != "" {
+			s.Contains(err.Error(), test.err, test.desc)
+			continue
+		}
+		if s.NoError(err, test.desc) {
+			s.Equal(test.expected, result.(*clusterconf.SnapshotNodeResult).IP.String(), test.desc)
+		}
+	}
+}
+
+func (s *clusterConf) datasetHeartbeat(id string, ip net.IP, inUse bool, snapshots ...clusterconf.DatasetSnapshot) {
+	req, err := acomm.NewRequest(acomm.RequestOptions{
+		Task: "dataset-heartbeat",
+		Args: &clusterconf.DatasetHeartbeatArgs{ID: id, IP: ip, InUse: inUse, Snapshots: snapshots},
+	})
+	s.Require().NoError(err)
+	_, _, err = s.clusterConf.DatasetHeartbeat(req)
+	s.Require().NoError(err)
+}
+
+func datasetIDs(datasets []*clusterconf.Dataset) []string {
+	ids := make([]string, len(datasets))
+	for i, dataset := range datasets {
+		ids[i] = dataset.ID
+	}
+	return ids
+}
@@ -0,0 +1,182 @@
+package clusterconf
+
+import (
+	"net"
+	"net/url"
+	"path"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/cerana/cerana/acomm"
+	"github.com/cerana/cerana/pkg/errors"
+)
+
+// BundleState is the overall state of a bundle.
+type BundleState string
+
+// Bundle states, from the heartbeats of nodes running the bundle.
+const (
+	// BundleHealthy bundles run on as many healthy nodes as their redundancy.
+	BundleHealthy BundleState = "healthy"
+	// BundleDegraded bundles run on fewer healthy nodes than their
+	// redundancy, but at least one.
+	BundleDegraded BundleState = "degraded"
+	// BundleUnavailable bundles don't run on any healthy nodes.
+	BundleUnavailable BundleState = "unavailable"
+	// BundleOverReplicated bundles are healthy but run on more nodes than
+	// their redundancy.
+	BundleOverReplicated BundleState = "over-replicated"
+)
+
+// BundleStatus is the status of a bundle, combining its definition with the
+// heartbeats of the nodes running it.
+type BundleStatus struct {
+	BundleID   uint64      `json:"bundleID"`
+	State      BundleState `json:"state"`
+	Redundancy uint64      `json:"redundancy"`
+	// Healthy is the number of nodes running the bundle without any failing
+	// health checks.
+	Healthy uint64              `json:"healthy"`
+	Nodes   []*BundleNodeStatus `json:"nodes"`
+}
+
+// BundleNodeStatus is the status of a bundle on a node.
+type BundleNodeStatus struct {
+	Serial        string          `json:"serial"`
+	IP            net.IP          `json:"ip"`
+	FailingChecks []*FailingCheck `json:"failingChecks"`
+}
+
+// FailingCheck is a health check that is failing on a node.
+type FailingCheck struct {
+	ID    string `json:"id"`
+	Error string `json:"error"`
+	// Since is when the check started failing. It is zero if unknown.
+	Since time.Time `json:"since"`
+}
+
+// BundleStatusArgs are args for retrieving a bundle status.
+type BundleStatusArgs struct {
+	ID uint64 `json:"id"`
+}
+
+// BundleStatusPayload is the result from retrieving a bundle status.
+type BundleStatusPayload struct {
+	Status *BundleStatus `json:"status"`
+}
+
+// BundleStatusListResult is the result from listing bundle statuses.
+type BundleStatusListResult struct {
+	Statuses []*BundleStatus `json:"statuses"`
+}
+
+// GetBundleStatus retrieves the status of a bundle.
+func (c *ClusterConf) GetBundleStatus(req *acomm.Request) (interface{}, *url.URL, error) {
+	var args BundleStatusArgs
+	if err := req.UnmarshalArgs(&args); err != nil {
+		return nil, nil, err
+	}
+	if args.ID == 0 {
+		return nil, nil, errors.Newv("missing arg: id", map[string]interface{}{"args": args})
+	}
+
+	bundle, err := c.getBundle(args.ID)
+	if err != nil {
+		return nil, nil, err
+	}
+	heartbeats, err := c.getBundleHeartbeats(path.Join(heartbeatPrefix, bundlesPrefix, strconv.FormatUint(args.ID, 10)))
+	if err != nil {
+		return nil, nil, err
+	}
+	return &BundleStatusPayload{bundleStatus(bundle, heartbeats[args.ID])}, nil, nil
+}
+
+// ListBundleStatus retrieves the status of all bundles.
+func (c *ClusterConf) ListBundleStatus(req *acomm.Request) (interface{}, *url.URL, error) {
+	bundles, err := c.getBundles(false)
+	if err != nil {
+		return nil, nil, err
+	}
+	heartbeats, err := c.getBundleHeartbeats(path.Join(heartbeatPrefix, bundlesPrefix))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return &BundleStatusListResult{bundleStatuses(bundles, heartbeats)}, nil, nil
+}
+
+// bundleStatuses returns the status of each bundle, ordered by id.
+func bundleStatuses(bundles []*Bundle, heartbeats map[uint64]BundleHeartbeats) []*BundleStatus {
+	statuses := make([]*BundleStatus, 0, len(bundles))
+	for _, bundle := range bundles {
+		statuses = append(statuses, bundleStatus(bundle, heartbeats[bundle.ID]))
+	}
+	sort.Sort(bundleStatusesByID(statuses))
+	return statuses
+}
+
+// bundleStatus determines the status of a bundle from the heartbeats of the
+// nodes running it.
+func bundleStatus(bundle *Bundle, heartbeats BundleHeartbeats) *BundleStatus {
+	status := &BundleStatus{
+		BundleID:   bundle.ID,
+		Redundancy: bundle.Redundancy,
+		Nodes:      make([]*BundleNodeStatus, 0, len(heartbeats)),
+	}
+	// Every bundle runs on at least one node.
+	if status.Redundancy == 0 {
+		status.Redundancy = 1
+	}
+
+	for serial, heartbeat := range heartbeats {
+		node := &BundleNodeStatus{
+			Serial:        serial,
+			IP:            heartbeat.IP,
+			FailingChecks: make([]*FailingCheck, 0, len(heartbeat.HealthErrors)),
+		}
+		for id, err := range heartbeat.HealthErrors {
+			check := &FailingCheck{ID: id, Since: heartbeat.FailingSince[id]}
+			if err != nil {
+				check.Error = err.Error()
+			}
+			node.FailingChecks = append(node.FailingChecks, check)
+		}
+		sort.Sort(failingChecksByID(node.FailingChecks))
+		if len(node.FailingChecks) == 0 {
+			status.Healthy++
+		}
+		status.Nodes = append(status.Nodes, node)
+	}
+	sort.Sort(nodeStatusesBySerial(status.Nodes))
+
+	switch {
+	case status.Healthy == 0:
+		status.State = BundleUnavailable
+	case status.Healthy < status.Redundancy:
+		status.State = BundleDegraded
+	case uint64(len(status.Nodes)) > status.Redundancy:
+		status.State = BundleOverReplicated
+	default:
+		status.State = BundleHealthy
+	}
+	return status
+}
+
+type bundleStatusesByID []*BundleStatus
+
+func (b bundleStatusesByID) Len() int           { return len(b) }
+func (b bundleStatusesByID) Swap(i, j int)      { b[i], b[j] = b[j], b[i] }
+func (b bundleStatusesByID) Less(i, j int) bool { return b[i].BundleID < b[j].BundleID }
+
+type nodeStatusesBySerial []*BundleNodeStatus
+
+func (n nodeStatusesBySerial) Len() int           { return len(n) }
+func (n nodeStatusesBySerial) Swap(i, j int)      { n[i], n[j] = n[j], n[i] }
+func (n nodeStatusesBySerial) Less(i, j int) bool { return n[i].Serial < n[j].Serial }
+
+type failingChecksByID []*FailingCheck
+
+func (f failingChecksByID) Len() int           { return len(f) }
+func (f failingChecksByID) Swap(i, j int)      { f[i], f[j] = f[j], f[i] }
+func (f failingChecksByID) Less(i, j int) bool { return f[i].ID < f[j].ID }
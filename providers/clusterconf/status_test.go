@@ -0,0 +1,82 @@
+package clusterconf_test
+
+import (
+	"errors"
+	"net"
+
+	"github.com/cerana/cerana/acomm"
+	"github.com/cerana/cerana/providers/clusterconf"
+)
+
+func (s *clusterConf) TestGetBundleStatus() {
+	bundle, err := s.addBundle()
+	s.Require().NoError(err)
+
+	status := s.bundleStatus(bundle.ID)
+	s.Equal(clusterconf.BundleUnavailable, status.State)
+	s.Equal(uint64(1), status.Redundancy)
+	s.Empty(status.Nodes)
+
+	s.bundleHeartbeat(bundle.ID, "serial1", nil)
+	s.Equal(clusterconf.BundleHealthy, s.bundleStatus(bundle.ID).State)
+
+	s.bundleHeartbeat(bundle.ID, "serial2", nil)
+	s.Equal(clusterconf.BundleOverReplicated, s.bundleStatus(bundle.ID).State)
+
+	s.bundleHeartbeat(bundle.ID, "serial1", map[string]error{"http": errors.New("connection refused")})
+	s.bundleHeartbeat(bundle.ID, "serial2", map[string]error{"http": errors.New("connection refused")})
+	status = s.bundleStatus(bundle.ID)
+	s.Equal(clusterconf.BundleUnavailable, status.State)
+	s.Equal(uint64(0), status.Healthy)
+	s.Require().Len(status.Nodes, 2)
+	s.Require().Len(status.Nodes[0].FailingChecks, 1)
+	failing := status.Nodes[0].FailingChecks[0]
+	s.Equal("http", failing.ID)
+	s.Equal("connection refused", failing.Error)
+	s.False(failing.Since.IsZero())
+
+	// Still failing checks keep their start time
+	s.bundleHeartbeat(bundle.ID, "serial1", map[string]error{"http": errors.New("timeout")})
+	status = s.bundleStatus(bundle.ID)
+	s.True(failing.Since.Equal(status.Nodes[0].FailingChecks[0].Since))
+
+	s.bundleHeartbeat(bundle.ID, "serial2", nil)
+	s.Equal(clusterconf.BundleHealthy, s.bundleStatus(bundle.ID).State)
+
+	req, err := acomm.NewRequest(acomm.RequestOptions{Task: "list-bundle-status"})
+	s.Require().NoError(err)
+	result, streamURL, err := s.clusterConf.ListBundleStatus(req)
+	s.Nil(streamURL)
+	s.Require().NoError(err)
+	statuses := result.(*clusterconf.BundleStatusListResult).Statuses
+	if s.Len(statuses, 1) {
+		s.Equal(bundle.ID, statuses[0].BundleID)
+		s.Equal(uint64(1), statuses[0].Healthy)
+	}
+}
+
+func (s *clusterConf) bundleStatus(id uint64) *clusterconf.BundleStatus {
+	req, err := acomm.NewRequest(acomm.RequestOptions{
+		Task: "get-bundle-status",
+		Args: &clusterconf.BundleStatusArgs{ID: id},
+	})
+	s.Require().NoError(err)
+	result, _, err := s.clusterConf.GetBundleStatus(req)
+	s.Require().NoError(err)
+	return result.(*clusterconf.BundleStatusPayload).Status
+}
+
+func (s *clusterConf) bundleHeartbeat(id uint64, serial string, healthErrors map[string]error) {
+	req, err := acomm.NewRequest(acomm.RequestOptions{
+		Task: "bundle-heartbeat",
+		Args: &clusterconf.BundleHeartbeatArgs{
+			ID:           id,
+			Serial:       serial,
+			IP:           net.ParseIP("10.0.0.1"),
+			HealthErrors: healthErrors,
+		},
+	})
+	s.Require().NoError(err)
+	_, _, err = s.clusterConf.BundleHeartbeat(req)
+	s.Require().NoError(err)
+}
@@ -0,0 +1,494 @@
+package clusterconf
+
+import (
+	"bytes"
+	"encoding/json"
+	"math/rand"
+	"net/url"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/cerana/cerana/acomm"
+	"github.com/cerana/cerana/pkg/errors"
+)
+
+const bundleTemplatesPrefix string = "bundle-templates"
+
+// parameterName matches valid template parameter names, which are referenced
+// as fields in the template.
+var parameterName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// BundleTemplate is a parameterized bundle config that bundles can be
+// instantiated from.
+type BundleTemplate struct {
+	c           *ClusterConf
+	ID          string              `json:"id"`
+	Description string              `json:"description"`
+	Parameters  []TemplateParameter `json:"parameters"`
+	// Bundle is the bundle config as JSON, with parameters referenced by
+	// text/template actions, e.g. `{"redundancy": {{.replicas}}}`. Parameter
+	// values are JSON string escaped when rendered.
+	Bundle string `json:"bundle"`
+	// ModIndex should be treated as opaque, but passed back on updates. It
+	// is also the version of the template recorded by its instances.
+	ModIndex uint64 `json:"modIndex"`
+}
+
+// TemplateParameter is a parameter declared by a bundle template.
+type TemplateParameter struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	// Default is used when the parameter isn't given, unless it is required.
+	Default  string `json:"default"`
+	Required bool   `json:"required"`
+}
+
+// BundleTemplateRef identifies the template version a bundle was instantiated
+// from and the parameters it was given.
+type BundleTemplateRef struct {
+	ID         string            `json:"id"`
+	Version    uint64            `json:"version"`
+	Parameters map[string]string `json:"parameters"`
+}
+
+// BundleTemplatePayload can be used for task args or result when a bundle
+// template object needs to be sent.
+type BundleTemplatePayload struct {
+	Template *BundleTemplate `json:"template"`
+	// Author identifies who made a change, recorded in the audit log.
+	Author string `json:"author,omitempty"`
+}
+
+// BundleTemplateListResult is the result from listing bundle templates.
+type BundleTemplateListResult struct {
+	Templates []*BundleTemplate `json:"templates"`
+}
+
+// InstantiateBundleArgs are args for instantiating a bundle from a template.
+type InstantiateBundleArgs struct {
+	TemplateID string `json:"templateID"`
+	// BundleID re-instantiates an existing instance of the template, reusing
+	// its parameters unless overridden. A new bundle is created if not set.
+	BundleID   uint64            `json:"bundleID"`
+	Parameters map[string]string `json:"parameters"`
+	// Author identifies who made a change, recorded in the revision history.
+	Author string `json:"author,omitempty"`
+	// Force skips checking the rendered bundle's references.
+	Force bool `json:"force,omitempty"`
+}
+
+// TemplateInstance is a bundle instantiated from a template.
+type TemplateInstance struct {
+	BundleID uint64 `json:"bundleID"`
+	Version  uint64 `json:"version"`
+	// Current is whether the bundle was instantiated from the current
+	// version of the template.
+	Current bool `json:"current"`
+}
+
+// TemplateInstanceListResult is the result from listing template instances.
+type TemplateInstanceListResult struct {
+	Instances []*TemplateInstance `json:"instances"`
+}
+
+// RolloutArgs are args for rolling out the current version of a template.
+type RolloutArgs struct {
+	ID string `json:"id"`
+	// Author identifies who made a change, recorded in the revision history.
+	Author string `json:"author,omitempty"`
+	// Force skips checking the rendered bundles' references.
+	Force bool `json:"force,omitempty"`
+}
+
+// RolloutResult is the result from rolling out a template.
+type RolloutResult struct {
+	// Updated are the ids of the bundles re-instantiated.
+	Updated []uint64 `json:"updated"`
+}
+
+// GetBundleTemplate retrieves a bundle template.
+func (c *ClusterConf) GetBundleTemplate(req *acomm.Request) (interface{}, *url.URL, error) {
+	var args IDArgs
+	if err := req.UnmarshalArgs(&args); err != nil {
+		return nil, nil, err
+	}
+	if args.ID == "" {
+		return nil, nil, errors.Newv("missing arg: id", map[string]interface{}{"args": args})
+	}
+
+	bundleTemplate, err := c.getBundleTemplate(args.ID)
+	if err != nil {
+		return nil, nil, err
+	}
+	return &BundleTemplatePayload{Template: bundleTemplate}, nil, nil
+}
+
+// ListBundleTemplates retrieves all bundle templates.
+func (c *ClusterConf) ListBundleTemplates(req *acomm.Request) (interface{}, *url.URL, error) {
+	values, err := c.kvGetAll(bundleTemplatesPrefix)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	templates := make([]*BundleTemplate, 0, len(values))
+	for key, value := range values {
+		if key == bundleTemplatesPrefix {
+			continue
+		}
+		bundleTemplate := &BundleTemplate{c: c}
+		if err := json.Unmarshal(value.Data, bundleTemplate); err != nil {
+			return nil, nil, errors.Wrapv(err, map[string]interface{}{"json": string(value.Data)})
+		}
+		bundleTemplate.ModIndex = value.Index
+		templates = append(templates, bundleTemplate)
+	}
+	return &BundleTemplateListResult{templates}, nil, nil
+}
+
+// UpdateBundleTemplate creates or updates a bundle template. When updating, a
+// Get should first be performed and the modified BundleTemplate passed back.
+// Existing instances are not changed until the template is rolled out.
+func (c *ClusterConf) UpdateBundleTemplate(req *acomm.Request) (interface{}, *url.URL, error) {
+	var args BundleTemplatePayload
+	if err := req.UnmarshalArgs(&args); err != nil {
+		return nil, nil, err
+	}
+	if args.Template == nil {
+		return nil, nil, errors.Newv("missing arg: template", map[string]interface{}{"args": args})
+	}
+	if err := args.Template.validate(); err != nil {
+		return nil, nil, err
+	}
+	args.Template.c = c
+
+	if err := c.audited(req, args.Author, bundleTemplateObject, args.Template.ID, path.Join(bundleTemplatesPrefix, args.Template.ID), args.Template.update); err != nil {
+		return nil, nil, err
+	}
+	return &BundleTemplatePayload{Template: args.Template}, nil, nil
+}
+
+// DeleteBundleTemplate deletes a bundle template. Templates with instances
+// are only deleted when forced, leaving the instances as they are.
+func (c *ClusterConf) DeleteBundleTemplate(req *acomm.Request) (interface{}, *url.URL, error) {
+	var args DeleteArgs
+	if err := req.UnmarshalArgs(&args); err != nil {
+		return nil, nil, err
+	}
+	if args.ID == "" {
+		return nil, nil, errors.Newv("missing arg: id", map[string]interface{}{"args": args})
+	}
+
+	if !args.Force {
+		bundles, err := c.getBundles(false)
+		if err != nil {
+			return nil, nil, err
+		}
+		if instances := templateInstances(bundles, args.ID, 0); len(instances) > 0 {
+			return nil, nil, errors.Newv("bundle template is referenced by bundles", map[string]interface{}{"templateID": args.ID, "instances": len(instances)})
+		}
+	}
+
+	key := path.Join(bundleTemplatesPrefix, args.ID)
+	err := c.audited(req, args.Author, bundleTemplateObject, args.ID, key, func() error {
+		return errors.Wrapv(c.kvDelete(key, 0), map[string]interface{}{"templateID": args.ID})
+	})
+	return nil, nil, err
+}
+
+// InstantiateBundle renders a bundle from a template and parameters and saves
+// it.
+func (c *ClusterConf) InstantiateBundle(req *acomm.Request) (interface{}, *url.URL, error) {
+	var args InstantiateBundleArgs
+	if err := req.UnmarshalArgs(&args); err != nil {
+		return nil, nil, err
+	}
+	if args.TemplateID == "" {
+		return nil, nil, errors.Newv("missing arg: templateID", map[string]interface{}{"args": args})
+	}
+
+	bundleTemplate, err := c.getBundleTemplate(args.TemplateID)
+	if err != nil {
+		return nil, nil, err
+	}
+	var current *Bundle
+	if args.BundleID != 0 {
+		if current, err = c.getBundle(args.BundleID); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	bundle, err := c.instantiate(req, bundleTemplate, current, args.Parameters, args.Author, args.Force)
+	if err != nil {
+		return nil, nil, err
+	}
+	return &BundlePayload{Bundle: bundle}, nil, nil
+}
+
+// ListTemplateInstances lists the bundles instantiated from a template.
+func (c *ClusterConf) ListTemplateInstances(req *acomm.Request) (interface{}, *url.URL, error) {
+	var args IDArgs
+	if err := req.UnmarshalArgs(&args); err != nil {
+		return nil, nil, err
+	}
+	if args.ID == "" {
+		return nil, nil, errors.Newv("missing arg: id", map[string]interface{}{"args": args})
+	}
+
+	bundleTemplate, err := c.getBundleTemplate(args.ID)
+	if err != nil {
+		return nil, nil, err
+	}
+	bundles, err := c.getBundles(false)
+	if err != nil {
+		return nil, nil, err
+	}
+	return &TemplateInstanceListResult{templateInstances(bundles, bundleTemplate.ID, bundleTemplate.ModIndex)}, nil, nil
+}
+
+// RolloutBundleTemplate re-instantiates the bundles of a template that were
+// instantiated from an older version of it, keeping their parameters.
+func (c *ClusterConf) RolloutBundleTemplate(req *acomm.Request) (interface{}, *url.URL, error) {
+	var args RolloutArgs
+	if err := req.UnmarshalArgs(&args); err != nil {
+		return nil, nil, err
+	}
+	if args.ID == "" {
+		return nil, nil, errors.Newv("missing arg: id", map[string]interface{}{"args": args})
+	}
+
+	bundleTemplate, err := c.getBundleTemplate(args.ID)
+	if err != nil {
+		return nil, nil, err
+	}
+	bundles, err := c.getBundles(false)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	result := &RolloutResult{Updated: make([]uint64, 0)}
+	for _, bundle := range bundles {
+		if bundle.Template == nil || bundle.Template.ID != bundleTemplate.ID || bundle.Template.Version == bundleTemplate.ModIndex {
+			continue
+		}
+		if _, err := c.instantiate(req, bundleTemplate, bundle, nil, args.Author, args.Force); err != nil {
+			return nil, nil, errors.Wrapv(err, map[string]interface{}{"updated": result.Updated})
+		}
+		result.Updated = append(result.Updated, bundle.ID)
+	}
+	sort.Sort(uint64Slice(result.Updated))
+	return result, nil, nil
+}
+
+// instantiate renders and saves an instance of a template, replacing the
+// current bundle if given.
+func (c *ClusterConf) instantiate(req *acomm.Request, bundleTemplate *BundleTemplate, current *Bundle, params map[string]string, author string, force bool) (*Bundle, error) {
+	bundle, err := bundleTemplate.instance(current, params)
+	if err != nil {
+		return nil, err
+	}
+	bundle.c = c
+
+	if err := bundle.validateHealthChecks(); err != nil {
+		return nil, err
+	}
+	if !force {
+		if err := bundle.checkReferences(); err != nil {
+			return nil, err
+		}
+	}
+	unlock, err := c.lockChanges()
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+	if err := bundle.checkProject(); err != nil {
+		return nil, err
+	}
+	if err := bundle.allocatePorts(); err != nil {
+		return nil, err
+	}
+	if err := c.audited(req, author, bundleReference, strconv.FormatUint(bundle.ID, 10), path.Join(bundleKey(bundle.ID), "config"), bundle.update); err != nil {
+		return nil, err
+	}
+	if err := c.saveRevision(bundleKey(bundle.ID), bundle.ModIndex, author, bundle); err != nil {
+		return nil, err
+	}
+	return bundle, nil
+}
+
+func (c *ClusterConf) getBundleTemplate(id string) (*BundleTemplate, error) {
+	key := path.Join(bundleTemplatesPrefix, id)
+	value, err := c.kvGet(key)
+	if err != nil {
+		if strings.Contains(err.Error(), "key not found") {
+			err = errors.Newv("bundle template not found", map[string]interface{}{"templateID": id})
+		}
+		return nil, err
+	}
+
+	bundleTemplate := &BundleTemplate{c: c}
+	if err := json.Unmarshal(value.Data, bundleTemplate); err != nil {
+		return nil, errors.Wrapv(err, map[string]interface{}{"json": string(value.Data)})
+	}
+	bundleTemplate.ModIndex = value.Index
+	return bundleTemplate, nil
+}
+
+// update saves the bundle template.
+func (t *BundleTemplate) update() error {
+	key := path.Join(bundleTemplatesPrefix, t.ID)
+
+	index, err := t.c.kvUpdate(key, t, t.ModIndex)
+	if err != nil {
+		return errors.Wrapv(err, map[string]interface{}{"templateID": t.ID})
+	}
+	t.ModIndex = index
+	return nil
+}
+
+// validate checks the template's id, parameters, and that the bundle can be
+// parsed as a template.
+func (t *BundleTemplate) validate() error {
+	if t.ID == "" || strings.Contains(t.ID, "/") {
+		return errors.Newv("invalid arg: template.id", map[string]interface{}{"templateID": t.ID})
+	}
+
+	names := make(map[string]bool, len(t.Parameters))
+	for _, param := range t.Parameters {
+		if !parameterName.MatchString(param.Name) {
+			return errors.Newv("invalid template parameter name", map[string]interface{}{"templateID": t.ID, "name": param.Name})
+		}
+		if names[param.Name] {
+			return errors.Newv("duplicate template parameter", map[string]interface{}{"templateID": t.ID, "name": param.Name})
+		}
+		names[param.Name] = true
+	}
+
+	_, err := t.parse()
+	return err
+}
+
+func (t *BundleTemplate) parse() (*template.Template, error) {
+	tmpl, err := template.New(t.ID).Option("missingkey=error").Parse(t.Bundle)
+	if err != nil {
+		return nil, errors.Wrapv(err, map[string]interface{}{"templateID": t.ID}, "invalid bundle template")
+	}
+	return tmpl, nil
+}
+
+// instance renders a bundle from the template. Parameters given for an
+// existing instance override the ones it was instantiated with, and the
+// instance keeps its id and external ports.
+func (t *BundleTemplate) instance(current *Bundle, params map[string]string) (*Bundle, error) {
+	given := make(map[string]string)
+	if current != nil {
+		if current.Template == nil || current.Template.ID != t.ID {
+			return nil, errors.Newv("bundle is not an instance of the template", map[string]interface{}{"bundleID": current.ID, "templateID": t.ID})
+		}
+		for name, value := range current.Template.Parameters {
+			given[name] = value
+		}
+	}
+	for name, value := range params {
+		given[name] = value
+	}
+
+	bundle, err := t.render(given)
+	if err != nil {
+		return nil, err
+	}
+	bundle.Template = &BundleTemplateRef{
+		ID:         t.ID,
+		Version:    t.ModIndex,
+		Parameters: given,
+	}
+
+	if current == nil {
+		rand.Seed(time.Now().UnixNano())
+		bundle.ID = uint64(rand.Int63())
+		return bundle, nil
+	}
+	bundle.ID = current.ID
+	bundle.ModIndex = current.ModIndex
+	for port, bundlePort := range bundle.Ports {
+		if bundlePort.ExternalPort == 0 && bundlePort.Public {
+			bundlePort.ExternalPort = current.Ports[port].ExternalPort
+			bundle.Ports[port] = bundlePort
+		}
+	}
+	return bundle, nil
+}
+
+// render renders the template's bundle with the given parameters and the
+// defaults of the rest.
+func (t *BundleTemplate) render(given map[string]string) (*Bundle, error) {
+	values := make(map[string]string, len(t.Parameters))
+	declared := make(map[string]bool, len(t.Parameters))
+	for _, param := range t.Parameters {
+		declared[param.Name] = true
+		value, ok := given[param.Name]
+		if !ok {
+			if param.Required {
+				return nil, errors.Newv("missing template parameter", map[string]interface{}{"templateID": t.ID, "name": param.Name})
+			}
+			value = param.Default
+		}
+		values[param.Name] = jsonEscape(value)
+	}
+	for name := range given {
+		if !declared[name] {
+			return nil, errors.Newv("unknown template parameter", map[string]interface{}{"templateID": t.ID, "name": name})
+		}
+	}
+
+	tmpl, err := t.parse()
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, values); err != nil {
+		return nil, errors.Wrapv(err, map[string]interface{}{"templateID": t.ID}, "failed to render bundle template")
+	}
+
+	bundle := &Bundle{}
+	if err := json.Unmarshal(buf.Bytes(), bundle); err != nil {
+		return nil, errors.Wrapv(err, map[string]interface{}{"templateID": t.ID, "json": buf.String()}, "rendered bundle template is not a valid bundle")
+	}
+	return bundle, nil
+}
+
+// jsonEscape escapes a value for use within a JSON string.
+func jsonEscape(value string) string {
+	// Marshaling a string can't fail
+	data, _ := json.Marshal(value)
+	return string(data[1 : len(data)-1])
+}
+
+// templateInstances returns the bundles instantiated from a template, ordered
+// by id.
+func templateInstances(bundles []*Bundle, id string, version uint64) []*TemplateInstance {
+	instances := make([]*TemplateInstance, 0)
+	for _, bundle := range bundles {
+		if bundle.Template == nil || bundle.Template.ID != id {
+			continue
+		}
+		instances = append(instances, &TemplateInstance{
+			BundleID: bundle.ID,
+			Version:  bundle.Template.Version,
+			Current:  bundle.Template.Version == version,
+		})
+	}
+	sort.Sort(templateInstancesByID(instances))
+	return instances
+}
+
+type templateInstancesByID []*TemplateInstance
+
+func (t templateInstancesByID) Len() int           { return len(t) }
+func (t templateInstancesByID) Swap(i, j int)      { t[i], t[j] = t[j], t[i] }
+func (t templateInstancesByID) Less(i, j int) bool { return t[i].BundleID < t[j].BundleID }
@@ -0,0 +1,185 @@
+package clusterconf_test
+
+import (
+	"github.com/cerana/cerana/acomm"
+	"github.com/cerana/cerana/providers/clusterconf"
+)
+
+func (s *clusterConf) TestUpdateBundleTemplate() {
+	tests := []struct {
+		desc     string
+		template *clusterconf.BundleTemplate
+		err      string
+	}{
+		{"missing template", nil, "missing arg: template"},
+		{"missing id", &clusterconf.BundleTemplate{}, "invalid arg: template.id"},
+		{"invalid parameter", &clusterconf.BundleTemplate{ID: "web", Parameters: []clusterconf.TemplateParameter{{Name: "a-b"}}}, "invalid template parameter name"},
+		{"duplicate parameter", &clusterconf.BundleTemplate{ID: "web", Parameters: []clusterconf.TemplateParameter{{Name: "a"}, {Name: "a"}}}, "duplicate template parameter"},
+		{"invalid template", &clusterconf.BundleTemplate{ID: "web", Bundle: "{{.a"}, "invalid bundle template"},
+		{"valid", s.webTemplate(), ""},
+	}
+
+	for _, test := range tests {
+		req, err := acomm.NewRequest(acomm.RequestOptions{
+			Task: "update-bundle-template",
+			Args: &clusterconf.BundleTemplatePayload{Template: test.template},
+		})
+		s.Require().NoError(err, test.desc)
+		result, streamURL, err := s.clusterConf.UpdateBundleTemplate(req)
+		s.Nil(streamURL, test.desc)
+		if test.err != "" {
+			s.Contains(err.Error(), test.err, test.desc)
+			s.Nil(result, test.desc)
+			continue
+		}
+		s.NoError(err, test.desc)
+		s.NotZero(result.(*clusterconf.BundleTemplatePayload).Template.ModIndex, test.desc)
+	}
+}
+
+func (s *clusterConf) TestInstantiateBundle() {
+	template := s.updateBundleTemplate(s.webTemplate())
+
+	tests := []struct {
+		desc       string
+		templateID string
+		params     map[string]string
+		err        string
+	}{
+		{"missing template id", "", nil, "missing arg: templateID"},
+		{"unknown template", "foo", nil, "bundle template not found"},
+		{"missing parameter", template.ID, nil, "missing template parameter"},
+		{"unknown parameter", template.ID, map[string]string{"name": "a", "foo": "b"}, "unknown template parameter"},
+		{"invalid bundle", template.ID, map[string]string{"name": "a", "redundancy": "x"}, "rendered bundle template is not a valid bundle"},
+		{"defaults", template.ID, map[string]string{"name": `a"b`}, ""},
+	}
+
+	var bundle *clusterconf.Bundle
+	for _, test := range tests {
+		result, err := s.instantiateBundle(&clusterconf.InstantiateBundleArgs{TemplateID: test.templateID, Parameters: test.params})
+		if test.err != "" {
+			s.Contains(err.Error(), test.err, test.desc)
+			continue
+		}
+		s.Require().NoError(err, test.desc)
+		bundle = result
+		s.NotZero(bundle.ID, test.desc)
+		s.Equal(uint64(2), bundle.Redundancy, test.desc)
+		s.Equal(`a"b`, bundle.Placement.NodeSelector["app"], test.desc)
+		if s.NotNil(bundle.Template, test.desc) {
+			s.Equal(template.ID, bundle.Template.ID, test.desc)
+			s.Equal(template.ModIndex, bundle.Template.Version, test.desc)
+			s.Equal(map[string]string{"name": `a"b`}, bundle.Template.Parameters, test.desc)
+		}
+	}
+
+	// Updating the template makes the instance outdated until rolled out
+	template.Parameters[1].Default = "3"
+	template = s.updateBundleTemplate(template)
+	instances := s.listTemplateInstances(template.ID)
+	if s.Len(instances, 1) {
+		s.Equal(bundle.ID, instances[0].BundleID)
+		s.False(instances[0].Current)
+	}
+
+	req, err := acomm.NewRequest(acomm.RequestOptions{
+		Task: "rollout-bundle-template",
+		Args: &clusterconf.RolloutArgs{ID: template.ID, Author: "alice"},
+	})
+	s.Require().NoError(err)
+	result, _, err := s.clusterConf.RolloutBundleTemplate(req)
+	s.Require().NoError(err)
+	s.Equal([]uint64{bundle.ID}, result.(*clusterconf.RolloutResult).Updated)
+	rolledOut := s.getBundle(bundle.ID)
+	s.Equal(uint64(3), rolledOut.Redundancy)
+	s.Equal(`a"b`, rolledOut.Placement.NodeSelector["app"])
+	instances = s.listTemplateInstances(template.ID)
+	if s.Len(instances, 1) {
+		s.True(instances[0].Current)
+	}
+
+	// Re-instantiating overrides the given parameters
+	bundle, err = s.instantiateBundle(&clusterconf.InstantiateBundleArgs{
+		TemplateID: template.ID,
+		BundleID:   bundle.ID,
+		Parameters: map[string]string{"redundancy": "5"},
+	})
+	s.Require().NoError(err)
+	s.Equal(uint64(5), bundle.Redundancy)
+	s.Equal(`a"b`, bundle.Placement.NodeSelector["app"])
+
+	req, err = acomm.NewRequest(acomm.RequestOptions{
+		Task: "delete-bundle-template",
+		Args: &clusterconf.DeleteArgs{ID: template.ID},
+	})
+	s.Require().NoError(err)
+	_, _, err = s.clusterConf.DeleteBundleTemplate(req)
+	if s.Error(err) {
+		s.Contains(err.Error(), "bundle template is referenced by bundles")
+	}
+
+	req, err = acomm.NewRequest(acomm.RequestOptions{
+		Task: "delete-bundle-template",
+		Args: &clusterconf.DeleteArgs{ID: template.ID, Force: true},
+	})
+	s.Require().NoError(err)
+	_, _, err = s.clusterConf.DeleteBundleTemplate(req)
+	s.Require().NoError(err)
+
+	req, err = acomm.NewRequest(acomm.RequestOptions{
+		Task: "get-bundle-template",
+		Args: &clusterconf.IDArgs{ID: template.ID},
+	})
+	s.Require().NoError(err)
+	_, _, err = s.clusterConf.GetBundleTemplate(req)
+	if s.Error(err) {
+		s.Contains(err.Error(), "bundle template not found")
+	}
+}
+
+func (s *clusterConf) webTemplate() *clusterconf.BundleTemplate {
+	return &clusterconf.BundleTemplate{
+		ID: "web",
+		Parameters: []clusterconf.TemplateParameter{
+			{Name: "name", Required: true},
+			{Name: "redundancy", Default: "2"},
+		},
+		Bundle: `{"redundancy": {{.redundancy}}, "placement": {"nodeSelector": {"app": "{{.name}}"}}}`,
+	}
+}
+
+func (s *clusterConf) updateBundleTemplate(template *clusterconf.BundleTemplate) *clusterconf.BundleTemplate {
+	req, err := acomm.NewRequest(acomm.RequestOptions{
+		Task: "update-bundle-template",
+		Args: &clusterconf.BundleTemplatePayload{Template: template},
+	})
+	s.Require().NoError(err)
+	result, _, err := s.clusterConf.UpdateBundleTemplate(req)
+	s.Require().NoError(err)
+	return result.(*clusterconf.BundleTemplatePayload).Template
+}
+
+func (s *clusterConf) instantiateBundle(args *clusterconf.InstantiateBundleArgs) (*clusterconf.Bundle, error) {
+	req, err := acomm.NewRequest(acomm.RequestOptions{
+		Task: "instantiate-bundle",
+		Args: args,
+	})
+	s.Require().NoError(err)
+	result, streamURL, err := s.clusterConf.InstantiateBundle(req)
+	s.Nil(streamURL)
+	if err != nil {
+		return nil, err
+	}
+	return result.(*clusterconf.BundlePayload).Bundle, nil
+}
+
+func (s *clusterConf) listTemplateInstances(id string) []*clusterconf.TemplateInstance {
+	req, err := acomm.NewRequest(acomm.RequestOptions{
+		Task: "list-template-instances",
+		Args: &clusterconf.IDArgs{ID: id},
+	})
+	s.Require().NoError(err)
+	result, _, err := s.clusterConf.ListTemplateInstances(req)
+	s.Require().NoError(err)
+	return result.(*clusterconf.TemplateInstanceListResult).Instances
+}
@@ -0,0 +1,225 @@
+package clusterconf
+
+import (
+	"encoding/json"
+	"io"
+	"net/url"
+	"strings"
+
+	"github.com/cerana/cerana/acomm"
+	"github.com/cerana/cerana/pkg/errors"
+	"github.com/cerana/cerana/pkg/kv"
+	"github.com/cerana/cerana/pkg/logrusx"
+	kvp "github.com/cerana/cerana/providers/kv"
+	"golang.org/x/net/context"
+)
+
+// WatchArgs are args for watching changes to config objects.
+type WatchArgs struct {
+	// Index is the modification index after which changes are reported.
+	// With an Index of 0 all existing objects are reported as created
+	// before any further changes.
+	Index uint64 `json:"index"`
+}
+
+// WatchEvent is a change to a config object. The object matching the watch
+// is set for create and update events; only the ID is known for deletes.
+type WatchEvent struct {
+	Type     kv.EventType `json:"type"`
+	ID       string       `json:"id"`
+	ModIndex uint64       `json:"modIndex"`
+	Bundle   *Bundle      `json:"bundle,omitempty"`
+	Dataset  *Dataset     `json:"dataset,omitempty"`
+	Service  *Service     `json:"service,omitempty"`
+	Node     *Node        `json:"node,omitempty"`
+	// Error is set when the underlying watch fails, after which no more
+	// events will be sent.
+	Error string `json:"error,omitempty"`
+}
+
+// watchObject sets the object for an event from its stored value.
+type watchObject func(event *WatchEvent, data []byte) error
+
+// watchEventReader converts a stream of kv events into WatchEvents.
+type watchEventReader struct {
+	prefix string
+	// suffix is the last key component holding the object, if any
+	suffix string
+	object watchObject
+}
+
+// kvWatchEvent mirrors kvp.Event, with the error left undecoded.
+type kvWatchEvent struct {
+	kv.Event
+	Error json.RawMessage
+}
+
+// WatchBundles streams changes to bundles.
+func (c *ClusterConf) WatchBundles(req *acomm.Request) (interface{}, *url.URL, error) {
+	return c.watch(req, watchEventReader{
+		prefix: bundlesPrefix,
+		suffix: "config",
+		object: func(event *WatchEvent, data []byte) error {
+			event.Bundle = &Bundle{c: c}
+			if err := json.Unmarshal(data, event.Bundle); err != nil {
+				return err
+			}
+			event.Bundle.ModIndex = event.ModIndex
+			return nil
+		},
+	})
+}
+
+// WatchDatasets streams changes to datasets.
+func (c *ClusterConf) WatchDatasets(req *acomm.Request) (interface{}, *url.URL, error) {
+	return c.watch(req, watchEventReader{
+		prefix: datasetsPrefix,
+		suffix: "config",
+		object: func(event *WatchEvent, data []byte) error {
+			event.Dataset = &Dataset{c: c}
+			if err := json.Unmarshal(data, event.Dataset); err != nil {
+				return err
+			}
+			event.Dataset.ModIndex = event.ModIndex
+			return nil
+		},
+	})
+}
+
+// WatchServices streams changes to services.
+func (c *ClusterConf) WatchServices(req *acomm.Request) (interface{}, *url.URL, error) {
+	return c.watch(req, watchEventReader{
+		prefix: servicesPrefix,
+		suffix: "config",
+		object: func(event *WatchEvent, data []byte) error {
+			event.Service = &Service{c: c}
+			if err := json.Unmarshal(data, event.Service); err != nil {
+				return err
+			}
+			event.Service.ModIndex = event.ModIndex
+			return nil
+		},
+	})
+}
+
+// WatchNodes streams changes to nodes. Nodes are removed when they stop
+// sending heartbeats.
+func (c *ClusterConf) WatchNodes(req *acomm.Request) (interface{}, *url.URL, error) {
+	return c.watch(req, watchEventReader{
+		prefix: nodesPrefix,
+		object: func(event *WatchEvent, data []byte) error {
+			event.Node = &Node{c: c}
+			return json.Unmarshal(data, event.Node)
+		},
+	})
+}
+
+// watch starts a kv watch on the prefix and returns a stream of WatchEvents
+// for the objects under it. The returned cookie stops the watch with kv-stop.
+func (c *ClusterConf) watch(req *acomm.Request, w watchEventReader) (interface{}, *url.URL, error) {
+	var args WatchArgs
+	if err := req.UnmarshalArgs(&args); err != nil {
+		return nil, nil, err
+	}
+
+	watchArgs := kvp.WatchArgs{Prefix: w.prefix + "/", Index: args.Index}
+	cookie, kvStreamURL, err := c.kv().Watch(context.Background(), watchArgs)
+	if err != nil {
+		return nil, nil, errors.Wrapv(err, map[string]interface{}{"args": watchArgs})
+	}
+	if kvStreamURL == nil {
+		return nil, nil, errors.Newv("kv watch missing stream", map[string]interface{}{"args": watchArgs})
+	}
+
+	// The watch is no longer needed once the event stream ends, including
+	// when it has already been stopped with the cookie
+	stop := func() { _ = c.kv().Stop(context.Background(), cookie) }
+
+	streamURL, err := c.tracker.NewStreamUnix(c.config.StreamDir(req.Task), w.read(kvStreamURL, stop))
+	if err != nil {
+		stop()
+		return nil, nil, err
+	}
+	return cookie, streamURL, nil
+}
+
+// read consumes the kv watch stream, writing the converted events to the
+// returned reader. Closing the reader ends the conversion and stops the watch.
+func (w watchEventReader) read(kvStreamURL *url.URL, stop func()) io.ReadCloser {
+	kvReader, kvWriter := io.Pipe()
+	go func() {
+		_ = kvWriter.CloseWithError(acomm.Stream(kvWriter, kvStreamURL))
+	}()
+
+	r, pw := io.Pipe()
+	go func() {
+		defer stop()
+		defer logrusx.LogReturnedErr(kvReader.Close, nil, "")
+
+		dec := json.NewDecoder(kvReader)
+		enc := json.NewEncoder(pw)
+		for {
+			var kvEvent kvWatchEvent
+			if err := dec.Decode(&kvEvent); err != nil {
+				if err != io.EOF {
+					_ = enc.Encode(&WatchEvent{Error: err.Error()})
+				}
+				_ = pw.CloseWithError(err)
+				return
+			}
+
+			event, err := w.convert(kvEvent)
+			if err != nil {
+				// No more events are sent after an error
+				_ = enc.Encode(&WatchEvent{Error: err.Error()})
+				_ = pw.CloseWithError(err)
+				return
+			}
+			if event == nil {
+				continue
+			}
+			if err := enc.Encode(event); err != nil {
+				_ = pw.CloseWithError(err)
+				return
+			}
+		}
+	}()
+
+	return r
+}
+
+// convert creates a WatchEvent from a kv event, returning nil for keys that
+// don't hold an object.
+func (w watchEventReader) convert(kvEvent kvWatchEvent) (*WatchEvent, error) {
+	if len(kvEvent.Error) > 0 && string(kvEvent.Error) != "null" {
+		var kvErr struct {
+			Cause string `json:"cause"`
+		}
+		_ = json.Unmarshal(kvEvent.Error, &kvErr)
+		return nil, errors.Wrap(errors.New(kvErr.Cause), "kv watch failed")
+	}
+
+	// key: {prefix}/{id}[/{suffix}]
+	parts := strings.Split(strings.TrimPrefix(kvEvent.Key, w.prefix+"/"), "/")
+	switch {
+	case parts[0] == "":
+		return nil, nil
+	case w.suffix == "" && len(parts) != 1:
+		return nil, nil
+	case w.suffix != "" && (len(parts) != 2 || parts[1] != w.suffix):
+		return nil, nil
+	}
+
+	event := &WatchEvent{
+		Type:     kvEvent.Type,
+		ID:       parts[0],
+		ModIndex: kvEvent.Index,
+	}
+	if event.Type == kv.Delete {
+		return event, nil
+	}
+	if err := w.object(event, kvEvent.Data); err != nil {
+		return nil, errors.Wrapv(err, map[string]interface{}{"key": kvEvent.Key, "json": string(kvEvent.Data)})
+	}
+	return event, nil
+}
@@ -0,0 +1,109 @@
+package clusterconf_test
+
+import (
+	"encoding/json"
+	"net"
+	"path"
+	"strconv"
+
+	"github.com/cerana/cerana/acomm"
+	kvpkg "github.com/cerana/cerana/pkg/kv"
+	"github.com/cerana/cerana/providers/clusterconf"
+	"github.com/cerana/cerana/providers/kv"
+)
+
+func (s *clusterConf) TestWatchBundles() {
+	bundle, err := s.addBundle()
+	s.Require().NoError(err)
+	// Keys other than the config are not reported
+	_, err = s.addBundleAssignment("node1")
+	s.Require().NoError(err)
+
+	req, err := acomm.NewRequest(acomm.RequestOptions{
+		Task: "watch-bundles",
+		Args: &clusterconf.WatchArgs{},
+	})
+	s.Require().NoError(err)
+	result, streamURL, err := s.clusterConf.WatchBundles(req)
+	s.Require().NoError(err)
+	s.Require().NotNil(streamURL)
+	cookie, ok := result.(kv.Cookie)
+	s.Require().True(ok)
+	defer s.stopWatch(cookie)
+
+	conn, err := net.Dial("unix", streamURL.RequestURI())
+	s.Require().NoError(err)
+	defer func() { _ = conn.Close() }()
+	dec := json.NewDecoder(conn)
+
+	events := make(map[string]clusterconf.WatchEvent)
+	for i := 0; i < 2; i++ {
+		var event clusterconf.WatchEvent
+		s.Require().NoError(dec.Decode(&event))
+		s.Empty(event.Error)
+		events[event.ID] = event
+	}
+	event, ok := events[strconv.FormatUint(bundle.ID, 10)]
+	s.Require().True(ok)
+	s.Equal(kvpkg.Create, event.Type)
+	s.Equal(bundle.ModIndex, event.ModIndex)
+	if s.NotNil(event.Bundle) {
+		s.Equal(bundle.ID, event.Bundle.ID)
+		s.Equal(bundle.ModIndex, event.Bundle.ModIndex)
+	}
+
+	s.Require().NoError(s.deleteKey(path.Join("bundles", event.ID)))
+	var deleted clusterconf.WatchEvent
+	s.Require().NoError(dec.Decode(&deleted))
+	s.Equal(kvpkg.Delete, deleted.Type)
+	s.Equal(event.ID, deleted.ID)
+	s.Nil(deleted.Bundle)
+}
+
+func (s *clusterConf) TestWatchDatasets() {
+	dataset, err := s.addDataset()
+	s.Require().NoError(err)
+
+	req, err := acomm.NewRequest(acomm.RequestOptions{
+		Task: "watch-datasets",
+		Args: &clusterconf.WatchArgs{},
+	})
+	s.Require().NoError(err)
+	result, streamURL, err := s.clusterConf.WatchDatasets(req)
+	s.Require().NoError(err)
+	s.Require().NotNil(streamURL)
+	defer s.stopWatch(result.(kv.Cookie))
+
+	conn, err := net.Dial("unix", streamURL.RequestURI())
+	s.Require().NoError(err)
+	defer func() { _ = conn.Close() }()
+
+	var event clusterconf.WatchEvent
+	s.Require().NoError(json.NewDecoder(conn).Decode(&event))
+	s.Equal(kvpkg.Create, event.Type)
+	s.Equal(dataset.ID, event.ID)
+	if s.NotNil(event.Dataset) {
+		s.Equal(dataset.Quota, event.Dataset.Quota)
+	}
+}
+
+// stopWatch stops a watch that may have already been stopped by its stream
+// being closed.
+func (s *clusterConf) stopWatch(cookie kv.Cookie) {
+	_, err := s.tracker.SyncRequest(s.config.CoordinatorURL(), acomm.RequestOptions{
+		Task: "kv-stop",
+		Args: cookie,
+	}, 0)
+	s.NoError(err)
+}
+
+func (s *clusterConf) deleteKey(key string) error {
+	resp, err := s.tracker.SyncRequest(s.config.CoordinatorURL(), acomm.RequestOptions{
+		Task: "kv-delete",
+		Args: kv.DeleteArgs{Key: key, Recursive: true},
+	}, 0)
+	if err != nil {
+		return err
+	}
+	return resp.Error
+}
@@ -19,6 +19,8 @@ func New(config *Config, tracker *acomm.Tracker) *Provider {
 	}
 }
 
+//go:generate clientgen
+
 // RegisterTasks registers all of the provider task handlers with the server.
 func (p *Provider) RegisterTasks(server *provider.Server) {
 	server.RegisterTask("import-dataset", p.DatasetImport)
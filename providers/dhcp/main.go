@@ -5,6 +5,7 @@ import (
 	"net"
 	"net/url"
 	"sort"
+	"strings"
 	"time"
 
 	"github.com/cerana/cerana/acomm"
@@ -12,6 +13,7 @@ import (
 	"github.com/cerana/cerana/provider"
 	"github.com/cerana/cerana/providers/kv"
 	"github.com/krolaw/dhcp4"
+	"golang.org/x/net/context"
 )
 
 const prefix string = "dhcp-leases/"
@@ -20,10 +22,9 @@ var ttlOffer = 1 * time.Minute
 
 // DHCP is a provider of dhcp functionality.
 type DHCP struct {
-	coordinator *url.URL
-	tracker     *acomm.Tracker
-	config      *Config
-	randIP      func() net.IP
+	kv     *kv.Client
+	config *Config
+	randIP func() net.IP
 }
 
 // Addresses specifies the argument to all endpoints
@@ -46,9 +47,8 @@ func New(config *Config, tracker *acomm.Tracker) (*DHCP, error) {
 	ones, bits := network.Mask.Size()
 	size := 1<<uint(bits-ones) - 2
 	return &DHCP{
-		coordinator: config.CoordinatorURL(),
-		tracker:     tracker,
-		config:      config,
+		kv:     kv.NewClient(tracker, config.CoordinatorURL()),
+		config: config,
 		randIP: func() net.IP {
 			num := rand.Intn(size) + 1
 			return dhcp4.IPAdd(network.IP, num)
@@ -56,102 +56,49 @@ func New(config *Config, tracker *acomm.Tracker) (*DHCP, error) {
 	}, nil
 }
 
+//go:generate clientgen
+
 // RegisterTasks registers all of DHCP's task handlers with the server.
 func (d *DHCP) RegisterTasks(server *provider.Server) {
 	server.RegisterTask("dhcp-offer-lease", d.get)
 	server.RegisterTask("dhcp-ack-lease", d.ack)
 }
 
-func lookupMAC(tracker *acomm.Tracker, coord *url.URL, ip string) (string, error) {
-	ch := make(chan *acomm.Response, 1)
-	handler := func(_ *acomm.Request, resp *acomm.Response) {
-		ch <- resp
-	}
-	req, err := acomm.NewRequest(acomm.RequestOptions{
-		Task:           "kv-get",
-		ResponseHook:   tracker.URL(),
-		SuccessHandler: handler,
-		ErrorHandler:   handler,
-		Args:           kv.GetArgs{Key: prefix + ip},
-	})
+func lookupMAC(client *kv.Client, ip string) (string, error) {
+	value, err := client.Get(context.Background(), kv.GetArgs{Key: prefix + ip})
 	if err != nil {
+		if strings.Contains(err.Error(), "key not found") {
+			// there is no lease for the ip
+			return "", nil
+		}
 		return "", err
 	}
 
-	if err = tracker.TrackRequest(req, 0); err != nil {
-		return "", err
-	}
-
-	if err = acomm.Send(coord, req); err != nil {
-		return "", err
-	}
-
-	resp := <-ch
-	if resp.Error != nil {
-		return "", nil
-	}
-
-	kvp := kv.Value{}
-	if err = resp.UnmarshalResult(&kvp); err != nil {
-		return "", err
-	}
-
-	return string(kvp.Data), nil
+	return string(value.Data), nil
 }
 
-func doESet(tracker *acomm.Tracker, coord *url.URL, mac, ip string, ttl time.Duration) (chan *acomm.Response, error) {
-	ch := make(chan *acomm.Response, 1)
-	handler := func(_ *acomm.Request, resp *acomm.Response) {
-		ch <- resp
-	}
-	req, err := acomm.NewRequest(acomm.RequestOptions{
-		Task:           "kv-ephemeral-set",
-		ResponseHook:   tracker.URL(),
-		SuccessHandler: handler,
-		ErrorHandler:   handler,
-		Args: kv.EphemeralSetArgs{
-			Key:   prefix + ip,
-			Value: mac,
-			TTL:   ttl,
-		},
+func doESet(client *kv.Client, mac, ip string, ttl time.Duration) error {
+	return client.EphemeralSet(context.Background(), kv.EphemeralSetArgs{
+		Key:   prefix + ip,
+		Value: mac,
+		TTL:   ttl,
 	})
-	if err != nil {
-		return nil, err
-	}
-
-	if err = tracker.TrackRequest(req, 0); err != nil {
-		return nil, err
-	}
-
-	if err = acomm.Send(coord, req); err != nil {
-		return nil, err
-	}
-
-	return ch, nil
 }
 
-func refreshLeasePending(tracker *acomm.Tracker, coord *url.URL, mac, ip string) (bool, error) {
-	ch, err := doESet(tracker, coord, mac, ip, ttlOffer)
-	if err != nil {
+func refreshLeasePending(client *kv.Client, mac, ip string) (bool, error) {
+	if err := doESet(client, mac, ip, ttlOffer); err != nil {
+		if strings.Contains(err.Error(), "lock held by another client") {
+			// the ip is leased to someone else, caller will try a different ip address
+			return false, nil
+		}
 		return false, err
 	}
 
-	// we don't care about kv returning an error per se, this just means that caller will try a different ip address
-	if resp := <-ch; resp.Error != nil {
-		return false, nil
-	}
-
 	return true, nil
 }
 
-func refreshLeaseAck(tracker *acomm.Tracker, coord *url.URL, mac, ip string, ttl time.Duration) error {
-	ch, err := doESet(tracker, coord, mac, ip, ttl)
-	if err != nil {
-		return err
-	}
-
-	resp := <-ch
-	return errors.ResetStack(resp.Error)
+func refreshLeaseAck(client *kv.Client, mac, ip string, ttl time.Duration) error {
+	return doESet(client, mac, ip, ttl)
 }
 
 func nextGetter(closer <-chan struct{}, taken []uint32, min, max uint32) <-chan uint32 {
@@ -195,40 +142,12 @@ func findHole(slice []uint32) uint32 {
 	return hole
 }
 
-func getAllAllocations(tracker *acomm.Tracker, coord *url.URL) (map[string]string, error) {
-	ch := make(chan *acomm.Response, 1)
-	handler := func(_ *acomm.Request, resp *acomm.Response) {
-		ch <- resp
-	}
-	req, err := acomm.NewRequest(acomm.RequestOptions{
-		Task:           "kv-getAll",
-		ResponseHook:   tracker.URL(),
-		SuccessHandler: handler,
-		ErrorHandler:   handler,
-		Args:           kv.GetArgs{Key: prefix},
-	})
+func getAllAllocations(client *kv.Client) (map[string]string, error) {
+	kvs, err := client.GetAll(context.Background(), kv.GetArgs{Key: prefix})
 	if err != nil {
 		return nil, err
 	}
 
-	if err = tracker.TrackRequest(req, 0); err != nil {
-		return nil, err
-	}
-
-	if err = acomm.Send(coord, req); err != nil {
-		return nil, err
-	}
-
-	resp := <-ch
-	if err = resp.Error; err != nil {
-		return nil, errors.Wrap(err)
-	}
-
-	kvs := map[string]kv.Value{}
-	if err = resp.UnmarshalResult(&kvs); err != nil {
-		return nil, err
-	}
-
 	allocations := make(map[string]string, len(kvs))
 	for k, v := range kvs {
 		allocations[k[len(prefix):]] = string(v.Data)
@@ -257,12 +176,12 @@ func (d *DHCP) get(req *acomm.Request) (interface{}, *url.URL, error) {
 
 	// shortcut client renewing ip
 	if addrs.IP != "" {
-		mac, err := lookupMAC(d.tracker, d.coordinator, addrs.IP)
+		mac, err := lookupMAC(d.kv, addrs.IP)
 		if err != nil {
 			return nil, nil, err
 		}
 		if mac == addrs.MAC {
-			ok, err := refreshLeasePending(d.tracker, d.coordinator, addrs.MAC, addrs.IP)
+			ok, err := refreshLeasePending(d.kv, addrs.MAC, addrs.IP)
 			if err != nil {
 				return nil, nil, err
 			}
@@ -279,7 +198,7 @@ func (d *DHCP) get(req *acomm.Request) (interface{}, *url.URL, error) {
 	// we could probably start building up a cache of mac:ip that we can verify in the kv
 	// but if we really wanted to be clever/efficient then we would add transactions to pkg/kv and have /dhcp/ips and /dhcp/macs
 
-	leases, err := getAllAllocations(d.tracker, d.coordinator)
+	leases, err := getAllAllocations(d.kv)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -293,7 +212,7 @@ func (d *DHCP) get(req *acomm.Request) (interface{}, *url.URL, error) {
 	}
 
 	if addrs.IP != "" {
-		ok, err := refreshLeasePending(d.tracker, d.coordinator, addrs.MAC, addrs.IP)
+		ok, err := refreshLeasePending(d.kv, addrs.MAC, addrs.IP)
 		if err != nil {
 			return nil, nil, err
 		}
@@ -313,7 +232,7 @@ func (d *DHCP) get(req *acomm.Request) (interface{}, *url.URL, error) {
 		}
 
 		addrs.IP = ip.String()
-		ok, err := refreshLeasePending(d.tracker, d.coordinator, addrs.MAC, addrs.IP)
+		ok, err := refreshLeasePending(d.kv, addrs.MAC, addrs.IP)
 		if err != nil {
 			return nil, nil, err
 		}
@@ -331,7 +250,7 @@ func (d *DHCP) get(req *acomm.Request) (interface{}, *url.URL, error) {
 
 	if len(ips) == 0 || ips[0] > ipToU32(d.config.Network().IP)+1 {
 		ip := dhcp4.IPAdd(d.config.Network().IP, 1)
-		_, err := refreshLeasePending(d.tracker, d.coordinator, addrs.MAC, ip.String())
+		_, err := refreshLeasePending(d.kv, addrs.MAC, ip.String())
 		if err != nil {
 			return nil, nil, err
 		}
@@ -347,7 +266,7 @@ func (d *DHCP) get(req *acomm.Request) (interface{}, *url.URL, error) {
 		ip := u32ToIP(uIP)
 
 		addrs.IP = ip.String()
-		ok, err := refreshLeasePending(d.tracker, d.coordinator, addrs.MAC, addrs.IP)
+		ok, err := refreshLeasePending(d.kv, addrs.MAC, addrs.IP)
 		if err != nil {
 			return nil, nil, err
 		}
@@ -377,7 +296,7 @@ func (d *DHCP) ack(req *acomm.Request) (interface{}, *url.URL, error) {
 		return nil, nil, errors.Newv("invalid ip", map[string]interface{}{"ip": addrs.IP})
 	}
 
-	mac, err := lookupMAC(d.tracker, d.coordinator, addrs.IP)
+	mac, err := lookupMAC(d.kv, addrs.IP)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -385,7 +304,7 @@ func (d *DHCP) ack(req *acomm.Request) (interface{}, *url.URL, error) {
 		return nil, nil, errors.Newv("requested ip not assigned to this mac", map[string]interface{}{"ip": addrs.IP, "assignedMAC": mac, "mac": addrs.MAC})
 	}
 
-	err = refreshLeaseAck(d.tracker, d.coordinator, addrs.MAC, addrs.IP, d.config.LeaseDuration())
+	err = refreshLeaseAck(d.kv, addrs.MAC, addrs.IP, d.config.LeaseDuration())
 	if err != nil {
 		return nil, nil, err
 	}
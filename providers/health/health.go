@@ -19,6 +19,8 @@ func New(config *provider.Config, tracker *acomm.Tracker) *Health {
 	}
 }
 
+//go:generate clientgen
+
 // RegisterTasks registers all of Health's task handlers with the server.
 func (h *Health) RegisterTasks(server *provider.Server) {
 	server.RegisterTask("health-uptime", h.Uptime)
@@ -7,6 +7,7 @@ import (
 	"github.com/cerana/cerana/acomm"
 	"github.com/cerana/cerana/pkg/errors"
 	"github.com/cerana/cerana/providers/systemd"
+	"golang.org/x/net/context"
 )
 
 // UptimeArgs are arguments for the uptime health check.
@@ -38,36 +39,13 @@ func (h *Health) Uptime(req *acomm.Request) (interface{}, *url.URL, error) {
 }
 
 func (h *Health) getUnitStatus(name string) (*systemd.UnitStatus, error) {
-	doneChan := make(chan *acomm.Response, 1)
-	rh := func(_ *acomm.Request, resp *acomm.Response) {
-		doneChan <- resp
-	}
-	req, err := acomm.NewRequest(acomm.RequestOptions{
-		Task:           "systemd-get",
-		ResponseHook:   h.tracker.URL(),
-		Args:           &systemd.GetArgs{Name: name},
-		SuccessHandler: rh,
-		ErrorHandler:   rh,
-	})
+	ctx, cancel := context.WithTimeout(context.Background(), h.config.RequestTimeout())
+	defer cancel()
 
+	client := systemd.NewClient(h.tracker, h.config.CoordinatorURL())
+	result, err := client.Get(ctx, systemd.GetArgs{Name: name})
 	if err != nil {
 		return nil, err
 	}
-	if err := h.tracker.TrackRequest(req, h.config.RequestTimeout()); err != nil {
-		return nil, err
-	}
-	if err := acomm.Send(h.config.CoordinatorURL(), req); err != nil {
-		return nil, err
-	}
-
-	resp := <-doneChan
-	if resp.Error != nil {
-		return nil, errors.ResetStack(resp.Error)
-	}
-
-	var result systemd.GetResult
-	if err := resp.UnmarshalResult(&result); err != nil {
-		return nil, err
-	}
 	return &result.Unit, nil
 }
@@ -0,0 +1,172 @@
+// Code generated by clientgen. DO NOT EDIT.
+
+package kv
+
+import (
+	"net/url"
+
+	"github.com/cerana/cerana/acomm"
+	"golang.org/x/net/context"
+)
+
+// Client makes requests for the kv provider's tasks through a
+// coordinator, handling request tracking and result decoding.
+type Client struct {
+	tracker     *acomm.Tracker
+	coordinator *url.URL
+}
+
+// NewClient creates a new Client. Requests are sent to the coordinator and
+// tracked by the tracker, which must already be started.
+func NewClient(tracker *acomm.Tracker, coordinator *url.URL) *Client {
+	return &Client{
+		tracker:     tracker,
+		coordinator: coordinator,
+	}
+}
+
+// Delete makes a `kv-delete` request.
+func (c *Client) Delete(ctx context.Context, args DeleteArgs) error {
+	opts := acomm.RequestOptions{
+		Task: "kv-delete",
+		Args: args,
+	}
+	_, err := c.tracker.Call(ctx, c.coordinator, opts, nil)
+	return err
+}
+
+// EphemeralDestroy makes a `kv-ephemeral-destroy` request.
+func (c *Client) EphemeralDestroy(ctx context.Context, args EphemeralDestroyArgs) error {
+	opts := acomm.RequestOptions{
+		Task: "kv-ephemeral-destroy",
+		Args: args,
+	}
+	_, err := c.tracker.Call(ctx, c.coordinator, opts, nil)
+	return err
+}
+
+// EphemeralSet makes a `kv-ephemeral-set` request.
+func (c *Client) EphemeralSet(ctx context.Context, args EphemeralSetArgs) error {
+	opts := acomm.RequestOptions{
+		Task: "kv-ephemeral-set",
+		Args: args,
+	}
+	_, err := c.tracker.Call(ctx, c.coordinator, opts, nil)
+	return err
+}
+
+// Get makes a `kv-get` request.
+func (c *Client) Get(ctx context.Context, args GetArgs) (Value, error) {
+	opts := acomm.RequestOptions{
+		Task: "kv-get",
+		Args: args,
+	}
+	var result Value
+	_, err := c.tracker.Call(ctx, c.coordinator, opts, &result)
+	return result, err
+}
+
+// GetAll makes a `kv-getAll` request.
+func (c *Client) GetAll(ctx context.Context, args GetArgs) (map[string]Value, error) {
+	opts := acomm.RequestOptions{
+		Task: "kv-getAll",
+		Args: args,
+	}
+	var result map[string]Value
+	_, err := c.tracker.Call(ctx, c.coordinator, opts, &result)
+	return result, err
+}
+
+// Keys makes a `kv-keys` request.
+func (c *Client) Keys(ctx context.Context, args GetArgs) ([]string, error) {
+	opts := acomm.RequestOptions{
+		Task: "kv-keys",
+		Args: args,
+	}
+	var result []string
+	_, err := c.tracker.Call(ctx, c.coordinator, opts, &result)
+	return result, err
+}
+
+// Lock makes a `kv-lock` request.
+func (c *Client) Lock(ctx context.Context, args LockArgs) (Cookie, error) {
+	opts := acomm.RequestOptions{
+		Task: "kv-lock",
+		Args: args,
+	}
+	var result Cookie
+	_, err := c.tracker.Call(ctx, c.coordinator, opts, &result)
+	return result, err
+}
+
+// Remove makes a `kv-remove` request.
+func (c *Client) Remove(ctx context.Context, args RemoveArgs) error {
+	opts := acomm.RequestOptions{
+		Task: "kv-remove",
+		Args: args,
+	}
+	_, err := c.tracker.Call(ctx, c.coordinator, opts, nil)
+	return err
+}
+
+// Renew makes a `kv-renew` request.
+func (c *Client) Renew(ctx context.Context, args Cookie) error {
+	opts := acomm.RequestOptions{
+		Task: "kv-renew",
+		Args: args,
+	}
+	_, err := c.tracker.Call(ctx, c.coordinator, opts, nil)
+	return err
+}
+
+// Set makes a `kv-set` request.
+func (c *Client) Set(ctx context.Context, args SetArgs) error {
+	opts := acomm.RequestOptions{
+		Task: "kv-set",
+		Args: args,
+	}
+	_, err := c.tracker.Call(ctx, c.coordinator, opts, nil)
+	return err
+}
+
+// Stop makes a `kv-stop` request.
+func (c *Client) Stop(ctx context.Context, args Cookie) error {
+	opts := acomm.RequestOptions{
+		Task: "kv-stop",
+		Args: args,
+	}
+	_, err := c.tracker.Call(ctx, c.coordinator, opts, nil)
+	return err
+}
+
+// Unlock makes a `kv-unlock` request.
+func (c *Client) Unlock(ctx context.Context, args Cookie) error {
+	opts := acomm.RequestOptions{
+		Task: "kv-unlock",
+		Args: args,
+	}
+	_, err := c.tracker.Call(ctx, c.coordinator, opts, nil)
+	return err
+}
+
+// Update makes a `kv-update` request.
+func (c *Client) Update(ctx context.Context, args UpdateArgs) (UpdateReturn, error) {
+	opts := acomm.RequestOptions{
+		Task: "kv-update",
+		Args: args,
+	}
+	var result UpdateReturn
+	_, err := c.tracker.Call(ctx, c.coordinator, opts, &result)
+	return result, err
+}
+
+// Watch makes a `kv-watch` request.
+func (c *Client) Watch(ctx context.Context, args WatchArgs) (Cookie, *url.URL, error) {
+	opts := acomm.RequestOptions{
+		Task: "kv-watch",
+		Args: args,
+	}
+	var result Cookie
+	respStreamURL, err := c.tracker.Call(ctx, c.coordinator, opts, &result)
+	return result, respStreamURL, err
+}
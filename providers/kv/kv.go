@@ -65,13 +65,15 @@ func (k *KV) kvDown() bool {
 	return k.kv == nil
 }
 
+//go:generate clientgen
+
 // RegisterTasks registers all of KV's task handlers with the server.
 func (k *KV) RegisterTasks(server *provider.Server) {
 	// simple.go
 	server.RegisterTask("kv-delete", k.delete)
-	server.RegisterTask("kv-get", k.get)
-	server.RegisterTask("kv-getAll", k.getAll)
-	server.RegisterTask("kv-keys", k.keys)
+	server.RegisterTask("kv-get", k.get)       // clientgen:result Value
+	server.RegisterTask("kv-getAll", k.getAll) // clientgen:result map[string]Value
+	server.RegisterTask("kv-keys", k.keys)     // clientgen:result []string
 	server.RegisterTask("kv-set", k.set)
 
 	// cas.go
@@ -19,6 +19,8 @@ func New(config *provider.Config, tracker *acomm.Tracker) *Namespace {
 	}
 }
 
+//go:generate clientgen
+
 // RegisterTasks registers all of Namespaces's task handlers with the server.
 func (n *Namespace) RegisterTasks(server *provider.Server) {
 	server.RegisterTask("namespace-set-user", n.SetUser)
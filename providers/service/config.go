@@ -17,6 +17,9 @@ type ConfigData struct {
 	provider.ConfigData
 	RollbackCloneCmd string `json:"rollback_clone_cmd"`
 	DatasetCloneDir  string `json:"dataset_clone_dir"`
+	// SecretEnvDir is the directory the environment files holding services'
+	// secret env vars are written to. Only root can access the files.
+	SecretEnvDir string `json:"secret_env_dir"`
 }
 
 // RollbackCloneCmd returns the full path of the clone/rollback script datasets
@@ -36,6 +39,15 @@ func (c *Config) DatasetCloneDir() string {
 	return dcp
 }
 
+// SecretEnvDir returns the directory for the environment files holding the
+// secret env vars of services.
+func (c *Config) SecretEnvDir() string {
+	var dir string
+	_ = c.UnmarshalKey("secret_env_dir", &dir)
+	// Checked at validation time
+	return dir
+}
+
 // LoadConfig loads and validates the config data.
 func (c *Config) LoadConfig() error {
 	if err := c.Config.LoadConfig(); err != nil {
@@ -64,5 +76,9 @@ func (c *Config) Validate() error {
 		return errors.New("missing dataset_clone_dir")
 	}
 
+	if c.SecretEnvDir() == "" {
+		return errors.New("missing secret_env_dir")
+	}
+
 	return nil
 }
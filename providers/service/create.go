@@ -1,9 +1,13 @@
 package service
 
 import (
+	"bytes"
 	"fmt"
+	"io/ioutil"
 	"net/url"
+	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 
 	"github.com/cerana/cerana/acomm"
@@ -20,7 +24,10 @@ type CreateArgs struct {
 	Description string            `json:"description"`
 	Cmd         []string          `json:"cmd"`
 	Env         map[string]string `json:"env"`
-	Overwrite   bool              `json:"overwrite"`
+	// Secrets are env vars with secret values. They are written to an
+	// environment file only root can read instead of the unit.
+	Secrets   map[string]string `json:"secrets"`
+	Overwrite bool              `json:"overwrite"`
 }
 
 // Create creates (or replaces) and starts (or restarts) a service.
@@ -77,13 +84,38 @@ func (p *Provider) Create(req *acomm.Request) (interface{}, *url.URL, error) {
 			Value:   fmt.Sprintf("%s=%s", key, val),
 		})
 	}
+	if len(args.Secrets) > 0 {
+		unitOptions = append(unitOptions, &unit.UnitOption{
+			Section: "Service",
+			Name:    "EnvironmentFile",
+			Value:   secretEnvFile(p.config.SecretEnvDir(), name),
+		})
+	}
 
 	requests, continueChecks, err := p.prepareCreateRequests(name, unitOptions, args.Overwrite)
 	if err != nil {
 		return nil, nil, err
 	}
 
+	// The env file is only written once the unit has been created, so an
+	// existing service that isn't overwritten keeps its secrets
+	var restoreEnvFile func() error
+	createCheck := continueChecks[0]
+	continueChecks[0] = func(resp *acomm.Response) (bool, error) {
+		restore, err := p.writeSecretEnvFile(name, args.Secrets)
+		if err != nil {
+			return false, err
+		}
+		restoreEnvFile = restore
+		return createCheck(resp)
+	}
+
 	if err = p.executeRequests(requests, continueChecks); err != nil {
+		if restoreEnvFile != nil {
+			if restoreErr := restoreEnvFile(); restoreErr != nil {
+				err = errors.Wrapv(err, map[string]interface{}{"restoreError": restoreErr.Error()})
+			}
+		}
 		return nil, nil, err
 	}
 
@@ -94,6 +126,72 @@ func (p *Provider) Create(req *acomm.Request) (interface{}, *url.URL, error) {
 	return GetResult{*service}, nil, nil
 }
 
+// writeSecretEnvFile writes the secret env vars of a service to an environment
+// file readable only by root. Any existing file is removed if there are no
+// secrets. The returned func restores the previous file, or removes the new one
+// if there wasn't one.
+func (p *Provider) writeSecretEnvFile(name string, secrets map[string]string) (func() error, error) {
+	envFile := secretEnvFile(p.config.SecretEnvDir(), name)
+	previous, err := ioutil.ReadFile(envFile)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, errors.Wrapv(err, map[string]interface{}{"path": envFile})
+	}
+	existed := err == nil
+	restore := func() error {
+		if existed {
+			return p.saveEnvFile(envFile, previous)
+		}
+		return removeEnvFile(envFile)
+	}
+
+	if len(secrets) == 0 {
+		return restore, removeEnvFile(envFile)
+	}
+
+	keys := make([]string, 0, len(secrets))
+	for key := range secrets {
+		// do not allow custom overrides of the internal cerana env variables
+		if strings.HasPrefix(key, "_CERANA_") {
+			continue
+		}
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var buf bytes.Buffer
+	for _, key := range keys {
+		_, _ = fmt.Fprintf(&buf, "%s=\"%s\"\n", key, envFileEscaper.Replace(secrets[key]))
+	}
+	return restore, p.saveEnvFile(envFile, buf.Bytes())
+}
+
+// saveEnvFile writes an environment file readable only by root.
+func (p *Provider) saveEnvFile(envFile string, data []byte) error {
+	if err := os.MkdirAll(p.config.SecretEnvDir(), 0700); err != nil {
+		return errors.Wrapv(err, map[string]interface{}{"path": p.config.SecretEnvDir()})
+	}
+	// Write and rename so the service never sees a partial file
+	tmpFile := envFile + ".tmp"
+	if err := ioutil.WriteFile(tmpFile, data, 0600); err != nil {
+		return errors.Wrapv(err, map[string]interface{}{"path": tmpFile})
+	}
+	return errors.Wrapv(os.Rename(tmpFile, envFile), map[string]interface{}{"path": envFile})
+}
+
+func removeEnvFile(envFile string) error {
+	if err := os.Remove(envFile); err != nil && !os.IsNotExist(err) {
+		return errors.Wrapv(err, map[string]interface{}{"path": envFile})
+	}
+	return nil
+}
+
+// envFileEscaper escapes values for double quoting in an environment file.
+var envFileEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "`", "\\`", "$", `\$`)
+
+func secretEnvFile(dir, name string) string {
+	return filepath.Join(dir, name+".env")
+}
+
 func (p *Provider) prepareCreateRequests(name string, unitOptions []*unit.UnitOption, overwrite bool) ([]*acomm.Request, []continueCheck, error) {
 	requests := make([]*acomm.Request, 0, 3)
 	continueChecks := make([]continueCheck, 0, 3)
@@ -2,6 +2,9 @@ package service_test
 
 import (
 	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"strings"
 
 	"github.com/cerana/cerana/acomm"
@@ -76,3 +79,59 @@ func (s *Provider) TestCreate() {
 		}
 	}
 }
+
+func (s *Provider) TestCreateSecrets() {
+	args := &service.CreateArgs{
+		ID:       uuid.New(),
+		BundleID: 219,
+		Dataset:  uuid.New(),
+		Cmd:      []string{"foo", "bar"},
+		Env:      map[string]string{"foo": "bar"},
+		Secrets:  map[string]string{"PASSWORD": `p"a$s`, "_CERANA_foo": "bar"},
+	}
+	envFile := filepath.Join(s.config.SecretEnvDir(), fmt.Sprintf("%d:%s.service.env", args.BundleID, args.ID))
+
+	req, err := acomm.NewRequest(acomm.RequestOptions{
+		Task: "service-create",
+		Args: args,
+	})
+	s.Require().NoError(err)
+	result, _, err := s.provider.Create(req)
+	s.Require().NoError(err)
+	env := result.(service.GetResult).Service.Env
+	s.Equal("bar", env["foo"])
+	s.NotContains(env, "PASSWORD")
+
+	info, err := os.Stat(envFile)
+	s.Require().NoError(err)
+	s.Equal(os.FileMode(0600), info.Mode().Perm())
+	data, err := ioutil.ReadFile(envFile)
+	s.Require().NoError(err)
+	s.Equal("PASSWORD=\"p\\\"a\\$s\"\n", string(data))
+
+	// An existing service that isn't overwritten keeps its secrets
+	args.Secrets = map[string]string{"PASSWORD": "changed"}
+	req, err = acomm.NewRequest(acomm.RequestOptions{
+		Task: "service-create",
+		Args: args,
+	})
+	s.Require().NoError(err)
+	_, _, err = s.provider.Create(req)
+	s.Error(err)
+	unchanged, err := ioutil.ReadFile(envFile)
+	s.Require().NoError(err)
+	s.Equal(data, unchanged)
+
+	// Without secrets the file is removed
+	args.Secrets = nil
+	args.Overwrite = true
+	req, err = acomm.NewRequest(acomm.RequestOptions{
+		Task: "service-create",
+		Args: args,
+	})
+	s.Require().NoError(err)
+	_, _, err = s.provider.Create(req)
+	s.Require().NoError(err)
+	_, err = os.Stat(envFile)
+	s.True(os.IsNotExist(err))
+}
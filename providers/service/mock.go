@@ -3,6 +3,7 @@ package service
 import (
 	"math/rand"
 	"net/url"
+	"strings"
 	"time"
 
 	"github.com/cerana/cerana/acomm"
@@ -57,6 +58,15 @@ func (m *Mock) Create(req *acomm.Request) (interface{}, *url.URL, error) {
 		return nil, nil, errors.New("missing arg: dataset")
 	}
 
+	// Like the provider, internal env vars can't be overridden and the
+	// dataset is recorded in one
+	env := map[string]string{"_CERANA_CLONE_SOURCE": args.Dataset}
+	for key, val := range args.Env {
+		if !strings.HasPrefix(key, "_CERANA_") {
+			env[key] = val
+		}
+	}
+
 	if _, ok := m.Data.Services[args.BundleID]; !ok {
 		m.Data.Services[args.BundleID] = make(map[string]Service)
 	}
@@ -69,7 +79,7 @@ func (m *Mock) Create(req *acomm.Request) (interface{}, *url.URL, error) {
 		Cmd:         args.Cmd,
 		UID:         uint64(rand.Int63n(60000)),
 		GID:         uint64(rand.Int63n(60000)),
-		Env:         args.Env,
+		Env:         env,
 	}
 	return GetResult{m.Data.Services[args.BundleID][args.ID]}, nil, nil
 }
@@ -2,6 +2,7 @@ package service
 
 import (
 	"net/url"
+	"os"
 
 	"github.com/cerana/cerana/acomm"
 	"github.com/cerana/cerana/pkg/errors"
@@ -33,7 +34,15 @@ func (p *Provider) Remove(req *acomm.Request) (interface{}, *url.URL, error) {
 		return nil, nil, err
 	}
 
-	return nil, nil, p.executeRequests(requests, nil)
+	if err := p.executeRequests(requests, nil); err != nil {
+		return nil, nil, err
+	}
+
+	envFile := secretEnvFile(p.config.SecretEnvDir(), name)
+	if err := os.Remove(envFile); err != nil && !os.IsNotExist(err) {
+		return nil, nil, errors.Wrapv(err, map[string]interface{}{"path": envFile})
+	}
+	return nil, nil, nil
 }
 
 func (p *Provider) prepareRemoveRequests(name string) ([]*acomm.Request, error) {
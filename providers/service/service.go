@@ -22,6 +22,8 @@ func New(config *Config, tracker *acomm.Tracker) *Provider {
 	}
 }
 
+//go:generate clientgen
+
 // RegisterTasks registers all of the provider task handlers with the server.
 func (p *Provider) RegisterTasks(server *provider.Server) {
 	server.RegisterTask("service-create", p.Create)
@@ -43,6 +43,7 @@ func (s *Provider) SetupSuite() {
 	flagset := pflag.NewFlagSet("service", pflag.PanicOnError)
 	v.Set("rollback_clone_cmd", "foo/bar")
 	v.Set("dataset_clone_dir", "tmp")
+	v.Set("secret_env_dir", filepath.Join(s.coordinator.SocketDir, "secrets"))
 	config := service.NewConfig(flagset, v)
 	s.Require().NoError(flagset.Parse([]string{}))
 	s.Require().NoError(config.LoadConfig())
@@ -0,0 +1,118 @@
+// Code generated by clientgen. DO NOT EDIT.
+
+package systemd
+
+import (
+	"net/url"
+
+	"github.com/cerana/cerana/acomm"
+	"golang.org/x/net/context"
+)
+
+// Client makes requests for the systemd provider's tasks through a
+// coordinator, handling request tracking and result decoding.
+type Client struct {
+	tracker     *acomm.Tracker
+	coordinator *url.URL
+}
+
+// NewClient creates a new Client. Requests are sent to the coordinator and
+// tracked by the tracker, which must already be started.
+func NewClient(tracker *acomm.Tracker, coordinator *url.URL) *Client {
+	return &Client{
+		tracker:     tracker,
+		coordinator: coordinator,
+	}
+}
+
+// Create makes a `systemd-create` request.
+func (c *Client) Create(ctx context.Context, args CreateArgs) (CreateResult, error) {
+	opts := acomm.RequestOptions{
+		Task: "systemd-create",
+		Args: args,
+	}
+	var result CreateResult
+	_, err := c.tracker.Call(ctx, c.coordinator, opts, &result)
+	return result, err
+}
+
+// Disable makes a `systemd-disable` request.
+func (c *Client) Disable(ctx context.Context, args DisableArgs) error {
+	opts := acomm.RequestOptions{
+		Task: "systemd-disable",
+		Args: args,
+	}
+	_, err := c.tracker.Call(ctx, c.coordinator, opts, nil)
+	return err
+}
+
+// Enable makes a `systemd-enable` request.
+func (c *Client) Enable(ctx context.Context, args EnableArgs) error {
+	opts := acomm.RequestOptions{
+		Task: "systemd-enable",
+		Args: args,
+	}
+	_, err := c.tracker.Call(ctx, c.coordinator, opts, nil)
+	return err
+}
+
+// Get makes a `systemd-get` request.
+func (c *Client) Get(ctx context.Context, args GetArgs) (*GetResult, error) {
+	opts := acomm.RequestOptions{
+		Task: "systemd-get",
+		Args: args,
+	}
+	var result *GetResult
+	_, err := c.tracker.Call(ctx, c.coordinator, opts, &result)
+	return result, err
+}
+
+// List makes a `systemd-list` request.
+func (c *Client) List(ctx context.Context) (*ListResult, error) {
+	opts := acomm.RequestOptions{
+		Task: "systemd-list",
+	}
+	var result *ListResult
+	_, err := c.tracker.Call(ctx, c.coordinator, opts, &result)
+	return result, err
+}
+
+// Remove makes a `systemd-remove` request.
+func (c *Client) Remove(ctx context.Context, args RemoveArgs) error {
+	opts := acomm.RequestOptions{
+		Task: "systemd-remove",
+		Args: args,
+	}
+	_, err := c.tracker.Call(ctx, c.coordinator, opts, nil)
+	return err
+}
+
+// Restart makes a `systemd-restart` request.
+func (c *Client) Restart(ctx context.Context, args ActionArgs) error {
+	opts := acomm.RequestOptions{
+		Task: "systemd-restart",
+		Args: args,
+	}
+	_, err := c.tracker.Call(ctx, c.coordinator, opts, nil)
+	return err
+}
+
+// Start makes a `systemd-start` request.
+func (c *Client) Start(ctx context.Context, args ActionArgs) error {
+	opts := acomm.RequestOptions{
+		Task: "systemd-start",
+		Args: args,
+	}
+	_, err := c.tracker.Call(ctx, c.coordinator, opts, nil)
+	return err
+}
+
+// Stop makes a `systemd-stop` request.
+func (c *Client) Stop(ctx context.Context, args ActionArgs) error {
+	opts := acomm.RequestOptions{
+		Task: "systemd-stop",
+		Args: args,
+	}
+	_, err := c.tracker.Call(ctx, c.coordinator, opts, nil)
+	return err
+}
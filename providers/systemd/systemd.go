@@ -33,6 +33,8 @@ func New(config *Config) (*Systemd, error) {
 	}, nil
 }
 
+//go:generate clientgen
+
 // RegisterTasks registers all of Systemd's task handlers with the server.
 func (s *Systemd) RegisterTasks(server *provider.Server) {
 	server.RegisterTask("systemd-create", s.Create)
@@ -0,0 +1,172 @@
+// Code generated by clientgen. DO NOT EDIT.
+
+package zfs
+
+import (
+	"net/url"
+
+	"github.com/cerana/cerana/acomm"
+	"golang.org/x/net/context"
+)
+
+// Client makes requests for the zfs provider's tasks through a
+// coordinator, handling request tracking and result decoding.
+type Client struct {
+	tracker     *acomm.Tracker
+	coordinator *url.URL
+}
+
+// NewClient creates a new Client. Requests are sent to the coordinator and
+// tracked by the tracker, which must already be started.
+func NewClient(tracker *acomm.Tracker, coordinator *url.URL) *Client {
+	return &Client{
+		tracker:     tracker,
+		coordinator: coordinator,
+	}
+}
+
+// Clone makes a `zfs-clone` request.
+func (c *Client) Clone(ctx context.Context, args CloneArgs) (*DatasetResult, error) {
+	opts := acomm.RequestOptions{
+		Task: "zfs-clone",
+		Args: args,
+	}
+	var result *DatasetResult
+	_, err := c.tracker.Call(ctx, c.coordinator, opts, &result)
+	return result, err
+}
+
+// Create makes a `zfs-create` request.
+func (c *Client) Create(ctx context.Context, args CreateArgs) (*DatasetResult, error) {
+	opts := acomm.RequestOptions{
+		Task: "zfs-create",
+		Args: args,
+	}
+	var result *DatasetResult
+	_, err := c.tracker.Call(ctx, c.coordinator, opts, &result)
+	return result, err
+}
+
+// Destroy makes a `zfs-destroy` request.
+func (c *Client) Destroy(ctx context.Context, args DestroyArgs) error {
+	opts := acomm.RequestOptions{
+		Task: "zfs-destroy",
+		Args: args,
+	}
+	_, err := c.tracker.Call(ctx, c.coordinator, opts, nil)
+	return err
+}
+
+// Exists makes a `zfs-exists` request.
+func (c *Client) Exists(ctx context.Context, args CommonArgs) (*ExistsResult, error) {
+	opts := acomm.RequestOptions{
+		Task: "zfs-exists",
+		Args: args,
+	}
+	var result *ExistsResult
+	_, err := c.tracker.Call(ctx, c.coordinator, opts, &result)
+	return result, err
+}
+
+// Get makes a `zfs-get` request.
+func (c *Client) Get(ctx context.Context, args CommonArgs) (*DatasetResult, error) {
+	opts := acomm.RequestOptions{
+		Task: "zfs-get",
+		Args: args,
+	}
+	var result *DatasetResult
+	_, err := c.tracker.Call(ctx, c.coordinator, opts, &result)
+	return result, err
+}
+
+// Holds makes a `zfs-holds` request.
+func (c *Client) Holds(ctx context.Context, args CommonArgs) (*HoldsResult, error) {
+	opts := acomm.RequestOptions{
+		Task: "zfs-holds",
+		Args: args,
+	}
+	var result *HoldsResult
+	_, err := c.tracker.Call(ctx, c.coordinator, opts, &result)
+	return result, err
+}
+
+// List makes a `zfs-list` request.
+func (c *Client) List(ctx context.Context, args ListArgs) (*ListResult, error) {
+	opts := acomm.RequestOptions{
+		Task: "zfs-list",
+		Args: args,
+	}
+	var result *ListResult
+	_, err := c.tracker.Call(ctx, c.coordinator, opts, &result)
+	return result, err
+}
+
+// Mount makes a `zfs-mount` request.
+func (c *Client) Mount(ctx context.Context, args MountArgs) error {
+	opts := acomm.RequestOptions{
+		Task: "zfs-mount",
+		Args: args,
+	}
+	_, err := c.tracker.Call(ctx, c.coordinator, opts, nil)
+	return err
+}
+
+// Receive makes a `zfs-receive` request.
+func (c *Client) Receive(ctx context.Context, args CommonArgs, streamURL *url.URL) error {
+	opts := acomm.RequestOptions{
+		Task:      "zfs-receive",
+		Args:      args,
+		StreamURL: streamURL,
+	}
+	_, err := c.tracker.Call(ctx, c.coordinator, opts, nil)
+	return err
+}
+
+// Rename makes a `zfs-rename` request.
+func (c *Client) Rename(ctx context.Context, args RenameArgs) error {
+	opts := acomm.RequestOptions{
+		Task: "zfs-rename",
+		Args: args,
+	}
+	_, err := c.tracker.Call(ctx, c.coordinator, opts, nil)
+	return err
+}
+
+// Rollback makes a `zfs-rollback` request.
+func (c *Client) Rollback(ctx context.Context, args RollbackArgs) error {
+	opts := acomm.RequestOptions{
+		Task: "zfs-rollback",
+		Args: args,
+	}
+	_, err := c.tracker.Call(ctx, c.coordinator, opts, nil)
+	return err
+}
+
+// Send makes a `zfs-send` request.
+func (c *Client) Send(ctx context.Context, args SendArgs) (*url.URL, error) {
+	opts := acomm.RequestOptions{
+		Task: "zfs-send",
+		Args: args,
+	}
+	return c.tracker.Call(ctx, c.coordinator, opts, nil)
+}
+
+// Snapshot makes a `zfs-snapshot` request.
+func (c *Client) Snapshot(ctx context.Context, args SnapshotArgs) error {
+	opts := acomm.RequestOptions{
+		Task: "zfs-snapshot",
+		Args: args,
+	}
+	_, err := c.tracker.Call(ctx, c.coordinator, opts, nil)
+	return err
+}
+
+// Unmount makes a `zfs-unmount` request.
+func (c *Client) Unmount(ctx context.Context, args UnmountArgs) error {
+	opts := acomm.RequestOptions{
+		Task: "zfs-unmount",
+		Args: args,
+	}
+	_, err := c.tracker.Call(ctx, c.coordinator, opts, nil)
+	return err
+}
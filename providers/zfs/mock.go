@@ -301,7 +301,7 @@ func (z *MockZFS) Rollback(req *acomm.Request) (interface{}, *url.URL, error) {
 
 // Send sends mock dataset data.
 func (z *MockZFS) Send(req *acomm.Request) (interface{}, *url.URL, error) {
-	var args CommonArgs
+	var args SendArgs
 	if err := req.UnmarshalArgs(&args); err != nil {
 		return nil, nil, err
 	}
@@ -11,9 +11,17 @@ import (
 	"github.com/cerana/cerana/zfs"
 )
 
-// Send returns information about a dataset.
+// SendArgs are arguments for the Send handler.
+type SendArgs struct {
+	Name string `json:"name"`
+	// FromSnap is the full name of an earlier snapshot to send an
+	// incremental stream from. A full stream is sent if it is empty.
+	FromSnap string `json:"fromSnap"`
+}
+
+// Send returns a stream of a snapshot.
 func (z *ZFS) Send(req *acomm.Request) (interface{}, *url.URL, error) {
-	var args CommonArgs
+	var args SendArgs
 	if err := req.UnmarshalArgs(&args); err != nil {
 		return nil, nil, err
 	}
@@ -41,7 +49,7 @@ func (z *ZFS) Send(req *acomm.Request) (interface{}, *url.URL, error) {
 		defer func() {
 			logrusx.LogReturnedErr(writer.Close, nil, "failed to close snapshot stream writer")
 		}()
-		if sendErr := ds.Send(writer); sendErr != nil {
+		if sendErr := ds.SendIncremental(writer, args.FromSnap); sendErr != nil {
 			logrus.WithField("error", sendErr).Error("failed to send snapshot")
 		}
 	}()
@@ -33,6 +33,8 @@ type DatasetResult struct {
 	Dataset *Dataset `json:"dataset"`
 }
 
+//go:generate clientgen
+
 // RegisterTasks registers all of ZFS's task handlers with the server.
 func (z *ZFS) RegisterTasks(server *provider.Server) {
 	server.RegisterTask("zfs-clone", z.Clone)
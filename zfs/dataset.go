@@ -525,12 +525,19 @@ func newFdCloser(output io.Writer) (fdCloser, error) {
 
 // Send sends a stream of a snapshot to the writer.
 func (d *Dataset) Send(output io.Writer) error {
+	return d.SendIncremental(output, "")
+}
+
+// SendIncremental sends a stream of the changes between an earlier snapshot,
+// identified by its full name, and the snapshot to the writer. A full stream
+// is sent if fromSnap is empty.
+func (d *Dataset) SendIncremental(output io.Writer, fromSnap string) error {
 	fdc, err := newFdCloser(output)
 	if err != nil {
 		return err
 	}
 
-	if err := send(d.Name, fdc.Fd(), "", false, false); err != nil {
+	if err := send(d.Name, fdc.Fd(), fromSnap, false, false); err != nil {
 		return err
 	}
 	return errors.Wrap(fdc.Close())